@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"monkey/token"
+	"strconv"
 	"strings"
 )
 
@@ -11,6 +12,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // Pos returns the position of the first character of the node.
+	End() token.Position // End returns the position immediately after the last character of the node.
 }
 
 // Statement represents a statement in the abstract syntax tree.
@@ -28,6 +31,7 @@ type Expression interface {
 // Program represents a program in the abstract syntax tree.
 type Program struct {
 	Statements []Statement
+	Comments   []token.Comment // Every comment in the source code, in the order they appear.
 }
 
 // TokenLiteral returns the token literal of the first statement in the program.
@@ -38,6 +42,22 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+// Pos returns the position of the first character of the program.
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+// End returns the position immediately after the last character of the program.
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 // String returns a string representation of the program statements
 func (p *Program) String() string {
 	var out bytes.Buffer
@@ -50,9 +70,11 @@ func (p *Program) String() string {
 
 // LetStatement represents a binding from a user defined identifier to an expression.
 type LetStatement struct {
-	Token token.Token // The original token.LET token.
-	Name  *Identifier // The identifier given by the user.
-	Value Expression  // The Expression that is represented by the Name.
+	Token   token.Token // The original token.LET token.
+	Name    *Identifier // The identifier given by the user, nil when the value is destructured.
+	Type    Type        // The annotated type of the binding, e.g. int in let x: int = 5, or nil.
+	Pattern Pattern     // The array or hash pattern the value is destructured with, e.g. let [a, b] = pair, or nil.
+	Value   Expression  // The Expression that is represented by the Name.
 }
 
 // statementNode is a placeholder function for the Statement interface.
@@ -63,12 +85,38 @@ func (l *LetStatement) TokenLiteral() string {
 	return l.Token.Literal
 }
 
+// Pos returns the position of the first character of the let statement.
+func (l *LetStatement) Pos() token.Position {
+	return l.Token.Pos
+}
+
+// End returns the position immediately after the last character of the let statement.
+func (l *LetStatement) End() token.Position {
+	if l.Value != nil {
+		return l.Value.End()
+	}
+	if l.Name != nil {
+		return l.Name.End()
+	}
+	if l.Pattern != nil {
+		return l.Pattern.End()
+	}
+	return l.Token.End
+}
+
 // String returns a string representation of the LetStatement
 func (l *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(l.TokenLiteral() + " ")
-	out.WriteString(l.Name.String())
+	if l.Pattern != nil {
+		out.WriteString(l.Pattern.String())
+	} else {
+		out.WriteString(l.Name.String())
+	}
+	if l.Type != nil {
+		out.WriteString(": " + l.Type.String())
+	}
 	out.WriteString(" = ")
 
 	if l.Value != nil {
@@ -80,8 +128,9 @@ func (l *LetStatement) String() string {
 
 // Identifier represents a user defined name that is mapped to an expression through a let binding.
 type Identifier struct {
-	Token token.Token // The original token.IDENT token.
-	Value string      // The string literal value with the identifier name.
+	Token   token.Token // The original token.IDENT token.
+	Value   string      // The string literal value with the identifier name.
+	Builtin bool        // Whether the name stands for the built-in function, whatever the program binds to it.
 }
 
 // statementNode is a placeholder function for the Statement interface.
@@ -92,6 +141,16 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+// Pos returns the position of the first character of the identifier.
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos
+}
+
+// End returns the position immediately after the last character of the identifier.
+func (i *Identifier) End() token.Position {
+	return i.Token.End
+}
+
 // String returns a string representation of the Identifier
 func (i *Identifier) String() string {
 	return i.Value
@@ -111,6 +170,19 @@ func (r *ReturnStatement) TokenLiteral() string {
 	return r.Token.Literal
 }
 
+// Pos returns the position of the first character of the return statement.
+func (r *ReturnStatement) Pos() token.Position {
+	return r.Token.Pos
+}
+
+// End returns the position immediately after the last character of the return statement.
+func (r *ReturnStatement) End() token.Position {
+	if r.ReturnValue != nil {
+		return r.ReturnValue.End()
+	}
+	return r.Token.End
+}
+
 // String returns a string representation of the ReturnStatement
 func (r *ReturnStatement) String() string {
 	var out bytes.Buffer
@@ -124,275 +196,1207 @@ func (r *ReturnStatement) String() string {
 	return out.String()
 }
 
-// ExpressionStatement is a simple wrapper around an expression to type coerce it into a Program.
-type ExpressionStatement struct {
-	Token      token.Token // The first token of the expression
-	Expression Expression  // The expression
+// ThrowStatement throws a value, unwinding to the nearest enclosing try expression.
+type ThrowStatement struct {
+	Token token.Token // The 'throw' token.
+	Value Expression  // The expression producing the thrown value.
 }
 
 // statementNode is a placeholder function for the Statement interface.
-func (e *ExpressionStatement) statementNode() {}
+func (t *ThrowStatement) statementNode() {}
 
-// TokenLiteral returns the literal value of the token of the expression statement.
-func (e *ExpressionStatement) TokenLiteral() string {
-	return e.Token.Literal
+// TokenLiteral returns the literal value of the token of the throw statement.
+func (t *ThrowStatement) TokenLiteral() string {
+	return t.Token.Literal
 }
 
-// String returns a string representation of the ExpressionStatement
-func (e *ExpressionStatement) String() string {
-	if e.Expression != nil {
-		return e.Expression.String()
-	}
-
-	return ""
+// Pos returns the position of the first character of the throw statement.
+func (t *ThrowStatement) Pos() token.Position {
+	return t.Token.Pos
 }
 
-// IntegerLiteral represents a node that is an integer value.
-type IntegerLiteral struct {
-	Token token.Token // The token for the integer literal.
-	Value int64       // The actual literal value.
+// End returns the position immediately after the last character of the throw statement.
+func (t *ThrowStatement) End() token.Position {
+	if t.Value != nil {
+		return t.Value.End()
+	}
+	return t.Token.End
 }
 
-// expressionNode is a placeholder function for the Expression interface.
-func (i *IntegerLiteral) expressionNode() {}
+// String returns a string representation of the ThrowStatement
+func (t *ThrowStatement) String() string {
+	var out bytes.Buffer
 
-// TokenLiteral returns the literal value of the token for the integer literal.
-func (i *IntegerLiteral) TokenLiteral() string {
-	return i.Token.Literal
+	out.WriteString(t.TokenLiteral() + " ")
+	if t.Value != nil {
+		out.WriteString(t.Value.String())
+	}
+
+	out.WriteString(";")
+	return out.String()
 }
 
-// String returns a string representation of the IntegerLiteral
-func (i *IntegerLiteral) String() string {
-	return i.Token.Literal
+// YieldStatement hands a value to the caller of a generator and suspends the generator
+// until the next value is asked for, e.g. yield i;
+type YieldStatement struct {
+	Token token.Token // The 'yield' token.
+	Value Expression  // The expression producing the yielded value.
 }
 
-// PrefixExpression represents an expression that has a prefix operator.
-type PrefixExpression struct {
-	Token    token.Token // The prefix token, e.g. ! or -
-	Operator string      // The string representation of the operator.
-	Right    Expression  // The expression to be evaluated.
+// statementNode is a placeholder function for the Statement interface.
+func (y *YieldStatement) statementNode() {}
+
+// TokenLiteral returns the literal value of the token of the yield statement.
+func (y *YieldStatement) TokenLiteral() string {
+	return y.Token.Literal
 }
 
-// expressionNode is a placeholder function for the Expression interface.
-func (p *PrefixExpression) expressionNode() {}
+// Pos returns the position of the first character of the yield statement.
+func (y *YieldStatement) Pos() token.Position {
+	return y.Token.Pos
+}
 
-// TokenLiteral returns the literal value of the token for the prefix expression.
-func (p *PrefixExpression) TokenLiteral() string {
-	return p.Token.Literal
+// End returns the position immediately after the last character of the yield statement.
+func (y *YieldStatement) End() token.Position {
+	if y.Value != nil {
+		return y.Value.End()
+	}
+	return y.Token.End
 }
 
-// String returns a string representation of the PrefixExpression
-func (p *PrefixExpression) String() string {
+// String returns a string representation of the YieldStatement
+func (y *YieldStatement) String() string {
 	var out bytes.Buffer
 
-	out.WriteString("(")
-	out.WriteString(p.Operator)
-	out.WriteString(p.Right.String())
-	out.WriteString(")")
+	out.WriteString(y.TokenLiteral() + " ")
+	if y.Value != nil {
+		out.WriteString(y.Value.String())
+	}
 
+	out.WriteString(";")
 	return out.String()
 }
 
-// InfixExpression represents an expression that is bound by an infix operator.
-type InfixExpression struct {
-	Token    token.Token // The operator token, e.g. +
-	Left     Expression  // The left hand side expression to be evaluated.
-	Operator string      // The operator literal value.
-	Right    Expression  // The right hand side expression to be evaluated.
+// ImportStatement loads a module and binds it to a name, e.g. import "lib/strings.mk" as str;
+type ImportStatement struct {
+	Token token.Token    // The 'import' token.
+	Path  *StringLiteral // The path of the module file.
+	Name  *Identifier    // The name the module is bound to.
 }
 
-// expressionNode is a placeholder function for the Expression interface.
-func (i *InfixExpression) expressionNode() {}
+// statementNode is a placeholder function for the Statement interface.
+func (i *ImportStatement) statementNode() {}
 
-// TokenLiteral returns the literal value of the token for the infix expression.
-func (i *InfixExpression) TokenLiteral() string {
+// TokenLiteral returns the literal value of the token of the import statement.
+func (i *ImportStatement) TokenLiteral() string {
 	return i.Token.Literal
 }
 
-// String returns a string representation of the InfixExpression
-func (i *InfixExpression) String() string {
+// Pos returns the position of the first character of the import statement.
+func (i *ImportStatement) Pos() token.Position {
+	return i.Token.Pos
+}
+
+// End returns the position immediately after the last character of the import statement.
+func (i *ImportStatement) End() token.Position {
+	if i.Name != nil {
+		return i.Name.End()
+	}
+	return i.Token.End
+}
+
+// String returns a string representation of the ImportStatement
+func (i *ImportStatement) String() string {
 	var out bytes.Buffer
 
-	out.WriteString("(")
-	out.WriteString(i.Left.String())
-	out.WriteString(" " + i.Operator + " ")
-	out.WriteString(i.Right.String())
-	out.WriteString(")")
+	out.WriteString(i.TokenLiteral() + " ")
+	out.WriteString(strconv.Quote(i.Path.Value))
+	out.WriteString(" as ")
+	out.WriteString(i.Name.String())
+	out.WriteString(";")
 
 	return out.String()
 }
 
-// Boolean represents a boolean literal.
-type Boolean struct {
-	Token token.Token // The token that represents the boolean literal.
-	Value bool        // The actual value of the boolean: true or false.
+// ExportStatement lists the top-level names of a module that importing code can use,
+// e.g. export upper, lower;
+type ExportStatement struct {
+	Token token.Token   // The 'export' token.
+	Names []*Identifier // The exported names.
 }
 
-// expressionNode is a placeholder function for the Expression interface.
-func (b *Boolean) expressionNode() {}
+// statementNode is a placeholder function for the Statement interface.
+func (e *ExportStatement) statementNode() {}
 
-// TokenLiteral returns the literal value of the token for the boolean expression.
-func (b *Boolean) TokenLiteral() string {
-	return b.Token.Literal
+// TokenLiteral returns the literal value of the token of the export statement.
+func (e *ExportStatement) TokenLiteral() string {
+	return e.Token.Literal
 }
 
-// String returns a string representation of the boolean expression
-func (b *Boolean) String() string {
-	return b.Token.Literal
+// Pos returns the position of the first character of the export statement.
+func (e *ExportStatement) Pos() token.Position {
+	return e.Token.Pos
 }
 
-// IfExpression represents a decision to make based on a conditional expression.
-// When true, Consequence is evaluated, when false Alternative is evaluated.
-type IfExpression struct {
-	Token       token.Token     // The 'if' Token
-	Condition   Expression      // The condition to evaluate
-	Consequence *BlockStatement // The result when the condition is true.
-	Alternative *BlockStatement // The result when the condition is false.
+// End returns the position immediately after the last character of the export statement.
+func (e *ExportStatement) End() token.Position {
+	if len(e.Names) > 0 {
+		return e.Names[len(e.Names)-1].End()
+	}
+	return e.Token.End
 }
 
-// expressionNode is a placeholder function for the Expression interface.
-func (i *IfExpression) expressionNode() {}
+// String returns a string representation of the ExportStatement
+func (e *ExportStatement) String() string {
+	names := []string{}
+	for _, name := range e.Names {
+		names = append(names, name.String())
+	}
 
-// TokenLiteral returns the literal value of the token for the infix expression.
-func (i *IfExpression) TokenLiteral() string {
-	return i.Token.Literal
+	return e.TokenLiteral() + " " + strings.Join(names, ", ") + ";"
 }
 
-// String returns a string representation of the if expression
-func (i *IfExpression) String() string {
-	var out bytes.Buffer
+// StructStatement declares a struct type with fixed fields, e.g. struct Point { x, y }
+// The name is bound to a constructor taking the values of the fields in order.
+type StructStatement struct {
+	Token  token.Token   // The 'struct' token.
+	Name   *Identifier   // The name of the struct type.
+	Fields []*Identifier // The names of the fields.
+	Rbrace token.Token   // The closing '}' token.
+}
 
-	out.WriteString("if")
-	out.WriteString(i.Condition.String())
-	out.WriteString(" ")
-	out.WriteString(i.Consequence.String())
+// statementNode is a placeholder function for the Statement interface.
+func (s *StructStatement) statementNode() {}
 
-	if i.Alternative != nil {
-		out.WriteString("else ")
-		out.WriteString(i.Alternative.String())
+// TokenLiteral returns the literal value of the token of the struct statement.
+func (s *StructStatement) TokenLiteral() string {
+	return s.Token.Literal
+}
+
+// Pos returns the position of the first character of the struct statement.
+func (s *StructStatement) Pos() token.Position {
+	return s.Token.Pos
+}
+
+// End returns the position immediately after the last character of the struct statement.
+func (s *StructStatement) End() token.Position {
+	if s.Rbrace.End.IsValid() {
+		return s.Rbrace.End
 	}
+	return s.Token.End
+}
 
-	return out.String()
+// String returns a string representation of the StructStatement
+func (s *StructStatement) String() string {
+	fields := []string{}
+	for _, field := range s.Fields {
+		fields = append(fields, field.String())
+	}
+
+	return s.TokenLiteral() + " " + s.Name.String() + " { " + strings.Join(fields, ", ") + " }"
 }
 
-// BlockStatement represents a scoped section of code that contains
-// additional statements.
-type BlockStatement struct {
-	Token      token.Token // The { token
-	Statements []Statement // A collection of scoped statements.
+// ImplStatement adds methods to a struct type, e.g. impl Point { fn dist(self) { ... } }
+// A method receives the value it is called on as its first parameter.
+type ImplStatement struct {
+	Token   token.Token // The 'impl' token.
+	Name    *Identifier // The name of the struct type.
+	Methods []*Method   // The methods, in the order they are defined.
+	Rbrace  token.Token // The closing '}' token.
+}
+
+// Method is a method defined in an impl statement.
+type Method struct {
+	Name     *Identifier      // The name of the method.
+	Function *FunctionLiteral // The function implementing the method.
 }
 
 // statementNode is a placeholder function for the Statement interface.
-func (b *BlockStatement) statementNode() {}
+func (i *ImplStatement) statementNode() {}
 
-// TokenLiteral returns the literal value of the token for the block statement.
-func (b *BlockStatement) TokenLiteral() string {
-	return b.Token.Literal
+// TokenLiteral returns the literal value of the token of the impl statement.
+func (i *ImplStatement) TokenLiteral() string {
+	return i.Token.Literal
 }
 
-// String returns a string representation of the block statement.
-func (b *BlockStatement) String() string {
+// Pos returns the position of the first character of the impl statement.
+func (i *ImplStatement) Pos() token.Position {
+	return i.Token.Pos
+}
+
+// End returns the position immediately after the last character of the impl statement.
+func (i *ImplStatement) End() token.Position {
+	if i.Rbrace.End.IsValid() {
+		return i.Rbrace.End
+	}
+	return i.Token.End
+}
+
+// String returns a string representation of the ImplStatement
+func (i *ImplStatement) String() string {
 	var out bytes.Buffer
 
-	for _, s := range b.Statements {
-		out.WriteString(s.String())
+	out.WriteString(i.TokenLiteral() + " " + i.Name.String() + " {")
+	for _, method := range i.Methods {
+		out.WriteString(" fn " + method.Name.String())
+		out.WriteString(strings.TrimPrefix(method.Function.String(), method.Function.TokenLiteral()))
 	}
+	out.WriteString(" }")
 
 	return out.String()
 }
 
-// FunctionLiteral represents a user defined function.
-type FunctionLiteral struct {
-	Token      token.Token     // The 'fn' token
-	Parameters []*Identifier   // The list of parameters which can be empty.
-	Body       *BlockStatement // The body of the function
-	Name       string          // The name of the function
+// EnumStatement declares an enum type whose values are one of a fixed set of variants,
+// e.g. enum Result { Ok(value), Err(reason) }. A variant without fields is a single value,
+// while a variant with fields is a constructor, e.g. Result.Ok(5).
+type EnumStatement struct {
+	Token    token.Token    // The 'enum' token.
+	Name     *Identifier    // The name of the enum type.
+	Variants []*EnumVariant // The variants, in the order they are declared.
+	Rbrace   token.Token    // The closing '}' token.
 }
 
-// expressionNode is a placeholder function for the Expression interface.
-func (f *FunctionLiteral) expressionNode() {}
+// EnumVariant is a variant declared in an enum statement.
+type EnumVariant struct {
+	Name   *Identifier   // The name of the variant.
+	Fields []*Identifier // The names of the fields of its payload.
+}
 
-// TokenLiteral returns the literal value of the token for the function literal.
-func (f *FunctionLiteral) TokenLiteral() string {
-	return f.Token.Literal
+// statementNode is a placeholder function for the Statement interface.
+func (e *EnumStatement) statementNode() {}
+
+// TokenLiteral returns the literal value of the token of the enum statement.
+func (e *EnumStatement) TokenLiteral() string {
+	return e.Token.Literal
 }
 
-// String returns a string representation of the function literal.
-func (f *FunctionLiteral) String() string {
-	var out bytes.Buffer
+// Pos returns the position of the first character of the enum statement.
+func (e *EnumStatement) Pos() token.Position {
+	return e.Token.Pos
+}
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+// End returns the position immediately after the last character of the enum statement.
+func (e *EnumStatement) End() token.Position {
+	if e.Rbrace.End.IsValid() {
+		return e.Rbrace.End
 	}
+	return e.Token.End
+}
 
-	out.WriteString(f.TokenLiteral())
-	if f.Name != "" {
-		out.WriteString(fmt.Sprintf("<%s>", f.Name))
+// String returns a string representation of the EnumStatement
+func (e *EnumStatement) String() string {
+	variants := []string{}
+	for _, variant := range e.Variants {
+		variants = append(variants, variant.String())
 	}
-	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(")")
-	out.WriteString(f.Body.String())
 
-	return out.String()
+	return e.TokenLiteral() + " " + e.Name.String() + " { " + strings.Join(variants, ", ") + " }"
 }
 
-// CallExpression represents a function and a set of arguments that can be called.
-type CallExpression struct {
-	Token     token.Token  // The '(' Token
-	Function  Expression   // The function to be called
-	Arguments []Expression // The arguments to be passed into the function.
+// String returns a string representation of the EnumVariant
+func (e *EnumVariant) String() string {
+	if len(e.Fields) == 0 {
+		return e.Name.String()
+	}
+
+	fields := []string{}
+	for _, field := range e.Fields {
+		fields = append(fields, field.String())
+	}
+
+	return e.Name.String() + "(" + strings.Join(fields, ", ") + ")"
 }
 
-// expressionNode is a placeholder function for the Expression interface.
-func (c *CallExpression) expressionNode() {}
+// WhileStatement represents a loop that runs its body for as long as a condition is truthy.
+type WhileStatement struct {
+	Token     token.Token     // The 'while' token.
+	Condition Expression      // The condition checked before each iteration.
+	Body      *BlockStatement // The statements to run on each iteration.
+}
 
-// TokenLiteral returns the literal value of the token for the call expression.
-func (c *CallExpression) TokenLiteral() string {
-	return c.Token.Literal
+// statementNode is a placeholder function for the Statement interface.
+func (w *WhileStatement) statementNode() {}
+
+// TokenLiteral returns the literal value of the token of the while statement.
+func (w *WhileStatement) TokenLiteral() string {
+	return w.Token.Literal
 }
 
-// String returns a string representation of the call expression.
-func (c *CallExpression) String() string {
-	var out bytes.Buffer
-	args := []string{}
+// Pos returns the position of the first character of the while statement.
+func (w *WhileStatement) Pos() token.Position {
+	return w.Token.Pos
+}
 
-	for _, a := range c.Arguments {
-		args = append(args, a.String())
+// End returns the position immediately after the last character of the while statement.
+func (w *WhileStatement) End() token.Position {
+	if w.Body != nil {
+		return w.Body.End()
 	}
+	return w.Token.End
+}
 
-	out.WriteString(c.Function.String())
-	out.WriteString("(")
-	out.WriteString(strings.Join(args, ", "))
-	out.WriteString(")")
+// String returns a string representation of the WhileStatement
+func (w *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while (")
+	out.WriteString(w.Condition.String())
+	out.WriteString(") ")
+	out.WriteString(w.Body.String())
 
 	return out.String()
 }
 
-// StringLiteral represents a string in the monkey language.
-type StringLiteral struct {
-	Token token.Token // The token.
-	Value string      // The literal string value.
+// ForStatement represents a C-style loop, e.g. for (let i = 0; i < n; i += 1) { ... }.
+// Each of the three clauses is optional.
+type ForStatement struct {
+	Token     token.Token     // The 'for' token.
+	Init      Statement       // The statement run once before the loop, or nil.
+	Condition Expression      // The condition checked before each iteration, or nil to loop forever.
+	Update    Expression      // The expression evaluated after each iteration, or nil.
+	Body      *BlockStatement // The statements to run on each iteration.
 }
 
-// expressionNode is a placeholder function for the Expression interface.
-func (s *StringLiteral) expressionNode() {}
+// statementNode is a placeholder function for the Statement interface.
+func (f *ForStatement) statementNode() {}
 
-// TokenLiteral returns the literal value of the token for the StringLiteral expression.
-func (s *StringLiteral) TokenLiteral() string {
-	return s.Token.Literal
+// TokenLiteral returns the literal value of the token of the for statement.
+func (f *ForStatement) TokenLiteral() string {
+	return f.Token.Literal
 }
 
-// String returns a string representation of the StringLiteral expression.
-func (s *StringLiteral) String() string {
-	return s.Token.Literal
+// Pos returns the position of the first character of the for statement.
+func (f *ForStatement) Pos() token.Position {
+	return f.Token.Pos
 }
 
-// ArrayLiteral represents an array of data.
-type ArrayLiteral struct {
-	Token    token.Token  // The '[' token.
-	Elements []Expression // A collection of expressions.
+// End returns the position immediately after the last character of the for statement.
+func (f *ForStatement) End() token.Position {
+	if f.Body != nil {
+		return f.Body.End()
+	}
+	return f.Token.End
+}
+
+// String returns a string representation of the ForStatement
+func (f *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	if f.Init != nil {
+		out.WriteString(strings.TrimSuffix(f.Init.String(), ";"))
+	}
+	out.WriteString("; ")
+	if f.Condition != nil {
+		out.WriteString(f.Condition.String())
+	}
+	out.WriteString("; ")
+	if f.Update != nil {
+		out.WriteString(f.Update.String())
+	}
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+
+	return out.String()
+}
+
+// ForInStatement represents a loop over the elements of an array, the keys of a hash or
+// the characters of a string, e.g. for (x in items) { ... }.
+type ForInStatement struct {
+	Token    token.Token     // The 'for' token.
+	Variable *Identifier     // The binding that holds the current element.
+	Iterable Expression      // The collection being iterated over.
+	Body     *BlockStatement // The statements to run on each iteration.
+}
+
+// statementNode is a placeholder function for the Statement interface.
+func (f *ForInStatement) statementNode() {}
+
+// TokenLiteral returns the literal value of the token of the for-in statement.
+func (f *ForInStatement) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+// Pos returns the position of the first character of the for-in statement.
+func (f *ForInStatement) Pos() token.Position {
+	return f.Token.Pos
+}
+
+// End returns the position immediately after the last character of the for-in statement.
+func (f *ForInStatement) End() token.Position {
+	if f.Body != nil {
+		return f.Body.End()
+	}
+	return f.Token.End
+}
+
+// String returns a string representation of the ForInStatement
+func (f *ForInStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	out.WriteString(f.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(f.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+
+	return out.String()
+}
+
+// BreakStatement represents a break out of the innermost loop.
+type BreakStatement struct {
+	Token token.Token // The 'break' token.
+}
+
+// statementNode is a placeholder function for the Statement interface.
+func (b *BreakStatement) statementNode() {}
+
+// TokenLiteral returns the literal value of the token of the break statement.
+func (b *BreakStatement) TokenLiteral() string {
+	return b.Token.Literal
+}
+
+// Pos returns the position of the first character of the break statement.
+func (b *BreakStatement) Pos() token.Position {
+	return b.Token.Pos
+}
+
+// End returns the position immediately after the last character of the break statement.
+func (b *BreakStatement) End() token.Position {
+	return b.Token.End
+}
+
+// String returns a string representation of the BreakStatement
+func (b *BreakStatement) String() string {
+	return b.TokenLiteral() + ";"
+}
+
+// ContinueStatement represents a jump to the next iteration of the innermost loop.
+type ContinueStatement struct {
+	Token token.Token // The 'continue' token.
+}
+
+// statementNode is a placeholder function for the Statement interface.
+func (c *ContinueStatement) statementNode() {}
+
+// TokenLiteral returns the literal value of the token of the continue statement.
+func (c *ContinueStatement) TokenLiteral() string {
+	return c.Token.Literal
+}
+
+// Pos returns the position of the first character of the continue statement.
+func (c *ContinueStatement) Pos() token.Position {
+	return c.Token.Pos
+}
+
+// End returns the position immediately after the last character of the continue statement.
+func (c *ContinueStatement) End() token.Position {
+	return c.Token.End
+}
+
+// String returns a string representation of the ContinueStatement
+func (c *ContinueStatement) String() string {
+	return c.TokenLiteral() + ";"
+}
+
+// ExpressionStatement is a simple wrapper around an expression to type coerce it into a Program.
+type ExpressionStatement struct {
+	Token      token.Token // The first token of the expression
+	Expression Expression  // The expression
+}
+
+// statementNode is a placeholder function for the Statement interface.
+func (e *ExpressionStatement) statementNode() {}
+
+// TokenLiteral returns the literal value of the token of the expression statement.
+func (e *ExpressionStatement) TokenLiteral() string {
+	return e.Token.Literal
+}
+
+// Pos returns the position of the first character of the expression statement.
+func (e *ExpressionStatement) Pos() token.Position {
+	if e.Expression != nil {
+		return e.Expression.Pos()
+	}
+	return e.Token.Pos
+}
+
+// End returns the position immediately after the last character of the expression statement.
+func (e *ExpressionStatement) End() token.Position {
+	if e.Expression != nil {
+		return e.Expression.End()
+	}
+	return e.Token.End
+}
+
+// String returns a string representation of the ExpressionStatement
+func (e *ExpressionStatement) String() string {
+	if e.Expression != nil {
+		return e.Expression.String()
+	}
+
+	return ""
+}
+
+// IntegerLiteral represents a node that is an integer value.
+type IntegerLiteral struct {
+	Token token.Token // The token for the integer literal.
+	Value int64       // The actual literal value.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (i *IntegerLiteral) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token for the integer literal.
+func (i *IntegerLiteral) TokenLiteral() string {
+	return i.Token.Literal
+}
+
+// Pos returns the position of the first character of the integer literal.
+func (i *IntegerLiteral) Pos() token.Position {
+	return i.Token.Pos
+}
+
+// End returns the position immediately after the last character of the integer literal.
+func (i *IntegerLiteral) End() token.Position {
+	return i.Token.End
+}
+
+// String returns a string representation of the IntegerLiteral
+func (i *IntegerLiteral) String() string {
+	return i.Token.Literal
+}
+
+// FloatLiteral represents a node that is a floating point value.
+type FloatLiteral struct {
+	Token token.Token // The token for the float literal.
+	Value float64     // The actual literal value.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (f *FloatLiteral) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token for the float literal.
+func (f *FloatLiteral) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+// Pos returns the position of the first character of the float literal.
+func (f *FloatLiteral) Pos() token.Position {
+	return f.Token.Pos
+}
+
+// End returns the position immediately after the last character of the float literal.
+func (f *FloatLiteral) End() token.Position {
+	return f.Token.End
+}
+
+// String returns a string representation of the FloatLiteral
+func (f *FloatLiteral) String() string {
+	return f.Token.Literal
+}
+
+// PrefixExpression represents an expression that has a prefix operator.
+type PrefixExpression struct {
+	Token    token.Token // The prefix token, e.g. ! or -
+	Operator string      // The string representation of the operator.
+	Right    Expression  // The expression to be evaluated.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (p *PrefixExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token for the prefix expression.
+func (p *PrefixExpression) TokenLiteral() string {
+	return p.Token.Literal
+}
+
+// Pos returns the position of the first character of the prefix expression.
+func (p *PrefixExpression) Pos() token.Position {
+	return p.Token.Pos
+}
+
+// End returns the position immediately after the last character of the prefix expression.
+func (p *PrefixExpression) End() token.Position {
+	if p.Right != nil {
+		return p.Right.End()
+	}
+	return p.Token.End
+}
+
+// String returns a string representation of the PrefixExpression
+func (p *PrefixExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(p.Operator)
+	out.WriteString(p.Right.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// InfixExpression represents an expression that is bound by an infix operator.
+type InfixExpression struct {
+	Token    token.Token // The operator token, e.g. +
+	Left     Expression  // The left hand side expression to be evaluated.
+	Operator string      // The operator literal value.
+	Right    Expression  // The right hand side expression to be evaluated.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (i *InfixExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token for the infix expression.
+func (i *InfixExpression) TokenLiteral() string {
+	return i.Token.Literal
+}
+
+// Pos returns the position of the first character of the infix expression.
+func (i *InfixExpression) Pos() token.Position {
+	if i.Left != nil {
+		return i.Left.Pos()
+	}
+	return i.Token.Pos
+}
+
+// End returns the position immediately after the last character of the infix expression.
+func (i *InfixExpression) End() token.Position {
+	if i.Right != nil {
+		return i.Right.End()
+	}
+	return i.Token.End
+}
+
+// String returns a string representation of the InfixExpression
+func (i *InfixExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(i.Left.String())
+	out.WriteString(" " + i.Operator + " ")
+	out.WriteString(i.Right.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// Boolean represents a boolean literal.
+type Boolean struct {
+	Token token.Token // The token that represents the boolean literal.
+	Value bool        // The actual value of the boolean: true or false.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (b *Boolean) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token for the boolean expression.
+func (b *Boolean) TokenLiteral() string {
+	return b.Token.Literal
+}
+
+// Pos returns the position of the first character of the boolean literal.
+func (b *Boolean) Pos() token.Position {
+	return b.Token.Pos
+}
+
+// End returns the position immediately after the last character of the boolean literal.
+func (b *Boolean) End() token.Position {
+	return b.Token.End
+}
+
+// String returns a string representation of the boolean expression
+func (b *Boolean) String() string {
+	return b.Token.Literal
+}
+
+// AssignExpression represents an assignment to an existing binding or to an element of an
+// array or hash, e.g. x = 5, x += 1 or items[0] = 5.
+type AssignExpression struct {
+	Token    token.Token // The assignment operator token, e.g. = or +=
+	Target   Expression  // The binding or index expression being assigned to.
+	Operator string      // The assignment operator, e.g. = or +=
+	Value    Expression  // The expression that produces the new value.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (ae *AssignExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token for the assign expression.
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+
+// Pos returns the position of the first character of the assign expression.
+func (ae *AssignExpression) Pos() token.Position {
+	if ae.Target != nil {
+		return ae.Target.Pos()
+	}
+	return ae.Token.Pos
+}
+
+// End returns the position immediately after the last character of the assign expression.
+func (ae *AssignExpression) End() token.Position {
+	if ae.Value != nil {
+		return ae.Value.End()
+	}
+	return ae.Token.End
+}
+
+// String returns a string representation of the AssignExpression.
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// IfExpression represents a decision to make based on a conditional expression.
+// When true, Consequence is evaluated, when false Alternative is evaluated.
+type IfExpression struct {
+	Token       token.Token     // The 'if' Token
+	Condition   Expression      // The condition to evaluate
+	Consequence *BlockStatement // The result when the condition is true.
+	Alternative *BlockStatement // The result when the condition is false.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (i *IfExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token for the infix expression.
+func (i *IfExpression) TokenLiteral() string {
+	return i.Token.Literal
+}
+
+// Pos returns the position of the first character of the if expression.
+func (i *IfExpression) Pos() token.Position {
+	return i.Token.Pos
+}
+
+// End returns the position immediately after the last character of the if expression.
+func (i *IfExpression) End() token.Position {
+	if i.Alternative != nil {
+		return i.Alternative.End()
+	}
+	if i.Consequence != nil {
+		return i.Consequence.End()
+	}
+	return i.Token.End
+}
+
+// String returns a string representation of the if expression
+func (i *IfExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("if")
+	out.WriteString(i.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(i.Consequence.String())
+
+	if i.Alternative != nil {
+		out.WriteString("else ")
+		out.WriteString(i.Alternative.String())
+	}
+
+	return out.String()
+}
+
+// TryExpression runs a block and handles the values thrown from it, e.g.
+// try { risky() } catch (e) { recover(e) } finally { cleanUp() }. Its value is the value of
+// the block, or of the catch block when something was thrown. At least one of the catch and
+// finally blocks is present.
+type TryExpression struct {
+	Token      token.Token     // The 'try' token.
+	Body       *BlockStatement // The statements that may throw.
+	CatchParam *Identifier     // The name the thrown value is bound to, or nil.
+	Catch      *BlockStatement // The statements run when something is thrown, or nil.
+	Finally    *BlockStatement // The statements that are always run last, or nil.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (t *TryExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token for the try expression.
+func (t *TryExpression) TokenLiteral() string {
+	return t.Token.Literal
+}
+
+// Pos returns the position of the first character of the try expression.
+func (t *TryExpression) Pos() token.Position {
+	return t.Token.Pos
+}
+
+// End returns the position immediately after the last character of the try expression.
+func (t *TryExpression) End() token.Position {
+	switch {
+	case t.Finally != nil:
+		return t.Finally.End()
+	case t.Catch != nil:
+		return t.Catch.End()
+	case t.Body != nil:
+		return t.Body.End()
+	}
+	return t.Token.End
+}
+
+// String returns a string representation of the try expression.
+func (t *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(t.Body.String())
+	if t.Catch != nil {
+		out.WriteString(" catch ")
+		if t.CatchParam != nil {
+			out.WriteString("(" + t.CatchParam.String() + ") ")
+		}
+		out.WriteString(t.Catch.String())
+	}
+	if t.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(t.Finally.String())
+	}
+
+	return out.String()
+}
+
+// BlockStatement represents a scoped section of code that contains
+// additional statements.
+type BlockStatement struct {
+	Token      token.Token // The { token
+	Statements []Statement // A collection of scoped statements.
+	Rbrace     token.Token // The closing } token.
+}
+
+// statementNode is a placeholder function for the Statement interface.
+func (b *BlockStatement) statementNode() {}
+
+// TokenLiteral returns the literal value of the token for the block statement.
+func (b *BlockStatement) TokenLiteral() string {
+	return b.Token.Literal
+}
+
+// Pos returns the position of the first character of the block statement.
+func (b *BlockStatement) Pos() token.Position {
+	return b.Token.Pos
+}
+
+// End returns the position immediately after the last character of the block statement.
+func (b *BlockStatement) End() token.Position {
+	if b.Rbrace.End.IsValid() {
+		return b.Rbrace.End
+	}
+	if len(b.Statements) > 0 {
+		return b.Statements[len(b.Statements)-1].End()
+	}
+	return b.Token.End
+}
+
+// String returns a string representation of the block statement.
+func (b *BlockStatement) String() string {
+	var out bytes.Buffer
+
+	for _, s := range b.Statements {
+		out.WriteString(s.String())
+	}
+
+	return out.String()
+}
+
+// FunctionLiteral represents a user defined function.
+type FunctionLiteral struct {
+	Token      token.Token     // The 'fn' token
+	Parameters []*Identifier   // The list of parameters which can be empty.
+	Patterns   []Pattern       // The pattern destructuring each parameter, nil for a plain parameter name.
+	Defaults   []Expression    // The default value of each parameter, nil for a required parameter.
+	Types      []Type          // The annotated type of each parameter, nil when it is not annotated.
+	Rest       *Identifier     // The parameter collecting any further arguments, e.g. rest in fn(a, ...rest), or nil.
+	RestType   Type            // The annotated type of each further argument, e.g. int in fn(...rest: int), or nil.
+	ReturnType Type            // The annotated type of the result, e.g. bool in fn(a) -> bool { ... }, or nil.
+	Generator  bool            // Whether the function is a generator, written fn*, whose calls return a generator.
+	Body       *BlockStatement // The body of the function
+	Name       string          // The name of the function
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (f *FunctionLiteral) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token for the function literal.
+func (f *FunctionLiteral) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+// Pos returns the position of the first character of the function literal.
+func (f *FunctionLiteral) Pos() token.Position {
+	return f.Token.Pos
+}
+
+// End returns the position immediately after the last character of the function literal.
+func (f *FunctionLiteral) End() token.Position {
+	if f.Body != nil {
+		return f.Body.End()
+	}
+	return f.Token.End
+}
+
+// String returns a string representation of the function literal.
+func (f *FunctionLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for i, p := range f.Parameters {
+		param := p.String()
+		if i < len(f.Patterns) && f.Patterns[i] != nil {
+			param = f.Patterns[i].String()
+		}
+		if i < len(f.Types) && f.Types[i] != nil {
+			param += ": " + f.Types[i].String()
+		}
+		if i < len(f.Defaults) && f.Defaults[i] != nil {
+			param += " = " + f.Defaults[i].String()
+		}
+		params = append(params, param)
+	}
+	if f.Rest != nil {
+		rest := "..." + f.Rest.String()
+		if f.RestType != nil {
+			rest += ": " + f.RestType.String()
+		}
+		params = append(params, rest)
+	}
+
+	out.WriteString(f.TokenLiteral())
+	if f.Generator {
+		out.WriteString("*")
+	}
+	if f.Name != "" {
+		out.WriteString(fmt.Sprintf("<%s>", f.Name))
+	}
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	if f.ReturnType != nil {
+		out.WriteString(" -> " + f.ReturnType.String() + " ")
+	}
+	out.WriteString(f.Body.String())
+
+	return out.String()
+}
+
+// MacroLiteral represents a macro definition, e.g. macro(x, y) { quote(unquote(x) + unquote(y)) }.
+// Macros are bound with a top-level let and expanded before the program runs.
+type MacroLiteral struct {
+	Token      token.Token     // The 'macro' token.
+	Parameters []*Identifier   // The names the quoted arguments are bound to.
+	Body       *BlockStatement // The body, which must evaluate to a quote.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (m *MacroLiteral) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token for the macro literal.
+func (m *MacroLiteral) TokenLiteral() string {
+	return m.Token.Literal
+}
+
+// Pos returns the position of the first character of the macro literal.
+func (m *MacroLiteral) Pos() token.Position {
+	return m.Token.Pos
+}
+
+// End returns the position immediately after the last character of the macro literal.
+func (m *MacroLiteral) End() token.Position {
+	if m.Body != nil {
+		return m.Body.End()
+	}
+	return m.Token.End
+}
+
+// String returns a string representation of the macro literal.
+func (m *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(m.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	out.WriteString(m.Body.String())
+
+	return out.String()
+}
+
+// SpreadExpression expands an array into the surrounding argument list or array literal,
+// e.g. ...xs in f(...xs) or [...xs, 4].
+type SpreadExpression struct {
+	Token token.Token // The '...' token.
+	Value Expression  // The array to expand.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (s *SpreadExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token of the spread expression.
+func (s *SpreadExpression) TokenLiteral() string {
+	return s.Token.Literal
+}
+
+// Pos returns the position of the first character of the spread expression.
+func (s *SpreadExpression) Pos() token.Position {
+	return s.Token.Pos
+}
+
+// End returns the position immediately after the last character of the spread expression.
+func (s *SpreadExpression) End() token.Position {
+	return s.Value.End()
+}
+
+// String returns a string representation of the spread expression.
+func (s *SpreadExpression) String() string {
+	return "..." + s.Value.String()
+}
+
+// CallExpression represents a function and a set of arguments that can be called.
+type CallExpression struct {
+	Token     token.Token  // The '(' Token
+	Function  Expression   // The function to be called
+	Arguments []Expression // The arguments to be passed into the function.
+	Rparen    token.Token  // The closing ')' token.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (c *CallExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token for the call expression.
+func (c *CallExpression) TokenLiteral() string {
+	return c.Token.Literal
+}
+
+// Pos returns the position of the first character of the call expression.
+func (c *CallExpression) Pos() token.Position {
+	if c.Function != nil {
+		return c.Function.Pos()
+	}
+	return c.Token.Pos
+}
+
+// End returns the position immediately after the last character of the call expression.
+func (c *CallExpression) End() token.Position {
+	if c.Rparen.End.IsValid() {
+		return c.Rparen.End
+	}
+	return c.Token.End
+}
+
+// String returns a string representation of the call expression.
+func (c *CallExpression) String() string {
+	var out bytes.Buffer
+	args := []string{}
+
+	for _, a := range c.Arguments {
+		args = append(args, a.String())
+	}
+
+	out.WriteString(c.Function.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// StringLiteral represents a string in the monkey language.
+type StringLiteral struct {
+	Token token.Token // The token.
+	Value string      // The literal string value.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (s *StringLiteral) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token for the StringLiteral expression.
+func (s *StringLiteral) TokenLiteral() string {
+	return s.Token.Literal
+}
+
+// Pos returns the position of the first character of the string literal.
+func (s *StringLiteral) Pos() token.Position {
+	return s.Token.Pos
+}
+
+// End returns the position immediately after the last character of the string literal.
+func (s *StringLiteral) End() token.Position {
+	return s.Token.End
+}
+
+// String returns a string representation of the StringLiteral expression.
+func (s *StringLiteral) String() string {
+	return s.Token.Literal
+}
+
+// InterpolatedString represents a string literal with embedded expressions, e.g. "Hello ${name}!".
+type InterpolatedString struct {
+	Token token.Token  // The TEMPLATE_HEAD token that starts the string.
+	Parts []Expression // The string pieces as *StringLiteral nodes and the embedded expressions, in order.
+	Tail  token.Token  // The TEMPLATE_TAIL token that ends the string.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (is *InterpolatedString) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token for the InterpolatedString expression.
+func (is *InterpolatedString) TokenLiteral() string {
+	return is.Token.Literal
+}
+
+// Pos returns the position of the opening quote of the interpolated string.
+func (is *InterpolatedString) Pos() token.Position {
+	return is.Token.Pos
+}
+
+// End returns the position immediately after the closing quote of the interpolated string.
+func (is *InterpolatedString) End() token.Position {
+	if is.Tail.End.IsValid() {
+		return is.Tail.End
+	}
+	if len(is.Parts) > 0 && is.Parts[len(is.Parts)-1] != nil {
+		return is.Parts[len(is.Parts)-1].End()
+	}
+	return is.Token.End
+}
+
+// String returns a string representation of the InterpolatedString expression.
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("\"")
+	for _, part := range is.Parts {
+		if str, ok := part.(*StringLiteral); ok {
+			out.WriteString(str.Value)
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+	out.WriteString("\"")
+
+	return out.String()
+}
+
+// ArrayLiteral represents an array of data.
+type ArrayLiteral struct {
+	Token    token.Token  // The '[' token.
+	Elements []Expression // A collection of expressions.
+	Rbracket token.Token  // The closing ']' token.
 }
 
 // expressionNode is a placeholder function for the Expression interface.
@@ -403,6 +1407,19 @@ func (a *ArrayLiteral) TokenLiteral() string {
 	return a.Token.Literal
 }
 
+// Pos returns the position of the first character of the array literal.
+func (a *ArrayLiteral) Pos() token.Position {
+	return a.Token.Pos
+}
+
+// End returns the position immediately after the last character of the array literal.
+func (a *ArrayLiteral) End() token.Position {
+	if a.Rbracket.End.IsValid() {
+		return a.Rbracket.End
+	}
+	return a.Token.End
+}
+
 // String returns a string representation of the ArrayLiteral expression.
 func (a *ArrayLiteral) String() string {
 	var out bytes.Buffer
@@ -422,9 +1439,10 @@ func (a *ArrayLiteral) String() string {
 
 // IndexExpression represents using indexing to get a value from an array. Example: items[1]
 type IndexExpression struct {
-	Token token.Token // The '[' Token
-	Left  Expression  // The identifier, function, or array that evaluates to an array of items.
-	Index Expression  // The index expression that is used to find the item in the list. e.g. 1 + 1 in items[1 + 1]
+	Token    token.Token // The '[' Token
+	Left     Expression  // The identifier, function, or array that evaluates to an array of items.
+	Index    Expression  // The index expression that is used to find the item in the list. e.g. 1 + 1 in items[1 + 1]
+	Rbracket token.Token // The closing ']' token.
 }
 
 // expressionNode is a placeholder function for the Expression interface.
@@ -435,6 +1453,22 @@ func (i *IndexExpression) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+// Pos returns the position of the first character of the index expression.
+func (i *IndexExpression) Pos() token.Position {
+	if i.Left != nil {
+		return i.Left.Pos()
+	}
+	return i.Token.Pos
+}
+
+// End returns the position immediately after the last character of the index expression.
+func (i *IndexExpression) End() token.Position {
+	if i.Rbracket.End.IsValid() {
+		return i.Rbracket.End
+	}
+	return i.Token.End
+}
+
 // String returns a string representation of the ArrayLiteral expression.
 func (i *IndexExpression) String() string {
 	var out bytes.Buffer
@@ -448,10 +1482,100 @@ func (i *IndexExpression) String() string {
 	return out.String()
 }
 
+// MemberExpression accesses a field or method of a struct value or an export of a module,
+// e.g. p.x or str.join
+type MemberExpression struct {
+	Token  token.Token // The '.' token.
+	Object Expression  // The expression producing the value whose member is accessed.
+	Member *Identifier // The name of the member.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (m *MemberExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token of the member expression.
+func (m *MemberExpression) TokenLiteral() string {
+	return m.Token.Literal
+}
+
+// Pos returns the position of the first character of the member expression.
+func (m *MemberExpression) Pos() token.Position {
+	if m.Object != nil {
+		return m.Object.Pos()
+	}
+	return m.Token.Pos
+}
+
+// End returns the position immediately after the last character of the member expression.
+func (m *MemberExpression) End() token.Position {
+	if m.Member != nil {
+		return m.Member.End()
+	}
+	return m.Token.End
+}
+
+// String returns a string representation of the MemberExpression
+func (m *MemberExpression) String() string {
+	return "(" + m.Object.String() + "." + m.Member.String() + ")"
+}
+
+// SliceExpression takes a sub-sequence of an array or string. e.g. items[1:3], items[:-1]
+type SliceExpression struct {
+	Token    token.Token // The '[' Token
+	Left     Expression  // The expression that evaluates to the array or string being sliced.
+	Low      Expression  // The first index of the slice, or nil when omitted.
+	High     Expression  // The index one past the last element of the slice, or nil when omitted.
+	Rbracket token.Token // The closing ']' token.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (s *SliceExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token for the SliceExpression.
+func (s *SliceExpression) TokenLiteral() string {
+	return s.Token.Literal
+}
+
+// Pos returns the position of the first character of the slice expression.
+func (s *SliceExpression) Pos() token.Position {
+	if s.Left != nil {
+		return s.Left.Pos()
+	}
+	return s.Token.Pos
+}
+
+// End returns the position immediately after the last character of the slice expression.
+func (s *SliceExpression) End() token.Position {
+	if s.Rbracket.End.IsValid() {
+		return s.Rbracket.End
+	}
+	return s.Token.End
+}
+
+// String returns a string representation of the SliceExpression.
+func (s *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(s.Left.String())
+	out.WriteString("[")
+	if s.Low != nil {
+		out.WriteString(s.Low.String())
+	}
+	out.WriteString(":")
+	if s.High != nil {
+		out.WriteString(s.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
 // HashLiteral is a dictionary or map type object that holds key value pairs.
 type HashLiteral struct {
-	Token token.Token               // The '{' Token
-	Pairs map[Expression]Expression // The key value pairs.
+	Token  token.Token               // The '{' Token
+	Pairs  map[Expression]Expression // The key value pairs.
+	Rbrace token.Token               // The closing '}' token.
 }
 
 // expressionNode is a placeholder function for the Expression interface.
@@ -462,6 +1586,19 @@ func (h *HashLiteral) TokenLiteral() string {
 	return h.Token.Literal
 }
 
+// Pos returns the position of the first character of the hash literal.
+func (h *HashLiteral) Pos() token.Position {
+	return h.Token.Pos
+}
+
+// End returns the position immediately after the last character of the hash literal.
+func (h *HashLiteral) End() token.Position {
+	if h.Rbrace.End.IsValid() {
+		return h.Rbrace.End
+	}
+	return h.Token.End
+}
+
 // String returns a string representation of the HashLiteral expression.
 func (h *HashLiteral) String() string {
 	var out bytes.Buffer
@@ -477,3 +1614,545 @@ func (h *HashLiteral) String() string {
 
 	return out.String()
 }
+
+// MatchExpression compares a value against a list of patterns and evaluates the body
+// of the first arm whose pattern matches and whose guard, if any, is truthy.
+type MatchExpression struct {
+	Token   token.Token // The 'match' token.
+	Subject Expression  // The value being matched.
+	Arms    []*MatchArm // The arms in the order they are tried.
+	Rbrace  token.Token // The closing '}' token.
+}
+
+// expressionNode is a placeholder function for the Expression interface.
+func (m *MatchExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value of the token of the match expression.
+func (m *MatchExpression) TokenLiteral() string {
+	return m.Token.Literal
+}
+
+// Pos returns the position of the first character of the match expression.
+func (m *MatchExpression) Pos() token.Position {
+	return m.Token.Pos
+}
+
+// End returns the position immediately after the last character of the match expression.
+func (m *MatchExpression) End() token.Position {
+	if m.Rbrace.End.IsValid() {
+		return m.Rbrace.End
+	}
+	return m.Token.End
+}
+
+// String returns a string representation of the match expression.
+func (m *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, arm := range m.Arms {
+		arms = append(arms, arm.String())
+	}
+
+	out.WriteString("match (")
+	out.WriteString(m.Subject.String())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// MatchArm is a single `pattern if guard => body` arm of a match expression.
+type MatchArm struct {
+	Pattern Pattern         // The pattern the subject has to match.
+	Guard   Expression      // The condition checked after the pattern matched, or nil.
+	Body    *BlockStatement // The statements evaluated when the arm is chosen.
+}
+
+// String returns a string representation of the match arm.
+func (m *MatchArm) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(m.Pattern.String())
+	if m.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(m.Guard.String())
+	}
+	out.WriteString(" => ")
+	out.WriteString(m.Body.String())
+
+	return out.String()
+}
+
+// Pattern represents the shape a value is matched against, e.g. in the arm of a match expression.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// WildcardPattern is the `_` pattern, which matches any value without binding it.
+type WildcardPattern struct {
+	Token token.Token // The '_' token.
+}
+
+// patternNode is a placeholder function for the Pattern interface.
+func (w *WildcardPattern) patternNode() {}
+
+// TokenLiteral returns the literal value of the token of the wildcard pattern.
+func (w *WildcardPattern) TokenLiteral() string {
+	return w.Token.Literal
+}
+
+// Pos returns the position of the first character of the wildcard pattern.
+func (w *WildcardPattern) Pos() token.Position {
+	return w.Token.Pos
+}
+
+// End returns the position immediately after the last character of the wildcard pattern.
+func (w *WildcardPattern) End() token.Position {
+	return w.Token.End
+}
+
+// String returns a string representation of the wildcard pattern.
+func (w *WildcardPattern) String() string {
+	return "_"
+}
+
+// LiteralPattern matches a value that is equal to a literal, e.g. 1, -2.5, "text" or true.
+type LiteralPattern struct {
+	Value Expression // The literal to compare against.
+}
+
+// patternNode is a placeholder function for the Pattern interface.
+func (l *LiteralPattern) patternNode() {}
+
+// TokenLiteral returns the literal value of the token of the literal pattern.
+func (l *LiteralPattern) TokenLiteral() string {
+	return l.Value.TokenLiteral()
+}
+
+// Pos returns the position of the first character of the literal pattern.
+func (l *LiteralPattern) Pos() token.Position {
+	return l.Value.Pos()
+}
+
+// End returns the position immediately after the last character of the literal pattern.
+func (l *LiteralPattern) End() token.Position {
+	return l.Value.End()
+}
+
+// String returns a string representation of the literal pattern.
+func (l *LiteralPattern) String() string {
+	return l.Value.String()
+}
+
+// BindingPattern matches any value and binds it to a name.
+type BindingPattern struct {
+	Name *Identifier // The name the value is bound to.
+}
+
+// patternNode is a placeholder function for the Pattern interface.
+func (b *BindingPattern) patternNode() {}
+
+// TokenLiteral returns the literal value of the token of the binding pattern.
+func (b *BindingPattern) TokenLiteral() string {
+	return b.Name.TokenLiteral()
+}
+
+// Pos returns the position of the first character of the binding pattern.
+func (b *BindingPattern) Pos() token.Position {
+	return b.Name.Pos()
+}
+
+// End returns the position immediately after the last character of the binding pattern.
+func (b *BindingPattern) End() token.Position {
+	return b.Name.End()
+}
+
+// String returns a string representation of the binding pattern.
+func (b *BindingPattern) String() string {
+	return b.Name.String()
+}
+
+// ArrayPattern matches an array element by element, e.g. [first, second] or [first, ...rest].
+// Without a rest element the array has to have exactly as many elements as the pattern.
+type ArrayPattern struct {
+	Token    token.Token // The '[' token.
+	Elements []Pattern   // The patterns for the leading elements.
+	HasRest  bool        // Whether the pattern ends with `...`, allowing further elements.
+	Rest     *Identifier // The name bound to an array of the remaining elements, or nil.
+	Rbracket token.Token // The closing ']' token.
+}
+
+// patternNode is a placeholder function for the Pattern interface.
+func (a *ArrayPattern) patternNode() {}
+
+// TokenLiteral returns the literal value of the token of the array pattern.
+func (a *ArrayPattern) TokenLiteral() string {
+	return a.Token.Literal
+}
+
+// Pos returns the position of the first character of the array pattern.
+func (a *ArrayPattern) Pos() token.Position {
+	return a.Token.Pos
+}
+
+// End returns the position immediately after the last character of the array pattern.
+func (a *ArrayPattern) End() token.Position {
+	if a.Rbracket.End.IsValid() {
+		return a.Rbracket.End
+	}
+	return a.Token.End
+}
+
+// String returns a string representation of the array pattern.
+func (a *ArrayPattern) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range a.Elements {
+		elements = append(elements, el.String())
+	}
+	if a.HasRest {
+		rest := "..."
+		if a.Rest != nil {
+			rest += a.Rest.String()
+		}
+		elements = append(elements, rest)
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// VariantPattern matches a value of an enum variant and its payload, e.g. Result.Ok(value)
+// or Color.Red. The payload has one pattern per field of the variant.
+type VariantPattern struct {
+	Enum   *Identifier // The name of the enum type.
+	Tag    *Identifier // The name of the variant.
+	Fields []Pattern   // The patterns for the fields of the payload, in order.
+	Rparen token.Token // The closing ')' token, if the pattern has parentheses.
+}
+
+// patternNode is a placeholder function for the Pattern interface.
+func (v *VariantPattern) patternNode() {}
+
+// TokenLiteral returns the literal value of the token of the variant pattern.
+func (v *VariantPattern) TokenLiteral() string {
+	return v.Enum.TokenLiteral()
+}
+
+// Pos returns the position of the first character of the variant pattern.
+func (v *VariantPattern) Pos() token.Position {
+	return v.Enum.Pos()
+}
+
+// End returns the position immediately after the last character of the variant pattern.
+func (v *VariantPattern) End() token.Position {
+	if v.Rparen.End.IsValid() {
+		return v.Rparen.End
+	}
+	return v.Tag.End()
+}
+
+// String returns a string representation of the variant pattern.
+func (v *VariantPattern) String() string {
+	if len(v.Fields) == 0 {
+		return v.Enum.String() + "." + v.Tag.String()
+	}
+
+	fields := []string{}
+	for _, field := range v.Fields {
+		fields = append(fields, field.String())
+	}
+
+	return v.Enum.String() + "." + v.Tag.String() + "(" + strings.Join(fields, ", ") + ")"
+}
+
+// VariantPatterns lists the variant patterns in a pattern, including nested ones, in the
+// order they appear.
+//
+// Parameters:
+//   - pattern: The pattern to search.
+//
+// Returns:
+//   - []*VariantPattern: The variant patterns.
+func VariantPatterns(pattern Pattern) []*VariantPattern {
+	switch pattern := pattern.(type) {
+	case *VariantPattern:
+		patterns := []*VariantPattern{pattern}
+		for _, field := range pattern.Fields {
+			patterns = append(patterns, VariantPatterns(field)...)
+		}
+		return patterns
+	case *ArrayPattern:
+		patterns := []*VariantPattern{}
+		for _, element := range pattern.Elements {
+			patterns = append(patterns, VariantPatterns(element)...)
+		}
+		return patterns
+	case *HashPattern:
+		patterns := []*VariantPattern{}
+		for _, value := range pattern.Values {
+			patterns = append(patterns, VariantPatterns(value)...)
+		}
+		return patterns
+	}
+	return nil
+}
+
+// EnumCoverage lists the variants of an enum that the arms of a match expression cover.
+type EnumCoverage struct {
+	Enum    *Identifier // The name of the enum type, as first written in an arm.
+	Covered []string    // The variants matched by an arm whatever their payload.
+}
+
+// Coverage finds the enums whose variants the arms of the match expression are matched
+// against, and which variants an arm without a guard matches whatever their payload. A match
+// over an enum is exhaustive when every variant is covered.
+//
+// Returns:
+//   - []*EnumCoverage: The enums in the order they are first matched against, or nil when
+//     an arm without a guard matches any value.
+func (m *MatchExpression) Coverage() []*EnumCoverage {
+	coverage := []*EnumCoverage{}
+	byName := map[string]*EnumCoverage{}
+
+	for _, arm := range m.Arms {
+		switch pattern := arm.Pattern.(type) {
+		case *WildcardPattern, *BindingPattern:
+			if arm.Guard == nil {
+				return nil
+			}
+		case *VariantPattern:
+			enum, ok := byName[pattern.Enum.Value]
+			if !ok {
+				enum = &EnumCoverage{Enum: pattern.Enum, Covered: []string{}}
+				byName[pattern.Enum.Value] = enum
+				coverage = append(coverage, enum)
+			}
+			if arm.Guard == nil && irrefutable(pattern.Fields) {
+				enum.Covered = append(enum.Covered, pattern.Tag.Value)
+			}
+		}
+	}
+
+	return coverage
+}
+
+// irrefutable reports whether the patterns match any values, being names or _.
+//
+// Parameters:
+//   - patterns: The patterns.
+//
+// Returns:
+//   - bool: Whether every pattern matches any value.
+func irrefutable(patterns []Pattern) bool {
+	for _, pattern := range patterns {
+		switch pattern.(type) {
+		case *WildcardPattern, *BindingPattern:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// HashPattern matches a hash that contains the given keys and whose values match the
+// given patterns, e.g. {"type": t}. Other keys in the hash are ignored.
+type HashPattern struct {
+	Token  token.Token  // The '{' token.
+	Keys   []Expression // The literal keys, in source order.
+	Values []Pattern    // The pattern for the value of each key.
+	Rbrace token.Token  // The closing '}' token.
+}
+
+// patternNode is a placeholder function for the Pattern interface.
+func (h *HashPattern) patternNode() {}
+
+// TokenLiteral returns the literal value of the token of the hash pattern.
+func (h *HashPattern) TokenLiteral() string {
+	return h.Token.Literal
+}
+
+// Pos returns the position of the first character of the hash pattern.
+func (h *HashPattern) Pos() token.Position {
+	return h.Token.Pos
+}
+
+// End returns the position immediately after the last character of the hash pattern.
+func (h *HashPattern) End() token.Position {
+	if h.Rbrace.End.IsValid() {
+		return h.Rbrace.End
+	}
+	return h.Token.End
+}
+
+// String returns a string representation of the hash pattern.
+func (h *HashPattern) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for i, key := range h.Keys {
+		pairs = append(pairs, key.String()+": "+h.Values[i].String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// Type represents a type annotation, e.g. the int in let x: int = 5. Annotations are optional
+// and only used by the type checker; the evaluator and the compiler ignore them.
+type Type interface {
+	Node
+	typeNode()
+}
+
+// NamedType is a type written as a name, e.g. int, string, any or the name of a struct or enum type.
+type NamedType struct {
+	Token token.Token // The name token.
+	Name  string      // The name of the type.
+}
+
+// typeNode is a placeholder function for the Type interface.
+func (n *NamedType) typeNode() {}
+
+// TokenLiteral returns the literal value of the token of the named type.
+func (n *NamedType) TokenLiteral() string {
+	return n.Token.Literal
+}
+
+// Pos returns the position of the first character of the named type.
+func (n *NamedType) Pos() token.Position {
+	return n.Token.Pos
+}
+
+// End returns the position immediately after the last character of the named type.
+func (n *NamedType) End() token.Position {
+	return n.Token.End
+}
+
+// String returns a string representation of the named type.
+func (n *NamedType) String() string {
+	return n.Name
+}
+
+// ArrayType is the type of an array whose elements have the same type, e.g. [int].
+type ArrayType struct {
+	Token    token.Token // The '[' token.
+	Element  Type        // The type of the elements.
+	Rbracket token.Token // The closing ']' token.
+}
+
+// typeNode is a placeholder function for the Type interface.
+func (a *ArrayType) typeNode() {}
+
+// TokenLiteral returns the literal value of the token of the array type.
+func (a *ArrayType) TokenLiteral() string {
+	return a.Token.Literal
+}
+
+// Pos returns the position of the first character of the array type.
+func (a *ArrayType) Pos() token.Position {
+	return a.Token.Pos
+}
+
+// End returns the position immediately after the last character of the array type.
+func (a *ArrayType) End() token.Position {
+	if a.Rbracket.End.IsValid() {
+		return a.Rbracket.End
+	}
+	return a.Token.End
+}
+
+// String returns a string representation of the array type.
+func (a *ArrayType) String() string {
+	return "[" + a.Element.String() + "]"
+}
+
+// HashType is the type of a hash whose keys and values have the same types, e.g. {string: int}.
+type HashType struct {
+	Token  token.Token // The '{' token.
+	Key    Type        // The type of the keys.
+	Value  Type        // The type of the values.
+	Rbrace token.Token // The closing '}' token.
+}
+
+// typeNode is a placeholder function for the Type interface.
+func (h *HashType) typeNode() {}
+
+// TokenLiteral returns the literal value of the token of the hash type.
+func (h *HashType) TokenLiteral() string {
+	return h.Token.Literal
+}
+
+// Pos returns the position of the first character of the hash type.
+func (h *HashType) Pos() token.Position {
+	return h.Token.Pos
+}
+
+// End returns the position immediately after the last character of the hash type.
+func (h *HashType) End() token.Position {
+	if h.Rbrace.End.IsValid() {
+		return h.Rbrace.End
+	}
+	return h.Token.End
+}
+
+// String returns a string representation of the hash type.
+func (h *HashType) String() string {
+	return "{" + h.Key.String() + ": " + h.Value.String() + "}"
+}
+
+// FunctionType is the type of a function, e.g. fn(int, string) -> bool.
+type FunctionType struct {
+	Token      token.Token // The 'fn' token.
+	Parameters []Type      // The types of the parameters.
+	Return     Type        // The type of the result, or nil when it is not given.
+	Rparen     token.Token // The closing ')' token of the parameters.
+}
+
+// typeNode is a placeholder function for the Type interface.
+func (f *FunctionType) typeNode() {}
+
+// TokenLiteral returns the literal value of the token of the function type.
+func (f *FunctionType) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+// Pos returns the position of the first character of the function type.
+func (f *FunctionType) Pos() token.Position {
+	return f.Token.Pos
+}
+
+// End returns the position immediately after the last character of the function type.
+func (f *FunctionType) End() token.Position {
+	if f.Return != nil {
+		return f.Return.End()
+	}
+	if f.Rparen.End.IsValid() {
+		return f.Rparen.End
+	}
+	return f.Token.End
+}
+
+// String returns a string representation of the function type.
+func (f *FunctionType) String() string {
+	params := []string{}
+	for _, param := range f.Parameters {
+		params = append(params, param.String())
+	}
+
+	out := f.TokenLiteral() + "(" + strings.Join(params, ", ") + ")"
+	if f.Return != nil {
+		out += " -> " + f.Return.String()
+	}
+	return out
+}
@@ -0,0 +1,321 @@
+package ast
+
+// ModifierFunc is applied to every node visited by Modify and returns the node to put in its place.
+type ModifierFunc func(Node) Node
+
+// Modify walks the tree below a node, children first, replacing every node with the result of
+// calling the modifier on it. The nodes along the way are copied rather than changed in place,
+// so the original tree can be modified again, e.g. when a macro body is expanded more than once.
+//
+// Parameters:
+//   - node: The root of the tree to modify.
+//   - modifier: The function producing the replacement for each node.
+//
+// Returns:
+//   - Node: The replacement for the root node.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+	case *Program:
+		n := *node
+		n.Statements = modifyStatements(node.Statements, modifier)
+		return modifier(&n)
+
+	case *ExpressionStatement:
+		n := *node
+		n.Expression = modifyExpression(node.Expression, modifier)
+		return modifier(&n)
+
+	case *LetStatement:
+		n := *node
+		n.Name = modifyIdentifier(node.Name, modifier)
+		n.Pattern = modifyPattern(node.Pattern, modifier)
+		n.Value = modifyExpression(node.Value, modifier)
+		return modifier(&n)
+
+	case *ReturnStatement:
+		n := *node
+		n.ReturnValue = modifyExpression(node.ReturnValue, modifier)
+		return modifier(&n)
+
+	case *ThrowStatement:
+		n := *node
+		n.Value = modifyExpression(node.Value, modifier)
+		return modifier(&n)
+
+	case *YieldStatement:
+		n := *node
+		n.Value = modifyExpression(node.Value, modifier)
+		return modifier(&n)
+
+	case *ImportStatement:
+		n := *node
+		n.Name = modifyIdentifier(node.Name, modifier)
+		return modifier(&n)
+
+	case *ExportStatement:
+		n := *node
+		n.Names = modifyIdentifiers(node.Names, modifier)
+		return modifier(&n)
+
+	case *StructStatement:
+		n := *node
+		n.Name = modifyIdentifier(node.Name, modifier)
+		return modifier(&n)
+
+	case *EnumStatement:
+		n := *node
+		n.Name = modifyIdentifier(node.Name, modifier)
+		return modifier(&n)
+
+	case *ImplStatement:
+		n := *node
+		n.Name = modifyIdentifier(node.Name, modifier)
+		n.Methods = make([]*Method, len(node.Methods))
+		for i, method := range node.Methods {
+			n.Methods[i] = &Method{Name: method.Name}
+			n.Methods[i].Function, _ = Modify(method.Function, modifier).(*FunctionLiteral)
+		}
+		return modifier(&n)
+
+	case *BlockStatement:
+		n := *node
+		n.Statements = modifyStatements(node.Statements, modifier)
+		return modifier(&n)
+
+	case *WhileStatement:
+		n := *node
+		n.Condition = modifyExpression(node.Condition, modifier)
+		n.Body = modifyBlock(node.Body, modifier)
+		return modifier(&n)
+
+	case *ForStatement:
+		n := *node
+		if node.Init != nil {
+			n.Init, _ = Modify(node.Init, modifier).(Statement)
+		}
+		n.Condition = modifyExpression(node.Condition, modifier)
+		n.Update = modifyExpression(node.Update, modifier)
+		n.Body = modifyBlock(node.Body, modifier)
+		return modifier(&n)
+
+	case *ForInStatement:
+		n := *node
+		n.Variable = modifyIdentifier(node.Variable, modifier)
+		n.Iterable = modifyExpression(node.Iterable, modifier)
+		n.Body = modifyBlock(node.Body, modifier)
+		return modifier(&n)
+
+	case *PrefixExpression:
+		n := *node
+		n.Right = modifyExpression(node.Right, modifier)
+		return modifier(&n)
+
+	case *InfixExpression:
+		n := *node
+		n.Left = modifyExpression(node.Left, modifier)
+		n.Right = modifyExpression(node.Right, modifier)
+		return modifier(&n)
+
+	case *AssignExpression:
+		n := *node
+		n.Target = modifyExpression(node.Target, modifier)
+		n.Value = modifyExpression(node.Value, modifier)
+		return modifier(&n)
+
+	case *IfExpression:
+		n := *node
+		n.Condition = modifyExpression(node.Condition, modifier)
+		n.Consequence = modifyBlock(node.Consequence, modifier)
+		n.Alternative = modifyBlock(node.Alternative, modifier)
+		return modifier(&n)
+
+	case *TryExpression:
+		n := *node
+		n.Body = modifyBlock(node.Body, modifier)
+		n.CatchParam = modifyIdentifier(node.CatchParam, modifier)
+		n.Catch = modifyBlock(node.Catch, modifier)
+		n.Finally = modifyBlock(node.Finally, modifier)
+		return modifier(&n)
+
+	case *FunctionLiteral:
+		n := *node
+		n.Parameters = modifyIdentifiers(node.Parameters, modifier)
+		if node.Patterns != nil {
+			n.Patterns = make([]Pattern, len(node.Patterns))
+			for i, pattern := range node.Patterns {
+				n.Patterns[i] = modifyPattern(pattern, modifier)
+			}
+		}
+		if node.Defaults != nil {
+			n.Defaults = modifyExpressions(node.Defaults, modifier)
+		}
+		n.Rest = modifyIdentifier(node.Rest, modifier)
+		n.Body = modifyBlock(node.Body, modifier)
+		return modifier(&n)
+
+	case *MacroLiteral:
+		n := *node
+		n.Parameters = modifyIdentifiers(node.Parameters, modifier)
+		n.Body = modifyBlock(node.Body, modifier)
+		return modifier(&n)
+
+	case *SpreadExpression:
+		n := *node
+		n.Value = modifyExpression(node.Value, modifier)
+		return modifier(&n)
+
+	case *CallExpression:
+		n := *node
+		n.Function = modifyExpression(node.Function, modifier)
+		n.Arguments = modifyExpressions(node.Arguments, modifier)
+		return modifier(&n)
+
+	case *InterpolatedString:
+		n := *node
+		n.Parts = modifyExpressions(node.Parts, modifier)
+		return modifier(&n)
+
+	case *ArrayLiteral:
+		n := *node
+		n.Elements = modifyExpressions(node.Elements, modifier)
+		return modifier(&n)
+
+	case *IndexExpression:
+		n := *node
+		n.Left = modifyExpression(node.Left, modifier)
+		n.Index = modifyExpression(node.Index, modifier)
+		return modifier(&n)
+
+	case *MemberExpression:
+		n := *node
+		n.Object = modifyExpression(node.Object, modifier)
+		return modifier(&n)
+
+	case *SliceExpression:
+		n := *node
+		n.Left = modifyExpression(node.Left, modifier)
+		n.Low = modifyExpression(node.Low, modifier)
+		n.High = modifyExpression(node.High, modifier)
+		return modifier(&n)
+
+	case *HashLiteral:
+		n := *node
+		n.Pairs = make(map[Expression]Expression, len(node.Pairs))
+		for key, val := range node.Pairs {
+			n.Pairs[modifyExpression(key, modifier)] = modifyExpression(val, modifier)
+		}
+		return modifier(&n)
+
+	case *MatchExpression:
+		n := *node
+		n.Subject = modifyExpression(node.Subject, modifier)
+		n.Arms = make([]*MatchArm, len(node.Arms))
+		for i, arm := range node.Arms {
+			n.Arms[i] = &MatchArm{
+				Pattern: modifyPattern(arm.Pattern, modifier),
+				Guard:   modifyExpression(arm.Guard, modifier),
+				Body:    modifyBlock(arm.Body, modifier),
+			}
+		}
+		return modifier(&n)
+
+	case *LiteralPattern:
+		n := *node
+		n.Value = modifyExpression(node.Value, modifier)
+		return modifier(&n)
+
+	case *BindingPattern:
+		n := *node
+		n.Name = modifyIdentifier(node.Name, modifier)
+		return modifier(&n)
+
+	case *ArrayPattern:
+		n := *node
+		n.Elements = make([]Pattern, len(node.Elements))
+		for i, element := range node.Elements {
+			n.Elements[i] = modifyPattern(element, modifier)
+		}
+		n.Rest = modifyIdentifier(node.Rest, modifier)
+		return modifier(&n)
+
+	case *VariantPattern:
+		n := *node
+		n.Enum = modifyIdentifier(node.Enum, modifier)
+		n.Fields = make([]Pattern, len(node.Fields))
+		for i, field := range node.Fields {
+			n.Fields[i] = modifyPattern(field, modifier)
+		}
+		return modifier(&n)
+
+	case *HashPattern:
+		n := *node
+		n.Keys = modifyExpressions(node.Keys, modifier)
+		n.Values = make([]Pattern, len(node.Values))
+		for i, value := range node.Values {
+			n.Values[i] = modifyPattern(value, modifier)
+		}
+		return modifier(&n)
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(statements []Statement, modifier ModifierFunc) []Statement {
+	out := make([]Statement, len(statements))
+	for i, statement := range statements {
+		out[i], _ = Modify(statement, modifier).(Statement)
+	}
+	return out
+}
+
+func modifyExpressions(expressions []Expression, modifier ModifierFunc) []Expression {
+	out := make([]Expression, len(expressions))
+	for i, expression := range expressions {
+		out[i] = modifyExpression(expression, modifier)
+	}
+	return out
+}
+
+func modifyIdentifiers(identifiers []*Identifier, modifier ModifierFunc) []*Identifier {
+	out := make([]*Identifier, len(identifiers))
+	for i, identifier := range identifiers {
+		out[i] = modifyIdentifier(identifier, modifier)
+	}
+	return out
+}
+
+func modifyExpression(expression Expression, modifier ModifierFunc) Expression {
+	if expression == nil {
+		return nil
+	}
+	out, _ := Modify(expression, modifier).(Expression)
+	return out
+}
+
+// modifyIdentifier keeps the original identifier when the modifier replaces it with something
+// that is not an identifier, since a binding position cannot hold any other expression.
+func modifyIdentifier(identifier *Identifier, modifier ModifierFunc) *Identifier {
+	if identifier == nil {
+		return nil
+	}
+	if out, ok := Modify(identifier, modifier).(*Identifier); ok {
+		return out
+	}
+	return identifier
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	out, _ := Modify(block, modifier).(*BlockStatement)
+	return out
+}
+
+func modifyPattern(pattern Pattern, modifier ModifierFunc) Pattern {
+	if pattern == nil {
+		return nil
+	}
+	out, _ := Modify(pattern, modifier).(Pattern)
+	return out
+}
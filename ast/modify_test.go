@@ -0,0 +1,214 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok {
+			return node
+		}
+
+		if integer.Value != 1 {
+			return node
+		}
+
+		integer = &IntegerLiteral{Value: 2}
+		return integer
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{
+			one(),
+			two(),
+		},
+		{
+			&Program{
+				Statements: []Statement{
+					&ExpressionStatement{Expression: one()},
+				},
+			},
+			&Program{
+				Statements: []Statement{
+					&ExpressionStatement{Expression: two()},
+				},
+			},
+		},
+		{
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&InfixExpression{Left: two(), Operator: "+", Right: one()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&PrefixExpression{Operator: "-", Right: one()},
+			&PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&IndexExpression{Left: one(), Index: one()},
+			&IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&SliceExpression{Left: one(), Low: one()},
+			&SliceExpression{Left: two(), Low: two()},
+		},
+		{
+			&IfExpression{
+				Condition: one(),
+				Consequence: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+				Alternative: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&IfExpression{
+				Condition: two(),
+				Consequence: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+				Alternative: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&ReturnStatement{ReturnValue: one()},
+			&ReturnStatement{ReturnValue: two()},
+		},
+		{
+			&LetStatement{Value: one()},
+			&LetStatement{Value: two()},
+		},
+		{
+			&FunctionLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&FunctionLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&CallExpression{Function: one(), Arguments: []Expression{one(), two()}},
+			&CallExpression{Function: two(), Arguments: []Expression{two(), two()}},
+		},
+		{
+			&ArrayLiteral{Elements: []Expression{one(), one()}},
+			&ArrayLiteral{Elements: []Expression{two(), two()}},
+		},
+		{
+			&WhileStatement{
+				Condition: one(),
+				Body:      &BlockStatement{Statements: []Statement{}},
+			},
+			&WhileStatement{
+				Condition: two(),
+				Body:      &BlockStatement{Statements: []Statement{}},
+			},
+		},
+		{
+			&MatchExpression{
+				Subject: one(),
+				Arms: []*MatchArm{
+					{Pattern: &LiteralPattern{Value: one()}, Guard: one(), Body: &BlockStatement{Statements: []Statement{}}},
+				},
+			},
+			&MatchExpression{
+				Subject: two(),
+				Arms: []*MatchArm{
+					{Pattern: &LiteralPattern{Value: two()}, Guard: two(), Body: &BlockStatement{Statements: []Statement{}}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		modified := Modify(tt.input, turnOneIntoTwo)
+
+		equal := reflect.DeepEqual(modified, tt.expected)
+		if !equal {
+			t.Errorf("not equal. got=%#v, want=%#v", modified, tt.expected)
+		}
+	}
+
+	hashLiteral := &HashLiteral{
+		Pairs: map[Expression]Expression{
+			one(): one(),
+			one(): one(),
+		},
+	}
+
+	Modify(hashLiteral, turnOneIntoTwo)
+
+	for key, val := range hashLiteral.Pairs {
+		key, _ := key.(*IntegerLiteral)
+		if key.Value != 1 {
+			t.Errorf("original key was changed. got=%d", key.Value)
+		}
+		val, _ := val.(*IntegerLiteral)
+		if val.Value != 1 {
+			t.Errorf("original value was changed. got=%d", val.Value)
+		}
+	}
+
+	modified := Modify(hashLiteral, turnOneIntoTwo).(*HashLiteral)
+	for key, val := range modified.Pairs {
+		key, _ := key.(*IntegerLiteral)
+		if key.Value != 2 {
+			t.Errorf("key is not %d, got=%d", 2, key.Value)
+		}
+		val, _ := val.(*IntegerLiteral)
+		if val.Value != 2 {
+			t.Errorf("value is not %d, got=%d", 2, val.Value)
+		}
+	}
+}
+
+func TestModifyLeavesOriginalUnchanged(t *testing.T) {
+	body := &BlockStatement{
+		Statements: []Statement{
+			&ExpressionStatement{Expression: &IntegerLiteral{Value: 1}},
+		},
+	}
+
+	Modify(body, func(node Node) Node {
+		if _, ok := node.(*IntegerLiteral); ok {
+			return &IntegerLiteral{Value: 2}
+		}
+		return node
+	})
+
+	stmt := body.Statements[0].(*ExpressionStatement)
+	if stmt.Expression.(*IntegerLiteral).Value != 1 {
+		t.Errorf("original tree was changed. got=%s", body.String())
+	}
+}
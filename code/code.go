@@ -10,36 +10,83 @@ import (
 type Opcode byte
 
 const (
-	OpConstant       Opcode = iota // represents a constant value
-	OpPop                          // Tells the vm to pop the stack.
-	OpAdd                          // Represents an addition operation
-	OpSub                          // Represents a subtraction operation
-	OpMul                          // Represents a multiplication operation
-	OpDiv                          // Represents a division operation
-	OpTrue                         // Represents the value true
-	OpFalse                        // Represents the value false
-	OpEqual                        // Represents equality
-	OpNotEqual                     // Represents inequality
-	OpGreaterThan                  // Represents left > right
-	OpMinus                        // Represents integer negation
-	OpBang                         // Represents boolean negation
-	OpJump                         // Represents a Jump
-	OpJumpNotTruthy                // Represents a jump when a condition is false
-	OpNull                         // Represents no value
-	OpGetGlobal                    // Get a global binding
-	OpSetGlobal                    // Set a global binding
-	OpArray                        // Construct an array from N elements off of the stack
-	OpHash                         // Construct a hash
-	OpIndex                        // Index expression
-	OpCall                         // Call a function
-	OpReturnValue                  // Return a value from the function call by popping the last value on the stack
-	OpReturn                       // Return from a function, no value is on the stack
-	OpGetLocal                     // Get a local binding
-	OpSetLocal                     // Set a local binding
-	OpGetBuiltin                   // Get a builtin function
-	OpClosure                      // Wrap the specified compiled function in a closure.
-	OpGetFree                      // Get free variables for closures.
-	OpCurrentClosure               // Load the current closure onto the stack
+	OpConstant           Opcode = iota // represents a constant value
+	OpPop                              // Tells the vm to pop the stack.
+	OpAdd                              // Represents an addition operation
+	OpSub                              // Represents a subtraction operation
+	OpMul                              // Represents a multiplication operation
+	OpDiv                              // Represents a division operation
+	OpTrue                             // Represents the value true
+	OpFalse                            // Represents the value false
+	OpEqual                            // Represents equality
+	OpNotEqual                         // Represents inequality
+	OpGreaterThan                      // Represents left > right
+	OpMinus                            // Represents integer negation
+	OpBang                             // Represents boolean negation
+	OpJump                             // Represents a Jump
+	OpJumpNotTruthy                    // Represents a jump when a condition is false
+	OpNull                             // Represents no value
+	OpGetGlobal                        // Get a global binding
+	OpSetGlobal                        // Set a global binding
+	OpArray                            // Construct an array from N elements off of the stack
+	OpHash                             // Construct a hash
+	OpIndex                            // Index expression
+	OpCall                             // Call a function
+	OpReturnValue                      // Return a value from the function call by popping the last value on the stack
+	OpReturn                           // Return from a function, no value is on the stack
+	OpGetLocal                         // Get a local binding
+	OpSetLocal                         // Set a local binding
+	OpGetBuiltin                       // Get a builtin function
+	OpClosure                          // Wrap the specified compiled function in a closure.
+	OpGetFree                          // Get free variables for closures.
+	OpCurrentClosure                   // Load the current closure onto the stack
+	OpMod                              // Represents a remainder operation
+	OpLessThan                         // Represents left < right
+	OpLessThanOrEqual                  // Represents left <= right
+	OpGreaterThanOrEqual               // Represents left >= right
+	OpJumpNotTruthyOrPop               // Jump when the top of the stack is falsy, leaving it in place; otherwise pop it
+	OpJumpTruthyOrPop                  // Jump when the top of the stack is truthy, leaving it in place; otherwise pop it
+	OpConcat                           // Join the string representations of N values off of the stack
+	OpGetLocalCell                     // Box a local binding in a cell if needed and push the cell, to capture it in a closure
+	OpGetFreeCell                      // Push the cell of a free variable, to capture it in a nested closure
+	OpAssignLocal                      // Assign to an existing local binding, writing through its cell when it has been captured
+	OpSetFree                          // Assign to a free variable of the current closure
+	OpIter                             // Replace the collection on top of the stack with an iterator over it
+	OpIterNext                         // Pop an iterator and push its next element, or jump when it is exhausted
+	OpMatchValue                       // Pop a pattern literal and a value and push whether they are equal
+	OpMatchArray                       // Pop a value and push whether it is an array with the given number of elements, or at least that many when the pattern has a rest element
+	OpMatchHash                        // Pop a value and push whether it is a hash
+	OpMatchKey                         // Pop a key and a hash and push whether the hash contains the key
+	OpRest                             // Pop an array and push a new array of its elements from the given index on
+	OpCheckValue                       // Pop a pattern literal and a value and fail unless they are equal
+	OpCheckArray                       // Pop a value and fail unless it is an array with the given number of elements, or at least that many when the pattern has a rest element
+	OpCheckHash                        // Pop a value and fail unless it is a hash
+	OpCheckKey                         // Pop a key and a hash and fail unless the hash contains the key
+	OpJumpIfArg                        // Jump when the caller passed an argument for the given parameter
+	OpSpread                           // Pop the given number of arrays and push an array of all their elements
+	OpCallSpread                       // Pop an array of arguments and call the function below it with them
+	OpSlice                            // Pop the high and low bounds and push the slice of the array or string below them
+	OpSetIndex                         // Pop a value, an index and an array or hash, store the value at the index and push it back
+	OpTry                              // Install an exception handler jumping to the operand, remembering the frame and stack pointer
+	OpEndTry                           // Remove the innermost exception handler
+	OpThrow                            // Pop a value and throw it to the innermost exception handler
+	OpImport                           // Push the module whose top-level code is the function constant at the operand, running that code the first time
+	OpModule                           // Pop the given number of name and value pairs and return them as the module of the current function
+	OpStruct                           // Push a new struct type declared like the struct type constant at the operand
+	OpImpl                             // Pop the given number of name and method pairs and the struct type below them, and add the methods to it
+	OpGetMember                        // Pop a value and push its member named by the string constant at the operand
+	OpSetMember                        // Pop a value and a struct value, store the value in the field named by the string constant at the operand and push it back
+	OpEnumPattern                      // Pop an enum type and fail unless it has the variant named by the string constant at the first operand, with the number of fields given by the second
+	OpExhaustive                       // Pop an enum type and a value, and fail if the value is a variant unless the array constant at the operand names all of the variants
+	OpMatchVariant                     // Pop an enum type and a value and push whether the value is the variant named by the string constant at the first operand
+	OpCheckVariant                     // Pop an enum type and a value and fail unless the value is the variant named by the string constant at the first operand
+	OpPayload                          // Pop a variant value and push the field of its payload at the given index
+	OpYield                            // Pop a value and hand it to the caller of the generator, suspending the generator until it is resumed
+	OpTailCall                         // Call a function whose result the current function returns, reusing the current frame
+	OpTailCallSpread                   // Pop an array of arguments and tail call the function below it with them, reusing the current frame
+	OpClearLocals                      // Clear the given number of locals from the given index on, so the bindings of a block scope start afresh
+	OpClearGlobals                     // Clear the given number of globals from the given index on, so the bindings of a block scope start afresh
+	OpGetGlobalCell                    // Box a global binding of a block scope in a cell if needed and push the cell, to capture it in a closure
 )
 
 // Instructions represent virtual machine instructions.
@@ -95,36 +142,83 @@ type Definition struct {
 
 // definitions contains a map of all the opcode types to some metadata about their use.
 var definitions = map[Opcode]*Definition{
-	OpConstant:       {"OpConstant", []int{2}},
-	OpPop:            {"OpPop", []int{}},
-	OpAdd:            {"OpAdd", []int{}},
-	OpSub:            {"OpSub", []int{}},
-	OpMul:            {"OpMul", []int{}},
-	OpDiv:            {"OpDiv", []int{}},
-	OpTrue:           {"OpTrue", []int{}},
-	OpFalse:          {"OpFalse", []int{}},
-	OpEqual:          {"OpEqual", []int{}},
-	OpNotEqual:       {"OpNotEqual", []int{}},
-	OpGreaterThan:    {"OpGreaterThan", []int{}},
-	OpMinus:          {"OpMinus", []int{}},
-	OpBang:           {"OpBang", []int{}},
-	OpJump:           {"OpJump", []int{2}},
-	OpJumpNotTruthy:  {"OpJumpNotTruthy", []int{2}},
-	OpNull:           {"OpNull", []int{}},
-	OpGetGlobal:      {"OpGetGlobal", []int{2}},
-	OpSetGlobal:      {"OpSetGlobal", []int{2}},
-	OpArray:          {"OpArray", []int{2}},
-	OpHash:           {"OpHash", []int{2}},
-	OpIndex:          {"OpIndex", []int{}},
-	OpCall:           {"OpCall", []int{1}},
-	OpReturnValue:    {"OpReturnValue", []int{}},
-	OpReturn:         {"OpReturn", []int{}},
-	OpGetLocal:       {"OpGetLocal", []int{1}},
-	OpSetLocal:       {"OpSetLocal", []int{1}},
-	OpGetBuiltin:     {"OpGetBuiltin", []int{1}},
-	OpClosure:        {"OpClosure", []int{2, 1}},
-	OpGetFree:        {"OpGetFree", []int{1}},
-	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	OpConstant:           {"OpConstant", []int{2}},
+	OpPop:                {"OpPop", []int{}},
+	OpAdd:                {"OpAdd", []int{}},
+	OpSub:                {"OpSub", []int{}},
+	OpMul:                {"OpMul", []int{}},
+	OpDiv:                {"OpDiv", []int{}},
+	OpTrue:               {"OpTrue", []int{}},
+	OpFalse:              {"OpFalse", []int{}},
+	OpEqual:              {"OpEqual", []int{}},
+	OpNotEqual:           {"OpNotEqual", []int{}},
+	OpGreaterThan:        {"OpGreaterThan", []int{}},
+	OpMinus:              {"OpMinus", []int{}},
+	OpBang:               {"OpBang", []int{}},
+	OpJump:               {"OpJump", []int{2}},
+	OpJumpNotTruthy:      {"OpJumpNotTruthy", []int{2}},
+	OpNull:               {"OpNull", []int{}},
+	OpGetGlobal:          {"OpGetGlobal", []int{2}},
+	OpSetGlobal:          {"OpSetGlobal", []int{2}},
+	OpArray:              {"OpArray", []int{2}},
+	OpHash:               {"OpHash", []int{2}},
+	OpIndex:              {"OpIndex", []int{}},
+	OpCall:               {"OpCall", []int{1}},
+	OpReturnValue:        {"OpReturnValue", []int{}},
+	OpReturn:             {"OpReturn", []int{}},
+	OpGetLocal:           {"OpGetLocal", []int{1}},
+	OpSetLocal:           {"OpSetLocal", []int{1}},
+	OpGetBuiltin:         {"OpGetBuiltin", []int{1}},
+	OpClosure:            {"OpClosure", []int{2, 1}},
+	OpGetFree:            {"OpGetFree", []int{1}},
+	OpCurrentClosure:     {"OpCurrentClosure", []int{}},
+	OpMod:                {"OpMod", []int{}},
+	OpLessThan:           {"OpLessThan", []int{}},
+	OpLessThanOrEqual:    {"OpLessThanOrEqual", []int{}},
+	OpGreaterThanOrEqual: {"OpGreaterThanOrEqual", []int{}},
+	OpJumpNotTruthyOrPop: {"OpJumpNotTruthyOrPop", []int{2}},
+	OpJumpTruthyOrPop:    {"OpJumpTruthyOrPop", []int{2}},
+	OpConcat:             {"OpConcat", []int{2}},
+	OpGetLocalCell:       {"OpGetLocalCell", []int{1}},
+	OpGetFreeCell:        {"OpGetFreeCell", []int{1}},
+	OpAssignLocal:        {"OpAssignLocal", []int{1}},
+	OpSetFree:            {"OpSetFree", []int{1}},
+	OpIter:               {"OpIter", []int{}},
+	OpIterNext:           {"OpIterNext", []int{2}},
+	OpMatchValue:         {"OpMatchValue", []int{}},
+	OpMatchArray:         {"OpMatchArray", []int{2, 1}},
+	OpMatchHash:          {"OpMatchHash", []int{}},
+	OpMatchKey:           {"OpMatchKey", []int{}},
+	OpRest:               {"OpRest", []int{2}},
+	OpCheckValue:         {"OpCheckValue", []int{}},
+	OpCheckArray:         {"OpCheckArray", []int{2, 1}},
+	OpCheckHash:          {"OpCheckHash", []int{}},
+	OpCheckKey:           {"OpCheckKey", []int{}},
+	OpJumpIfArg:          {"OpJumpIfArg", []int{1, 2}},
+	OpSpread:             {"OpSpread", []int{2}},
+	OpCallSpread:         {"OpCallSpread", []int{}},
+	OpSlice:              {"OpSlice", []int{}},
+	OpSetIndex:           {"OpSetIndex", []int{}},
+	OpTry:                {"OpTry", []int{2}},
+	OpEndTry:             {"OpEndTry", []int{}},
+	OpThrow:              {"OpThrow", []int{}},
+	OpImport:             {"OpImport", []int{2}},
+	OpModule:             {"OpModule", []int{2}},
+	OpStruct:             {"OpStruct", []int{2}},
+	OpImpl:               {"OpImpl", []int{2}},
+	OpGetMember:          {"OpGetMember", []int{2}},
+	OpSetMember:          {"OpSetMember", []int{2}},
+	OpEnumPattern:        {"OpEnumPattern", []int{2, 1}},
+	OpExhaustive:         {"OpExhaustive", []int{2}},
+	OpMatchVariant:       {"OpMatchVariant", []int{2, 1}},
+	OpCheckVariant:       {"OpCheckVariant", []int{2, 1}},
+	OpPayload:            {"OpPayload", []int{2}},
+	OpYield:              {"OpYield", []int{}},
+	OpTailCall:           {"OpTailCall", []int{1}},
+	OpTailCallSpread:     {"OpTailCallSpread", []int{}},
+	OpClearLocals:        {"OpClearLocals", []int{1, 1}},
+	OpClearGlobals:       {"OpClearGlobals", []int{2, 2}},
+	OpGetGlobalCell:      {"OpGetGlobalCell", []int{2}},
 }
 
 // Lookup is used to access opcode definitions from other packages.
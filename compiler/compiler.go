@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/code"
+	"monkey/module"
 	"monkey/object"
+	"monkey/token"
+	"path/filepath"
 	"sort"
+	"strings"
 )
 
 // Compiler represents the bytecode compiler.
@@ -14,6 +18,10 @@ type Compiler struct {
 	symbolTable *SymbolTable       // Where identifiers are stored.
 	scopes      []CompilationScope // A collection of compilation scopes
 	scopeIndex  int                // The index for the current compilation scope
+	loader      *module.Loader     // Finds and parses the files named in import statements.
+	path        string             // The path of the file being compiled, empty for code not read from a file.
+	modules     map[string]int     // The constant index of the function of each module compiled so far, by path.
+	importing   []string           // The modules being compiled, the outermost first.
 }
 
 // Bytecode represents instructions for our bytecode vm.
@@ -33,6 +41,34 @@ type CompilationScope struct {
 	instructions        code.Instructions  // instructions is the collection of bytecode instructions
 	lastInstruction     EmittedInstruction // The last instruction emitted
 	previousInstruction EmittedInstruction // The instruction emitted before last instruction
+	loops               []*LoopContext     // The loops enclosing the code being compiled, innermost last
+	tries               []*TryContext      // The exception handlers active in the code being compiled, innermost last
+	module              bool               // Whether the scope holds the top-level code of a module
+	moduleReturns       []int              // The positions of the OpJump instructions emitted for return statements in the top-level code of a module
+}
+
+// LoopContext records the jumps emitted for break and continue statements in a loop,
+// which are patched once the loop's continue and end positions are known.
+type LoopContext struct {
+	breakJumps    []int // The positions of the OpJump instructions emitted for break.
+	continueJumps []int // The positions of the OpJump instructions emitted for continue.
+	tryDepth      int   // The number of active exception handlers when the loop was entered.
+}
+
+// BlockContext records a block scope being compiled, such as a loop or a match arm, whose
+// bindings are new each time it runs, as in the evaluator, where it runs in an environment
+// of its own.
+type BlockContext struct {
+	snapshot blockSnapshot // The symbol table before the block.
+	start    int           // The index of the first symbol defined in the block.
+	clearPos int           // The position of the instruction clearing the block's bindings when it starts.
+}
+
+// TryContext records an exception handler that is active while the code of a try expression
+// is compiled, so that return, break and continue can remove it and run the finally block
+// on their way out.
+type TryContext struct {
+	finally *ast.BlockStatement // The finally block of the try expression, or nil.
 }
 
 // New creates a new compiler instance.
@@ -56,6 +92,8 @@ func New() *Compiler {
 		symbolTable: symbolTable,
 		scopes:      []CompilationScope{mainScope},
 		scopeIndex:  0,
+		loader:      module.NewLoader(),
+		modules:     map[string]int{},
 	}
 }
 
@@ -66,6 +104,22 @@ func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
 	return compiler
 }
 
+// SetLoader sets the loader finding the files named in import statements, and the path of the
+// file being compiled, which imports are resolved relative to. The file counts as being
+// compiled for detecting import cycles, so a module importing it back forms a cycle.
+//
+// Parameters:
+//   - loader: The loader.
+//   - path: The path of the file being compiled, or an empty string for code not read from a file.
+func (c *Compiler) SetLoader(loader *module.Loader, path string) {
+	c.loader = loader
+	c.path = path
+	c.importing = nil
+	if path != "" {
+		c.importing = []string{filepath.Clean(path)}
+	}
+}
+
 // Compile produces bytecode from our ast.
 //
 // Parameters:
@@ -103,48 +157,36 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 	case *ast.InfixExpression:
-		if node.Operator == "<" {
-			err := c.Compile(node.Right)
-			if err != nil {
-				return err
-			}
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
 
-			err = c.Compile(node.Left)
-			if err != nil {
-				return err
-			}
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
 
-			c.emit(code.OpGreaterThan)
-		} else {
-			err := c.Compile(node.Left)
-			if err != nil {
-				return err
-			}
+		err = c.Compile(node.Right)
+		if err != nil {
+			return err
+		}
 
-			err = c.Compile(node.Right)
-			if err != nil {
-				return err
-			}
+		err = c.emitInfixOperator(node.Token, node.Operator)
+		if err != nil {
+			return err
+		}
 
-			switch node.Operator {
-			case "+":
-				c.emit(code.OpAdd)
-			case "-":
-				c.emit(code.OpSub)
-			case "*":
-				c.emit(code.OpMul)
-			case "/":
-				c.emit(code.OpDiv)
-			case ">":
-				c.emit(code.OpGreaterThan)
-			case "==":
-				c.emit(code.OpEqual)
-			case "!=":
-				c.emit(code.OpNotEqual)
-			default:
-				return fmt.Errorf("unknown operator %s", node.Operator)
-			}
+	case *ast.AssignExpression:
+		err := c.compileAssignExpression(node)
+		if err != nil {
+			return err
+		}
+	case *ast.MatchExpression:
+		err := c.compileMatchExpression(node)
+		if err != nil {
+			return err
 		}
+
 	case *ast.IfExpression:
 		err := c.Compile(node.Condition)
 		if err != nil {
@@ -159,8 +201,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 
+		// A block that does not end in an expression still has to produce a value.
 		if c.lastInstructionIs(code.OpPop) {
 			c.removeLastPop()
+		} else {
+			c.emit(code.OpNull)
 		}
 
 		// emit an `OpJump` with a bogus value to be updated later
@@ -180,6 +225,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 			if c.lastInstructionIs(code.OpPop) {
 				c.removeLastPop()
+			} else {
+				c.emit(code.OpNull)
 			}
 		}
 
@@ -197,11 +244,33 @@ func (c *Compiler) Compile(node ast.Node) error {
 		integer := &object.Integer{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(integer))
 
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
 	case *ast.StringLiteral:
 		str := &object.String{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(str))
 
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			err := c.Compile(part)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpConcat, len(node.Parts))
+
 	case *ast.ArrayLiteral:
+		if hasSpread(node.Elements) {
+			err := c.compileSpreadList(node.Elements)
+			if err != nil {
+				return err
+			}
+			break
+		}
+
 		for _, e := range node.Elements {
 			err := c.Compile(e)
 			if err != nil {
@@ -247,10 +316,46 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		c.emit(code.OpIndex)
 
+	case *ast.MemberExpression:
+		err := c.Compile(node.Object)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpGetMember, c.addConstant(&object.String{Value: node.Member.Value}))
+
+	case *ast.SliceExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+
+		for _, bound := range []ast.Expression{node.Low, node.High} {
+			if bound == nil {
+				c.emit(code.OpNull)
+				continue
+			}
+			err = c.Compile(bound)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpSlice)
+
 	case *ast.Identifier:
+		if node.Builtin {
+			index, ok := object.BuiltinIndex(node.Value)
+			if !ok {
+				return fmt.Errorf("%s: undefined builtin %s", node.Pos(), node.Value)
+			}
+			c.emit(code.OpGetBuiltin, index)
+			return nil
+		}
+
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
-			return fmt.Errorf("undefined variable %s", node.Value)
+			return fmt.Errorf("%s: undefined variable %s", node.Pos(), node.Value)
 		}
 
 		c.loadSymbol(symbol)
@@ -265,17 +370,21 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 	case *ast.LetStatement:
+		if node.Pattern != nil {
+			err := c.compileDestructuring(node)
+			if err != nil {
+				return err
+			}
+			break
+		}
+
 		symbol := c.symbolTable.Define(node.Name.Value)
 		err := c.Compile(node.Value)
 		if err != nil {
 			return err
 		}
 
-		if symbol.Scope == GlobalScope {
-			c.emit(code.OpSetGlobal, symbol.Index)
-		} else {
-			c.emit(code.OpSetLocal, symbol.Index)
-		}
+		c.storeSymbol(symbol)
 
 	case *ast.FunctionLiteral:
 		c.enterScope()
@@ -284,8 +393,40 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.symbolTable.DefineFunctionName(node.Name)
 		}
 
+		params := []Symbol{}
 		for _, p := range node.Parameters {
-			c.symbolTable.Define(p.Value)
+			params = append(params, c.symbolTable.Define(p.Value))
+		}
+		if node.Rest != nil {
+			c.symbolTable.Define(node.Rest.Value)
+		}
+
+		numDefaults := 0
+		for i, def := range node.Defaults {
+			if def == nil {
+				continue
+			}
+			numDefaults++
+
+			// The default is only evaluated when the caller did not pass the argument.
+			skipPos := c.emit(code.OpJumpIfArg, i, 9999)
+			err := c.Compile(def)
+			if err != nil {
+				return err
+			}
+			c.storeSymbol(params[i])
+			c.replaceInstruction(skipPos, code.Make(code.OpJumpIfArg, i, len(c.currentInstructions())))
+		}
+
+		for i, pattern := range node.Patterns {
+			if pattern == nil {
+				continue
+			}
+			param := params[i]
+			err := c.compilePattern(pattern, func() { c.loadSymbol(param) }, nil)
+			if err != nil {
+				return err
+			}
 		}
 
 		err := c.Compile(node.Body)
@@ -298,36 +439,163 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if !c.lastInstructionIs(code.OpReturnValue) {
 			c.emit(code.OpReturn)
 		}
+		c.markTailCalls()
 		freeSymbols := c.symbolTable.FreeSymbols
 		numLocals := c.symbolTable.numDefinitions
 		instructions := c.leaveScope()
 
 		for _, s := range freeSymbols {
-			c.loadSymbol(s)
+			c.loadSymbolCell(s)
 		}
 
 		compiledFn := &object.CompiledFunction{
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Parameters),
+			NumDefaults:   numDefaults,
+			Variadic:      node.Rest != nil,
+			Generator:     node.Generator,
 		}
 		fnIndex := c.addConstant(compiledFn)
 		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
 
+	case *ast.WhileStatement:
+		err := c.compileWhileStatement(node)
+		if err != nil {
+			return err
+		}
+
+	case *ast.ForStatement:
+		err := c.compileForStatement(node)
+		if err != nil {
+			return err
+		}
+
+	case *ast.ForInStatement:
+		err := c.compileForInStatement(node)
+		if err != nil {
+			return err
+		}
+
+	case *ast.BreakStatement:
+		loop := c.currentLoop()
+		err := c.leaveTries(loop.tryDepth)
+		if err != nil {
+			return err
+		}
+		loop.breakJumps = append(loop.breakJumps, c.emit(code.OpJump, 9999))
+
+	case *ast.ContinueStatement:
+		loop := c.currentLoop()
+		err := c.leaveTries(loop.tryDepth)
+		if err != nil {
+			return err
+		}
+		loop.continueJumps = append(loop.continueJumps, c.emit(code.OpJump, 9999))
+
 	case *ast.ReturnStatement:
 		err := c.Compile(node.ReturnValue)
 		if err != nil {
 			return err
 		}
 
+		err = c.leaveTries(0)
+		if err != nil {
+			return err
+		}
+
+		// A return in the top-level code of a module ends that code early.
+		if scope := &c.scopes[c.scopeIndex]; scope.module {
+			c.emit(code.OpPop)
+			scope.moduleReturns = append(scope.moduleReturns, c.emit(code.OpJump, 9999))
+			break
+		}
 		c.emit(code.OpReturnValue)
 
+	case *ast.ImportStatement:
+		index, err := c.compileModule(node)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpImport, index)
+		c.storeSymbol(c.symbolTable.Define(node.Name.Value))
+
+	case *ast.ExportStatement:
+		// The exports are collected once the whole module has been compiled.
+
+	case *ast.StructStatement:
+		fields := make([]string, len(node.Fields))
+		for i, field := range node.Fields {
+			fields[i] = field.Value
+		}
+
+		c.emit(code.OpStruct, c.addConstant(&object.Struct{Name: node.Name.Value, Fields: fields}))
+		c.storeSymbol(c.symbolTable.Define(node.Name.Value))
+
+	case *ast.EnumStatement:
+		enum := &object.Enum{Name: node.Name.Value}
+		for _, variant := range node.Variants {
+			fields := make([]string, len(variant.Fields))
+			for i, field := range variant.Fields {
+				fields[i] = field.Value
+			}
+			enum.AddVariant(variant.Name.Value, fields)
+		}
+
+		c.emit(code.OpConstant, c.addConstant(enum))
+		c.storeSymbol(c.symbolTable.Define(node.Name.Value))
+
+	case *ast.ImplStatement:
+		err := c.compileImplStatement(node)
+		if err != nil {
+			return err
+		}
+
+	case *ast.ThrowStatement:
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpThrow)
+
+	case *ast.YieldStatement:
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpYield)
+
+	case *ast.TryExpression:
+		err := c.compileTryExpression(node)
+		if err != nil {
+			return err
+		}
+
+	case *ast.MacroLiteral:
+		return fmt.Errorf("%s: macros can only be defined with a top-level let", node.Pos())
+
 	case *ast.CallExpression:
+		if ident, ok := node.Function.(*ast.Identifier); ok && (ident.Value == "quote" || ident.Value == "unquote") {
+			return fmt.Errorf("%s: %s can only be used inside a macro", node.Pos(), ident.Value)
+		}
+
 		err := c.Compile(node.Function)
 		if err != nil {
 			return err
 		}
 
+		if hasSpread(node.Arguments) {
+			err := c.compileSpreadList(node.Arguments)
+			if err != nil {
+				return err
+			}
+			c.emit(code.OpCallSpread)
+			break
+		}
+
 		for _, a := range node.Arguments {
 			err := c.Compile(a)
 			if err != nil {
@@ -343,90 +611,1088 @@ func (c *Compiler) Compile(node ast.Node) error {
 	return nil
 }
 
-// Bytecode outputs the bytecode generated by the compiler.
-func (c *Compiler) Bytecode() *Bytecode {
-	return &Bytecode{
-		Instructions: c.currentInstructions(),
-		Constants:    c.constants,
-	}
-}
-
-// addConstant appends a constant value to the end of the compiler's constants slice.
+// emitInfixOperator emits the opcode for a binary operator whose operands are on the stack.
 //
 // Parameters:
-//   - obj: the constant object to add the the compiler's constant slice.
+//   - tok: The operator token, used to report an unknown operator.
+//   - operator: The operator, e.g. +
 //
 // Returns:
-//   - int: The index position of the newly added object.
-func (c *Compiler) addConstant(obj object.Object) int {
-	c.constants = append(c.constants, obj)
-	return len(c.constants) - 1
+//   - error: An error if the operator is unknown.
+func (c *Compiler) emitInfixOperator(tok token.Token, operator string) error {
+	switch operator {
+	case "+":
+		c.emit(code.OpAdd)
+	case "-":
+		c.emit(code.OpSub)
+	case "*":
+		c.emit(code.OpMul)
+	case "/":
+		c.emit(code.OpDiv)
+	case "%":
+		c.emit(code.OpMod)
+	case ">":
+		c.emit(code.OpGreaterThan)
+	case "<":
+		c.emit(code.OpLessThan)
+	case ">=":
+		c.emit(code.OpGreaterThanOrEqual)
+	case "<=":
+		c.emit(code.OpLessThanOrEqual)
+	case "==":
+		c.emit(code.OpEqual)
+	case "!=":
+		c.emit(code.OpNotEqual)
+	default:
+		return fmt.Errorf("%s: unknown operator %s", tok.Pos, operator)
+	}
+	return nil
 }
 
-// emit adds a new opcode and operands to the compiler instructions
+// compileAssignExpression compiles an assignment to an existing binding. The assigned
+// value is left on the stack as the result of the expression.
 //
 // Parameters:
-//   - op: The opcode instruction to add.
-//   - operands: The operands to add to the instruction.
+//   - node: The assign expression.
 //
 // Returns:
-//   - int: Essentially a stack pointer to the start of the current instruction.
-func (c *Compiler) emit(op code.Opcode, operands ...int) int {
-	ins := code.Make(op, operands...)
-	pos := c.addInstruction(ins)
-	c.setLastInstruction(op, pos)
-	return pos
+//   - error: An error if the target is undefined or cannot be assigned to.
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	if index, ok := node.Target.(*ast.IndexExpression); ok {
+		return c.compileIndexAssignment(node, index)
+	}
+	if member, ok := node.Target.(*ast.MemberExpression); ok {
+		return c.compileMemberAssignment(node, member)
+	}
+
+	ident := node.Target.(*ast.Identifier)
+
+	symbol, ok := c.symbolTable.Resolve(ident.Value)
+	if !ok {
+		return fmt.Errorf("%s: undefined variable %s", ident.Pos(), ident.Value)
+	}
+	if symbol.Scope == BuiltinScope || symbol.Scope == FunctionScope {
+		return fmt.Errorf("%s: cannot assign to %s", ident.Pos(), ident.Value)
+	}
+
+	if node.Operator != "=" {
+		c.loadSymbol(symbol)
+	}
+
+	err := c.Compile(node.Value)
+	if err != nil {
+		return err
+	}
+
+	if node.Operator != "=" {
+		err = c.emitInfixOperator(node.Token, strings.TrimSuffix(node.Operator, "="))
+		if err != nil {
+			return err
+		}
+	}
+
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, symbol.Index)
+	case LocalScope:
+		c.emit(code.OpAssignLocal, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, symbol.Index)
+	}
+	c.loadSymbol(symbol)
+
+	return nil
 }
 
-// addInstruction adds an instruction to the compiler and returns the start
-// position for the new instruction.
+// compileIndexAssignment compiles an assignment to an element of an array or hash. For a
+// compound assignment the collection and index are kept in hidden bindings, so that they
+// are only evaluated once while being both read from and written to.
 //
 // Parameters:
-//   - ins: The instruction to add
+//   - node: The assign expression.
+//   - target: The index expression being assigned to.
 //
 // Returns:
-//   - int: The starting position for the current instruction.
-func (c *Compiler) addInstruction(ins []byte) int {
-	posNewInstruction := len(c.currentInstructions())
-	updatedInstructions := append(c.currentInstructions(), ins...)
-	c.scopes[c.scopeIndex].instructions = updatedInstructions
-	return posNewInstruction
-}
+//   - error: An error if the collection, index or value failed to compile.
+func (c *Compiler) compileIndexAssignment(node *ast.AssignExpression, target *ast.IndexExpression) error {
+	err := c.Compile(target.Left)
+	if err != nil {
+		return err
+	}
 
-// setLastInstruction keeps track of the instructions that were recently emited in the compiler.
-//
-// Parameters:
-//   - op: The opcode most recently emitted.
-//   - pos: The position in the instruction set where this was emitted.
-func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
-	previous := c.scopes[c.scopeIndex].lastInstruction
-	last := EmittedInstruction{Opcode: op, Position: pos}
-	c.scopes[c.scopeIndex].previousInstruction = previous
-	c.scopes[c.scopeIndex].lastInstruction = last
-}
+	err = c.Compile(target.Index)
+	if err != nil {
+		return err
+	}
 
-// lastInstructionIsPop evaluates if the last emitted instruction was an OpPop.
-func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
-	if len(c.currentInstructions()) == 0 {
-		return false
+	if node.Operator != "=" {
+		pos := len(c.currentInstructions())
+		index := c.symbolTable.Define(fmt.Sprintf("$index%d", pos))
+		c.storeSymbol(index)
+		collection := c.symbolTable.Define(fmt.Sprintf("$collection%d", pos))
+		c.storeSymbol(collection)
+
+		c.loadSymbol(collection)
+		c.loadSymbol(index)
+		c.loadSymbol(collection)
+		c.loadSymbol(index)
+		c.emit(code.OpIndex)
 	}
 
-	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
-}
+	err = c.Compile(node.Value)
+	if err != nil {
+		return err
+	}
 
-// removeLastPop removes the last pop instruction from the stack when it's not needed.
-func (c *Compiler) removeLastPop() {
-	last := c.scopes[c.scopeIndex].lastInstruction
-	previous := c.scopes[c.scopeIndex].previousInstruction
+	if node.Operator != "=" {
+		err = c.emitInfixOperator(node.Token, strings.TrimSuffix(node.Operator, "="))
+		if err != nil {
+			return err
+		}
+	}
 
-	old := c.currentInstructions()
-	new := old[:last.Position]
+	c.emit(code.OpSetIndex)
 
-	c.scopes[c.scopeIndex].instructions = new
-	c.scopes[c.scopeIndex].lastInstruction = previous
+	return nil
 }
 
-// replaceInstruction allows us to replace an instruction with a set of new instructions.
+// compileMemberAssignment compiles an assignment to a field of a struct value. For a compound
+// assignment the struct value is kept in a hidden binding, so that it is only evaluated once
+// while being both read from and written to.
+//
+// Parameters:
+//   - node: The assign expression.
+//   - target: The member expression being assigned to.
+//
+// Returns:
+//   - error: An error if the struct value or the value failed to compile.
+func (c *Compiler) compileMemberAssignment(node *ast.AssignExpression, target *ast.MemberExpression) error {
+	err := c.Compile(target.Object)
+	if err != nil {
+		return err
+	}
+
+	name := c.addConstant(&object.String{Value: target.Member.Value})
+
+	if node.Operator != "=" {
+		receiver := c.symbolTable.Define(fmt.Sprintf("$receiver%d", len(c.currentInstructions())))
+		c.storeSymbol(receiver)
+
+		c.loadSymbol(receiver)
+		c.loadSymbol(receiver)
+		c.emit(code.OpGetMember, name)
+	}
+
+	err = c.Compile(node.Value)
+	if err != nil {
+		return err
+	}
+
+	if node.Operator != "=" {
+		err = c.emitInfixOperator(node.Token, strings.TrimSuffix(node.Operator, "="))
+		if err != nil {
+			return err
+		}
+	}
+
+	c.emit(code.OpSetMember, name)
+
+	return nil
+}
+
+// compileImplStatement compiles the methods of an impl statement and adds them to the
+// struct type, which is loaded below the name and method pairs.
+//
+// Parameters:
+//   - node: The impl statement.
+//
+// Returns:
+//   - error: An error if the struct type is undefined or a method failed to compile.
+func (c *Compiler) compileImplStatement(node *ast.ImplStatement) error {
+	symbol, ok := c.symbolTable.Resolve(node.Name.Value)
+	if !ok {
+		return fmt.Errorf("%s: undefined variable %s", node.Name.Pos(), node.Name.Value)
+	}
+	c.loadSymbol(symbol)
+
+	for _, method := range node.Methods {
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: method.Name.Value}))
+
+		err := c.Compile(method.Function)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.emit(code.OpImpl, len(node.Methods))
+
+	return nil
+}
+
+// compileMatchExpression compiles a match expression into a chain of tests. The subject is
+// stored in a hidden binding, and each arm tests its pattern and guard against it, jumping
+// to the next arm as soon as a test fails. When no arm matches the result is null. Before
+// the first arm the variant patterns are checked against their enum types, along with
+// whether the arms cover every variant of each enum type they match against.
+//
+// Parameters:
+//   - node: The match expression.
+//
+// Returns:
+//   - error: An error if the subject, a pattern, a guard or a body failed to compile.
+func (c *Compiler) compileMatchExpression(node *ast.MatchExpression) error {
+	err := c.Compile(node.Subject)
+	if err != nil {
+		return err
+	}
+
+	// The subject and the names bound in an arm are only in scope within the match.
+	outer := c.symbolTable.snapshot()
+	defer c.symbolTable.restore(outer)
+
+	subject := c.symbolTable.Define(fmt.Sprintf("$match%d", len(c.currentInstructions())))
+	c.storeSymbol(subject)
+
+	err = c.compileExhaustiveCheck(node, subject)
+	if err != nil {
+		return err
+	}
+
+	endJumps := []int{}
+	for _, arm := range node.Arms {
+		failJumps := []int{}
+		block := c.enterBlock()
+
+		err := c.compilePattern(arm.Pattern, func() { c.loadSymbol(subject) }, &failJumps)
+		if err != nil {
+			return err
+		}
+
+		if arm.Guard != nil {
+			err := c.Compile(arm.Guard)
+			if err != nil {
+				return err
+			}
+			failJumps = append(failJumps, c.emit(code.OpJumpNotTruthy, 9999))
+		}
+
+		err = c.Compile(arm.Body)
+		if err != nil {
+			return err
+		}
+
+		if c.lastInstructionIs(code.OpPop) {
+			c.removeLastPop()
+		} else {
+			c.emit(code.OpNull)
+		}
+		endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+		c.leaveBlock(block)
+
+		nextArmPos := len(c.currentInstructions())
+		for _, pos := range failJumps {
+			c.changeOperand(pos, nextArmPos)
+		}
+	}
+
+	c.emit(code.OpNull)
+
+	endPos := len(c.currentInstructions())
+	for _, pos := range endJumps {
+		c.changeOperand(pos, endPos)
+	}
+
+	return nil
+}
+
+// compileExhaustiveCheck compiles the checks that the variant patterns of a match expression
+// name variants of enum types with the right number of fields, and, when the subject is a
+// variant, that the arms cover every variant of each enum type they match against.
+//
+// Parameters:
+//   - node: The match expression.
+//   - subject: The hidden binding holding the subject.
+//
+// Returns:
+//   - error: An error if the name of an enum type is undefined.
+func (c *Compiler) compileExhaustiveCheck(node *ast.MatchExpression, subject Symbol) error {
+	for _, arm := range node.Arms {
+		for _, pattern := range ast.VariantPatterns(arm.Pattern) {
+			err := c.Compile(pattern.Enum)
+			if err != nil {
+				return err
+			}
+			c.emit(code.OpEnumPattern, c.addConstant(&object.String{Value: pattern.Tag.Value}), len(pattern.Fields))
+		}
+	}
+
+	for _, coverage := range node.Coverage() {
+		covered := &object.Array{Elements: []object.Object{}}
+		for _, tag := range coverage.Covered {
+			covered.Elements = append(covered.Elements, &object.String{Value: tag})
+		}
+
+		c.loadSymbol(subject)
+		err := c.Compile(coverage.Enum)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpExhaustive, c.addConstant(covered))
+	}
+
+	return nil
+}
+
+// compileDestructuring compiles a let statement that destructures its value with a pattern.
+// The value is stored in a hidden binding so that each part of the pattern can load it.
+//
+// Parameters:
+//   - node: The let statement.
+//
+// Returns:
+//   - error: An error if the value or the pattern failed to compile.
+func (c *Compiler) compileDestructuring(node *ast.LetStatement) error {
+	err := c.Compile(node.Value)
+	if err != nil {
+		return err
+	}
+
+	value := c.symbolTable.Define(fmt.Sprintf("$let%d", len(c.currentInstructions())))
+	c.storeSymbol(value)
+
+	return c.compilePattern(node.Pattern, func() { c.loadSymbol(value) }, nil)
+}
+
+// compilePattern compiles the tests and bindings for a pattern. In a match expression every
+// test leaves a boolean that is consumed by an OpJumpNotTruthy, so the stack is unchanged
+// whether the pattern matches or not. When destructuring there is nowhere to jump to, so
+// the tests stop the VM with an error instead.
+//
+// Parameters:
+//   - pattern: The pattern to compile.
+//   - load: Emits the instructions that push the value being matched.
+//   - failJumps: Collects the positions of the jumps taken when the value does not match,
+//     or nil when destructuring.
+//
+// Returns:
+//   - error: An error if a literal or the name of an enum type in the pattern failed to compile.
+func (c *Compiler) compilePattern(pattern ast.Pattern, load func(), failJumps *[]int) error {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+
+	case *ast.BindingPattern:
+		load()
+		c.storeSymbol(c.symbolTable.Define(pattern.Name.Value))
+
+	case *ast.LiteralPattern:
+		load()
+		err := c.Compile(pattern.Value)
+		if err != nil {
+			return err
+		}
+		c.emitPatternTest(code.OpMatchValue, code.OpCheckValue, failJumps)
+
+	case *ast.ArrayPattern:
+		hasRest := 0
+		if pattern.HasRest {
+			hasRest = 1
+		}
+
+		load()
+		c.emitPatternTest(code.OpMatchArray, code.OpCheckArray, failJumps, len(pattern.Elements), hasRest)
+
+		for i, element := range pattern.Elements {
+			index := c.addConstant(&object.Integer{Value: int64(i)})
+			loadElement := func() {
+				load()
+				c.emit(code.OpConstant, index)
+				c.emit(code.OpIndex)
+			}
+
+			err := c.compilePattern(element, loadElement, failJumps)
+			if err != nil {
+				return err
+			}
+		}
+
+		if pattern.Rest != nil {
+			load()
+			c.emit(code.OpRest, len(pattern.Elements))
+			c.storeSymbol(c.symbolTable.Define(pattern.Rest.Value))
+		}
+
+	case *ast.VariantPattern:
+		load()
+		err := c.Compile(pattern.Enum)
+		if err != nil {
+			return err
+		}
+		tag := c.addConstant(&object.String{Value: pattern.Tag.Value})
+		c.emitPatternTest(code.OpMatchVariant, code.OpCheckVariant, failJumps, tag, len(pattern.Fields))
+
+		for i, field := range pattern.Fields {
+			loadField := func() {
+				load()
+				c.emit(code.OpPayload, i)
+			}
+
+			err := c.compilePattern(field, loadField, failJumps)
+			if err != nil {
+				return err
+			}
+		}
+
+	case *ast.HashPattern:
+		load()
+		c.emitPatternTest(code.OpMatchHash, code.OpCheckHash, failJumps)
+
+		for i, key := range pattern.Keys {
+			load()
+			err := c.Compile(key)
+			if err != nil {
+				return err
+			}
+			c.emitPatternTest(code.OpMatchKey, code.OpCheckKey, failJumps)
+
+			loadValue := func() {
+				load()
+				c.Compile(key) // The key already compiled without error above.
+				c.emit(code.OpIndex)
+			}
+
+			err = c.compilePattern(pattern.Values[i], loadValue, failJumps)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// emitPatternTest emits a single test of a pattern, either as a match opcode followed by a
+// jump to the next arm, or as the check opcode that fails when destructuring.
+//
+// Parameters:
+//   - match: The opcode that pushes whether the test passed.
+//   - check: The opcode that stops the VM with an error when the test fails.
+//   - failJumps: Collects the position of the jump, or nil when destructuring.
+//   - operands: The operands of the test.
+func (c *Compiler) emitPatternTest(match, check code.Opcode, failJumps *[]int, operands ...int) {
+	if failJumps == nil {
+		c.emit(check, operands...)
+		return
+	}
+
+	c.emit(match, operands...)
+	*failJumps = append(*failJumps, c.emit(code.OpJumpNotTruthy, 9999))
+}
+
+// compileSpreadList compiles the elements of an array literal or argument list that
+// contains spreads into a single array. Runs of plain elements are collected into arrays
+// and joined with the spread arrays by OpSpread.
+//
+// Parameters:
+//   - elements: The elements, some of which are *ast.SpreadExpression.
+//
+// Returns:
+//   - error: An error if an element failed to compile.
+func (c *Compiler) compileSpreadList(elements []ast.Expression) error {
+	parts := 0
+	pending := 0
+
+	for _, el := range elements {
+		spread, ok := el.(*ast.SpreadExpression)
+		if !ok {
+			err := c.Compile(el)
+			if err != nil {
+				return err
+			}
+			pending++
+			continue
+		}
+
+		if pending > 0 {
+			c.emit(code.OpArray, pending)
+			parts++
+			pending = 0
+		}
+
+		err := c.Compile(spread.Value)
+		if err != nil {
+			return err
+		}
+		parts++
+	}
+
+	if pending > 0 {
+		c.emit(code.OpArray, pending)
+		parts++
+	}
+
+	c.emit(code.OpSpread, parts)
+	return nil
+}
+
+// hasSpread reports whether any of the expressions is a spread.
+//
+// Parameters:
+//   - exps: The elements of an array literal or argument list.
+//
+// Returns:
+//   - bool: True when an element is an *ast.SpreadExpression, otherwise false.
+func hasSpread(exps []ast.Expression) bool {
+	for _, e := range exps {
+		if _, ok := e.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compileLogicalExpression compiles && and || so that the right operand is only
+// evaluated when the left operand does not decide the result. The result is the
+// value of the operand that decided it.
+//
+// Parameters:
+//   - node: The infix expression with a && or || operator.
+//
+// Returns:
+//   - error: An error if either operand failed to compile.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	err := c.Compile(node.Left)
+	if err != nil {
+		return err
+	}
+
+	// Emit the jump with a bogus value 9999 until we know where the right operand ends.
+	var jumpPos int
+	if node.Operator == "&&" {
+		jumpPos = c.emit(code.OpJumpNotTruthyOrPop, 9999)
+	} else {
+		jumpPos = c.emit(code.OpJumpTruthyOrPop, 9999)
+	}
+
+	err = c.Compile(node.Right)
+	if err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileWhileStatement compiles a while loop. The condition is checked before each
+// iteration and a continue jumps back to it.
+//
+// Parameters:
+//   - node: The while statement.
+//
+// Returns:
+//   - error: An error if the condition or body failed to compile.
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	startPos := len(c.currentInstructions())
+
+	err := c.Compile(node.Condition)
+	if err != nil {
+		return err
+	}
+	exitPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	c.enterLoop()
+	err = c.Compile(node.Body)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpJump, startPos)
+
+	c.changeOperand(exitPos, len(c.currentInstructions()))
+	c.leaveLoop(startPos)
+	c.emitLoopValue()
+	return nil
+}
+
+// compileForStatement compiles a C-style for loop. A continue jumps to the update
+// expression, which runs before the condition is checked again.
+//
+// Parameters:
+//   - node: The for statement.
+//
+// Returns:
+//   - error: An error if any part of the loop failed to compile.
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	block := c.enterBlock()
+	defer c.leaveBlock(block)
+
+	if node.Init != nil {
+		err := c.Compile(node.Init)
+		if err != nil {
+			return err
+		}
+	}
+
+	startPos := len(c.currentInstructions())
+
+	exitPos := -1
+	if node.Condition != nil {
+		err := c.Compile(node.Condition)
+		if err != nil {
+			return err
+		}
+		exitPos = c.emit(code.OpJumpNotTruthy, 9999)
+	}
+
+	c.enterLoop()
+	err := c.Compile(node.Body)
+	if err != nil {
+		return err
+	}
+
+	continuePos := len(c.currentInstructions())
+	if node.Update != nil {
+		err := c.Compile(node.Update)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+	c.emit(code.OpJump, startPos)
+
+	if exitPos != -1 {
+		c.changeOperand(exitPos, len(c.currentInstructions()))
+	}
+	c.leaveLoop(continuePos)
+	c.emitLoopValue()
+	return nil
+}
+
+// compileForInStatement compiles a for-in loop. The iterator is kept in a hidden
+// binding so that break and continue do not have to clean up the stack.
+//
+// Parameters:
+//   - node: The for-in statement.
+//
+// Returns:
+//   - error: An error if the iterable or body failed to compile.
+func (c *Compiler) compileForInStatement(node *ast.ForInStatement) error {
+	err := c.Compile(node.Iterable)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+
+	block := c.enterBlock()
+	defer c.leaveBlock(block)
+
+	iterator := c.symbolTable.Define(fmt.Sprintf("$iter%d", len(c.currentInstructions())))
+	c.storeSymbol(iterator)
+
+	startPos := len(c.currentInstructions())
+	c.loadSymbol(iterator)
+	nextPos := c.emit(code.OpIterNext, 9999)
+
+	variable := c.symbolTable.Define(node.Variable.Value)
+	c.storeSymbol(variable)
+
+	c.enterLoop()
+	err = c.Compile(node.Body)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpJump, startPos)
+
+	c.changeOperand(nextPos, len(c.currentInstructions()))
+	c.leaveLoop(startPos)
+	c.emitLoopValue()
+	return nil
+}
+
+// emitLoopValue ends a loop, which has no value, by popping a null, so that the loop
+// leaves null as the last popped value just as it evaluates to null in the evaluator.
+// The exit and break jumps of the loop land on it, and it becomes the return value of
+// a function whose body ends with the loop.
+func (c *Compiler) emitLoopValue() {
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+}
+
+// enterLoop starts tracking the break and continue statements of a new innermost loop.
+func (c *Compiler) enterLoop() {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, &LoopContext{tryDepth: len(scope.tries)})
+}
+
+// leaveLoop points the break jumps of the innermost loop at the current position and its
+// continue jumps at the given position, then stops tracking the loop.
+//
+// Parameters:
+//   - continuePos: The position a continue statement jumps to.
+func (c *Compiler) leaveLoop(continuePos int) {
+	scope := &c.scopes[c.scopeIndex]
+	loop := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	for _, pos := range loop.breakJumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	for _, pos := range loop.continueJumps {
+		c.changeOperand(pos, continuePos)
+	}
+}
+
+// enterBlock starts a block scope. The bindings of the block are cleared when it starts
+// running, so that closures that captured them in an earlier run keep their own.
+//
+// Returns:
+//   - BlockContext: The block, for leaveBlock.
+func (c *Compiler) enterBlock() BlockContext {
+	block := BlockContext{start: c.symbolTable.numDefinitions, snapshot: c.symbolTable.snapshot()}
+	if c.symbolTable.Outer == nil {
+		block.clearPos = c.emit(code.OpClearGlobals, block.start, 0)
+	} else {
+		block.clearPos = c.emit(code.OpClearLocals, block.start, 0)
+	}
+	return block
+}
+
+// leaveBlock ends a block scope, clearing the bindings it defined when it starts and
+// taking them out of scope again.
+//
+// Parameters:
+//   - block: The block, as returned by enterBlock.
+func (c *Compiler) leaveBlock(block BlockContext) {
+	op := code.Opcode(c.currentInstructions()[block.clearPos])
+	c.replaceInstruction(block.clearPos, code.Make(op, block.start, c.symbolTable.numDefinitions-block.start))
+	c.symbolTable.restore(block.snapshot)
+}
+
+// currentLoop returns the innermost loop being compiled. The parser only allows break
+// and continue inside a loop, so there is always one.
+//
+// Returns:
+//   - *LoopContext: The innermost loop.
+func (c *Compiler) currentLoop() *LoopContext {
+	loops := c.scopes[c.scopeIndex].loops
+	return loops[len(loops)-1]
+}
+
+// compileModule compiles the module an import statement names into a function running its
+// top-level code, unless it was already compiled. The module is compiled in a symbol table of
+// its own, so it cannot see the names of the importing code.
+//
+// Parameters:
+//   - node: The import statement.
+//
+// Returns:
+//   - int: The constant index of the function.
+//   - error: An error if the module cannot be found, forms an import cycle or fails to compile.
+func (c *Compiler) compileModule(node *ast.ImportStatement) (int, error) {
+	path, err := c.loader.Resolve(node.Path.Value, c.path)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s", node.Pos(), err)
+	}
+
+	if index, ok := c.modules[path]; ok {
+		return index, nil
+	}
+	if err := module.Cycle(c.importing, path); err != nil {
+		return 0, fmt.Errorf("%s: %s", node.Pos(), err)
+	}
+
+	program, err := c.loader.Parse(path)
+	if err != nil {
+		return 0, err
+	}
+
+	symbolTable, importerPath := c.symbolTable, c.path
+	c.symbolTable = NewSymbolTable()
+	for i, v := range object.Builtins {
+		c.symbolTable.DefineBuiltin(i, v.Name)
+	}
+	c.path = path
+	c.importing = append(c.importing, path)
+
+	c.enterScope()
+	c.scopes[c.scopeIndex].module = true
+	err = c.compileModuleBody(program)
+	numLocals := c.symbolTable.numDefinitions
+	instructions := c.leaveScope()
+
+	c.symbolTable, c.path = symbolTable, importerPath
+	c.importing = c.importing[:len(c.importing)-1]
+
+	if err != nil {
+		// Errors from a module imported by this one already name their file.
+		if _, ok := err.(*module.Error); !ok {
+			err = &module.Error{Path: path, Err: err}
+		}
+		return 0, err
+	}
+
+	index := c.addConstant(&object.CompiledFunction{
+		Instructions: instructions,
+		NumLocals:    numLocals,
+		Module:       path,
+	})
+	c.modules[path] = index
+	return index, nil
+}
+
+// compileModuleBody compiles the top-level code of a module, followed by the instructions
+// returning the module built from its exports.
+//
+// Parameters:
+//   - program: The parsed module.
+//
+// Returns:
+//   - error: An error if compiling the code failed or an exported name is not defined.
+func (c *Compiler) compileModuleBody(program *ast.Program) error {
+	err := c.Compile(program)
+	if err != nil {
+		return err
+	}
+
+	for _, pos := range c.scopes[c.scopeIndex].moduleReturns {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	exports := module.Exports(program)
+	for _, name := range exports {
+		symbol, ok := c.symbolTable.Resolve(name.Value)
+		if !ok || symbol.Scope == BuiltinScope {
+			return fmt.Errorf("%s: cannot export undefined name %s", name.Pos(), name.Value)
+		}
+
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: name.Value}))
+		c.loadSymbol(symbol)
+	}
+
+	c.emit(code.OpModule, len(exports))
+	c.emit(code.OpReturnValue)
+	return nil
+}
+
+// compileTryExpression compiles a try expression. The try block runs under a handler that
+// jumps to the catch block, which finds the thrown value on the stack. When there is a finally
+// block, a second handler covers the catch block (or the try block, if there is no catch) and
+// runs the finally block before throwing the value on; otherwise the finally block runs once
+// the value of the expression is on the stack.
+//
+// Parameters:
+//   - node: The try expression.
+//
+// Returns:
+//   - error: An error if compiling any of the blocks failed.
+func (c *Compiler) compileTryExpression(node *ast.TryExpression) error {
+	handlerPos := c.emit(code.OpTry, 9999)
+
+	c.enterTry(node.Finally)
+	err := c.compileBlockValue(node.Body)
+	c.leaveTry()
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpEndTry)
+
+	finallyHandlerPos := handlerPos
+	if node.Catch != nil {
+		afterCatchPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(handlerPos, len(c.currentInstructions()))
+
+		if node.Finally != nil {
+			finallyHandlerPos = c.emit(code.OpTry, 9999)
+			c.enterTry(node.Finally)
+		}
+
+		if node.CatchParam != nil {
+			c.storeSymbol(c.symbolTable.Define(node.CatchParam.Value))
+		} else {
+			c.emit(code.OpPop)
+		}
+
+		err := c.compileBlockValue(node.Catch)
+		if err != nil {
+			return err
+		}
+
+		if node.Finally != nil {
+			c.leaveTry()
+			c.emit(code.OpEndTry)
+		}
+		c.changeOperand(afterCatchPos, len(c.currentInstructions()))
+	}
+
+	if node.Finally == nil {
+		return nil
+	}
+
+	err = c.Compile(node.Finally)
+	if err != nil {
+		return err
+	}
+	endPos := c.emit(code.OpJump, 9999)
+
+	// The thrown value stays on the stack while the finally block runs and is then thrown on.
+	c.changeOperand(finallyHandlerPos, len(c.currentInstructions()))
+	err = c.Compile(node.Finally)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpThrow)
+
+	c.changeOperand(endPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileBlockValue compiles a block so that it leaves its value on the stack. A block that
+// does not end in an expression produces null.
+//
+// Parameters:
+//   - block: The block to compile.
+//
+// Returns:
+//   - error: An error if compiling the block failed.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	err := c.Compile(block)
+	if err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+// enterTry starts tracking a new innermost exception handler.
+//
+// Parameters:
+//   - finally: The finally block to run when leaving the handler early, or nil.
+func (c *Compiler) enterTry(finally *ast.BlockStatement) {
+	scope := &c.scopes[c.scopeIndex]
+	scope.tries = append(scope.tries, &TryContext{finally: finally})
+}
+
+// leaveTry stops tracking the innermost exception handler.
+func (c *Compiler) leaveTry() {
+	scope := &c.scopes[c.scopeIndex]
+	scope.tries = scope.tries[:len(scope.tries)-1]
+}
+
+// leaveTries removes the exception handlers above a depth, innermost first, and runs their
+// finally blocks. It is used by return, break and continue, which jump out of the handlers
+// without reaching their OpEndTry.
+//
+// Parameters:
+//   - depth: The number of handlers to keep.
+//
+// Returns:
+//   - error: An error if compiling a finally block failed.
+func (c *Compiler) leaveTries(depth int) error {
+	tries := c.scopes[c.scopeIndex].tries
+	for i := len(tries) - 1; i >= depth; i-- {
+		c.emit(code.OpEndTry)
+		if tries[i].finally == nil {
+			continue
+		}
+
+		// A return, break or continue inside the finally block only leaves the handlers outside it.
+		c.scopes[c.scopeIndex].tries = tries[:i]
+		err := c.Compile(tries[i].finally)
+		c.scopes[c.scopeIndex].tries = tries
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bytecode outputs the bytecode generated by the compiler.
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+	}
+}
+
+// addConstant appends a constant value to the end of the compiler's constants slice.
+//
+// Parameters:
+//   - obj: the constant object to add the the compiler's constant slice.
+//
+// Returns:
+//   - int: The index position of the newly added object.
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// emit adds a new opcode and operands to the compiler instructions
+//
+// Parameters:
+//   - op: The opcode instruction to add.
+//   - operands: The operands to add to the instruction.
+//
+// Returns:
+//   - int: Essentially a stack pointer to the start of the current instruction.
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+	c.setLastInstruction(op, pos)
+	return pos
+}
+
+// addInstruction adds an instruction to the compiler and returns the start
+// position for the new instruction.
+//
+// Parameters:
+//   - ins: The instruction to add
+//
+// Returns:
+//   - int: The starting position for the current instruction.
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+	return posNewInstruction
+}
+
+// setLastInstruction keeps track of the instructions that were recently emited in the compiler.
+//
+// Parameters:
+//   - op: The opcode most recently emitted.
+//   - pos: The position in the instruction set where this was emitted.
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+// lastInstructionIsPop evaluates if the last emitted instruction was an OpPop.
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+// removeLastPop removes the last pop instruction from the stack when it's not needed.
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	new := old[:last.Position]
+
+	c.scopes[c.scopeIndex].instructions = new
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+// replaceInstruction allows us to replace an instruction with a set of new instructions.
 // WARNING: This function assumes that the newInstruction is the same width as the one being replaced.
 func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
 	ins := c.currentInstructions()
@@ -489,6 +1755,59 @@ func (c *Compiler) replaceLastPopWithReturn() {
 	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
 }
 
+// markTailCalls turns the calls in tail position of the function being compiled into tail
+// calls. A call is in tail position when its result is returned right away: the OpCall or
+// OpCallSpread is followed by OpReturnValue, directly or through the jumps leaving the
+// branches of an if expression. A call inside a try block is followed by OpEndTry, so it is
+// never a tail call.
+func (c *Compiler) markTailCalls() {
+	ins := c.currentInstructions()
+	for pos := 0; pos < len(ins); {
+		def, _ := code.Lookup(ins[pos])
+		next := pos + 1
+		for _, w := range def.OperandWidths {
+			next += w
+		}
+
+		if returnsAt(ins, next) {
+			switch code.Opcode(ins[pos]) {
+			case code.OpCall:
+				ins[pos] = byte(code.OpTailCall)
+			case code.OpCallSpread:
+				ins[pos] = byte(code.OpTailCallSpread)
+			}
+		}
+		pos = next
+	}
+}
+
+// returnsAt reports whether the instructions at a position return the value on top of the
+// stack, following any jumps on the way.
+//
+// Parameters:
+//   - ins: The instructions of a function.
+//   - pos: The position.
+//
+// Returns:
+//   - bool: Whether the value is returned without running any other instruction.
+func returnsAt(ins code.Instructions, pos int) bool {
+	for pos < len(ins) {
+		switch code.Opcode(ins[pos]) {
+		case code.OpReturnValue:
+			return true
+		case code.OpJump:
+			target := int(code.ReadUint16(ins[pos+1:]))
+			if target <= pos {
+				return false
+			}
+			pos = target
+		default:
+			return false
+		}
+	}
+	return false
+}
+
 func (c *Compiler) loadSymbol(s Symbol) {
 	switch s.Scope {
 	case GlobalScope:
@@ -503,3 +1822,33 @@ func (c *Compiler) loadSymbol(s Symbol) {
 		c.emit(code.OpCurrentClosure)
 	}
 }
+
+// storeSymbol pops the top of the stack into a newly defined binding.
+//
+// Parameters:
+//   - s: The symbol to store into.
+func (c *Compiler) storeSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, s.Index)
+	} else {
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+// loadSymbolCell pushes the cell that holds a variable so a closure can capture it,
+// rather than the variable's current value.
+//
+// Parameters:
+//   - s: The symbol being captured.
+func (c *Compiler) loadSymbolCell(s Symbol) {
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpGetLocalCell, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFreeCell, s.Index)
+	case GlobalScope:
+		c.emit(code.OpGetGlobalCell, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
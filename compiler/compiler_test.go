@@ -5,8 +5,12 @@ import (
 	"monkey/ast"
 	"monkey/code"
 	"monkey/lexer"
+	"monkey/module"
 	"monkey/object"
 	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -170,11 +174,31 @@ func TestBooleanExpressions(t *testing.T) {
 		},
 		{
 			input:             "1 < 2",
-			expectedConstants: []any{2, 1},
+			expectedConstants: []any{1, 2},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpGreaterThan),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 <= 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThanOrEqual),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 >= 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGreaterThanOrEqual),
 				code.Make(code.OpPop),
 			},
 		},
@@ -232,6 +256,45 @@ func TestBooleanExpressions(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "true && false",
+			expectedConstants: []any{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthyOrPop, 5),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "false || 1 % 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpFalse),
+				// 0001
+				code.Make(code.OpJumpTruthyOrPop, 11),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpConstant, 1),
+				// 0010
+				code.Make(code.OpMod),
+				// 0011
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestConditionals(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -251,34 +314,440 @@ func TestConditionals(t *testing.T) {
 				// 0010
 				code.Make(code.OpNull),
 				// 0011
-				code.Make(code.OpPop),
-				// 0012
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 1),
+				// 0015
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			if (true) { 10 } else { 20 }; 3333;
+			`,
+			expectedConstants: []any{10, 20, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 13),
+				// 0010
+				code.Make(code.OpConstant, 1),
+				// 0013
+				code.Make(code.OpPop),
+				// 0014
+				code.Make(code.OpConstant, 2),
+				// 0017
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			if (true) { let x = 1 }; 3333;
+			`,
+			expectedConstants: []any{1, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpSetGlobal, 0),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpJump, 15),
+				// 0014
+				code.Make(code.OpNull),
+				// 0015
+				code.Make(code.OpPop),
+				// 0016
+				code.Make(code.OpConstant, 1),
+				// 0019
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+			while (true) { break; continue; } 3333;
+			`,
+			expectedConstants: []any{3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 13),
+				// 0004
+				code.Make(code.OpJump, 13),
+				// 0007
+				code.Make(code.OpJump, 0),
+				// 0010
+				code.Make(code.OpJump, 0),
+				// 0013
+				code.Make(code.OpNull),
+				// 0014
+				code.Make(code.OpPop),
+				// 0015
+				code.Make(code.OpConstant, 0),
+				// 0018
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			for (let i = 0; i < 1; i += 1) { continue; }
+			`,
+			expectedConstants: []any{0, 1, 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpClearGlobals, 0, 1),
+				// 0005
+				code.Make(code.OpConstant, 0),
+				// 0008
+				code.Make(code.OpSetGlobal, 0),
+				// 0011
+				code.Make(code.OpGetGlobal, 0),
+				// 0014
+				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpLessThan),
+				// 0018
+				code.Make(code.OpJumpNotTruthy, 41),
+				// 0021
+				code.Make(code.OpJump, 24),
+				// 0024
+				code.Make(code.OpGetGlobal, 0),
+				// 0027
+				code.Make(code.OpConstant, 2),
+				// 0030
+				code.Make(code.OpAdd),
+				// 0031
+				code.Make(code.OpSetGlobal, 0),
+				// 0034
+				code.Make(code.OpGetGlobal, 0),
+				// 0037
+				code.Make(code.OpPop),
+				// 0038
+				code.Make(code.OpJump, 11),
+				// 0041
+				code.Make(code.OpNull),
+				// 0042
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			for (x in [1]) { x }
+			`,
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpIter),
+				// 0007
+				code.Make(code.OpClearGlobals, 0, 2),
+				// 0012
+				code.Make(code.OpSetGlobal, 0),
+				// 0015
+				code.Make(code.OpGetGlobal, 0),
+				// 0018
+				code.Make(code.OpIterNext, 31),
+				// 0021
+				code.Make(code.OpSetGlobal, 1),
+				// 0024
+				code.Make(code.OpGetGlobal, 1),
+				// 0027
+				code.Make(code.OpPop),
+				// 0028
+				code.Make(code.OpJump, 15),
+				// 0031
+				code.Make(code.OpNull),
+				// 0032
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+			try { throw 1; } catch (e) { e }
+			`,
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 12),
+				// 0003
+				code.Make(code.OpConstant, 0),
+				// 0006
+				code.Make(code.OpThrow),
+				// 0007
+				code.Make(code.OpNull),
+				// 0008
+				code.Make(code.OpEndTry),
+				// 0009
+				code.Make(code.OpJump, 18),
+				// 0012
+				code.Make(code.OpSetGlobal, 0),
+				// 0015
+				code.Make(code.OpGetGlobal, 0),
+				// 0018
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			try { 1 } finally { 2 }
+			`,
+			expectedConstants: []any{1, 2, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 14),
+				// 0003
+				code.Make(code.OpConstant, 0),
+				// 0006
+				code.Make(code.OpEndTry),
+				// 0007
+				code.Make(code.OpConstant, 1),
+				// 0010
+				code.Make(code.OpPop),
+				// 0011
+				code.Make(code.OpJump, 19),
+				// 0014
+				code.Make(code.OpConstant, 2),
+				// 0017
+				code.Make(code.OpPop),
+				// 0018
+				code.Make(code.OpThrow),
+				// 0019
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestParameterDefaultsAndSpread(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+			fn(a, b = 1, ...rest) { b }
+			`,
+			expectedConstants: []any{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpJumpIfArg, 1, 9),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			let xs = [2]; [1, ...xs, 3];
+			`,
+			expectedConstants: []any{2, 1, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSpread, 3),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			len(...[1]);
+			`,
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSpread, 1),
+				code.Make(code.OpCallSpread),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+			let [a, b] = [1, 2];
+			`,
+			expectedConstants: []any{1, 2, 0, 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpConstant, 1),
+				// 0006
+				code.Make(code.OpArray, 2),
+				// 0009
+				code.Make(code.OpSetGlobal, 0),
+				// 0012
+				code.Make(code.OpGetGlobal, 0),
+				// 0015
+				code.Make(code.OpCheckArray, 2, 0),
+				// 0019
+				code.Make(code.OpGetGlobal, 0),
+				// 0022
+				code.Make(code.OpConstant, 2),
+				// 0025
+				code.Make(code.OpIndex),
+				// 0026
+				code.Make(code.OpSetGlobal, 1),
+				// 0029
+				code.Make(code.OpGetGlobal, 0),
+				// 0032
+				code.Make(code.OpConstant, 3),
+				// 0035
+				code.Make(code.OpIndex),
+				// 0036
+				code.Make(code.OpSetGlobal, 2),
+			},
+		},
+		{
+			input: `
+			fn({x}) { x }
+			`,
+			expectedConstants: []any{
+				"x",
+				"x",
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCheckHash),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpCheckKey),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpIndex),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+			match (1) { 1 => 2, _ => 3 }
+			`,
+			expectedConstants: []any{1, 1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpClearGlobals, 1, 0),
+				// 0011
+				code.Make(code.OpGetGlobal, 0),
+				// 0014
 				code.Make(code.OpConstant, 1),
-				// 0015
+				// 0017
+				code.Make(code.OpMatchValue),
+				// 0018
+				code.Make(code.OpJumpNotTruthy, 27),
+				// 0021
+				code.Make(code.OpConstant, 2),
+				// 0024
+				code.Make(code.OpJump, 39),
+				// 0027
+				code.Make(code.OpClearGlobals, 1, 0),
+				// 0032
+				code.Make(code.OpConstant, 3),
+				// 0035
+				code.Make(code.OpJump, 39),
+				// 0038
+				code.Make(code.OpNull),
+				// 0039
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input: `
-			if (true) { 10 } else { 20 }; 3333;
+			match ([1]) { [x, ...] => x }
 			`,
-			expectedConstants: []any{10, 20, 3333},
+			expectedConstants: []any{1, 0},
 			expectedInstructions: []code.Instructions{
 				// 0000
-				code.Make(code.OpTrue),
-				// 0001
-				code.Make(code.OpJumpNotTruthy, 10),
-				// 0004
 				code.Make(code.OpConstant, 0),
-				// 0007
-				code.Make(code.OpJump, 13),
-				// 0010
-				code.Make(code.OpConstant, 1),
-				// 0013
-				code.Make(code.OpPop),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpSetGlobal, 0),
+				// 0009
+				code.Make(code.OpClearGlobals, 1, 1),
 				// 0014
-				code.Make(code.OpConstant, 2),
+				code.Make(code.OpGetGlobal, 0),
 				// 0017
+				code.Make(code.OpMatchArray, 1, 1),
+				// 0021
+				code.Make(code.OpJumpNotTruthy, 40),
+				// 0024
+				code.Make(code.OpGetGlobal, 0),
+				// 0027
+				code.Make(code.OpConstant, 1),
+				// 0030
+				code.Make(code.OpIndex),
+				// 0031
+				code.Make(code.OpSetGlobal, 1),
+				// 0034
+				code.Make(code.OpGetGlobal, 1),
+				// 0037
+				code.Make(code.OpJump, 41),
+				// 0040
+				code.Make(code.OpNull),
+				// 0041
 				code.Make(code.OpPop),
 			},
 		},
@@ -446,6 +915,19 @@ func TestStringExpressions(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             `"n = ${1 + 2}!"`,
+			expectedConstants: []any{"n = ", 1, 2, "!"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConcat, 3),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -522,51 +1004,272 @@ func TestHashLiterals(t *testing.T) {
 			input:             "{1: 2 + 3, 4: 5 * 6}",
 			expectedConstants: []any{1, 2, 3, 4, 5, 6},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpAdd),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpConstant, 5),
-				code.Make(code.OpMul),
-				code.Make(code.OpHash, 4),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpConstant, 5),
+				code.Make(code.OpMul),
+				code.Make(code.OpHash, 4),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "[1, 2, 3][1 + 1]",
+			expectedConstants: []any{1, 2, 3, 1, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 3),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpAdd),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "{1: 2}[2 - 1]",
+			expectedConstants: []any{1, 2, 2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpHash, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpSub),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "[1, 2][:1]",
+			expectedConstants: []any{1, 2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpNull),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctionsWithoutReturnValue(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `fn() { }`,
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctionCalls(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `fn() { 24 }();`,
+			expectedConstants: []any{
+				24,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpCall, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			let noArg = fn() { 24 };
+			noArg();
+			`,
+			expectedConstants: []any{
+				24,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpCall, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			let oneArg = fn(a) { a };
+			oneArg(24);
+			`,
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+				24,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			let manyArg = fn(a, b, c) { a; b; c };
+			manyArg(24, 25, 26);
+			`,
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpPop),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpPop),
+					code.Make(code.OpGetLocal, 2),
+					code.Make(code.OpReturnValue),
+				},
+				24,
+				25,
+				26,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpCall, 3),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let x = 1; x = 2;",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { let x = 1; x += 2; }",
+			expectedConstants: []any{
+				1,
+				2,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpAssignLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn(a) { fn() { a = 3 } }",
+			expectedConstants: []any{
+				3,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetFree, 0),
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpGetLocalCell, 0),
+					code.Make(code.OpClosure, 1, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
 				code.Make(code.OpPop),
 			},
 		},
-	}
-
-	runCompilerTests(t, tests)
-}
-
-func TestIndexExpressions(t *testing.T) {
-	tests := []compilerTestCase{
 		{
-			input:             "[1, 2, 3][1 + 1]",
-			expectedConstants: []any{1, 2, 3, 1, 1},
+			input:             "let a = [1]; a[0] = 2;",
+			expectedConstants: []any{1, 0, 2},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
 				code.Make(code.OpConstant, 1),
 				code.Make(code.OpConstant, 2),
-				code.Make(code.OpArray, 3),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpAdd),
-				code.Make(code.OpIndex),
+				code.Make(code.OpSetIndex),
 				code.Make(code.OpPop),
 			},
 		},
 		{
-			input:             "{1: 2}[2 - 1]",
-			expectedConstants: []any{1, 2, 2, 1},
+			input:             "let a = [1]; a[0] += 2;",
+			expectedConstants: []any{1, 0, 2},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpHash, 2),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpSub),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpSetGlobal, 2),
+				code.Make(code.OpGetGlobal, 2),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpGetGlobal, 2),
+				code.Make(code.OpGetGlobal, 1),
 				code.Make(code.OpIndex),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetIndex),
 				code.Make(code.OpPop),
 			},
 		},
@@ -575,109 +1278,198 @@ func TestIndexExpressions(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
-func TestFunctionsWithoutReturnValue(t *testing.T) {
-	tests := []compilerTestCase{
-		{
-			input: `fn() { }`,
-			expectedConstants: []any{
-				[]code.Instructions{
-					code.Make(code.OpReturn),
-				},
-			},
-			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 0, 0),
-				code.Make(code.OpPop),
-			},
-		},
+func TestAssignmentErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 1", "1:1: undefined variable x"},
+		{"len = 1", "1:1: cannot assign to len"},
+		{"let f = fn() { f = 1 }", "1:16: cannot assign to f"},
 	}
 
-	runCompilerTests(t, tests)
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		compiler := New()
+		err := compiler.Compile(program)
+		if err == nil {
+			t.Fatalf("input %q - expected compiler error, got none", tt.input)
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("input %q - wrong error. want=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
 }
 
-func TestFunctionCalls(t *testing.T) {
+func TestMacroErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn() { macro(x) { x } }", "1:16: macros can only be defined with a top-level let"},
+		{"quote(1 + 2)", "1:1: quote can only be used inside a macro"},
+		{"unquote(x)", "1:1: unquote can only be used inside a macro"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		compiler := New()
+		err := compiler.Compile(program)
+		if err == nil {
+			t.Fatalf("input %q - expected compiler error, got none", tt.input)
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("input %q - wrong error. want=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestStructs(t *testing.T) {
 	tests := []compilerTestCase{
-		{
-			input: `fn() { 24 }();`,
-			expectedConstants: []any{
-				24,
-				[]code.Instructions{
-					code.Make(code.OpConstant, 0),
-					code.Make(code.OpReturnValue),
-				},
-			},
-			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 1, 0),
-				code.Make(code.OpCall, 0),
-				code.Make(code.OpPop),
-			},
-		},
 		{
 			input: `
-			let noArg = fn() { 24 };
-			noArg();
+			struct Point { x, y }
+			let p = Point(1, 2);
+			p.x = p.y;
 			`,
 			expectedConstants: []any{
-				24,
-				[]code.Instructions{
-					code.Make(code.OpConstant, 0),
-					code.Make(code.OpReturnValue),
-				},
+				&object.Struct{Name: "Point", Fields: []string{"x", "y"}},
+				1,
+				2,
+				"x",
+				"y",
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpStruct, 0),
 				code.Make(code.OpSetGlobal, 0),
 				code.Make(code.OpGetGlobal, 0),
-				code.Make(code.OpCall, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpGetMember, 4),
+				code.Make(code.OpSetMember, 3),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input: `
-			let oneArg = fn(a) { a };
-			oneArg(24);
+			struct Point { x }
+			impl Point { fn getX(self) { self.x } }
 			`,
 			expectedConstants: []any{
+				&object.Struct{Name: "Point", Fields: []string{"x"}},
+				"getX",
+				"x",
 				[]code.Instructions{
 					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetMember, 2),
 					code.Make(code.OpReturnValue),
 				},
-				24,
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpStruct, 0),
 				code.Make(code.OpSetGlobal, 0),
 				code.Make(code.OpGetGlobal, 0),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpCall, 1),
-				code.Make(code.OpPop),
+				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpImpl, 1),
 			},
 		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestEnums(t *testing.T) {
+	option := &object.Enum{Name: "Option"}
+	option.AddVariant("Some", []string{"value"})
+	option.AddVariant("None", []string{})
+
+	tests := []compilerTestCase{
 		{
 			input: `
-			let manyArg = fn(a, b, c) { a; b; c };
-			manyArg(24, 25, 26);
+			enum Option { Some(value), None }
+			match (Option.None) { Option.Some(v) => v, Option.None => 0 }
 			`,
 			expectedConstants: []any{
-				[]code.Instructions{
-					code.Make(code.OpGetLocal, 0),
-					code.Make(code.OpPop),
-					code.Make(code.OpGetLocal, 1),
-					code.Make(code.OpPop),
-					code.Make(code.OpGetLocal, 2),
-					code.Make(code.OpReturnValue),
-				},
-				24,
-				25,
-				26,
+				option,
+				"None",
+				"Some",
+				"None",
+				&object.Array{Elements: []object.Object{&object.String{Value: "Some"}, &object.String{Value: "None"}}},
+				"Some",
+				"None",
+				0,
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 0, 0),
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
 				code.Make(code.OpSetGlobal, 0),
+				// 0006
 				code.Make(code.OpGetGlobal, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpCall, 3),
+				// 0009
+				code.Make(code.OpGetMember, 1),
+				// 0012
+				code.Make(code.OpSetGlobal, 1),
+				// 0015
+				code.Make(code.OpGetGlobal, 0),
+				// 0018
+				code.Make(code.OpEnumPattern, 2, 1),
+				// 0022
+				code.Make(code.OpGetGlobal, 0),
+				// 0025
+				code.Make(code.OpEnumPattern, 3, 0),
+				// 0029
+				code.Make(code.OpGetGlobal, 1),
+				// 0032
+				code.Make(code.OpGetGlobal, 0),
+				// 0035
+				code.Make(code.OpExhaustive, 4),
+				// 0038
+				code.Make(code.OpClearGlobals, 2, 1),
+				// 0043
+				code.Make(code.OpGetGlobal, 1),
+				// 0046
+				code.Make(code.OpGetGlobal, 0),
+				// 0049
+				code.Make(code.OpMatchVariant, 5, 1),
+				// 0053
+				code.Make(code.OpJumpNotTruthy, 71),
+				// 0056
+				code.Make(code.OpGetGlobal, 1),
+				// 0059
+				code.Make(code.OpPayload, 0),
+				// 0062
+				code.Make(code.OpSetGlobal, 2),
+				// 0065
+				code.Make(code.OpGetGlobal, 2),
+				// 0068
+				code.Make(code.OpJump, 96),
+				// 0071
+				code.Make(code.OpClearGlobals, 3, 0),
+				// 0076
+				code.Make(code.OpGetGlobal, 1),
+				// 0079
+				code.Make(code.OpGetGlobal, 0),
+				// 0082
+				code.Make(code.OpMatchVariant, 6, 0),
+				// 0086
+				code.Make(code.OpJumpNotTruthy, 95),
+				// 0089
+				code.Make(code.OpConstant, 7),
+				// 0092
+				code.Make(code.OpJump, 96),
+				// 0095
+				code.Make(code.OpNull),
+				// 0096
 				code.Make(code.OpPop),
 			},
 		},
@@ -686,6 +1478,102 @@ func TestFunctionCalls(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestImports(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "m.mk"), []byte("let a = 1; export a;"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := `import "m.mk" as m; import "m.mk" as n; m`
+	expectedConstants := []any{
+		1,
+		"a",
+		[]code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpSetLocal, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpGetLocal, 0),
+			code.Make(code.OpModule, 1),
+			code.Make(code.OpReturnValue),
+		},
+	}
+	expectedInstructions := []code.Instructions{
+		code.Make(code.OpImport, 2),
+		code.Make(code.OpSetGlobal, 0),
+		code.Make(code.OpImport, 2),
+		code.Make(code.OpSetGlobal, 1),
+		code.Make(code.OpGetGlobal, 0),
+		code.Make(code.OpPop),
+	}
+
+	compiler := New()
+	compiler.SetLoader(module.NewLoader(), filepath.Join(dir, "main.mk"))
+	err = compiler.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	bytecode := compiler.Bytecode()
+
+	err = testInstructions(expectedInstructions, bytecode.Instructions)
+	if err != nil {
+		t.Fatalf("testInstructions failed: %s", err)
+	}
+
+	err = testConstants(t, expectedConstants, bytecode.Constants)
+	if err != nil {
+		t.Fatalf("testConstants failed: %s", err)
+	}
+
+	fn := bytecode.Constants[2].(*object.CompiledFunction)
+	if fn.Module != filepath.Join(dir, "m.mk") {
+		t.Errorf("fn.Module wrong. want=%q, got=%q", filepath.Join(dir, "m.mk"), fn.Module)
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	input := `fn*() { yield 1; yield 2; }`
+	expectedConstants := []any{
+		1,
+		2,
+		[]code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpYield),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpYield),
+			code.Make(code.OpReturn),
+		},
+	}
+	expectedInstructions := []code.Instructions{
+		code.Make(code.OpClosure, 2, 0),
+		code.Make(code.OpPop),
+	}
+
+	compiler := New()
+	err := compiler.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	bytecode := compiler.Bytecode()
+
+	err = testInstructions(expectedInstructions, bytecode.Instructions)
+	if err != nil {
+		t.Fatalf("testInstructions failed: %s", err)
+	}
+
+	err = testConstants(t, expectedConstants, bytecode.Constants)
+	if err != nil {
+		t.Fatalf("testConstants failed: %s", err)
+	}
+
+	fn := bytecode.Constants[2].(*object.CompiledFunction)
+	if !fn.Generator {
+		t.Errorf("fn.Generator is false")
+	}
+}
+
 func TestLetStatementScopes(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -821,6 +1709,11 @@ func testConstants(
 				return fmt.Errorf("constant %d - testIntegerObject failed: %s",
 					i, err)
 			}
+		case object.Object:
+			if actual[i].Inspect() != constant.Inspect() {
+				return fmt.Errorf("constant %d - wrong object. want=%q, got=%q",
+					i, constant.Inspect(), actual[i].Inspect())
+			}
 		case []code.Instructions:
 			fn, ok := actual[i].(*object.CompiledFunction)
 			if !ok {
@@ -895,7 +1788,7 @@ func TestBuiltins(t *testing.T) {
 				[]code.Instructions{
 					code.Make(code.OpGetBuiltin, 0),
 					code.Make(code.OpArray, 0),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 			},
@@ -927,7 +1820,7 @@ func TestClosures(t *testing.T) {
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocalCell, 0),
 					code.Make(code.OpClosure, 0, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -957,13 +1850,13 @@ func TestClosures(t *testing.T) {
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetFree, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetFreeCell, 0),
+					code.Make(code.OpGetLocalCell, 0),
 					code.Make(code.OpClosure, 0, 2),
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocalCell, 0),
 					code.Make(code.OpClosure, 1, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -1011,15 +1904,15 @@ func TestClosures(t *testing.T) {
 				[]code.Instructions{
 					code.Make(code.OpConstant, 2),
 					code.Make(code.OpSetLocal, 0),
-					code.Make(code.OpGetFree, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetFreeCell, 0),
+					code.Make(code.OpGetLocalCell, 0),
 					code.Make(code.OpClosure, 4, 2),
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
 					code.Make(code.OpConstant, 1),
 					code.Make(code.OpSetLocal, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocalCell, 0),
 					code.Make(code.OpClosure, 5, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -1036,6 +1929,77 @@ func TestClosures(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestTailCalls(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `fn(f) { if (true) { f() } else { f(1) } }`,
+			expectedConstants: []any{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpTrue),
+					code.Make(code.OpJumpNotTruthy, 11),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpTailCall, 0),
+					code.Make(code.OpJump, 18),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpTailCall, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `fn(f, xs) { f(...xs) }`,
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpSpread, 1),
+					code.Make(code.OpTailCallSpread),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `fn(f) { let x = f(); x }`,
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCall, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+
+	// A call in a try block has to run under the handler, so it is never a tail call.
+	compiler := New()
+	err := compiler.Compile(parse(`fn(f) { try { return f(); } catch (e) { 0 } }`))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	fn := compiler.Bytecode().Constants[1].(*object.CompiledFunction)
+	if strings.Contains(fn.Instructions.String(), "OpTailCall") {
+		t.Errorf("call in try block compiled to a tail call:\n%s", fn.Instructions)
+	}
+}
+
 func TestRecursiveFunctions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -1050,7 +2014,7 @@ func TestRecursiveFunctions(t *testing.T) {
 					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpConstant, 0),
 					code.Make(code.OpSub),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 				1,
@@ -1079,7 +2043,7 @@ func TestRecursiveFunctions(t *testing.T) {
 					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpConstant, 0),
 					code.Make(code.OpSub),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 				1,
@@ -1088,7 +2052,7 @@ func TestRecursiveFunctions(t *testing.T) {
 					code.Make(code.OpSetLocal, 0),
 					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpConstant, 2),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 			},
@@ -1104,3 +2068,18 @@ func TestRecursiveFunctions(t *testing.T) {
 
 	runCompilerTests(t, tests)
 }
+
+func TestCompilerErrorPositions(t *testing.T) {
+	program := parse("let a = 1;\na + b;")
+
+	compiler := New()
+	err := compiler.Compile(program)
+	if err == nil {
+		t.Fatalf("expected compiler error but resulted in none.")
+	}
+
+	expected := "2:5: undefined variable b"
+	if err.Error() != expected {
+		t.Errorf("wrong compiler error. want=%q, got=%q", expected, err)
+	}
+}
@@ -1,5 +1,7 @@
 package compiler
 
+import "maps"
+
 type SymbolScope string
 
 const (
@@ -14,12 +16,15 @@ type Symbol struct {
 	Name  string
 	Scope SymbolScope
 	Index int
+	Block bool // Whether a global is defined in a block scope, so that closures capture it like a local.
 }
 
 type SymbolTable struct {
 	Outer          *SymbolTable
 	store          map[string]Symbol
 	numDefinitions int
+	blockStart     int  // The index of the first symbol defined in the innermost block scope.
+	inBlock        bool // Whether the names defined now are in a block scope.
 	FreeSymbols    []Symbol
 }
 
@@ -35,10 +40,18 @@ func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
 	return s
 }
 
+// Define defines a name. A name defined again in the same block scope keeps its symbol, so
+// that closures that captured the first definition see the second, as in the evaluator,
+// where a second let overwrites the first in the same environment.
 func (st *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := st.store[name]; ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) && symbol.Index >= st.blockStart {
+		return symbol
+	}
+
 	symbol := Symbol{Name: name, Scope: GlobalScope, Index: st.numDefinitions}
 	if st.Outer == nil {
 		symbol.Scope = GlobalScope
+		symbol.Block = st.inBlock
 	} else {
 		symbol.Scope = LocalScope
 	}
@@ -55,7 +68,7 @@ func (st *SymbolTable) Resolve(name string) (Symbol, bool) {
 			return obj, ok
 		}
 
-		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+		if (obj.Scope == GlobalScope && !obj.Block) || obj.Scope == BuiltinScope {
 			return obj, ok
 		}
 
@@ -84,3 +97,37 @@ func (st *SymbolTable) defineFree(original Symbol) Symbol {
 	st.store[symbol.Name] = symbol
 	return symbol
 }
+
+// blockSnapshot is the state of a symbol table before a block scope, for restore.
+type blockSnapshot struct {
+	store      map[string]Symbol
+	blockStart int
+	inBlock    bool
+}
+
+// snapshot starts a block scope, in which a name defined again gets a symbol of its own,
+// and copies the names defined so far, for restore.
+func (st *SymbolTable) snapshot() blockSnapshot {
+	snapshot := blockSnapshot{store: maps.Clone(st.store), blockStart: st.blockStart, inBlock: st.inBlock}
+	st.blockStart = st.numDefinitions
+	st.inBlock = true
+	return snapshot
+}
+
+// restore ends the block scope started by the snapshot, taking the names defined since
+// out of scope again and bringing back the symbols they shadowed. Their slots stay
+// allocated, and the free symbols resolved in the meantime are kept.
+func (st *SymbolTable) restore(snapshot blockSnapshot) {
+	for name, symbol := range st.store {
+		if symbol.Scope != GlobalScope && symbol.Scope != LocalScope {
+			continue
+		}
+		if old, ok := snapshot.store[name]; !ok {
+			delete(st.store, name)
+		} else if old != symbol {
+			st.store[name] = old
+		}
+	}
+	st.blockStart = snapshot.blockStart
+	st.inBlock = snapshot.inBlock
+}
@@ -334,3 +334,54 @@ func TestShadowingFunctionName(t *testing.T) {
 			expected.Name, expected, result)
 	}
 }
+
+func TestRestoreSnapshot(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+
+	snapshot := global.snapshot()
+	global.Define("a")
+	global.Define("b")
+	global.restore(snapshot)
+
+	if result, ok := global.Resolve("a"); !ok || result != a {
+		t.Errorf("a not restored. want=%+v, got=%+v", a, result)
+	}
+	if _, ok := global.Resolve("b"); ok {
+		t.Errorf("b still resolvable after restore")
+	}
+	if c := global.Define("c"); c.Index != 3 {
+		t.Errorf("slots reused after restore. want index 3, got=%d", c.Index)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	snapshot = local.snapshot()
+	free := NewEnclosedSymbolTable(local)
+	local.Define("x")
+	local.restore(snapshot)
+	if _, ok := free.Resolve("x"); ok {
+		t.Errorf("x still resolvable after restore")
+	}
+}
+
+func TestDefineAgainInSameBlock(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	if again := global.Define("a"); again != a {
+		t.Errorf("a defined again in the same block got a new symbol. want=%+v, got=%+v", a, again)
+	}
+
+	snapshot := global.snapshot()
+	inner := global.Define("a")
+	if inner == a {
+		t.Errorf("a defined in a block shares the symbol of the outer a")
+	}
+	if again := global.Define("a"); again != inner {
+		t.Errorf("a defined again in the block got a new symbol. want=%+v, got=%+v", inner, again)
+	}
+	global.restore(snapshot)
+
+	if again := global.Define("a"); again != a {
+		t.Errorf("a defined again after the block got a new symbol. want=%+v, got=%+v", a, again)
+	}
+}
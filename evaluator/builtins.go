@@ -4,11 +4,17 @@ import (
 	"monkey/object"
 )
 
-var builtins = map[string]*object.Builtin{
-	"len":   object.GetBuiltinByName("len"),
-	"first": object.GetBuiltinByName("first"),
-	"last":  object.GetBuiltinByName("last"),
-	"rest":  object.GetBuiltinByName("rest"),
-	"push":  object.GetBuiltinByName("push"),
-	"puts":  object.GetBuiltinByName("puts"),
+// lookupBuiltin finds a built-in function by name. The functions are looked up by name in
+// the index of object.Builtins on every use, so that the functions added by the host with
+// object.AddBuiltin are found as well.
+//
+// Parameters:
+//   - name: The name of the function.
+//
+// Returns:
+//   - *object.Builtin: The function, or nil if there is none with the name.
+//   - bool: Whether the function was found.
+func lookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin := object.GetBuiltinByName(name)
+	return builtin, builtin != nil
 }
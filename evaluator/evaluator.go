@@ -2,14 +2,18 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
 	"monkey/ast"
 	"monkey/object"
+	"strings"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = object.NULL
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 // Eval is the evaluator function that handles conversion from ast nodes to objects.
@@ -37,25 +41,100 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+
+		if node.Pattern != nil {
+			if err := bindPattern(node.Pattern, val, env); err != nil {
+				return err
+			}
+		} else {
+			env.Set(node.Name.Value, val)
+		}
+
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
 
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := evalTailExpression(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
+		if val, ok := val.(*object.ReturnValue); ok {
+			return val
+		}
 		return &object.ReturnValue{Value: val}
 
+	case *ast.ImportStatement:
+		mod, ok := env.Import(node.Path.Value)
+		if !ok {
+			return newError("cannot import %q without a module loader", node.Path.Value)
+		}
+		if isError(mod) {
+			return mod
+		}
+		env.Set(node.Name.Value, mod)
+
+	case *ast.ExportStatement:
+		// The exports are collected once the whole module has been evaluated.
+
+	case *ast.StructStatement:
+		fields := make([]string, len(node.Fields))
+		for i, field := range node.Fields {
+			fields[i] = field.Value
+		}
+		env.Set(node.Name.Value, &object.Struct{Name: node.Name.Value, Fields: fields, Methods: map[string]object.Object{}})
+
+	case *ast.ImplStatement:
+		return evalImplStatement(node, env)
+
+	case *ast.EnumStatement:
+		enum := &object.Enum{Name: node.Name.Value}
+		for _, variant := range node.Variants {
+			fields := make([]string, len(variant.Fields))
+			for i, field := range variant.Fields {
+				fields[i] = field.Value
+			}
+			enum.AddVariant(variant.Name.Value, fields)
+		}
+		env.Set(node.Name.Value, enum)
+
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return &object.Exception{Value: val}
+
+	case *ast.YieldStatement:
+		return evalYieldStatement(node, env)
+
 	// Expressions
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -74,6 +153,30 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return evalIndexExpression(left, index)
 
+	case *ast.MemberExpression:
+		obj := Eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		return evalMemberExpression(obj, node.Member.Value)
+
+	case *ast.SliceExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		bounds := []object.Object{NULL, NULL}
+		for i, bound := range []ast.Expression{node.Low, node.High} {
+			if bound == nil {
+				continue
+			}
+			bounds[i] = Eval(bound, env)
+			if isError(bounds[i]) {
+				return bounds[i]
+			}
+		}
+		return evalSliceExpression(left, bounds[0], bounds[1])
+
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
 		if isError(right) {
@@ -86,12 +189,21 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(left) {
 			return left
 		}
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, left, env)
+		}
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalInfixExpression(node.Operator, left, right)
 
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 
@@ -101,16 +213,29 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{
+			Parameters: params,
+			Patterns:   node.Patterns,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
+			Body:       body,
+			Env:        env,
+			Generator:  node.Generator,
+		}
+
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+
+	case *ast.MacroLiteral:
+		return newError("macros can only be defined with a top-level let")
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
+		if isCallTo(node, "quote") {
+			return quote(node, env)
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+		function, args, err := evalCallee(node, env)
+		if err != nil {
+			return err
 		}
 		return applyFunction(function, args)
 
@@ -137,9 +262,13 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
-			return result.Value
-		case *object.Error:
-			return result
+			value := unwrapReturnValue(result)
+			if exception, ok := value.(*object.Exception); ok {
+				return exception.Uncaught()
+			}
+			return value
+		case *object.Exception:
+			return result.Uncaught()
 		}
 	}
 
@@ -155,22 +284,400 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 // Returns:
 //   - object.Object: The result of evaluating the body.
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	return evalStatements(block.Statements, env)
+}
+
+// evalStatements evaluates the statements of a block, stopping at a statement that returns,
+// throws or leaves a loop.
+//
+// Parameters:
+//   - statements: The statements to evaluate.
+//   - env: The environment.
+//
+// Returns:
+//   - object.Object: The result of the last evaluated statement.
+func evalStatements(statements []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
-	for _, statement := range block.Statements {
+	for _, statement := range statements {
 		result = Eval(statement, env)
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.EXCEPTION_OBJ || rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
+		}
+	}
+
+	return result
+}
+
+// evalTailBlock evaluates a block in tail position: the body of a function, or a branch of
+// an if expression or the body of a match arm ending such a block. The value of the last statement is the result of the
+// function, so a call there is returned as a tail call for the caller of the function to make.
+//
+// Parameters:
+//   - block: The block to evaluate.
+//   - env: The environment.
+//
+// Returns:
+//   - object.Object: The result of the block, which may be a return value holding a tail call.
+func evalTailBlock(block *ast.BlockStatement, env *object.Environment) object.Object {
+	last := len(block.Statements) - 1
+	if last < 0 {
+		return nil
+	}
+	stmt, ok := block.Statements[last].(*ast.ExpressionStatement)
+	if !ok {
+		return evalStatements(block.Statements, env)
+	}
+
+	result := evalStatements(block.Statements[:last], env)
+	if result != nil {
+		rt := result.Type()
+		if rt == object.RETURN_VALUE_OBJ || rt == object.EXCEPTION_OBJ || rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 			return result
 		}
 	}
 
+	return evalTailExpression(stmt.Expression, env)
+}
+
+// evalTailExpression evaluates an expression in tail position. A call there is returned as
+// a tail call, and so is a call in tail position of a branch of an if expression, of the
+// body of a match arm, or of the right hand side of && or ||.
+//
+// Parameters:
+//   - expr: The expression to evaluate.
+//   - env: The environment.
+//
+// Returns:
+//   - object.Object: The value of the expression, which may be a return value holding a
+//     tail call.
+func evalTailExpression(expr ast.Expression, env *object.Environment) object.Object {
+	switch node := expr.(type) {
+	case *ast.CallExpression:
+		return evalTailCall(node, env)
+	case *ast.IfExpression:
+		condition := Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return evalTailBlock(node.Consequence, env)
+		} else if node.Alternative != nil {
+			return evalTailBlock(node.Alternative, env)
+		}
+		return NULL
+	case *ast.MatchExpression:
+		arm, armEnv, err := evalMatchArm(node, env)
+		if arm == nil {
+			return err
+		}
+		return evalTailBlock(arm.Body, armEnv)
+	case *ast.InfixExpression:
+		if node.Operator != "&&" && node.Operator != "||" {
+			return Eval(node, env)
+		}
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		if isTruthy(left) == (node.Operator == "||") {
+			return left
+		}
+		return evalTailExpression(node.Right, env)
+	default:
+		return Eval(node, env)
+	}
+}
+
+// evalTailCall evaluates the function and the arguments of a call in tail position, leaving
+// the call itself to the caller of the function containing it.
+//
+// Parameters:
+//   - node: The call expression.
+//   - env: The environment.
+//
+// Returns:
+//   - object.Object: A return value holding the tail call, or an error.
+func evalTailCall(node *ast.CallExpression, env *object.Environment) object.Object {
+	if isCallTo(node, "quote") {
+		quoted := quote(node, env)
+		if isError(quoted) {
+			return quoted
+		}
+		return &object.ReturnValue{Value: quoted}
+	}
+
+	function, args, err := evalCallee(node, env)
+	if err != nil {
+		return err
+	}
+	return &object.ReturnValue{Value: &object.TailCall{Function: function, Arguments: args}}
+}
+
+// evalCallee evaluates the function and the arguments of a call.
+//
+// Parameters:
+//   - node: The call expression.
+//   - env: The environment.
+//
+// Returns:
+//   - object.Object: The called function.
+//   - []object.Object: The arguments.
+//   - object.Object: An error if the function or an argument could not be evaluated, or nil.
+func evalCallee(node *ast.CallExpression, env *object.Environment) (object.Object, []object.Object, object.Object) {
+	function := Eval(node.Function, env)
+	if isError(function) {
+		return nil, nil, function
+	}
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return nil, nil, args[0]
+	}
+	return function, args, nil
+}
+
+// evalTryExpression runs the body of a try expression, handing anything thrown from it to
+// the catch block. The finally block runs last however the body and catch block end, and
+// only replaces their result when it throws or leaves through return, break or continue.
+//
+// Parameters:
+//   - node: The try expression.
+//   - env: The environment to evaluate the blocks in.
+//
+// Returns:
+//   - object.Object: The value of the body or catch block, or the exception still being thrown.
+func evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	// A tail call returned from the blocks is made here, so that it runs inside the try.
+	result := callTailCall(Eval(node.Body, env))
+
+	if exception, ok := result.(*object.Exception); ok && node.Catch != nil {
+		if node.CatchParam != nil {
+			env.Set(node.CatchParam.Value, exception.Value)
+		}
+		result = callTailCall(Eval(node.Catch, env))
+	}
+
+	if node.Finally != nil {
+		finally := Eval(node.Finally, env)
+		if finally != nil {
+			switch finally.Type() {
+			case object.EXCEPTION_OBJ, object.RETURN_VALUE_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+				return finally
+			}
+		}
+	}
+
+	if result == nil {
+		return NULL
+	}
 	return result
 }
 
+// evalImplStatement adds the methods of an impl statement to a struct type.
+//
+// Parameters:
+//   - node: The impl statement.
+//   - env: The environment the methods are defined in.
+//
+// Returns:
+//   - object.Object: nil, or an error if the name does not refer to a struct type.
+func evalImplStatement(node *ast.ImplStatement, env *object.Environment) object.Object {
+	obj := evalIdentifier(node.Name, env)
+	if isError(obj) {
+		return obj
+	}
+
+	st, ok := obj.(*object.Struct)
+	if !ok {
+		return newError("impl target must be STRUCT, got %s", obj.Type())
+	}
+
+	for _, method := range node.Methods {
+		st.Methods[method.Name.Value] = Eval(method.Function, env)
+	}
+
+	return nil
+}
+
+// evalMemberExpression looks up a member of a value: a field or method of a struct value,
+// a variant of an enum type, a field of the payload of a variant, or an export of a module.
+//
+// Parameters:
+//   - obj: The value whose member is accessed.
+//   - name: The name of the member.
+//
+// Returns:
+//   - object.Object: The value of the member, or an error if there is no such member.
+func evalMemberExpression(obj object.Object, name string) object.Object {
+	switch obj := obj.(type) {
+	case *object.Instance:
+		if index := obj.Struct.FieldIndex(name); index >= 0 {
+			return obj.Field(index)
+		}
+		if method, ok := obj.Struct.Methods[name]; ok {
+			return &object.BoundMethod{Receiver: obj, Method: method}
+		}
+		return newError("%s has no field or method %s", obj.Struct.Name, name)
+	case *object.Enum:
+		variant := obj.Variant(name)
+		if variant == nil {
+			return newError("%s has no variant %s", obj.Name, name)
+		}
+		if variant.Value != nil {
+			return variant.Value
+		}
+		return variant
+	case *object.Variant:
+		if index := obj.Kind.FieldIndex(name); index >= 0 {
+			return obj.Payload[index]
+		}
+		return newError("%s has no field %s", obj.Kind.Name(), name)
+	case *object.Module:
+		return evalModuleIndexExpression(obj, &object.String{Value: name})
+	case *object.Error:
+		return evalErrorIndexExpression(obj, &object.String{Value: name})
+	default:
+		return newError("member access not supported: %s", obj.Type())
+	}
+}
+
+// evalWhileStatement runs the body of a while loop for as long as its condition is truthy.
+//
+// Parameters:
+//   - node: The while statement.
+//   - env: The environment.
+//
+// Returns:
+//   - object.Object: NULL, or the error or return value that ended the loop.
+func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result, done := evalLoopBody(node.Body, env)
+		if done {
+			return result
+		}
+	}
+}
+
+// evalForStatement runs a C-style for loop. The update expression also runs after a continue.
+// The loop runs in an environment of its own, so the bindings of its init statement and its
+// body are not seen after it.
+//
+// Parameters:
+//   - node: The for statement.
+//   - env: The environment.
+//
+// Returns:
+//   - object.Object: NULL, or the error or return value that ended the loop.
+func evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	env = object.NewEnclosedEnvironment(env)
+	if node.Init != nil {
+		init := Eval(node.Init, env)
+		if isError(init) {
+			return init
+		}
+	}
+
+	for {
+		if node.Condition != nil {
+			condition := Eval(node.Condition, env)
+			if isError(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				return NULL
+			}
+		}
+
+		result, done := evalLoopBody(node.Body, env)
+		if done {
+			return result
+		}
+
+		if node.Update != nil {
+			update := Eval(node.Update, env)
+			if isError(update) {
+				return update
+			}
+		}
+	}
+}
+
+// evalForInStatement runs the body of a for-in loop once for each element of an array,
+// each key of a hash in sorted order, or each character of a string. The loop runs in an
+// environment of its own, so its variable and the bindings of its body are not seen after
+// it. The variable, like a let in the body, is a single binding for all iterations, so a
+// closure created in the body sees the value it was given last, as on the virtual machine.
+//
+// Parameters:
+//   - node: The for-in statement.
+//   - env: The environment.
+//
+// Returns:
+//   - object.Object: NULL, or the error or return value that ended the loop.
+func evalForInStatement(node *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	iterator, ok := object.NewIterator(iterable)
+	if !ok {
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	env = object.NewEnclosedEnvironment(env)
+	for {
+		value, ok := iterator.Next()
+		if !ok {
+			return NULL
+		}
+		if isError(value) {
+			return value
+		}
+		env.Set(node.Variable.Value, value)
+
+		result, done := evalLoopBody(node.Body, env)
+		if done {
+			return result
+		}
+	}
+}
+
+// evalLoopBody evaluates one iteration of a loop body.
+//
+// Parameters:
+//   - body: The loop body.
+//   - env: The environment.
+//
+// Returns:
+//   - object.Object: The value the loop should return when it has to stop.
+//   - bool: Whether the loop has to stop, because of a break, an error or a return.
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+	result := Eval(body, env)
+	if result == nil {
+		return nil, false
+	}
+
+	switch result.Type() {
+	case object.BREAK_OBJ:
+		return NULL, true
+	case object.RETURN_VALUE_OBJ, object.EXCEPTION_OBJ:
+		return result, true
+	}
+	return nil, false
+}
+
 // nativeBoolToBooleanObject is a helper function that converts a go bool type to a monkey Boolean.
 //
 // Parameters:
@@ -234,12 +741,14 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 // Returns:
 //   - object.Object: The result of evaluating the integer negation operation.
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 // evalInfixExpression handles the evaluation for all infix expressions
@@ -255,6 +764,8 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
@@ -264,8 +775,195 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalInterpolatedString evaluates each part of an interpolated string and joins
+// their string representations.
+//
+// Parameters:
+//   - node: The interpolated string.
+//   - env: The environment to evaluate the embedded expressions in.
+//
+// Returns:
+//   - object.Object: The resulting string, or the first error encountered.
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+
+	for _, part := range node.Parts {
+		value := Eval(part, env)
+		if isError(value) {
+			return value
+		}
+		out.WriteString(value.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+// evalAssignExpression evaluates an assignment to an existing binding. A compound
+// assignment such as x += 1 applies the operator to the current value first.
+//
+// Parameters:
+//   - node: The assign expression.
+//   - env: The environment to evaluate the assignment in.
+//
+// Returns:
+//   - object.Object: The assigned value, or an error.
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	if index, ok := node.Target.(*ast.IndexExpression); ok {
+		return evalIndexAssignment(node, index, env)
+	}
+	if member, ok := node.Target.(*ast.MemberExpression); ok {
+		return evalMemberAssignment(node, member, env)
+	}
+
+	ident := node.Target.(*ast.Identifier)
+
+	current, ok := env.Get(ident.Value)
+	if !ok {
+		if _, ok := lookupBuiltin(ident.Value); ok {
+			return newError("cannot assign to builtin: %s", ident.Value)
+		}
+		return newError("identifier not found: %s", ident.Value)
+	}
+
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if node.Operator != "=" {
+		val = evalInfixExpression(strings.TrimSuffix(node.Operator, "="), current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	env.Assign(ident.Value, val)
+	return val
+}
+
+// evalMemberAssignment evaluates an assignment to a field of a struct value, changing the
+// value in place. The value whose field is assigned is evaluated once, before the new value.
+//
+// Parameters:
+//   - node: The assign expression.
+//   - target: The member expression being assigned to.
+//   - env: The environment to evaluate the assignment in.
+//
+// Returns:
+//   - object.Object: The assigned value, or an error.
+func evalMemberAssignment(node *ast.AssignExpression, target *ast.MemberExpression, env *object.Environment) object.Object {
+	obj := Eval(target.Object, env)
+	if isError(obj) {
+		return obj
+	}
+
+	instance, ok := obj.(*object.Instance)
+	if !ok {
+		return newError("member assignment not supported: %s", obj.Type())
+	}
+	index := instance.Struct.FieldIndex(target.Member.Value)
+	if index < 0 {
+		return newError("%s has no field %s", instance.Struct.Name, target.Member.Value)
+	}
+
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if node.Operator != "=" {
+		val = evalInfixExpression(strings.TrimSuffix(node.Operator, "="), instance.Field(index), val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	instance.SetField(index, val)
+	return val
+}
+
+// evalIndexAssignment evaluates an assignment to an element of an array or hash, changing
+// the collection in place. The collection and index are evaluated once, before the value.
+//
+// Parameters:
+//   - node: The assign expression.
+//   - target: The index expression being assigned to.
+//   - env: The environment to evaluate the assignment in.
+//
+// Returns:
+//   - object.Object: The assigned value, or an error.
+func evalIndexAssignment(node *ast.AssignExpression, target *ast.IndexExpression, env *object.Environment) object.Object {
+	left := Eval(target.Left, env)
+	if isError(left) {
+		return left
+	}
+	index := Eval(target.Index, env)
+	if isError(index) {
+		return index
+	}
+
+	var current object.Object
+	if node.Operator != "=" {
+		current = evalIndexExpression(left, index)
+		if isError(current) {
+			return current
+		}
+	}
+
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if node.Operator != "=" {
+		val = evalInfixExpression(strings.TrimSuffix(node.Operator, "="), current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		integer, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		if !left.SetAt(integer.Value, val) {
+			return newError("index out of range: %d", integer.Value)
+		}
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		left.Set(key.HashKey(), object.HashPair{Key: index, Value: val})
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+
+	return val
+}
+
+// evalLogicalExpression evaluates && and || expressions. The right hand side is only
+// evaluated when the left hand side does not decide the result, and the result is the
+// value of the operand that decided it.
+//
+// Parameters:
+//   - node: The infix expression with a && or || operator.
+//   - left: The already evaluated left hand side.
+//   - env: The environment to evaluate the right hand side in.
+//
+// Returns:
+//   - object.Object: The result of evaluating the logical expression.
+func evalLogicalExpression(node *ast.InfixExpression, left object.Object, env *object.Environment) object.Object {
+	if isTruthy(left) == (node.Operator == "||") {
+		return left
 	}
+	return Eval(node.Right, env)
 }
 
 // evalIntegerInfixExpression evaluates all infix expressions for the integer type.
@@ -289,11 +987,65 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalFloatInfixExpression evaluates all infix expressions for the float type.
+// An integer operand is converted to a float first.
+//
+// Parameters:
+//   - operator: The operator for the expression.
+//   - left: The left hand side expression.
+//   - right: The right hand side expression.
+//
+// Returns:
+//   - object.Object: The result of evaluating the infix expression.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -303,6 +1055,31 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
+// isNumber checks to see if an object is an integer or a float.
+//
+// Parameters:
+//   - obj: The object to check.
+//
+// Returns:
+//   - bool: True when the object is numeric, otherwise false.
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat converts a numeric object to a float64.
+//
+// Parameters:
+//   - obj: An integer or float object.
+//
+// Returns:
+//   - float64: The value of the object as a float.
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
@@ -337,6 +1114,223 @@ func evalIfExpression(i *ast.IfExpression, env *object.Environment) object.Objec
 	}
 }
 
+// evalMatchExpression evaluates the body of the first arm whose pattern matches the subject
+// and whose guard, if any, is truthy. Each arm gets its own environment for the names its
+// pattern binds. Before any arm is tried the variant patterns are checked against their enum
+// types, and a match over an enum has to cover all of its variants.
+//
+// Parameters:
+//   - node: The match expression.
+//   - env: The environment.
+//
+// Returns:
+//   - object.Object: The result of the chosen arm, or NULL when no arm matches.
+func evalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+	arm, armEnv, result := evalMatchArm(node, env)
+	if arm == nil {
+		return result
+	}
+	return Eval(arm.Body, armEnv)
+}
+
+// evalMatchArm chooses the arm of a match expression whose body is evaluated, without
+// evaluating it.
+//
+// Parameters:
+//   - node: The match expression.
+//   - env: The environment.
+//
+// Returns:
+//   - *ast.MatchArm: The chosen arm, or nil when no arm matches or an error occurred.
+//   - *object.Environment: The environment holding the names the pattern of the arm binds.
+//   - object.Object: NULL when no arm matches, an error, or nil when an arm was chosen.
+func evalMatchArm(node *ast.MatchExpression, env *object.Environment) (*ast.MatchArm, *object.Environment, object.Object) {
+	subject := Eval(node.Subject, env)
+	if isError(subject) {
+		return nil, nil, subject
+	}
+
+	if err := checkMatchExhaustive(node, subject, env); err != nil {
+		return nil, nil, err
+	}
+
+	for _, arm := range node.Arms {
+		armEnv := object.NewEnclosedEnvironment(env)
+		if bindPattern(arm.Pattern, subject, armEnv) != nil {
+			continue
+		}
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) {
+				return nil, nil, guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return arm, armEnv, nil
+	}
+
+	return nil, nil, NULL
+}
+
+// checkMatchExhaustive checks that every variant pattern in the arms of a match expression
+// names a variant of an enum type with the right number of fields, and, when the subject is
+// a variant, that the arms cover all variants of each enum type they match against, unless
+// an arm matches any value.
+//
+// Parameters:
+//   - node: The match expression.
+//   - subject: The value matched.
+//   - env: The environment the enum types are looked up in.
+//
+// Returns:
+//   - object.Object: nil, or an error describing the first problem found.
+func checkMatchExhaustive(node *ast.MatchExpression, subject object.Object, env *object.Environment) object.Object {
+	for _, arm := range node.Arms {
+		for _, pattern := range ast.VariantPatterns(arm.Pattern) {
+			if _, err := evalVariantPattern(pattern, env); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, ok := subject.(*object.Variant); !ok {
+		return nil
+	}
+
+	for _, coverage := range node.Coverage() {
+		enum := Eval(coverage.Enum, env).(*object.Enum) // Checked with the patterns above.
+		if missing := enum.MissingVariants(coverage.Covered); len(missing) > 0 {
+			return newError("non-exhaustive match on %s: missing %s", enum.Name, strings.Join(missing, ", "))
+		}
+	}
+
+	return nil
+}
+
+// evalVariantPattern looks up the variant a variant pattern matches.
+//
+// Parameters:
+//   - pattern: The variant pattern.
+//   - env: The environment the enum type is looked up in.
+//
+// Returns:
+//   - *object.VariantType: The variant.
+//   - *object.Exception: An error if the pattern does not name a variant of an enum type, or
+//     has the wrong number of fields.
+func evalVariantPattern(pattern *ast.VariantPattern, env *object.Environment) (*object.VariantType, *object.Exception) {
+	obj := Eval(pattern.Enum, env)
+	if isError(obj) {
+		return nil, obj.(*object.Exception)
+	}
+
+	variant, err := object.LookupVariant(obj, pattern.Tag.Value, len(pattern.Fields))
+	if err != nil {
+		return nil, newError("%s", err)
+	}
+
+	return variant, nil
+}
+
+// bindPattern matches a value against a pattern, binding the names in the pattern as it goes.
+// It is used both to choose the arm of a match expression and to destructure let bindings
+// and function parameters.
+//
+// Parameters:
+//   - pattern: The pattern to match against.
+//   - value: The value to match.
+//   - env: The environment the names in the pattern are bound in.
+//
+// Returns:
+//   - *object.Exception: nil when the value matches the pattern, otherwise an error describing the mismatch.
+func bindPattern(pattern ast.Pattern, value object.Object, env *object.Environment) *object.Exception {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return nil
+
+	case *ast.BindingPattern:
+		env.Set(pattern.Name.Value, value)
+		return nil
+
+	case *ast.LiteralPattern:
+		literal := Eval(pattern.Value, env)
+		if !object.Equals(literal, value) {
+			return newError("%s does not match %s", value.Inspect(), literal.Inspect())
+		}
+		return nil
+
+	case *ast.ArrayPattern:
+		array, ok := value.(*object.Array)
+		if !ok {
+			return newError("cannot destructure %s as an array", value.Type())
+		}
+		elements := array.Snapshot()
+
+		n := len(pattern.Elements)
+		if pattern.HasRest && len(elements) < n {
+			return newError("cannot destructure an array of length %d, expected length of at least %d", len(elements), n)
+		}
+		if !pattern.HasRest && len(elements) != n {
+			return newError("cannot destructure an array of length %d, expected length %d", len(elements), n)
+		}
+
+		for i, element := range pattern.Elements {
+			if err := bindPattern(element, elements[i], env); err != nil {
+				return err
+			}
+		}
+
+		if pattern.Rest != nil {
+			env.Set(pattern.Rest.Value, &object.Array{Elements: elements[n:]})
+		}
+		return nil
+
+	case *ast.VariantPattern:
+		kind, err := evalVariantPattern(pattern, env)
+		if err != nil {
+			return err
+		}
+
+		variant, ok := value.(*object.Variant)
+		if !ok || variant.Kind != kind {
+			return newError("%s does not match %s", value.Inspect(), kind.Name())
+		}
+
+		for i, field := range pattern.Fields {
+			if err := bindPattern(field, variant.Payload[i], env); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *ast.HashPattern:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return newError("cannot destructure %s as a hash", value.Type())
+		}
+
+		for i, key := range pattern.Keys {
+			// The parser only accepts hashable literals as keys.
+			hashKey := Eval(key, env).(object.Hashable)
+
+			pair, ok := hash.Get(hashKey.HashKey())
+			if !ok {
+				return newError("missing hash key: %s", key.String())
+			}
+
+			if err := bindPattern(pattern.Values[i], pair.Value, env); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return newError("unknown pattern: %T", pattern)
+}
+
 // isTruthy evaluates the input object and determines if is a "truthy" value.
 //
 // Parameters:
@@ -357,28 +1351,29 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-// newError creates a new Error object with the provided message.
+// newError creates a new Error object with the provided message, thrown as an exception.
 //
 // Parameters:
 //   - format: The format string used to create the error message.
 //   - a: Arguments to the format string.
 //
 // Returns:
-//   - *object.Error: The error object with a formatted error message.
-func newError(format string, a ...any) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+//   - *object.Exception: The exception holding the error with a formatted error message.
+func newError(format string, a ...any) *object.Exception {
+	return &object.Exception{Value: &object.Error{Message: fmt.Sprintf(format, a...)}}
 }
 
-// isError checks to see if the input object is an error type.
+// isError checks to see if the input object is an exception being thrown. Error values that
+// are not being thrown, such as those made by error(msg) or bound by a catch, are not.
 //
 // Parameters:
-//   - obj: The input object which may be an error.
+//   - obj: The input object which may be an exception.
 //
 // Returns:
-//   - bool: True when the input is an error type, otherwise false.
+//   - bool: True when the input is an exception, otherwise false.
 func isError(obj object.Object) bool {
 	if obj != nil {
-		return obj.Type() == object.ERROR_OBJ
+		return obj.Type() == object.EXCEPTION_OBJ
 	}
 
 	return false
@@ -393,10 +1388,16 @@ func isError(obj object.Object) bool {
 // Returns:
 //   - object.Object: The found value or an error.
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if node.Builtin {
+		if builtin, ok := lookupBuiltin(node.Value); ok {
+			return builtin
+		}
+		return newError("builtin not found: %s", node.Value)
+	}
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := lookupBuiltin(node.Value); ok {
 		return builtin
 	}
 
@@ -415,6 +1416,20 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	var result []object.Object
 
 	for _, e := range exps {
+		if spread, ok := e.(*ast.SpreadExpression); ok {
+			evaluated := Eval(spread.Value, env)
+			if isError(evaluated) {
+				return []object.Object{evaluated}
+			}
+
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				return []object.Object{newError("cannot spread %s", evaluated.Type())}
+			}
+			result = append(result, array.Snapshot()...)
+			continue
+		}
+
 		evaluated := Eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
@@ -436,19 +1451,70 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 func applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		return callFunction(fn, args)
 	case *object.Builtin:
-		if result := fn.Fn(args...); result != nil {
+		if result := fn.Apply(applyFunction, args...); result != nil {
 			return result
 		}
 		return NULL
+	case *object.Struct:
+		if len(args) != len(fn.Fields) {
+			return newError("wrong number of arguments to %s: want=%d, got=%d", fn.Name, len(fn.Fields), len(args))
+		}
+		return &object.Instance{Struct: fn, Fields: append([]object.Object{}, args...)}
+	case *object.BoundMethod:
+		return applyFunction(fn.Method, append([]object.Object{fn.Receiver}, args...))
+	case *object.VariantType:
+		if len(args) != len(fn.Fields) {
+			return newError("wrong number of arguments to %s: want=%d, got=%d", fn.Name(), len(fn.Fields), len(args))
+		}
+		return &object.Variant{Kind: fn, Payload: append([]object.Object{}, args...)}
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
+// callFunction calls a function written in Monkey. A tail call returned by the body is made
+// by the loop here instead of by a nested call, so that tail-recursive functions run in
+// constant Go stack depth.
+//
+// Parameters:
+//   - fn: The function to be called.
+//   - args: The arguments to pass to the function call.
+//
+// Returns:
+//   - object.Object: The result of calling the function.
+func callFunction(fn *object.Function, args []object.Object) object.Object {
+	for {
+		if fn.Generator {
+			if err := checkArity(fn, len(args)); err != nil {
+				return err
+			}
+			return newGenerator(fn, args)
+		}
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+
+		evaluated := evalTailBlock(fn.Body, extendedEnv)
+		tail, ok := tailCall(evaluated)
+		if !ok {
+			return unwrapReturnValue(evaluated)
+		}
+
+		next, nextArgs := tail.Function, tail.Arguments
+		if bound, ok := next.(*object.BoundMethod); ok {
+			next, nextArgs = bound.Method, append([]object.Object{bound.Receiver}, nextArgs...)
+		}
+		function, ok := next.(*object.Function)
+		if !ok {
+			return applyFunction(next, nextArgs)
+		}
+		fn, args = function, nextArgs
+	}
+}
+
 // extendFunctionEnv encloses the functions environment with a new one that has the arguments defined.
 //
 // Parameters:
@@ -457,13 +1523,90 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 //
 // Returns:
 //   - *object.Environment: The extended environment.
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+//   - *object.Exception: An error if an argument does not match the pattern of its parameter.
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Exception) {
+	if err := checkArity(fn, len(args)); err != nil {
+		return nil, err
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for idx, param := range fn.Parameters {
-		env.Set(param.Value, args[idx])
+		if idx < len(args) {
+			env.Set(param.Value, args[idx])
+			continue
+		}
+
+		// Defaults are evaluated in the new environment, so they can refer to earlier parameters.
+		val := Eval(fn.Defaults[idx], env)
+		if err, ok := val.(*object.Exception); ok {
+			return nil, err
+		}
+		env.Set(param.Value, val)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	for idx, pattern := range fn.Patterns {
+		if pattern == nil {
+			continue
+		}
+		// The argument may have come from a default value, so it is read back from the environment.
+		arg, _ := env.Get(fn.Parameters[idx].Value)
+		if err := bindPattern(pattern, arg, env); err != nil {
+			return nil, err
+		}
+	}
+
+	return env, nil
+}
+
+// checkArity checks the number of arguments passed to a function.
+//
+// Parameters:
+//   - fn: The function.
+//   - got: The number of arguments passed.
+//
+// Returns:
+//   - *object.Exception: The error describing the expected number of arguments, or nil.
+func checkArity(fn *object.Function, got int) *object.Exception {
+	required := 0
+	for idx := range fn.Parameters {
+		if idx >= len(fn.Defaults) || fn.Defaults[idx] == nil {
+			required++
+		}
+	}
+
+	if got < required || (fn.Rest == nil && got > len(fn.Parameters)) {
+		return arityError(required, len(fn.Parameters), fn.Rest != nil, got)
 	}
+	return nil
+}
 
-	return env
+// arityError creates the error for a call with the wrong number of arguments.
+//
+// Parameters:
+//   - required: The number of parameters without a default value.
+//   - total: The number of parameters, not counting a rest parameter.
+//   - variadic: Whether the function has a rest parameter.
+//   - got: The number of arguments passed.
+//
+// Returns:
+//   - *object.Exception: The error describing the expected number of arguments.
+func arityError(required, total int, variadic bool, got int) *object.Exception {
+	switch {
+	case variadic:
+		return newError("wrong number of arguments: want at least %d, got=%d", required, got)
+	case required != total:
+		return newError("wrong number of arguments: want between %d and %d, got=%d", required, total, got)
+	default:
+		return newError("wrong number of arguments: want=%d, got=%d", total, got)
+	}
 }
 
 // unwrapReturnValue unwraps the return value when it exists
@@ -476,6 +1619,7 @@ func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Enviro
 // Returns:
 //   - object.Object: A return value or the input object.
 func unwrapReturnValue(obj object.Object) object.Object {
+	obj = callTailCall(obj)
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
 		return returnValue.Value
 	}
@@ -483,6 +1627,44 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
+// tailCall gets the tail call held by a return value.
+//
+// Parameters:
+//   - obj: The object which may be a return value holding a tail call.
+//
+// Returns:
+//   - *object.TailCall: The tail call.
+//   - bool: False when the object does not hold a tail call.
+func tailCall(obj object.Object) (*object.TailCall, bool) {
+	if returnValue, ok := obj.(*object.ReturnValue); ok {
+		tail, ok := returnValue.Value.(*object.TailCall)
+		return tail, ok
+	}
+	return nil, false
+}
+
+// callTailCall makes the call held by a return value, for code returning from a function
+// body in a way the loop in callFunction does not see, such as the body of a try expression.
+//
+// Parameters:
+//   - obj: The object which may be a return value holding a tail call.
+//
+// Returns:
+//   - object.Object: A return value holding the result of the call, the error it threw, or
+//     the input object when it holds no tail call.
+func callTailCall(obj object.Object) object.Object {
+	tail, ok := tailCall(obj)
+	if !ok {
+		return obj
+	}
+
+	result := applyFunction(tail.Function, tail.Arguments)
+	if isError(result) {
+		return result
+	}
+	return &object.ReturnValue{Value: result}
+}
+
 // evalIndexExpression evaluates an indexing expression like items[1]
 //
 // Parameters:
@@ -495,8 +1677,14 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.ERROR_OBJ:
+		return evalErrorIndexExpression(left, index)
+	case left.Type() == object.MODULE_OBJ:
+		return evalModuleIndexExpression(left, index)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
@@ -511,15 +1699,66 @@ func evalIndexExpression(left, index object.Object) object.Object {
 // Returns:
 //   - object.Object: The object that was at the index position in the array or null.
 func evalArrayIndexExpression(array, index object.Object) object.Object {
-	arrayObject := array.(*object.Array)
+	element, ok := array.(*object.Array).At(index.(*object.Integer).Value)
+	if !ok {
+		return NULL
+	}
+	return element
+}
+
+// evalStringIndexExpression is used to evaluate an indexing expression on a string.
+// Strings are indexed by code point, not by byte.
+//
+// Parameters:
+//   - str: the string which contains the character we need.
+//   - index: the code point position of the character.
+//
+// Returns:
+//   - object.Object: A string holding the character at the index position or null.
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
 	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements)) - 1
+	length := int64(len(runes))
 
-	if idx < 0 || idx > max {
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
 		return NULL
 	}
 
-	return arrayObject.Elements[idx]
+	return &object.String{Value: string(runes[idx])}
+}
+
+// evalSliceExpression is used to evaluate a slice of an array or a string.
+// Strings are sliced by code point, not by byte.
+//
+// Parameters:
+//   - left: the array or string being sliced.
+//   - low: the first index of the slice, or null to start at the beginning.
+//   - high: the index one past the end of the slice, or null to run to the end.
+//
+// Returns:
+//   - object.Object: A new array or string holding the slice, or an error.
+func evalSliceExpression(left, low, high object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		elements := left.Snapshot()
+		lo, hi, err := object.SliceBounds(len(elements), low, high)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return &object.Array{Elements: elements[lo:hi]}
+	case *object.String:
+		runes := []rune(left.Value)
+		lo, hi, err := object.SliceBounds(len(runes), low, high)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return &object.String{Value: string(runes[lo:hi])}
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
 }
 
 // evalHashLiteral evaluates a hash literal.
@@ -571,7 +1810,7 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key.HashKey())
 
 	if !ok {
 		return NULL
@@ -579,3 +1818,32 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 
 	return pair.Value
 }
+
+// evalErrorIndexExpression gets a field of a caught error, as e["message"] or e.message:
+// "message" is its message and "data" the value it was created with. Any other field is null.
+//
+// Parameters:
+//   - err: The error to get the field from.
+//   - index: The name of the field.
+//
+// Returns:
+//   - object.Object: The value of the field or null.
+func evalErrorIndexExpression(err, index object.Object) object.Object {
+	errorObject := err.(*object.Error)
+	field, ok := index.(*object.String)
+	if !ok {
+		return NULL
+	}
+
+	switch field.Value {
+	case "message":
+		return &object.String{Value: errorObject.Message}
+	case "data":
+		if errorObject.Data == nil {
+			return NULL
+		}
+		return errorObject.Data
+	default:
+		return NULL
+	}
+}
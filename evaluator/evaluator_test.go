@@ -1,7 +1,10 @@
 package evaluator
 
 import (
+	"monkey/internal/hosttest"
+	"monkey/lexer"
 	"monkey/object"
+	"monkey/parser"
 	"testing"
 )
 
@@ -25,6 +28,9 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 + 10 % 4", 4},
 	}
 
 	for _, tt := range tests {
@@ -33,6 +39,57 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 2", 2},
+		{"false && 2", false},
+		{"1 || 2", 1},
+		{"if (false) { 1 } || 3", 3},
+		{"1 < 2 && 2 < 3", true},
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"let x = 0; let f = fn() { let x = 1; true }; false && f(); x", 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"2.5", 2.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3.0},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"10 - 2.5", 7.5},
+		{"7 / 2.0", 3.5},
+		{"(1 + 2 + 3) / 4.0", 1.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -48,6 +105,16 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"1 != 1", false},
 		{"1 == 2", false},
 		{"1 != 2", true},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"2.5 >= 2", true},
+		{"1.5 < 2", true},
+		{"2 > 2.5", false},
+		{"1 == 1.0", true},
+		{"0.1 + 0.2 != 0.3", true},
 		{"true == true", true},
 		{"false == false", true},
 		{"true == false", false},
@@ -96,6 +163,8 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (true) { 10; 20 }", 20},
+		{"if (false) { 10 } else { let x = 5; x; x + 1 }", 6},
 	}
 
 	for _, tt := range tests {
@@ -109,6 +178,110 @@ func TestIfElseExpressions(t *testing.T) {
 	}
 }
 
+func TestDefaultAndVariadicParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let f = fn(a, b = 10) { a + b }; f(1) + f(1, 2)", 14},
+		{"let f = fn(a, b = a * 2, c = b + 1) { a + b + c }; f(1)", 6},
+		{"let f = fn(a, ...rest) { a + len(rest) }; f(1) + f(1, 2, 3)", 4},
+		{"let sum = fn(...xs) { let s = 0; for (x in xs) { s += x; } s }; sum(1, 2, 3, 4)", 10},
+		{"let f = fn(...r) { r }; len(f())", 0},
+		{"let args = [1, 2, 3]; let f = fn(a, b, c) { a * 100 + b * 10 + c }; f(...args)", 123},
+		{"let xs = [1, 2]; let ys = [0, ...xs, 3, ...xs]; len(ys) * 100 + ys[3] * 10 + ys[4]", 631},
+		{"let f = fn(a, b = 2, ...rest) { a + b + len(rest) }; f(...[1]) + f(1, 5, 7, 8)", 11},
+		{"let count = fn(...args) { len(args) }; let g = fn(...args) { count(0, ...args) }; g(1, 2)", 3},
+		{"let make = fn(n = 5) { fn(x = n) { x } }; make()() + make(1)(2)", 7},
+		{"let f = fn([a, b] = [1, 2]) { a + b }; f() + f([3, 4])", 10},
+		{"len(...[[1, 2, 3]])", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let [a, b] = [1, 2]; a + b", 3},
+		{"let [a, ...rest] = [1, 2, 3]; a + len(rest)", 3},
+		{"let [_, second] = [1, 2]; second", 2},
+		{"let [[a, b], c] = [[1, 2], 3]; a + b + c", 6},
+		{`let {name, age} = {"name": "Ann", "age": 30}; age`, 30},
+		{`let {"name": n, "tags": [first, ...]} = {"name": "x", "tags": [5, 6]}; first`, 5},
+		{`let f = fn([x, y], {z}) { x * y + z }; f([2, 3], {"z": 4})`, 10},
+		{`let f = fn() { let [a, b] = [1, 2]; let {c} = {"c": 3}; a + b + c }; f()`, 6},
+		{"let make = fn([a, b]) { fn() { a + b } }; make([1, 2])()", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	describe := `
+let describe = fn(x) {
+	match (x) {
+		0 => "zero",
+		-1 => "minus one",
+		[n] if n > 100 => "big",
+		1.5 => "one and a half",
+		"hi" => "greeting",
+		true => "yes",
+		[] => "empty",
+		[a] => "one: ${a}",
+		[first, ...rest] => "first ${first}, ${len(rest)} more",
+		{"type": "circle", "r": r} => "circle ${r}",
+		{"type": t} => "shape ${t}",
+		_ => "other"
+	}
+};
+	`
+
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{describe + "describe(0)", "zero"},
+		{describe + "describe(-1)", "minus one"},
+		{describe + "describe([500])", "big"},
+		{describe + "describe(1.5)", "one and a half"},
+		{describe + `describe("hi")`, "greeting"},
+		{describe + "describe(true)", "yes"},
+		{describe + "describe([])", "empty"},
+		{describe + "describe([7])", "one: 7"},
+		{describe + "describe([1, 2, 3])", "first 1, 2 more"},
+		{describe + `describe({"type": "circle", "r": 2})`, "circle 2"},
+		{describe + `describe({"type": "square", "size": 3})`, "shape square"},
+		{describe + "describe(false)", "other"},
+		{describe + "describe(7)", "other"},
+		{describe + `describe("1")`, "other"},
+		{"match (2.0) { 2 => 20, _ => 0 }", 20},
+		{"match ([1, [2, 3]]) { [a, [b, c]] => a + b + c }", 6},
+		{"match (3) { x => { let y = x * 2; y + 1 } }", 7},
+		{"match ([1, 2]) { [a] => a, [a, b, ...] => a + b }", 3},
+		{"match (5) { 1 => 10 }", nil},
+		{"let f = fn(x) { if (x < 0) { 1 } else if (x == 0) { 2 } else { 3 } }; f(-5) * 100 + f(0) * 10 + f(5)", 123},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -139,6 +312,59 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let i = 0; let s = 0; while (i < 5) { s += i; i += 1; } s", 10},
+		{"let s = 0; for (let i = 0; i < 10; i += 1) { if (i % 2 == 0) { continue; } if (i > 7) { break; } s += i; } s", 16},
+		{"let s = 0; for (x in [1, 2, 3]) { s += x; } s", 6},
+		{"let s = 0; for (k in {3: 1, 1: 2, 2: 3}) { s = s * 10 + k; } s", 123},
+		{`let n = 0; for (c in "héllo") { n += 1; } n`, 5},
+		{"let s = 0; for (let i = 0; i < 3; i += 1) { for (let j = 0; j < 3; j += 1) { if (j == 1) { break; } s += 1; } } s", 3},
+		{"let f = fn() { let i = 0; while (true) { i += 1; if (i == 5) { break; } } i }; f()", 5},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10; } } 0 }; f()", 20},
+		{"let f = fn() { let s = 0; for (let i = 0; i < 10000; i += 1) { s += 1; } s }; f()", 10000},
+		{"let i = 100; for (let i = 0; i < 3; i += 1) {} i", 100},
+		{"let x = 100; for (x in [1, 2]) { let x = 0; } x", 100},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestLoopValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"let i = 0; while (i < 3) { i += 1 }; i", 3},
+		{"let s = 0; for (let i = 0; i < 3; i += 1) { s += i }; s", 3},
+		{"let s = 0; for (x in [1, 2]) { s += x }; s", 3},
+		{"let i = 0; while (i < 3) { i += 1 }", nil},
+		{"for (let i = 0; i < 3; i += 1) { if (i == 1) { break; } }", nil},
+		{"for (x in [1, 2]) { x }", nil},
+		{"let f = fn() { for (x in [1, 2]) { x } }; f()", nil},
+		{"let r = if (true) { while (false) { } }; r", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(expected))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestLoopOverStrings(t *testing.T) {
+	input := `let out = ""; for (c in "héllo") { out = c + out; } out`
+	testStringObject(t, testEval(input), "olléh")
+}
+
 func TestErrorHandling(t *testing.T) {
 	tests := []struct {
 		input           string
@@ -160,6 +386,17 @@ if (10 > 1) {
 }`, "unknown operator: BOOLEAN + BOOLEAN",
 		},
 		{"foobar", "identifier not found: foobar"},
+		{"foobar = 1", "identifier not found: foobar"},
+		{"len = 1", "cannot assign to builtin: len"},
+		{"len(1); 5", "argument to `len` not supported, got INTEGER"},
+		{"let f = fn() { first(1); 2 }; f() + 1", "argument to `first` must be ARRAY, got INTEGER"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1"},
+		{`let a = [1]; a["x"] = 2`, "array index must be INTEGER, got STRING"},
+		{`let s = "a"; s[0] = "b"`, "index assignment not supported: STRING"},
+		{"let h = {}; h[[]] = 1", "unusable as hash key: ARRAY"},
+		{"[1, 2][true:]", "slice bounds must be integers, got BOOLEAN"},
+		{"{1: 2}[0:1]", "slice operator not supported: HASH"},
+		{`let x = "a"; x -= 1`, "type mismatch: STRING - INTEGER"},
 		{
 			`"Hello" - "World"`,
 			"unknown operator: STRING - STRING",
@@ -168,6 +405,45 @@ if (10 > 1) {
 			`{"name": "Monkey"}[fn(x) {x}];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{"1 / 0", "division by zero"},
+		{"1 % 0", "division by zero"},
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+		{"let [a, b] = [1];", "cannot destructure an array of length 1, expected length 2"},
+		{"let [a, b, ...rest] = [1];", "cannot destructure an array of length 1, expected length of at least 2"},
+		{`let {name} = {"age": 1};`, "missing hash key: name"},
+		{"let [a] = 5;", "cannot destructure INTEGER as an array"},
+		{"let {a} = [1];", "cannot destructure ARRAY as a hash"},
+		{"let f = fn([a]) { a }; f(1)", "cannot destructure INTEGER as an array"},
+		{"let [1, x] = [2, 3];", "2 does not match 1"},
+		{"fn(a) { a }()", "wrong number of arguments: want=1, got=0"},
+		{"fn(a, b = 1) { a }()", "wrong number of arguments: want between 1 and 2, got=0"},
+		{"fn(a, b = 1) { a }(1, 2, 3)", "wrong number of arguments: want between 1 and 2, got=3"},
+		{"fn(a, ...r) { a }()", "wrong number of arguments: want at least 1, got=0"},
+		{"let f = fn(a) { a }; f(...5)", "cannot spread INTEGER"},
+		{"[...1]", "cannot spread INTEGER"},
+		{"true && -false", "unknown operator: -BOOLEAN"},
+		{"throw 1;", "uncaught exception: 1"},
+		{`throw error("boom");`, "boom"},
+		{"try { throw 1; } finally { 2 }", "uncaught exception: 1"},
+		{"try { throw 1; } catch (e) { throw e + 1; }", "uncaught exception: 2"},
+		{"struct Point { x, y } Point(1)", "wrong number of arguments to Point: want=2, got=1"},
+		{"struct Point { x, y } Point(1, 2).z", "Point has no field or method z"},
+		{"struct Point { x, y } let p = Point(1, 2); p.z = 1", "Point has no field z"},
+		{"struct Point { x, y } Point(1, 2).dist()", "Point has no field or method dist"},
+		{"let a = 1; impl a { fn f(self) { 1 } }", "impl target must be STRUCT, got INTEGER"},
+		{"[1, 2].len", "member access not supported: ARRAY"},
+		{"let a = [1]; a.x = 1", "member assignment not supported: ARRAY"},
+		{"struct Point { x, y } impl Point { fn f(self, a) { a } } Point(1, 2).f()", "wrong number of arguments: want=2, got=1"},
+		{"enum Result { Ok(value), Err(reason) } Result.Ok(1, 2)", "wrong number of arguments to Result.Ok: want=1, got=2"},
+		{"enum Result { Ok(value), Err(reason) } Result.Maybe", "Result has no variant Maybe"},
+		{"enum Result { Ok(value), Err(reason) } Result.Ok(1).reason", "Result.Ok has no field reason"},
+		{"enum Result { Ok(value), Err(reason) } match (Result.Ok(1)) { Result.Ok(v) => v }", "non-exhaustive match on Result: missing Err"},
+		{"enum Color { Red, Green, Blue } match (Color.Red) { Color.Red => 1, Color.Green if true => 2 }", "non-exhaustive match on Color: missing Green, Blue"},
+		{"enum Option { Some(value), None } match (Option.None) { Option.Some(1) => 1, Option.None => 0 }", "non-exhaustive match on Option: missing Some"},
+		{"enum Result { Ok(value), Err(reason) } match (1) { Result.Ok(v) => v, Result.Bad(e) => e }", "Result has no variant Bad"},
+		{"enum Result { Ok(value), Err(reason) } match (1) { Result.Ok(v, w) => v, _ => 0 }", "wrong number of fields in pattern Result.Ok: want=1, got=2"},
+		{"let Result = 1; match (1) { Result.Ok(v) => v, _ => 0 }", "variant pattern needs an ENUM, got INTEGER"},
+		{"enum Pair { Of(a, b) } let [Pair.Of(x, y)] = [1]; x", "1 does not match Pair.Of"},
 	}
 
 	for _, tt := range tests {
@@ -184,6 +460,150 @@ if (10 > 1) {
 	}
 }
 
+func TestStructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"struct Point { x, y } let p = Point(1, 2); p.x", 1},
+		{"struct Point { x, y } let p = Point(1, 2); p.y", 2},
+		{"struct Point { x, y } let p = Point(1, 2); p.x = 3; p.x", 3},
+		{"struct Point { x, y } let p = Point(1, 2); p.y = 5", 5},
+		{"struct Point { x, y } let p = Point(1, 2); p.y += 10; p.y", 12},
+		{"struct Box { value } let a = Box(1); let b = a; b.value = 7; a.value", 7},
+		{"struct Line { from, to } struct Point { x, y } let l = Line(Point(0, 0), Point(3, 4)); l.to.y", 4},
+		{"struct Point { x, y } impl Point { fn dist(self) { self.x * self.x + self.y * self.y } } Point(3, 4).dist()", 25},
+		{"struct Point { x, y } impl Point { fn add(self, other) { Point(self.x + other.x, self.y + other.y) } } Point(1, 2).add(Point(3, 4)).y", 6},
+		{"struct Counter { n } impl Counter { fn inc(self) { self.n += 1; self } } let c = Counter(0); c.inc().inc(); c.n", 2},
+		{"struct Point { x, y } impl Point { fn getX(self) { self.x } } let p = Point(9, 0); let f = p.getX; p.x = 1; f()", 1},
+		{"struct Point { x, y } impl Point { fn x(self) { 0 } } Point(5, 6).x", 5},
+		{"struct Point { x, y } let p = Point(1, 2); impl Point { fn sum(self) { self.x + self.y } } p.sum()", 3},
+		{"struct Node { value, next } let list = Node(1, Node(2, Node(3, false))); let s = 0; let n = list; while (n != false) { s += n.value; n = n.next; } s", 6},
+		{"struct P { x } let f = fn() { struct Q { y } Q(4) }; f().y", 4},
+		{"struct Empty { } impl Empty { fn name(self) { \"empty\" } } Empty().name()", "empty"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestEnums(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"enum Result { Ok(value), Err(reason) } let r = Result.Ok(5); match (r) { Result.Ok(v) => v, Result.Err(e) => 0 }", 5},
+		{`enum Result { Ok(value), Err(reason) } let r = Result.Err("bad"); match (r) { Result.Ok(v) => v, Result.Err(e) => e }`, "bad"},
+		{"enum Result { Ok(value), Err(reason) } Result.Ok(7).value", 7},
+		{"enum Color { Red, Green, Blue } let c = Color.Green; match (c) { Color.Red => 1, Color.Green => 2, Color.Blue => 3 }", 2},
+		{"enum Color { Red, Green } if (Color.Red == Color.Red) { 1 } else { 0 }", 1},
+		{"enum Color { Red, Green } if (Color.Red == Color.Green) { 1 } else { 0 }", 0},
+		{"enum Color { Red, Green } match (5) { Color.Red => 1, 5 => 2 }", 2},
+		{"enum Color { Red, Green } match (\"c\") { Color.Red => 1 }", nil},
+		{"enum Shape { Rect(w, h), Circle(r) } let area = fn(s) { match (s) { Shape.Rect(w, h) => w * h, Shape.Circle(r) => 3 * r * r } }; area(Shape.Rect(2, 3)) + area(Shape.Circle(2))", 18},
+		{"enum Option { Some(value), None } let f = fn(o) { match (o) { Option.Some(1) => 10, Option.Some(v) => v, Option.None => 0 } }; f(Option.Some(1)) + f(Option.Some(5)) + f(Option.None)", 15},
+		{"enum Option { Some(value), None } match (Option.Some(3)) { Option.Some(v) if v > 5 => 1, Option.Some(v) => 2, Option.None => 3 }", 2},
+		{"enum Option { Some(value), None } match (Option.None) { Option.Some(v) => v, _ => 9 }", 9},
+		{"enum Option { Some(value), None } match (Option.Some(4)) { Option.None => 0, other => 8 }", 8},
+		{"enum Option { Some(value), None } match (Option.Some(Option.Some(6))) { Option.Some(Option.Some(v)) => v, Option.Some(_) => 1, Option.None => 0 }", 6},
+		{"enum Option { Some(value), None } match ([Option.Some(1), Option.None]) { [Option.Some(a), Option.None] => a, _ => 0 }", 1},
+		{"enum Option { Some(value), None } match (5) { Option.Some(v) => v, Option.None => 0 }", nil},
+		{"enum Pair { Of(a, b) } let [x, Pair.Of(y, z)] = [1, Pair.Of(2, 3)]; x + y + z", 6},
+		{"enum Tree { Leaf, Node(left, value, right) } let sum = fn(t) { match (t) { Tree.Leaf => 0, Tree.Node(l, v, r) => sum(l) + v + sum(r) } }; sum(Tree.Node(Tree.Node(Tree.Leaf, 1, Tree.Leaf), 2, Tree.Leaf))", 3},
+		{"enum Result { Ok(value), Err(reason) } let ok = Result.Ok; ok(11).value", 11},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestStructInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, y } Point", "struct Point { x, y }"},
+		{"struct Point { x, y } Point(1, [2])", "Point { x: 1, y: [2] }"},
+		{`struct Name { value } Name("a")`, `Name { value: a }`},
+		{"enum Result { Ok(value), Err(reason), None } Result", "enum Result { Ok(value), Err(reason), None }"},
+		{"enum Result { Ok(value), Err(reason), None } Result.Ok(5)", "Result.Ok(5)"},
+		{"enum Result { Ok(value), Err(reason), None } Result.Err([1, 2])", "Result.Err([1, 2])"},
+		{"enum Result { Ok(value), Err(reason), None } Result.None", "Result.None"},
+		{"enum Result { Ok(value), Err(reason), None } Result.Ok", "Result.Ok"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong inspect for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"try { throw 1; } catch (e) { e + 1 }", 2},
+		{"try { 5 } catch (e) { 0 }", 5},
+		{"try { throw 1; } catch { 2 }", 2},
+		{"try { let y = 1; } catch (e) { 0 }", nil},
+		{"let r = 1 + try { throw 1; } catch (e) { e + 1 }; r", 3},
+		{`try { [1][true] } catch (e) { e["message"] }`, "index operator not supported: ARRAY"},
+		{`try { 1 + true } catch (e) { e["message"] }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`let f = fn(a) { a }; try { f() } catch (e) { e["message"] }`, "wrong number of arguments: want=1, got=0"},
+		{`try { throw error("boom", 42); } catch (e) { e["data"] }`, 42},
+		{`try { throw error("boom"); } catch (e) { e["message"] }`, "boom"},
+		{`try { throw error("boom"); } catch (e) { e["data"] }`, nil},
+		{"let x = 0; try { x = 1 } finally { x = x + 10 }; x", 11},
+		{"let x = 0; try { throw 1; } catch (e) { x = e } finally { x += 10 }; x", 11},
+		{"let log = 0; let f = fn() { try { return 1; } finally { log = 2; } }; f() + log", 3},
+		{`let x = 0; let f = fn() { try { throw "a"; } finally { x = 5; } }; try { f() } catch (e) { x }`, 5},
+		{"try { try { throw 1; } catch (e) { throw e + 1; } } catch (e) { e * 10 }", 20},
+		{"try { try { throw 1; } finally { throw 2; } } catch (e) { e }", 2},
+		{`let f = fn(n) { if (n == 0) { throw "done"; } f(n - 1) }; try { f(5) } catch (e) { e }`, "done"},
+		{"let s = 0; for (x in [1, 2, 3]) { try { if (x == 2) { continue; } s += x } finally { s += 10 } } s", 34},
+		{"let s = 0; while (true) { try { break; } finally { s = 7 } } s", 7},
+		{"try { len(1) } catch (e) { e.message }", "argument to `len` not supported, got INTEGER"},
+		{"let f = fn() { push(1, 2); 3 }; try { f() } catch (e) { e.message }", "argument to `push` must be ARRAY, got INTEGER"},
+		{"try { throw error(\"boom\", 42); } catch (e) { e.data }", 42},
+		{"first([error(\"boom\")]).message", "boom"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -270,6 +690,89 @@ addTwo(2);
 	testIntegerObject(t, testEval(input), 4)
 }
 
+func TestIndexAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = [1, 2, 3]; a[0] = 9; a[0] + a[2]", 12},
+		{"let a = [1, 2, 3]; a[-1] = 9; a[2]", 9},
+		{"let a = [1, 2, 3]; a[1] += 10; a[1]", 12},
+		{"let a = [1, 2, 3]; let b = a; b[0] = 5; a[0]", 5},
+		{"let a = [1, 2, 3]; let b = a[:]; a[0] = 9; b[0]", 1},
+		{`let h = {}; h["k"] = 1; h["k"] += 2; h["k"]`, 3},
+		{"let a = [[1], [2]]; a[1][0] = 7; a[1][0]", 7},
+		{"let a = [0]; let i = 0; let next = fn() { i += 1; 0 }; a[next()] += 5; i * 10 + a[0]", 15},
+		{"let f = fn(a) { a[0] = 4 }; let a = [0]; f(a) + a[0]", 8},
+		{"let a = []; for (let i = 0; i < 10000; i += 1) { append!(a, i) }; len(a)", 10000},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; let y = x = 5; x + y", 10},
+		{"let a = 1; let b = 2; a = b = 3; a + b", 6},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x %= 4", 2},
+		{"let s = 0; let add = fn(n) { s += n }; add(1); add(2); s", 3},
+		{"let f = fn() { let x = 1; x = x + 1; x }; f()", 2},
+		{"let f = fn(n) { n += 10; n }; f(1)", 11},
+		{`
+		let counter = fn() {
+			let count = 0;
+			fn() { count += 1 }
+		};
+		let a = counter();
+		let b = counter();
+		a(); a(); b();
+		a() * 10 + b()
+		`, 32},
+		{`
+		let f = fn() {
+			let x = 1;
+			let get = fn() { x };
+			x = 5;
+			get()
+		};
+		f()
+		`, 5},
+		{`
+		let outer = fn() {
+			let x = 0;
+			let middle = fn() {
+				let inner = fn() { x += 1 };
+				inner();
+				inner();
+			};
+			middle();
+			x
+		};
+		outer()
+		`, 2},
+		{`
+		let f = fn() {
+			let x = 1;
+			let g = fn() { x = 7 };
+			g();
+			x
+		};
+		let h = fn() { let y = 3; y };
+		f() + h()
+		`, 10},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 
@@ -298,6 +801,23 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"${1}"`, "1"},
+		{`let user = {"name": "Ana"}; let count = 3; "Hello ${user["name"]}, you have ${count} items"`, "Hello Ana, you have 3 items"},
+		{`"${[1, 2.5]} ${true} ${if (false) { 1 }} ${"${"nested"}"}"`, "[1, 2.5] true null nested"},
+		{`"price: \$${10}"`, "price: $10"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testStringObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -306,6 +826,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
+		{`len("日本語")`, 3},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 		{`len([1, 2, 3])`, 3},
@@ -321,6 +843,16 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`rest([])`, nil},
 		{`push([], 1)`, []int{1}},
 		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`let a = [1]; push(a, 2); a`, []int{1}},
+		{`let a = [1]; append!(a, 2, 3); a`, []int{1, 2, 3}},
+		{`append!({}, 1)`, "argument to `append!` must be ARRAY, got HASH"},
+		{`let h = {"a": 1}; delete!(h, "a")`, 1},
+		{`let h = {"a": 1}; delete!(h, "a"); h["a"]`, nil},
+		{`delete!({}, "a")`, nil},
+		{`delete!([], 0)`, "argument to `delete!` must be HASH, got ARRAY"},
+		{`error("boom")`, "boom"},
+		{`error(1)`, "argument to `error` must be STRING, got INTEGER"},
+		{`error("boom", 1, 2)`, "wrong number of arguments. got=3, want=1 or 2"},
 	}
 
 	for _, tt := range tests {
@@ -423,6 +955,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -438,6 +978,33 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][:-1]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][3:1]", "[]"},
+		{"[1, 2, 3, 4][-10:10]", "[1, 2, 3, 4]"},
+		{`"héllo wörld"[2:5]`, "llo"},
+		{`"héllo"[1:]`, "éllo"},
+		{`"héllo"[:-3]`, "hé"},
+		{`"héllo"[4:2]`, ""},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong slice for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
 	{
@@ -523,3 +1090,183 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`"monkey"[0]`, "m"},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[2]`, "l"},
+		{`let s = "日本語"; s[2]`, "語"},
+		{`let café = "☕"; café[0]`, "☕"},
+		{`"héllo"[5]`, nil},
+		{`"héllo"[-1]`, "o"},
+		{`"héllo"[-4]`, "é"},
+		{`"héllo"[-6]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := tt.expected.(string)
+		if ok {
+			testStringObject(t, evaluated, str)
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestSpawnAndChannels(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let ch = chan(); spawn(fn() { send(ch, 1); send(ch, 2); close(ch); }); array(ch)", "[1, 2]"},
+		{`let task = spawn(fn(a, b) { a + b }, 1, 2); [recv(task), recv(task, "closed")]`, "[3, closed]"},
+		{"let task = spawn(len, [1, 2]); recv(task)", "2"},
+		{"struct Point { x, y } recv(spawn(Point, 3, 4)).y", "4"},
+		{`let task = spawn(fn() { throw "boom"; }); try { recv(task) } catch (e) { e }`, "boom"},
+		{`let task = spawn(fn(a) { a }); try { recv(task) } catch (e) { e["message"] }`, "wrong number of arguments: want=1, got=0"},
+		{"let done = chan(); let work = fn(n) { send(done, n * n) }; for (let i = 1; i <= 4; i += 1) { spawn(work, i) } let sum = 0; for (let i = 0; i < 4; i += 1) { sum += recv(done) } sum", "30"},
+		{"let counter = fn() { let count = 0; let done = chan(); spawn(fn() { count = 5; send(done, true) }); recv(done); count }; counter()", "5"},
+		{"let results = chan(3); let worker = fn(jobs) { for (j in jobs) { send(results, j * 10) } }; let jobs = chan(3); spawn(worker, jobs); send(jobs, 1); send(jobs, 2); close(jobs); [recv(results), recv(results)]", "[10, 20]"},
+		{`let a = chan(1); let b = chan(1); send(b, "x"); select([a, b])`, "[1, x]"},
+		{`let a = chan(); select([a], "nothing")`, "nothing"},
+		{"let a = chan(1); select([[a, 5]]); recv(a)", "5"},
+		{"let a = chan(); close(a); select([a])", "[0, null]"},
+		{`let task = spawn(fn() { throw "boom"; }); try { select([task]) } catch (e) { e }`, "boom"},
+		{"let g = fn*() { yield 1; yield 2; }; let it = g(); let task = spawn(fn() { next(it) }); [recv(task), next(it)]", "[1, 2]"},
+		{"let h = {}; let done = chan(); let work = fn(k) { for (let i = 0; i < 100; i += 1) { h[k * 100 + i] = 1; h[i] } send(done, true) }; for (let k = 0; k < 4; k += 1) { spawn(work, k) } for (let k = 0; k < 4; k += 1) { recv(done) } let sum = 0; for (let k = 0; k < 400; k += 1) { sum += h[k] } sum", "400"},
+		{"struct Cursor { at } let c = Cursor(0); let done = chan(); let work = fn(k) { for (let i = 0; i < 100; i += 1) { c.at = k; c.at } send(done, true) }; for (let k = 1; k <= 4; k += 1) { spawn(work, k) } for (let k = 0; k < 4; k += 1) { recv(done) } c.at > 0", "true"},
+		{"let xs = []; let done = chan(); let work = fn(k) { for (let i = 0; i < 100; i += 1) { append!(xs, k); xs[0] = k; xs[-1]; len(xs) } send(done, true) }; for (let k = 0; k < 4; k += 1) { spawn(work, k) } for (let k = 0; k < 4; k += 1) { recv(done) } len(xs)", "400"},
+		{`let ch = chan(); spawn(fn() { 1 }); try { recv(ch) } catch (e) { e["message"] }`, "deadlock: waiting on a channel with no live task left"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestChannelErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = chan(); close(a); close(a)", "close of closed channel"},
+		{"let a = chan(); close(a); send(a, 1)", "send on closed channel"},
+		{"let a = chan(); close(a); select([[a, 1]])", "send on closed channel"},
+		{"select([1])", "case of `select` must be CHANNEL or [CHANNEL, value], got 1"},
+		{"spawn(1)", "argument to `spawn` must be a function, got INTEGER"},
+		{"chan(-1)", "capacity of `chan` must not be negative, got -1"},
+		{`chan("a")`, "argument to `chan` must be INTEGER, got STRING"},
+		{"recv([])", "argument to `recv` must be CHANNEL, got ARRAY"},
+		{"recv(chan())", "deadlock: waiting on a channel with no live task left"},
+		{"send(chan(), 1)", "deadlock: waiting on a channel with no live task left"},
+		{"select([chan(), [chan(), 1]])", "deadlock: waiting on a channel with no live task left"},
+		{"let ch = chan(); for (x in ch) { x }", "deadlock: waiting on a channel with no live task left"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+
+	evaluated := testEval(`recv(spawn(fn() { throw "boom"; }))`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "uncaught exception: boom" {
+		t.Errorf("exception from task not thrown by recv. got=%v", evaluated)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"let loop = fn(n) { if (n == 0) { return 0; } loop(n - 1) }; loop(1000000)", 0},
+		{"let loop = fn(n) { n == 0 || loop(n - 1) }; loop(1000000)", true},
+		{"let loop = fn(n) { n > 0 && loop(n - 1) }; loop(1000000)", false},
+		{"let loop = fn(n) { match (n) { 0 => { 0 }, _ => { loop(n - 1) } } }; loop(1000000)", 0},
+		{"let loop = fn(n) { match (n) { 0 => { 0 }, m if m > 0 => { if (m > 1) { loop(m - 2) } else { loop(m - 1) } } } }; loop(1000000)", 0},
+		{"let loop = fn(n) { return n == 0 || loop(n - 1); }; loop(1000000)", true},
+		{"let count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } }; count(100000, 0)", 100000},
+		{"let count = fn(n, acc) { if (n > 0) { count(n - 1, acc + 1) } else { acc } }; count(100000, 0)", 100000},
+		{"let count = fn(n, acc) { if (n == 0) { return acc; } return count(n - 1, acc + 1); }; count(100000, 0)", 100000},
+		{"let isOdd = 0; let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } }; isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } }; isEven(100001)", false},
+		{"let count = fn(n, acc = 0) { if (n == 0) { return acc; } count(n - 1, acc + 2) }; count(100000)", 200000},
+		{"let sum = fn(n, ...rest) { if (n == 0) { return len(rest); } sum(n - 1, n, n) }; sum(100000)", 2},
+		{"struct Counter { step } impl Counter { fn run(self, n, acc) { if (n == 0) { return acc; } self.run(n - 1, acc + self.step) } } Counter(3).run(100000, 0)", 300000},
+		{"let make = fn() { let total = 0; let add = fn(n) { if (n == 0) { return total; } total += n; add(n - 1) }; add }; make()(1000)", 500500},
+		{"let f = fn(n) { len([n]) }; f(1)", 1},
+		{`let fail = fn() { throw "boom"; }; let g = fn() { try { return fail(); } catch (e) { "caught " + e } }; g()`, "caught boom"},
+		{`let log = []; let f = fn() { append!(log, "f"); 1 }; let g = fn() { try { return f(); } finally { append!(log, "finally"); } }; g(); log[0] + " " + log[1]`, "f finally"},
+		{"let double = fn(x) { x * 2 }; return double(21); 0", 42},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestAddedOperators(t *testing.T) {
+	operators := hosttest.Operators(t)
+
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"2 ** 3 ** 2", 512},
+		{"2 * 3 ** 2", 18},
+		{"~2 ** 2", 4},
+		{"~(2 ** 2)", -4},
+		{"len(1 .. 2 + 3)", 5},
+		{"5 |> fn(x) { x * 2 } |> fn(x) { x + 1 }", 11},
+		{"1 .. 4 |> len", 4},
+		{"let f = fn(n) { n .. n + 1 }; f(3)", "[3, 4]"},
+		{`"a" ** 2`, "ERROR: arguments to `pow` must be INTEGER"},
+		{"let pipe = 1; 1 |> len", "ERROR: not a function: INTEGER"},
+		{`try { "a" ** 2 } catch (e) { e.message }`, "arguments to `pow` must be INTEGER"},
+		{"let pow = fn(a, b) { 0 }; 2 ** 3", 8},
+		{"let f = fn(pow) { 2 ** 3 }; f(1)", 8},
+	}
+
+	for _, tt := range tests {
+		p := parser.NewWithOperators(lexer.New(hosttest.Prelude+tt.input), operators)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+
+		evaluated := Eval(program, object.NewEnvironment())
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"runtime"
+)
+
+// newGenerator calls a generator function. Its body runs on a goroutine of its own, which is
+// started by the first request for a value and suspended at each yield, so the body and the
+// code asking for its values never run at the same time. The arguments are bound when the
+// body starts, as in the virtual machine, where default values are part of the body.
+//
+// Parameters:
+//   - fn: The generator function.
+//   - args: The arguments of the call.
+//
+// Returns:
+//   - *object.Generator: The generator producing the values yielded by the body.
+func newGenerator(fn *object.Function, args []object.Object) *object.Generator {
+	resume := make(chan struct{})
+	yields := make(chan object.Object)
+
+	yield := func(val object.Object) bool {
+		yields <- val
+		if _, ok := <-resume; !ok {
+			// The generator was dropped, so the goroutine ends without running any more of the
+			// body, not even its finally blocks, as a dropped generator is never resumed on the
+			// virtual machine either.
+			runtime.Goexit()
+		}
+		return true
+	}
+
+	run := func() {
+		defer close(yields)
+
+		// The parameters are bound in an environment enclosed by the generator environment,
+		// so that yield statements in the body find the generator they belong to.
+		body := *fn
+		body.Env = object.NewGeneratorEnvironment(fn.Env, yield)
+		env, err := extendFunctionEnv(&body, args)
+		if err == nil {
+			err, _ = unwrapReturnValue(Eval(fn.Body, env)).(*object.Exception)
+		}
+		if err != nil {
+			yields <- err
+		}
+	}
+
+	started := false
+	generator := &object.Generator{
+		Resume: func() (object.Object, bool) {
+			if !started {
+				started = true
+				go run()
+			} else {
+				resume <- struct{}{}
+			}
+			val, ok := <-yields
+			return val, ok
+		},
+	}
+
+	// A generator that is dropped before its body has finished would leave the goroutine
+	// waiting forever, so it is told to end.
+	runtime.AddCleanup(generator, func(resume chan struct{}) { close(resume) }, resume)
+
+	return generator
+}
+
+// evalYieldStatement hands a value to the caller of the generator and waits until the next
+// value is asked for.
+//
+// Parameters:
+//   - node: The yield statement.
+//   - env: The environment of the generator body.
+//
+// Returns:
+//   - object.Object: An error if the value could not be evaluated, a return value if the
+//     environment is not that of a generator function, otherwise nil.
+func evalYieldStatement(node *ast.YieldStatement, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if !env.Yield(val) {
+		return &object.ReturnValue{Value: NULL}
+	}
+	return nil
+}
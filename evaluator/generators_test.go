@@ -0,0 +1,113 @@
+package evaluator
+
+import (
+	"monkey/internal/hosttest"
+	"monkey/object"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let count = fn*(n) { let i = 0; while (i < n) { yield i; i += 1; } }; let g = count(3); [next(g), next(g), next(g), next(g)]", "[0, 1, 2, null]"},
+		{`let g = fn*() { yield 1; }(); [next(g), next(g, "done"), next(g, "done")]`, "[1, done, done]"},
+		{"let naturals = fn*() { let i = 0; while (true) { yield i; i += 1; } }; let sum = 0; for (n in naturals()) { if (n > 4) { break; } sum += n; }; sum", "10"},
+		{"let g = fn*() { yield 1; yield 2; yield 3; }; len(g())", "3"},
+		{"let g = fn*(a, b = a * 2) { yield a; yield b; }; array(g(3))", "[3, 6]"},
+		{"let g = fn*(x) { if (x) { yield 1; } else { yield 2; } yield 3; }; array(g(false))", "[2, 3]"},
+		{"let g = fn*() { yield 1; return 5; yield 2; }; array(g())", "[1]"},
+		{`let g = fn*() { try { yield 1; throw "x"; } catch (e) { yield e; } finally { yield "f"; } }; array(g())`, "[1, x, f]"},
+		{"let inner = fn*() { yield 1; yield 2; }; let outer = fn*() { for (x in inner()) { yield x * 10; } }; array(outer())", "[10, 20]"},
+		{"let side = [0]; let g = fn*() { side[0] += 1; yield side[0]; }; let it = g(); let before = side[0]; next(it); [before, side[0]]", "[0, 1]"},
+		{"let g = fn*() { yield 1; }; let a = g(); let b = g(); [next(a), next(b), next(a)]", "[1, 1, null]"},
+		{"struct Range { lo, hi } impl Range { fn* items(self) { let i = self.lo; while (i < self.hi) { yield i; i += 1; } } } array(Range(2, 5).items())", "[2, 3, 4]"},
+		{`array("ab")`, "[a, b]"},
+		{"array([1, 2])", "[1, 2]"},
+		{"fn*(a) { yield a; }", "fn*(a) {\nyield a;\n}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let g = fn*(a) { yield a; }; g()", "wrong number of arguments: want=1, got=0"},
+		{`let g = fn*() { yield 1; throw "boom"; }; let it = g(); next(it); next(it)`, "uncaught exception: boom"},
+		{`let g = fn*() { throw error("bad"); }; len(g())`, "bad"},
+		{`let g = fn*() { throw error("bad"); }; for (x in g()) { x }`, "bad"},
+		{"let it = [0]; let g = fn*() { yield next(it[0]); }; it[0] = g(); next(it[0])", "generator is already running"},
+		{"next([1])", "argument to `next` must be GENERATOR, got ARRAY"},
+		{"array(1)", "argument to `array` not supported, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+
+	evaluated := testEval(`let g = fn*() { yield 1; throw "boom"; }; let it = g(); next(it); try { next(it) } catch (e) { [e, next(it)] }`)
+	if evaluated.Inspect() != "[boom, null]" {
+		t.Errorf("exception from generator not caught. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestDroppedGeneratorStops(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	testEval("let g = fn*() { while (true) { yield 1; } }; next(g()); next(g());")
+
+	for range 100 {
+		runtime.GC()
+		if runtime.NumGoroutine() <= before {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("goroutines of dropped generators still running. before=%d, after=%d", before, runtime.NumGoroutine())
+}
+
+func TestDroppedGeneratorSkipsFinally(t *testing.T) {
+	var finished atomic.Bool
+	hosttest.AddBuiltin(t, "finish", func(args ...object.Object) object.Object {
+		finished.Store(true)
+		return nil
+	})
+	before := runtime.NumGoroutine()
+
+	testEval("let g = fn*() { try { while (true) { yield 1; } } finally { finish(); } }; next(g());")
+
+	for range 100 {
+		runtime.GC()
+		if runtime.NumGoroutine() <= before {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if runtime.NumGoroutine() > before {
+		t.Fatalf("goroutine of dropped generator still running. before=%d, after=%d", before, runtime.NumGoroutine())
+	}
+	if finished.Load() {
+		t.Errorf("finally block of dropped generator was run")
+	}
+}
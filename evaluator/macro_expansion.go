@@ -0,0 +1,170 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+)
+
+// maxExpansionDepth limits how often the code produced by a macro may expand into further
+// macro calls, so that a macro expanding into a call to itself fails instead of hanging.
+const maxExpansionDepth = 100
+
+// DefineMacros moves the macro definitions at the top level of a program into an environment.
+// A macro definition is a let statement binding a macro literal, e.g. let m = macro(x) { ... };
+// the definitions are removed from the program, which then only holds the code to expand.
+//
+// Parameters:
+//   - program: The parsed program.
+//   - env: The environment to define the macros in.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := program.Statements[:0]
+	for _, statement := range program.Statements {
+		let, ok := statement.(*ast.LetStatement)
+		if !ok || let.Name == nil {
+			statements = append(statements, statement)
+			continue
+		}
+		macro, ok := let.Value.(*ast.MacroLiteral)
+		if !ok {
+			statements = append(statements, statement)
+			continue
+		}
+
+		env.Set(let.Name.Value, &object.Macro{Parameters: macro.Parameters, Body: macro.Body, Env: env})
+	}
+	program.Statements = statements
+}
+
+// ExpandMacros replaces every call to a macro defined in an environment with the code the
+// macro produces. The arguments are passed to the macro as quotes and are not evaluated.
+// This runs between parsing and evaluation or compilation, so both engines support macros.
+//
+// Parameters:
+//   - program: The program to expand, after its macros have been defined with DefineMacros.
+//   - env: The environment holding the macros.
+//
+// Returns:
+//   - ast.Node: The expanded program.
+//   - error: An error if a macro failed or did not produce a quote.
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	return expandMacros(program, env, 0)
+}
+
+// ExpandModuleMacros defines the macros of a module file and expands them, for a
+// module.Loader to run on each file it parses. The macros of a module are its own: the code
+// importing it does not see them, nor does the module see those of that code.
+//
+// Parameters:
+//   - program: The parsed module.
+//
+// Returns:
+//   - *ast.Program: The expanded module.
+//   - error: An error if a macro failed or did not produce a quote.
+func ExpandModuleMacros(program *ast.Program) (*ast.Program, error) {
+	env := object.NewEnvironment()
+	DefineMacros(program, env)
+	expanded, err := ExpandMacros(program, env)
+	if err != nil {
+		return nil, err
+	}
+	return expanded.(*ast.Program), nil
+}
+
+func expandMacros(node ast.Node, env *object.Environment, depth int) (ast.Node, error) {
+	var err error
+	expanded := ast.Modify(node, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || err != nil {
+			return node
+		}
+		macro, ok := macroCall(call, env)
+		if !ok {
+			return node
+		}
+		name := call.Function.(*ast.Identifier).Value
+
+		if depth >= maxExpansionDepth {
+			err = fmt.Errorf("%s: macro expansion of %s exceeded %d levels", call.Pos(), name, maxExpansionDepth)
+			return node
+		}
+		if len(call.Arguments) != len(macro.Parameters) {
+			err = fmt.Errorf("%s: wrong number of arguments to macro %s: want=%d, got=%d",
+				call.Pos(), name, len(macro.Parameters), len(call.Arguments))
+			return node
+		}
+
+		evaluated := unwrapReturnValue(Eval(macro.Body, extendMacroEnv(macro, call.Arguments)))
+		if evaluated == nil {
+			evaluated = NULL
+		}
+		if exception, ok := evaluated.(*object.Exception); ok {
+			err = fmt.Errorf("%s: macro %s failed: %s", call.Pos(), name, exception.Uncaught().Message)
+			return node
+		}
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			err = fmt.Errorf("%s: macro %s must return a quote, got %s", call.Pos(), name, evaluated.Type())
+			return node
+		}
+		if _, ok := quote.Node.(ast.Expression); !ok {
+			err = fmt.Errorf("%s: macro %s must return a quoted expression", call.Pos(), name)
+			return node
+		}
+
+		// The produced code may call macros of its own.
+		result, expandErr := expandMacros(quote.Node, env, depth+1)
+		if expandErr != nil {
+			err = expandErr
+			return node
+		}
+		return result
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return expanded, nil
+}
+
+// macroCall looks up the macro called by a call expression.
+//
+// Parameters:
+//   - call: The call expression.
+//   - env: The environment holding the macros.
+//
+// Returns:
+//   - *object.Macro: The called macro.
+//   - bool: Whether the call is a call to a macro.
+func macroCall(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+// extendMacroEnv binds the parameters of a macro to its quoted arguments.
+//
+// Parameters:
+//   - macro: The macro being expanded.
+//   - args: The unevaluated arguments of the call.
+//
+// Returns:
+//   - *object.Environment: The environment to evaluate the macro body in.
+func extendMacroEnv(macro *object.Macro, args []ast.Expression) *object.Environment {
+	extended := object.NewEnclosedEnvironment(macro.Env)
+
+	for i, param := range macro.Parameters {
+		extended.Set(param.Value, &object.Quote{Node: args[i]})
+	}
+
+	return extended
+}
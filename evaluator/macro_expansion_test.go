@@ -0,0 +1,229 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	_, ok := env.Get("number")
+	if ok {
+		t.Fatalf("number should not be defined")
+	}
+	_, ok = env.Get("function")
+	if ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+
+	if macro.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", macro.Parameters[0])
+	}
+	if macro.Parameters[1].String() != "y" {
+		t.Fatalf("parameter is not 'y'. got=%q", macro.Parameters[1])
+	}
+
+	expectedBody := "(x + y)"
+
+	if macro.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`
+			let infixExpression = macro() { quote(1 + 2); };
+
+			infixExpression();
+			`,
+			`(1 + 2)`,
+		},
+		{
+			`
+			let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+
+			reverse(2 + 2, 10 - 5);
+			`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+			let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+
+			unless(10 > 5, puts("not greater"), puts("greater"));
+			`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			`
+			let double = macro(x) { quote(unquote(x) * 2) };
+			let quadruple = macro(x) { quote(double(double(unquote(x)))) };
+
+			quadruple(a);
+			`,
+			`(a * 2) * 2`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(tt.expected)
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("expansion failed: %s", err)
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal. want=%q, got=%q", expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestExpandMacrosMoreThanOnce(t *testing.T) {
+	input := `
+	let twice = macro(x) { quote(unquote(x) + unquote(x)) };
+	twice(1);
+	twice(a);
+	`
+
+	program := testParseProgram(input)
+	env := object.NewEnvironment()
+	DefineMacros(program, env)
+	expanded, err := ExpandMacros(program, env)
+	if err != nil {
+		t.Fatalf("expansion failed: %s", err)
+	}
+
+	if expanded.String() != "(1 + 1)(a + a)" {
+		t.Errorf("wrong expansion. got=%q", expanded.String())
+	}
+}
+
+func TestEvalExpandedMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			`
+			let unless = macro(cond, a, b) { quote(if (!(unquote(cond))) { unquote(a) } else { unquote(b) }) };
+			unless(1 > 2, 10, 20)
+			`,
+			10,
+		},
+		{
+			// The t bound by the macro does not capture the t of the caller.
+			`
+			let square = macro(x) { quote((fn(t) { t * t })(unquote(x))) };
+			let t = 3;
+			square(t + 1)
+			`,
+			16,
+		},
+		{
+			// The tmp bound by the macro does not shadow the tmp passed in by the caller.
+			`
+			let withTmp = macro(x) { quote((fn() { let tmp = 100; unquote(x) })()) };
+			let tmp = 1;
+			withTmp(tmp + 1)
+			`,
+			2,
+		},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+		macroEnv := object.NewEnvironment()
+		DefineMacros(program, macroEnv)
+		expanded, err := ExpandMacros(program, macroEnv)
+		if err != nil {
+			t.Fatalf("expansion failed: %s", err)
+		}
+
+		testIntegerObject(t, Eval(expanded, object.NewEnvironment()), tt.expected)
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let m = macro(x) { x }; m(1, 2)", "1:25: wrong number of arguments to macro m: want=1, got=2"},
+		{"let m = macro() { 1 }; m()", "1:24: macro m must return a quote, got INTEGER"},
+		{"let m = macro() { foo }; m()", "1:26: macro m failed: identifier not found: foo"},
+		{"let m = macro() { quote(m()) }; m()", "1:25: macro expansion of m exceeded 100 levels"},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+
+		_, err := ExpandMacros(program, env)
+		if err == nil {
+			t.Errorf("expected an error for %q", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+
+	evaluated := testEval("let f = fn() { macro(x) { x } }; f()")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "macros can only be defined with a top-level let" {
+		t.Errorf("expected an error for a nested macro literal. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
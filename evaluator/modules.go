@@ -0,0 +1,124 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/module"
+	"monkey/object"
+	"path/filepath"
+)
+
+// Importer evaluates the modules named in import statements. Each module file is evaluated
+// once, in an environment of its own, and every import of it shares the resulting module.
+type Importer struct {
+	loader  *module.Loader            // Finds and parses the module files.
+	modules map[string]*object.Module // The modules evaluated so far, by path.
+	loading []string                  // The modules being evaluated, the outermost first.
+}
+
+// NewImporter creates a new importer.
+//
+// Parameters:
+//   - loader: The loader finding and parsing the module files.
+//
+// Returns:
+//   - *Importer: The new importer.
+func NewImporter(loader *module.Loader) *Importer {
+	return &Importer{loader: loader, modules: map[string]*object.Module{}}
+}
+
+// Import evaluates a module, unless it was already evaluated, and returns its exports.
+//
+// Parameters:
+//   - path: The path written in the import statement.
+//   - from: The path of the importing file, or an empty string for code not read from a file.
+//
+// Returns:
+//   - object.Object: The module, or the exception raised while loading or evaluating it.
+func (i *Importer) Import(path, from string) object.Object {
+	resolved, err := i.loader.Resolve(path, from)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	if mod, ok := i.modules[resolved]; ok {
+		return mod
+	}
+	if len(i.loading) == 0 && from != "" {
+		// The importing file is the one being run, which the importer did not load itself.
+		i.loading = []string{filepath.Clean(from)}
+		defer func() { i.loading = nil }()
+	}
+	if err := module.Cycle(i.loading, resolved); err != nil {
+		return newError("%s", err)
+	}
+
+	program, err := i.loader.Parse(resolved)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	i.loading = append(i.loading, resolved)
+	defer func() { i.loading = i.loading[:len(i.loading)-1] }()
+
+	env := object.NewModuleEnvironment(resolved, i)
+	if exception := evalModule(program, env); exception != nil {
+		return exception
+	}
+
+	mod := &object.Module{Path: resolved, Exports: map[string]object.Object{}}
+	for _, name := range module.Exports(program) {
+		val, ok := env.Get(name.Value)
+		if !ok {
+			return newError("%s:%s: cannot export undefined name %s", resolved, name.Pos(), name.Value)
+		}
+		mod.Exports[name.Value] = val
+	}
+
+	i.modules[resolved] = mod
+	return mod
+}
+
+// evalModule runs the top-level code of a module. A return statement ends it early.
+//
+// Parameters:
+//   - program: The parsed module.
+//   - env: The environment of the module.
+//
+// Returns:
+//   - *object.Exception: The exception thrown by the code, or nil.
+func evalModule(program *ast.Program, env *object.Environment) *object.Exception {
+	for _, statement := range program.Statements {
+		switch result := Eval(statement, env).(type) {
+		case *object.Exception:
+			return result
+		case *object.ReturnValue:
+			exception, _ := unwrapReturnValue(result).(*object.Exception)
+			return exception
+		}
+	}
+
+	return nil
+}
+
+// evalModuleIndexExpression looks up a value exported by a module.
+//
+// Parameters:
+//   - mod: The module.
+//   - index: The name of the exported value.
+//
+// Returns:
+//   - object.Object: The exported value, or an error if the module does not export the name.
+func evalModuleIndexExpression(mod, index object.Object) object.Object {
+	moduleObject := mod.(*object.Module)
+	name, ok := index.(*object.String)
+	if !ok {
+		return newError("module member must be STRING, got %s", index.Type())
+	}
+
+	val, ok := moduleObject.Exports[name.Value]
+	if !ok {
+		return newError("module %s does not export %s", moduleObject.Path, name.Value)
+	}
+
+	return val
+}
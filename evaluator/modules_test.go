@@ -0,0 +1,165 @@
+package evaluator
+
+import (
+	"monkey/lexer"
+	"monkey/module"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var moduleFiles = map[string]string{
+	"lib/strings.mk": `
+		let join = fn(items, sep) {
+			let out = "";
+			for (let i = 0; i < len(items); i += 1) {
+				if (i > 0) { out += sep; }
+				out += items[i];
+			}
+			out
+		};
+		let hidden = 1;
+		export join;
+	`,
+	"lib/counter.mk": `
+		let count = [0];
+		let next = fn() { count[0] += 1; count[0] };
+		export next;
+	`,
+	"lib/math.mk": `
+		import "strings.mk" as strings;
+		let square = fn(x) { x * x };
+		let describe = fn(name) { strings["join"]([name, "squared"], " ") };
+		export square, describe;
+	`,
+	"lib/early.mk": `
+		let a = 1;
+		if (true) { return 0; }
+		a = 2;
+		export a;
+	`,
+	"lib/macros.mk": `
+		let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) };
+		let sign = fn(x) { unless(x < 0, "pos", "neg") };
+		export sign;
+	`,
+	"lib/fails.mk":     `throw error("module failed", 7);`,
+	"lib/undefined.mk": `let a = 1; export a, b;`,
+	"lib/a.mk":         `import "b.mk" as b; export b;`,
+	"lib/b.mk":         `import "a.mk" as a; export a;`,
+	"lib/broken.mk":    `let = 1;`,
+	"shared/greet.mk":  `let greet = fn(name) { "hello " + name }; export greet;`,
+}
+
+func writeModuleFiles(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range moduleFiles {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func testEvalWithModules(t *testing.T, dir, input string) object.Object {
+	t.Helper()
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	loader := module.NewLoader(filepath.Join(dir, "shared"))
+	loader.Expand = ExpandModuleMacros
+	importer := NewImporter(loader)
+	env := object.NewModuleEnvironment(filepath.Join(dir, "main.mk"), importer)
+
+	return Eval(program, env)
+}
+
+func TestImports(t *testing.T) {
+	dir := writeModuleFiles(t)
+
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`import "lib/strings.mk" as s; s["join"](["a", "b", "c"], ", ")`, "a, b, c"},
+		{`import "lib/math.mk" as m; m["square"](4)`, 16},
+		{`import "lib/math.mk" as m; m["describe"]("x")`, "x squared"},
+		{`import "lib/counter.mk" as a; import "lib/counter.mk" as b; a["next"](); b["next"]()`, 2},
+		{`import "lib/math.mk" as m; import "lib/strings.mk" as s; s["join"](["x", "y"], "")`, "xy"},
+		{`import "lib/early.mk" as e; e["a"]`, 1},
+		{`import "greet.mk" as g; g["greet"]("monkey")`, "hello monkey"},
+		{`import "lib/macros.mk" as m; m.sign(-2) + m.sign(3)`, "negpos"},
+		{`import "lib/macros.mk" as m; unless`, "identifier not found: unless"},
+		{`import "lib/math.mk" as m; m.square(4)`, 16},
+		{`import "lib/strings.mk" as s; s.hidden`, "module " + filepath.Join(dir, "lib/strings.mk") + " does not export hidden"},
+		{`import "lib/fails.mk" as f; 1`, "module failed"},
+		{`import "lib/strings.mk" as s; s["hidden"]`, "module " + filepath.Join(dir, "lib/strings.mk") + " does not export hidden"},
+		{`import "lib/strings.mk" as s; s[1]`, "module member must be STRING, got INTEGER"},
+		{`import "lib/missing.mk" as m;`, `cannot find module "lib/missing.mk"`},
+		{`import "lib/undefined.mk" as u;`, filepath.Join(dir, "lib/undefined.mk") + ":1:22: cannot export undefined name b"},
+		{`import "lib/a.mk" as a;`, "import cycle: " + filepath.Join(dir, "lib/a.mk") + " -> " + filepath.Join(dir, "lib/b.mk") + " -> " + filepath.Join(dir, "lib/a.mk")},
+		{`import "lib/broken.mk" as b;`, filepath.Join(dir, "lib/broken.mk") + ":1:5: expected next token to be IDENT, but got = instead"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithModules(t, dir, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			testStringObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestImportCycleBackToEntry(t *testing.T) {
+	dir := writeModuleFiles(t)
+	a, b := filepath.Join(dir, "lib/a.mk"), filepath.Join(dir, "lib/b.mk")
+
+	p := parser.New(lexer.New(moduleFiles["lib/a.mk"]))
+	program := p.ParseProgram()
+	env := object.NewModuleEnvironment(a, NewImporter(module.NewLoader()))
+
+	evaluated := Eval(program, env)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := "import cycle: " + a + " -> " + b + " -> " + a
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+	}
+}
+
+func TestImportWithoutLoader(t *testing.T) {
+	evaluated := testEval(`import "lib/strings.mk" as s;`)
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := `cannot import "lib/strings.mk" without a module loader`
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+	}
+}
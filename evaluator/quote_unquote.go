@@ -0,0 +1,234 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+	"strconv"
+	"sync/atomic"
+)
+
+// gensymCounter numbers the names made by gensym so that no two are the same.
+var gensymCounter atomic.Int64
+
+// gensym makes a fresh name based on an existing one. The name contains a '#', which the
+// lexer never puts in an identifier, so it cannot clash with a name written by the user.
+//
+// Parameters:
+//   - name: The name the fresh name is based on.
+//
+// Returns:
+//   - string: A name that is not used anywhere else.
+func gensym(name string) string {
+	return fmt.Sprintf("%s#%d", name, gensymCounter.Add(1))
+}
+
+// quote turns the argument of a quote(...) call into a Quote without evaluating it, apart
+// from the unquote(...) calls inside it, which are evaluated and replaced by their result.
+// Bindings introduced inside the quote are renamed to fresh names, so that the code a macro
+// produces cannot capture or shadow the names used in the code it is expanded into.
+//
+// Parameters:
+//   - node: The quote call.
+//   - env: The environment the unquote calls are evaluated in.
+//
+// Returns:
+//   - object.Object: The quote, or an error.
+func quote(node *ast.CallExpression, env *object.Environment) object.Object {
+	if len(node.Arguments) != 1 {
+		return newError("wrong number of arguments to quote: want=1, got=%d", len(node.Arguments))
+	}
+
+	// Set the unquote calls aside while renaming, since the code they produce belongs to the caller.
+	unquotes := map[string]*ast.CallExpression{}
+	quoted := ast.Modify(node.Arguments[0], func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || !isCallTo(call, "unquote") {
+			return node
+		}
+		call = restoreUnquoteCalls(call, unquotes).(*ast.CallExpression)
+		placeholder := gensym("unquote")
+		unquotes[placeholder] = call
+		return &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: placeholder, Pos: call.Pos(), End: call.End()}, Value: placeholder}
+	})
+
+	quoted = renameBindings(quoted)
+
+	var err *object.Exception
+	quoted = ast.Modify(quoted, func(node ast.Node) ast.Node {
+		ident, ok := node.(*ast.Identifier)
+		if !ok || err != nil {
+			return node
+		}
+		call, ok := unquotes[ident.Value]
+		if !ok {
+			return node
+		}
+		if len(call.Arguments) != 1 {
+			err = newError("wrong number of arguments to unquote: want=1, got=%d", len(call.Arguments))
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		if isError(unquoted) {
+			err = unquoted.(*object.Exception)
+			return node
+		}
+
+		replacement, convErr := convertObjectToASTNode(unquoted, call.Token)
+		if convErr != nil {
+			err = convErr
+			return node
+		}
+		return replacement
+	})
+	if err != nil {
+		return err
+	}
+
+	return &object.Quote{Node: quoted}
+}
+
+// restoreUnquoteCalls puts back the unquote calls that were set aside from inside the
+// argument of another unquote call, so that the outer call is evaluated as it was written.
+//
+// Parameters:
+//   - node: The node to restore the calls in.
+//   - unquotes: The unquote calls that were set aside, by placeholder name.
+//
+// Returns:
+//   - ast.Node: The node with its unquote calls restored.
+func restoreUnquoteCalls(node ast.Node, unquotes map[string]*ast.CallExpression) ast.Node {
+	return ast.Modify(node, func(node ast.Node) ast.Node {
+		ident, ok := node.(*ast.Identifier)
+		if !ok {
+			return node
+		}
+		if call, ok := unquotes[ident.Value]; ok {
+			delete(unquotes, ident.Value)
+			return call
+		}
+		return node
+	})
+}
+
+// renameBindings gives every name bound inside a node a fresh name, and renames the
+// identifiers referring to those names to match.
+//
+// Parameters:
+//   - node: The node to rename the bindings in.
+//
+// Returns:
+//   - ast.Node: The node with its bindings renamed.
+func renameBindings(node ast.Node) ast.Node {
+	renames := map[string]string{}
+	bind := func(ident *ast.Identifier) {
+		if ident != nil {
+			if _, ok := renames[ident.Value]; !ok {
+				renames[ident.Value] = gensym(ident.Value)
+			}
+		}
+	}
+
+	ast.Modify(node, func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			bind(node.Name)
+		case *ast.FunctionLiteral:
+			for _, param := range node.Parameters {
+				bind(param)
+			}
+			bind(node.Rest)
+		case *ast.ForInStatement:
+			bind(node.Variable)
+		case *ast.TryExpression:
+			bind(node.CatchParam)
+		case *ast.StructStatement:
+			bind(node.Name)
+		case *ast.EnumStatement:
+			bind(node.Name)
+		case *ast.BindingPattern:
+			bind(node.Name)
+		case *ast.ArrayPattern:
+			bind(node.Rest)
+		}
+		return node
+	})
+	if len(renames) == 0 {
+		return node
+	}
+
+	return ast.Modify(node, func(node ast.Node) ast.Node {
+		ident, ok := node.(*ast.Identifier)
+		if !ok {
+			return node
+		}
+		name, ok := renames[ident.Value]
+		if !ok {
+			return node
+		}
+		tok := ident.Token
+		tok.Literal = name
+		return &ast.Identifier{Token: tok, Value: name}
+	})
+}
+
+// convertObjectToASTNode turns the value of an unquote call back into a node of the tree.
+//
+// Parameters:
+//   - obj: The value of the unquote call.
+//   - tok: The token of the unquote call, used for the position of the new node.
+//
+// Returns:
+//   - ast.Node: The node producing the value.
+//   - *object.Exception: An error if the value cannot be written as a node.
+func convertObjectToASTNode(obj object.Object, tok token.Token) (ast.Node, *object.Exception) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		tok.Type, tok.Literal = token.INT, strconv.FormatInt(obj.Value, 10)
+		return &ast.IntegerLiteral{Token: tok, Value: obj.Value}, nil
+	case *object.Float:
+		tok.Type, tok.Literal = token.FLOAT, obj.Inspect()
+		return &ast.FloatLiteral{Token: tok, Value: obj.Value}, nil
+	case *object.String:
+		tok.Type, tok.Literal = token.STRING, obj.Value
+		return &ast.StringLiteral{Token: tok, Value: obj.Value}, nil
+	case *object.Boolean:
+		tok.Type, tok.Literal = token.FALSE, "false"
+		if obj.Value {
+			tok.Type, tok.Literal = token.TRUE, "true"
+		}
+		return &ast.Boolean{Token: tok, Value: obj.Value}, nil
+	case *object.Array:
+		array := &ast.ArrayLiteral{
+			Token:    token.Token{Type: token.LBRACKET, Literal: "[", Pos: tok.Pos, End: tok.End},
+			Elements: []ast.Expression{},
+		}
+		for _, element := range obj.Snapshot() {
+			node, err := convertObjectToASTNode(element, tok)
+			if err != nil {
+				return nil, err
+			}
+			array.Elements = append(array.Elements, node.(ast.Expression))
+		}
+		return array, nil
+	case *object.Quote:
+		return obj.Node, nil
+	default:
+		return nil, newError("cannot unquote %s", obj.Type())
+	}
+}
+
+// isCallTo reports whether a call expression calls the function with the given name.
+//
+// Parameters:
+//   - call: The call expression.
+//   - name: The name of the function.
+//
+// Returns:
+//   - bool: Whether the called function is the identifier name.
+func isCallTo(call *ast.CallExpression, name string) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
@@ -0,0 +1,126 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"strings"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+	}
+
+	for _, tt := range tests {
+		testQuoteObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote("monkey"))`, `monkey`},
+		{`quote(unquote([1, 2]))`, `[1, 2]`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let quotedInfixExpression = quote(4 + 4);
+		quote(unquote(4 + 4) + unquote(quotedInfixExpression))`, `(8 + (4 + 4))`},
+		{`quote(unquote(quote(unquote(1 + 2))))`, `3`},
+	}
+
+	for _, tt := range tests {
+		testQuoteObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteHygiene(t *testing.T) {
+	evaluated := testEval(`let x = 1; quote(fn(x, y) { let z = x + unquote(x); z + w })`)
+	quote, ok := evaluated.(*object.Quote)
+	if !ok {
+		t.Fatalf("expected *object.Quote. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	fn, ok := quote.Node.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("quote.Node is not *ast.FunctionLiteral. got=%T", quote.Node)
+	}
+
+	x, y := fn.Parameters[0].Value, fn.Parameters[1].Value
+	if !strings.HasPrefix(x, "x#") || !strings.HasPrefix(y, "y#") {
+		t.Fatalf("parameters were not renamed. got=%s, %s", x, y)
+	}
+
+	let := fn.Body.Statements[0].(*ast.LetStatement)
+	z := let.Name.Value
+	if !strings.HasPrefix(z, "z#") {
+		t.Fatalf("let binding was not renamed. got=%s", z)
+	}
+
+	// The unquoted x is evaluated outside the quote, and w is not bound inside it.
+	expected := "fn(" + x + ", " + y + ")let " + z + " = (" + x + " + 1);(" + z + " + w)"
+	if fn.String() != expected {
+		t.Errorf("wrong renaming. want=%q, got=%q", expected, fn.String())
+	}
+
+	again := testEval(`quote(fn(x) { x })`).(*object.Quote).Node.(*ast.FunctionLiteral)
+	if again.Parameters[0].Value == x {
+		t.Errorf("two quotes produced the same name %s", x)
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(1, 2)`, "wrong number of arguments to quote: want=1, got=2"},
+		{`quote(unquote())`, "wrong number of arguments to unquote: want=1, got=0"},
+		{`quote(unquote(fn(x) { x }))`, "cannot unquote FUNCTION"},
+		{`quote(unquote(foobar))`, "identifier not found: foobar"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func testQuoteObject(t *testing.T, evaluated object.Object, expected string) {
+	t.Helper()
+
+	quote, ok := evaluated.(*object.Quote)
+	if !ok {
+		t.Fatalf("expected *object.Quote. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if quote.Node == nil {
+		t.Fatalf("quote.Node is nil")
+	}
+
+	if quote.Node.String() != expected {
+		t.Errorf("not equal. got=%q, want=%q", quote.Node.String(), expected)
+	}
+}
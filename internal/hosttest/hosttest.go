@@ -0,0 +1,88 @@
+// Package hosttest provides what the tests of the evaluator and the virtual machine need to
+// act as a host embedding the interpreter, which adds built-in functions and operators of
+// its own.
+package hosttest
+
+import (
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+// Prelude defines the functions the operators returned by Operators are mapped to, other
+// than the built-in pow.
+const Prelude = `
+let pipe = fn(x, f) { f(x) };
+let range = fn(a, b) { let xs = []; for (let i = a; i <= b; i += 1) { append!(xs, i) } xs };
+let neg = fn(x) { -x };
+`
+
+// AddBuiltin adds a built-in function for the rest of a test, and removes it again when
+// the test ends, so that the built-in functions other tests see do not depend on the order
+// the tests run in.
+//
+// Parameters:
+//   - t: The test.
+//   - name: The name the function is called by.
+//   - fn: The function.
+func AddBuiltin(t testing.TB, name string, fn object.BuiltinFunction) {
+	t.Helper()
+	builtins := object.Builtins
+	if err := object.AddBuiltin(name, fn); err != nil {
+		t.Fatalf("cannot add builtin: %s", err)
+	}
+	t.Cleanup(func() { object.Builtins = builtins })
+}
+
+// Pow is a built-in function raising an integer to the power of another.
+//
+// Parameters:
+//   - args: The base and the exponent.
+//
+// Returns:
+//   - object.Object: The power, or an *object.Exception if the arguments are not integers.
+func Pow(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return &object.Exception{Value: &object.Error{Message: "wrong number of arguments to `pow`"}}
+	}
+	base, ok1 := args[0].(*object.Integer)
+	exponent, ok2 := args[1].(*object.Integer)
+	if !ok1 || !ok2 {
+		return &object.Exception{Value: &object.Error{Message: "arguments to `pow` must be INTEGER"}}
+	}
+
+	result := int64(1)
+	for i := int64(0); i < exponent.Value; i++ {
+		result *= base.Value
+	}
+	return &object.Integer{Value: result}
+}
+
+// Operators adds Pow as the built-in function pow for the rest of a test, and returns a set
+// of operators using it: a right-associative ** mapped to pow, binding tighter than *; a
+// |> pipeline mapped to pipe, binding looser than any operator but assignment; a .. range
+// mapped to range, binding looser than +; and a prefix ~ mapped to neg. Prelude defines
+// pipe, range and neg.
+//
+// Parameters:
+//   - t: The test.
+//
+// Returns:
+//   - *parser.Operators: The operators.
+func Operators(t testing.TB) *parser.Operators {
+	t.Helper()
+	AddBuiltin(t, "pow", Pow)
+
+	operators := parser.NewOperators()
+	for _, err := range []error{
+		operators.Infix("|>", parser.ASSIGN+1, parser.LeftAssociative, "pipe"),
+		operators.Infix("..", parser.SUM-1, parser.LeftAssociative, "range"),
+		operators.Infix("**", parser.PRODUCT+1, parser.RightAssociative, "pow"),
+		operators.Prefix("~", parser.PREFIX, "neg"),
+	} {
+		if err != nil {
+			t.Fatalf("cannot add operator: %s", err)
+		}
+	}
+	return operators
+}
@@ -1,16 +1,39 @@
 package lexer
 
 import (
+	"fmt"
 	"monkey/token"
 	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Lexer represents a lexical analyzer for the Monkey language.
 type Lexer struct {
-	input        string // The source code being lexed.
-	position     int    // The current position in the input (points to current char).
-	readPosition int    // The next position in the input (after current char).
-	ch           byte   // The current char under examination.
+	filename     string   // The name of the file the source code came from, if any.
+	input        string   // The source code being lexed.
+	position     int      // The current byte position in the input (points to current char).
+	readPosition int      // The next byte position in the input (after current char).
+	ch           rune     // The current char under examination, decoded from UTF-8.
+	line         int      // The line number of the current char.
+	column       int      // The column number of the current char, counted in runes.
+	errors       []*Error // The problems found in the source code so far.
+	templates    []int    // The open brace depth of each string interpolation being lexed, innermost last.
+	operators    []string // The operators added with AddOperator, read on top of the built-in ones.
+}
+
+// Error represents a problem found in the source code while lexing, such as an
+// unterminated string literal or an invalid escape sequence.
+type Error struct {
+	Pos     token.Position // The position where the problem was found.
+	Message string         // A description of the problem.
+}
+
+// Error returns the error message prefixed with its position.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
 }
 
 // New creates a new Lexer instance.
@@ -22,62 +45,196 @@ type Lexer struct {
 // Returns:
 //   - *Lexer: A pointer to the newly created Lexer instance.
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFilename("", input)
+}
+
+// NewWithFilename creates a new Lexer instance for source code read from a file.
+// The file name is recorded in the position of every token.
+//
+// Parameters:
+//   - filename: The name of the file that contains the source code.
+//   - input: The source code string to be lexed.
+//
+// Returns:
+//   - *Lexer: A pointer to the newly created Lexer instance.
+func NewWithFilename(filename, input string) *Lexer {
+	l := &Lexer{filename: filename, input: input, line: 1}
 	l.readChar()
 	return l
 }
 
-// readChar reads the next character from the input and updates the Lexer's state.
+// AddOperator makes the lexer read an operator symbol that is not built into the language
+// as a single token, whose type is the symbol itself. Where a valid built-in token starts
+// at the same character, the symbol is only read if it is longer, so adding ** does not
+// change how * is read, and adding .. does not change how ... is read.
+//
+// Parameters:
+//   - symbol: The operator symbol, made of punctuation characters.
+func (l *Lexer) AddOperator(symbol string) {
+	if symbol != "" && !slices.Contains(l.operators, symbol) {
+		l.operators = append(l.operators, symbol)
+	}
+}
+
+// readChar decodes the next UTF-8 character from the input and updates the Lexer's state.
+// Invalid UTF-8 is decoded as utf8.RuneError one byte at a time.
 func (l *Lexer) readChar() {
+	if l.readPosition > len(l.input) {
+		return // Already at the end of the input.
+	}
+
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
+	width := 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition++
+	l.readPosition += width
+}
+
+// Errors returns the problems found in the source code so far.
+//
+// Returns:
+//   - []*Error: The errors in the order they were found.
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
+// addError records a problem found in the source code.
+//
+// Parameters:
+//   - pos: The position of the problem.
+//   - format: The format string used to create the error message.
+//   - a: Arguments to the format string.
+func (l *Lexer) addError(pos token.Position, format string, a ...any) {
+	l.errors = append(l.errors, &Error{Pos: pos, Message: fmt.Sprintf(format, a...)})
+}
+
+// pos returns the source position of the current character.
+//
+// Returns:
+//   - token.Position: The position of the current character.
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
 }
 
 // NextToken evaluates the current character and returns the corresponding token.
+// Any comments before the token are attached to it as leading trivia, and comments
+// that follow it on the same line are attached as trailing trivia.
 func (l *Lexer) NextToken() token.Token {
+	leading := l.readLeadingComments()
+
+	tok := l.readToken()
+	tok.Leading = leading
+	if tok.Type != token.EOF {
+		tok.Trailing = l.readTrailingComments()
+	}
+
+	return tok
+}
+
+// readToken evaluates the current character and returns the corresponding token
+// without any trivia.
+//
+// Returns:
+//   - token.Token: The token starting at the current character.
+func (l *Lexer) readToken() token.Token {
 	var tok token.Token
 
-	l.skipWhitespace()
+	start := l.pos()
+
+	if tok, ok := l.readOperator(); ok {
+		return tok
+	}
 
 	switch l.ch {
 	case '"':
+		tok.Literal, tok.Type = l.readString(token.STRING, token.TEMPLATE_HEAD)
+	case '`':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = l.readRawString()
 	case '=':
 		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.EQ, Literal: literal}
+			tok = l.newTwoCharToken(token.EQ)
+		} else if l.peekChar() == '>' {
+			tok = l.newTwoCharToken(token.FAT_ARROW)
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.PLUS_ASSIGN)
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.MINUS_ASSIGN)
+		} else if l.peekChar() == '>' {
+			tok = l.newTwoCharToken(token.ARROW)
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.NE, Literal: literal}
+			tok = l.newTwoCharToken(token.NE)
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.SLASH_ASSIGN)
+		} else {
+			tok = newToken(token.SLASH, l.ch)
+		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.ASTERISK_ASSIGN)
+		} else {
+			tok = newToken(token.ASTERISK, l.ch)
+		}
+	case '%':
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.PERCENT_ASSIGN)
+		} else {
+			tok = newToken(token.PERCENT, l.ch)
+		}
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.LE)
+		} else {
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.GE)
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.newTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.newTwoCharToken(token.OR)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ',':
@@ -87,8 +244,20 @@ func (l *Lexer) NextToken() token.Token {
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case '{':
+		if len(l.templates) > 0 {
+			l.templates[len(l.templates)-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
+		if len(l.templates) > 0 && l.templates[len(l.templates)-1] == 0 {
+			// This brace closes an interpolated expression, so the string continues.
+			l.templates = l.templates[:len(l.templates)-1]
+			tok.Literal, tok.Type = l.readString(token.TEMPLATE_TAIL, token.TEMPLATE_MIDDLE)
+			break
+		}
+		if len(l.templates) > 0 {
+			l.templates[len(l.templates)-1]--
+		}
 		tok = newToken(token.RBRACE, l.ch)
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
@@ -96,37 +265,166 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RBRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
+		tok.Pos, tok.End = start, start
+		return tok
 	default:
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos, tok.End = start, l.pos()
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Pos, tok.End = start, l.pos()
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			tok = token.Token{Type: token.ILLEGAL, Literal: l.input[l.position:l.readPosition]}
 		}
 	}
 	l.readChar()
+	tok.Pos, tok.End = start, l.pos()
 	return tok
 }
 
+// readOperator reads the longest operator added with AddOperator that starts at the current
+// character, unless a valid built-in token starting there is at least as long.
+//
+// Returns:
+//   - token.Token: The operator token.
+//   - bool: Whether an operator was read.
+func (l *Lexer) readOperator() (token.Token, bool) {
+	symbol := ""
+	for _, op := range l.operators {
+		if len(op) > len(symbol) && strings.HasPrefix(l.input[l.position:], op) {
+			symbol = op
+		}
+	}
+	if symbol == "" {
+		return token.Token{}, false
+	}
+
+	// Read the built-in token on a copy of the lexer to find out how long it is.
+	probe := *l
+	probe.errors = nil
+	probe.templates = slices.Clone(l.templates)
+	probe.operators = nil
+	if tok := probe.readToken(); tok.Type != token.ILLEGAL && probe.position-l.position >= len(symbol) {
+		return token.Token{}, false
+	}
+
+	start := l.pos()
+	for l.position < start.Offset+len(symbol) {
+		l.readChar()
+	}
+	return token.Token{Type: token.TokenType(symbol), Literal: symbol, Pos: start, End: l.pos()}, true
+}
+
 // skipWhitespace is a function that is used to iterate over any
 // whitespace characters to exclude them from token generation.
 func (l *Lexer) skipWhitespace() {
-	whitespace := []byte{' ', '\r', '\t', '\n'}
+	whitespace := []rune{' ', '\r', '\t', '\n'}
 	for slices.Contains(whitespace, l.ch) {
 		l.readChar()
 	}
 }
 
+// readLeadingComments skips whitespace and collects every comment found before
+// the next token.
+//
+// Returns:
+//   - []token.Comment: The comments in the order they were found.
+func (l *Lexer) readLeadingComments() []token.Comment {
+	var comments []token.Comment
+
+	for {
+		l.skipWhitespace()
+		if !l.atComment() {
+			return comments
+		}
+		comments = append(comments, l.readComment())
+	}
+}
+
+// readTrailingComments collects the comments that follow a token on the same line.
+// Scanning stops at the first newline, at the end of a line comment, or at anything
+// other than blanks and comments.
+//
+// Returns:
+//   - []token.Comment: The comments in the order they were found.
+func (l *Lexer) readTrailingComments() []token.Comment {
+	var comments []token.Comment
+
+	for {
+		for l.ch == ' ' || l.ch == '\t' || l.ch == '\r' {
+			l.readChar()
+		}
+		if !l.atComment() {
+			return comments
+		}
+
+		comment := l.readComment()
+		comments = append(comments, comment)
+		if !comment.IsBlock() {
+			return comments
+		}
+	}
+}
+
+// atComment checks to see if the current character starts a line or block comment.
+//
+// Returns:
+//   - bool: True when the input at the current position is // or /*, otherwise false.
+func (l *Lexer) atComment() bool {
+	return l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*')
+}
+
+// readComment reads the line or block comment that starts at the current character.
+// A line comment runs up to, but not including, the next newline. A block comment
+// runs up to and including the closing */; one that is never closed runs to the end
+// of the input and is reported as an error.
+//
+// Returns:
+//   - token.Comment: The comment that was read.
+func (l *Lexer) readComment() token.Comment {
+	start := l.pos()
+
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+	} else {
+		l.readChar()
+		l.readChar()
+		for !(l.ch == '*' && l.peekChar() == '/') && l.ch != 0 {
+			l.readChar()
+		}
+		if l.ch != 0 {
+			l.readChar()
+			l.readChar()
+		} else {
+			l.addError(start, "unterminated block comment")
+		}
+	}
+
+	end := l.pos()
+	return token.Comment{Text: l.input[start.Offset:end.Offset], Pos: start, End: end}
+}
+
 // readIdentifier reads characters until it finds no more letters.
 // It then produces the string identifier between the two positions.
+// An identifier may end in a '!' that is not part of a '!=', which
+// marks functions that mutate their arguments, e.g. append!.
 //
 // Returns:
 //   - string: A new user-defined identifier.
@@ -135,38 +433,198 @@ func (l *Lexer) readIdentifier() string {
 	for isLetter(l.ch) {
 		l.readChar()
 	}
+	if l.ch == '!' && l.peekChar() != '=' {
+		l.readChar()
+	}
 	return l.input[position:l.position]
 }
 
 // readNumber reads characters until it finds no more numeric characters.
 // It then produces the string representation of the number between the two
-// positions.
+// positions. Integers may use a 0x, 0b or 0o prefix and underscores between
+// digits, and a decimal number with a fraction or exponent is a float.
 //
 // Returns:
 //   - string: The string representation of a user provided number.
-func (l *Lexer) readNumber() string {
+//   - token.TokenType: token.FLOAT when the number has a fraction or exponent, otherwise token.INT.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
-	for isDigit(l.ch) {
+	tokenType := token.TokenType(token.INT)
+
+	if l.ch == '0' && isBasePrefix(l.peekChar()) {
 		l.readChar()
+		l.readChar()
+		for isHexDigit(l.ch) || l.ch == '_' {
+			l.readChar()
+		}
+		return l.input[position:l.position], tokenType
 	}
-	return l.input[position:l.position]
+
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if next == '+' || next == '-' {
+			next = l.peekCharAt(2)
+		}
+
+		if isDigit(next) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return l.input[position:l.position], tokenType
+}
+
+// readDigits reads decimal digits and the underscores that separate them.
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+}
+
+// readString reads a part of a double quoted string literal and processes its escape
+// sequences. A part starts at the opening " or at the } that closes an interpolated
+// expression, and ends at the closing " or at the ${ that opens the next interpolated
+// expression. The lexer is left on the last character of the part. An unterminated
+// literal or an invalid escape sequence is recorded as an error and the characters
+// read so far are kept.
+//
+// Parameters:
+//   - endType: The token type of a part that ends the string literal.
+//   - interpolationType: The token type of a part that ends with ${.
+//
+// Returns:
+//   - string: The string value with escape sequences replaced.
+//   - token.TokenType: endType or interpolationType depending on how the part ended.
+func (l *Lexer) readString(endType, interpolationType token.TokenType) (string, token.TokenType) {
+	start := l.pos()
+	var out strings.Builder
+
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case '"':
+			return out.String(), endType
+		case 0:
+			l.addError(start, "unterminated string literal")
+			return out.String(), endType
+		case '$':
+			if l.peekChar() != '{' {
+				out.WriteRune(l.ch)
+				break
+			}
+			l.readChar()
+			l.templates = append(l.templates, 0)
+			return out.String(), interpolationType
+		case '\\':
+			l.readEscape(&out)
+		default:
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
+// readEscape processes the escape sequence that starts at the current \ character
+// and writes the resulting character to out. The lexer is left on the last character
+// of the escape sequence.
+//
+// Parameters:
+//   - out: The builder for the string value being read.
+func (l *Lexer) readEscape(out *strings.Builder) {
+	start := l.pos()
+	l.readChar()
+
+	switch l.ch {
+	case 'n':
+		out.WriteRune('\n')
+	case 't':
+		out.WriteRune('\t')
+	case 'r':
+		out.WriteRune('\r')
+	case '0':
+		out.WriteRune(0)
+	case '\\', '"', '\'', '$':
+		out.WriteRune(l.ch)
+	case 'u':
+		l.readUnicodeEscape(start, out)
+	case 0:
+		// Let readString report the unterminated literal.
+		out.WriteRune('\\')
+	default:
+		l.addError(start, "invalid escape sequence \\%c", l.ch)
+		out.WriteRune('\\')
+		out.WriteRune(l.ch)
+	}
+}
+
+// readUnicodeEscape processes a \u{XXXX} escape sequence where the lexer is on the u.
+// Between one and six hexadecimal digits name the Unicode code point.
+//
+// Parameters:
+//   - start: The position of the \ that started the escape sequence.
+//   - out: The builder for the string value being read.
+func (l *Lexer) readUnicodeEscape(start token.Position, out *strings.Builder) {
+	if l.peekChar() != '{' {
+		l.addError(start, "invalid escape sequence \\u, expected \\u{XXXX}")
+		return
+	}
+	l.readChar()
+
+	digitsStart := l.readPosition
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+	}
+	digits := l.input[digitsStart:l.readPosition]
+
+	if l.peekChar() != '}' || len(digits) == 0 || len(digits) > 6 {
+		l.addError(start, "invalid escape sequence \\u{%s, expected \\u{XXXX}", digits)
+		return
+	}
+	l.readChar()
+
+	value, _ := strconv.ParseUint(digits, 16, 32)
+	r := rune(value)
+	if !utf8.ValidRune(r) {
+		l.addError(start, "invalid Unicode code point U+%s in escape sequence", strings.ToUpper(digits))
+		return
+	}
+
+	out.WriteRune(r)
 }
 
-// readString reads characters until it finds no more " characters.
-// It then produces the string representation of the string literal.
+// readRawString reads a backtick quoted string literal. Raw strings may span
+// several lines and do not process escape sequences. The lexer is left on the
+// closing ` character.
 //
 // Returns:
 //   - string: The string value.
-func (l *Lexer) readString() string {
+func (l *Lexer) readRawString() string {
+	start := l.pos()
 	position := l.position + 1
+
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		if l.ch == '`' {
+			return l.input[position:l.position]
+		}
+		if l.ch == 0 {
+			l.addError(start, "unterminated raw string literal")
+			return l.input[position:l.position]
 		}
 	}
-
-	return l.input[position:l.position]
 }
 
 // newToken is a helper function to generate a Token.
@@ -180,11 +638,27 @@ func (l *Lexer) readString() string {
 //
 // Returns:
 //   - Token: A new token.
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-// isLetter checks to see if a character is a letter or an underscore.
+// newTwoCharToken generates a token from the current and the next character,
+// leaving the lexer on the second character.
+//
+// Example: '<' followed by '=' for less than or equal.
+//
+// Parameters:
+//   - tokenType: The TokenType of the new token.
+//
+// Returns:
+//   - Token: A new token.
+func (l *Lexer) newTwoCharToken(tokenType token.TokenType) token.Token {
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: tokenType, Literal: string(ch) + string(l.ch)}
+}
+
+// isLetter checks to see if a character is a Unicode letter or an underscore.
 // These values are considered valid when used by an identifier.
 //
 // Parameters:
@@ -192,8 +666,8 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 //
 // Returns:
 //   - bool: True when the character is a letter, otherwise false.
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
 // isDigit checks to see if a character is considered to be numeric.
@@ -204,17 +678,65 @@ func isLetter(ch byte) bool {
 //
 // Returns:
 //   - bool: True when the character is numeric, otherwise false.
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-// peekChar returns the next character in the source input.
+// isBasePrefix checks to see if a character follows the 0 of a prefixed integer literal.
+//
+// Parameters:
+//   - ch: the character to check.
+//
+// Returns:
+//   - bool: True when the character is x, b or o in either case, otherwise false.
+func isBasePrefix(ch rune) bool {
+	switch ch {
+	case 'x', 'X', 'b', 'B', 'o', 'O':
+		return true
+	}
+	return false
+}
+
+// isHexDigit checks to see if a character is a hexadecimal digit.
+//
+// Parameters:
+//   - ch: the character to check.
 //
 // Returns:
-//   - byte: The next character beyond the current read position.
-func (l *Lexer) peekChar() byte {
+//   - bool: True when the character is 0-9, a-f, or A-F, otherwise false.
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+// peekChar decodes the next character in the source input without consuming it.
+//
+// Returns:
+//   - rune: The next character beyond the current read position.
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
 	}
-	return l.input[l.readPosition]
+	ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return ch
+}
+
+// peekCharAt decodes a character further ahead in the source input without consuming it.
+//
+// Parameters:
+//   - n: How many characters ahead to look, where 1 is the same as peekChar.
+//
+// Returns:
+//   - rune: The character n places beyond the current character, or 0 past the end of the input.
+func (l *Lexer) peekCharAt(n int) rune {
+	offset := l.readPosition
+	for ; n > 1 && offset < len(l.input); n-- {
+		_, size := utf8.DecodeRuneInString(l.input[offset:])
+		offset += size
+	}
+
+	if offset >= len(l.input) {
+		return 0
+	}
+	ch, _ := utf8.DecodeRuneInString(l.input[offset:])
+	return ch
 }
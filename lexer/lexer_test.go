@@ -14,7 +14,7 @@ let add = fn(x, y) {
 };
 
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if (5 > 10) {
@@ -137,3 +137,669 @@ if (5 > 10) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x == "hi";`
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+		expectedEnd  token.Position
+	}{
+		{token.LET, token.Position{Filename: "test.mk", Offset: 0, Line: 1, Column: 1}, token.Position{Filename: "test.mk", Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, token.Position{Filename: "test.mk", Offset: 4, Line: 1, Column: 5}, token.Position{Filename: "test.mk", Offset: 5, Line: 1, Column: 6}},
+		{token.ASSIGN, token.Position{Filename: "test.mk", Offset: 6, Line: 1, Column: 7}, token.Position{Filename: "test.mk", Offset: 7, Line: 1, Column: 8}},
+		{token.INT, token.Position{Filename: "test.mk", Offset: 8, Line: 1, Column: 9}, token.Position{Filename: "test.mk", Offset: 9, Line: 1, Column: 10}},
+		{token.SEMICOLON, token.Position{Filename: "test.mk", Offset: 9, Line: 1, Column: 10}, token.Position{Filename: "test.mk", Offset: 10, Line: 1, Column: 11}},
+		{token.IDENT, token.Position{Filename: "test.mk", Offset: 13, Line: 2, Column: 3}, token.Position{Filename: "test.mk", Offset: 14, Line: 2, Column: 4}},
+		{token.EQ, token.Position{Filename: "test.mk", Offset: 15, Line: 2, Column: 5}, token.Position{Filename: "test.mk", Offset: 17, Line: 2, Column: 7}},
+		{token.STRING, token.Position{Filename: "test.mk", Offset: 18, Line: 2, Column: 8}, token.Position{Filename: "test.mk", Offset: 22, Line: 2, Column: 12}},
+		{token.SEMICOLON, token.Position{Filename: "test.mk", Offset: 22, Line: 2, Column: 12}, token.Position{Filename: "test.mk", Offset: 23, Line: 2, Column: 13}},
+		{token.EOF, token.Position{Filename: "test.mk", Offset: 23, Line: 2, Column: 13}, token.Position{Filename: "test.mk", Offset: 23, Line: 2, Column: 13}},
+	}
+
+	l := NewWithFilename("test.mk", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Pos != tt.expectedPos {
+			t.Errorf("tests[%d] - pos wrong. expected=%+v, got=%+v", i, tt.expectedPos, tok.Pos)
+		}
+
+		if tok.End != tt.expectedEnd {
+			t.Errorf("tests[%d] - end wrong. expected=%+v, got=%+v", i, tt.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading line
+/* leading
+   block */ let x = 5; // trailing
+let y /* inline */ = x / 2;
+/* unterminated`
+
+	tests := []struct {
+		expectedType     token.TokenType
+		expectedLiteral  string
+		expectedLeading  []string
+		expectedTrailing []string
+	}{
+		{token.LET, "let", []string{"// leading line", "/* leading\n   block */"}, nil},
+		{token.IDENT, "x", nil, nil},
+		{token.ASSIGN, "=", nil, nil},
+		{token.INT, "5", nil, nil},
+		{token.SEMICOLON, ";", nil, []string{"// trailing"}},
+		{token.LET, "let", nil, nil},
+		{token.IDENT, "y", nil, []string{"/* inline */"}},
+		{token.ASSIGN, "=", nil, nil},
+		{token.IDENT, "x", nil, nil},
+		{token.SLASH, "/", nil, nil},
+		{token.INT, "2", nil, nil},
+		{token.SEMICOLON, ";", nil, nil},
+		{token.EOF, "", []string{"/* unterminated"}, nil},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		testComments(t, i, "leading", tt.expectedLeading, tok.Leading)
+		testComments(t, i, "trailing", tt.expectedTrailing, tok.Trailing)
+	}
+}
+
+func testComments(t *testing.T, i int, kind string, expected []string, actual []token.Comment) {
+	t.Helper()
+
+	if len(actual) != len(expected) {
+		t.Fatalf("tests[%d] - wrong number of %s comments. expected=%d, got=%d", i, kind, len(expected), len(actual))
+	}
+
+	for j, text := range expected {
+		if actual[j].Text != text {
+			t.Errorf("tests[%d] - %s comment %d wrong. expected=%q, got=%q", i, kind, j, text, actual[j].Text)
+		}
+	}
+}
+
+func TestUnicodeInput(t *testing.T) {
+	input := `let café = "héllo wörld";
+let 名前 = café;
+über`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENT, "café", 5},
+		{token.ASSIGN, "=", 10},
+		{token.STRING, "héllo wörld", 12},
+		{token.SEMICOLON, ";", 25},
+		{token.LET, "let", 1},
+		{token.IDENT, "名前", 5},
+		{token.ASSIGN, "=", 8},
+		{token.IDENT, "café", 10},
+		{token.SEMICOLON, ";", 14},
+		{token.IDENT, "über", 1},
+		{token.EOF, "", 5},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - column wrong. expected=%d, got=%d", i, tt.expectedColumn, tok.Pos.Column)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\nb"`, "a\nb"},
+		{`"tab\there"`, "tab\there"},
+		{`"\r\0"`, "\r\x00"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"it\'s"`, "it's"},
+		{`"back\\slash"`, `back\slash`},
+		{`"\u{48}\u{e9}"`, "Hé"},
+		{`"\u{1F600}"`, "😀"},
+		{"`raw\\n\n\"string\"`", "raw\\n\n\"string\""},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.STRING {
+			t.Fatalf("input %q - tokentype wrong. expected=%q, got=%q", tt.input, token.STRING, tok.Type)
+		}
+
+		if tok.Literal != tt.expected {
+			t.Errorf("input %q - literal wrong. expected=%q, got=%q", tt.input, tt.expected, tok.Literal)
+		}
+
+		if len(l.Errors()) != 0 {
+			t.Errorf("input %q - unexpected errors: %v", tt.input, l.Errors())
+		}
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"abc`, `1:1: unterminated string literal`},
+		{"let s = `abc", `1:9: unterminated raw string literal`},
+		{`"a\qb"`, `1:3: invalid escape sequence \q`},
+		{`"\u1234"`, `1:2: invalid escape sequence \u, expected \u{XXXX}`},
+		{`"\u{12"`, `1:2: invalid escape sequence \u{12, expected \u{XXXX}`},
+		{`"\u{D800}"`, `1:2: invalid Unicode code point U+D800 in escape sequence`},
+		{"x /* open", `1:3: unterminated block comment`},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("input %q - wrong number of errors. expected=1, got=%d (%v)", tt.input, len(errors), errors)
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("input %q - wrong error. expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	input := `5 3.14 0.5e3 1E-2 2e+10 0xFF 0b1010 0o17 1_000_000 7.foo 1e`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "0.5e3"},
+		{token.FLOAT, "1E-2"},
+		{token.FLOAT, "2e+10"},
+		{token.INT, "0xFF"},
+		{token.INT, "0b1010"},
+		{token.INT, "0o17"},
+		{token.INT, "1_000_000"},
+		{token.INT, "7"},
+		{token.DOT, "."},
+		{token.IDENT, "foo"},
+		{token.INT, "1"},
+		{token.IDENT, "e"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestComparisonAndLogicalOperators(t *testing.T) {
+	input := `a <= b >= c && d || e % f & |`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.LE, "<="},
+		{token.IDENT, "b"},
+		{token.GE, ">="},
+		{token.IDENT, "c"},
+		{token.AND, "&&"},
+		{token.IDENT, "d"},
+		{token.OR, "||"},
+		{token.IDENT, "e"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "f"},
+		{token.ILLEGAL, "&"},
+		{token.ILLEGAL, "|"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	input := `"Hello ${user["name"]}, you have ${count + 1} items" "${ {"a": 1}["a"] }" "cost: \${x}" "$5"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.TEMPLATE_HEAD, "Hello "},
+		{token.IDENT, "user"},
+		{token.LBRACKET, "["},
+		{token.STRING, "name"},
+		{token.RBRACKET, "]"},
+		{token.TEMPLATE_MIDDLE, ", you have "},
+		{token.IDENT, "count"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.TEMPLATE_TAIL, " items"},
+		{token.TEMPLATE_HEAD, ""},
+		{token.LBRACE, "{"},
+		{token.STRING, "a"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "a"},
+		{token.RBRACKET, "]"},
+		{token.TEMPLATE_TAIL, ""},
+		{token.STRING, "cost: ${x}"},
+		{token.STRING, "$5"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestAssignmentOperators(t *testing.T) {
+	input := `x += 1; x -= 1; x *= 2; x /= 2; x %= 2;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PERCENT_ASSIGN, "%="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLoopKeywords(t *testing.T) {
+	input := `while for in break continue iterate`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.IN, "in"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.IDENT, "iterate"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestMatchTokens(t *testing.T) {
+	input := `match (x) { [a, ...rest] => a, _ => 0 } ..`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.MATCH, "match"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "a"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RBRACKET, "]"},
+		{token.FAT_ARROW, "=>"},
+		{token.IDENT, "a"},
+		{token.COMMA, ","},
+		{token.IDENT, "_"},
+		{token.FAT_ARROW, "=>"},
+		{token.INT, "0"},
+		{token.RBRACE, "}"},
+		{token.DOT, "."},
+		{token.DOT, "."},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestMutatingIdentifiers(t *testing.T) {
+	input := `append!(a, 1); a!=b; !done`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "append!"},
+		{token.LPAREN, "("},
+		{token.IDENT, "a"},
+		{token.COMMA, ","},
+		{token.INT, "1"},
+		{token.RPAREN, ")"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.NE, "!="},
+		{token.IDENT, "b"},
+		{token.SEMICOLON, ";"},
+		{token.BANG, "!"},
+		{token.IDENT, "done"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestStructTokens(t *testing.T) {
+	input := `struct Point { x, y } impl Point { fn dist(self) { self.x } } p.x = 1.5;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRUCT, "struct"},
+		{token.IDENT, "Point"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.COMMA, ","},
+		{token.IDENT, "y"},
+		{token.RBRACE, "}"},
+		{token.IMPL, "impl"},
+		{token.IDENT, "Point"},
+		{token.LBRACE, "{"},
+		{token.FUNCTION, "fn"},
+		{token.IDENT, "dist"},
+		{token.LPAREN, "("},
+		{token.IDENT, "self"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "self"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.RBRACE, "}"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "p"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.FLOAT, "1.5"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTypeAnnotationTokens(t *testing.T) {
+	input := `let f: fn(int) -> [string] = x - y;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "f"},
+		{token.COLON, ":"},
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "int"},
+		{token.RPAREN, ")"},
+		{token.ARROW, "->"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "string"},
+		{token.RBRACKET, "]"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "x"},
+		{token.MINUS, "-"},
+		{token.IDENT, "y"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestGeneratorTokens(t *testing.T) {
+	input := `fn*(n) { yield n * 2; }`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FUNCTION, "fn"},
+		{token.ASTERISK, "*"},
+		{token.LPAREN, "("},
+		{token.IDENT, "n"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.YIELD, "yield"},
+		{token.IDENT, "n"},
+		{token.ASTERISK, "*"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestAddedOperators(t *testing.T) {
+	input := `x |> f ** 2; ...y .. z* *3 |`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"},
+		{"|>", "|>"},
+		{token.IDENT, "f"},
+		{"**", "**"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "y"},
+		{"..", ".."},
+		{token.IDENT, "z"},
+		{token.ASTERISK, "*"},
+		{token.ASTERISK, "*"},
+		{token.INT, "3"},
+		{token.ILLEGAL, "|"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	l.AddOperator("|>")
+	l.AddOperator("**")
+	l.AddOperator("..")
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tt.expectedLiteral == ".." && (tok.Pos.Column != 19 || tok.End.Column != 21) {
+			t.Fatalf("tests[%d] - wrong span. expected=19..21, got=%d..%d", i, tok.Pos.Column, tok.End.Column)
+		}
+	}
+}
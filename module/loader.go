@@ -0,0 +1,198 @@
+package module
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SearchPathEnv is the environment variable holding the default search path, a list of
+// directories separated like the PATH variable of the operating system.
+const SearchPathEnv = "MONKEYPATH"
+
+// Loader finds and parses the files named in import statements.
+type Loader struct {
+	SearchPath []string                                         // The directories searched for imports that are not found next to the importing file.
+	Operators  *parser.Operators                                // The operators added by the host, parsed in module files too; nil if there are none.
+	Expand     func(program *ast.Program) (*ast.Program, error) // Defines and expands the macros of a parsed module file; nil if modules cannot use macros.
+}
+
+// Error is a problem with a module file, such as a parse error, reported with the path of the file.
+type Error struct {
+	Path string // The path of the module file.
+	Err  error  // The problem, whose message usually starts with its position in the file.
+}
+
+// Error returns the error message prefixed with the path of the module file.
+func (e *Error) Error() string {
+	return e.Path + ":" + e.Err.Error()
+}
+
+// Unwrap returns the underlying problem.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewLoader creates a new loader.
+//
+// Parameters:
+//   - searchPath: The directories searched for imports, in order.
+//
+// Returns:
+//   - *Loader: The new loader.
+func NewLoader(searchPath ...string) *Loader {
+	return &Loader{SearchPath: searchPath}
+}
+
+// NewLoaderFromEnv creates a new loader searching the directories listed in the MONKEYPATH
+// environment variable.
+//
+// Returns:
+//   - *Loader: The new loader.
+func NewLoaderFromEnv() *Loader {
+	return NewLoader(filepath.SplitList(os.Getenv(SearchPathEnv))...)
+}
+
+// Resolve finds the file an import statement refers to. A path starting with ./ or ../ is
+// relative to the directory of the importing file; any other relative path is looked up there
+// first and then in each directory of the search path.
+//
+// Parameters:
+//   - path: The path written in the import statement.
+//   - from: The path of the importing file, or an empty string for code not read from a file.
+//
+// Returns:
+//   - string: The cleaned path of the module file, which identifies the module.
+//   - error: An error if no such file exists.
+func (l *Loader) Resolve(path, from string) (string, error) {
+	if filepath.IsAbs(path) {
+		if isFile(path) {
+			return filepath.Clean(path), nil
+		}
+		return "", fmt.Errorf("cannot find module %q", path)
+	}
+
+	dir := "."
+	if from != "" {
+		dir = filepath.Dir(from)
+	}
+	candidates := []string{filepath.Join(dir, path)}
+
+	if !strings.HasPrefix(path, "./") && !strings.HasPrefix(path, "../") {
+		for _, searchDir := range l.SearchPath {
+			candidates = append(candidates, filepath.Join(searchDir, path))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if isFile(candidate) {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("cannot find module %q", path)
+}
+
+// Parse reads and parses a module file, and expands the macros it defines with Expand.
+//
+// Parameters:
+//   - path: The resolved path of the module file.
+//
+// Returns:
+//   - *ast.Program: The parsed module.
+//   - error: An *Error if the file cannot be read, contains a parse error, or its macros
+//     cannot be expanded, which without Expand is whenever it defines any.
+func (l *Loader) Parse(path string) (*ast.Program, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &Error{Path: path, Err: err}
+	}
+
+	p := parser.NewWithOperators(lexer.New(string(source)), l.Operators)
+	program := p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 0 {
+		return nil, &Error{Path: path, Err: errors[0]}
+	}
+
+	if l.Expand == nil {
+		if macro := firstMacro(program); macro != nil {
+			return nil, &Error{Path: path, Err: fmt.Errorf("%s: macros are not supported in modules", macro.Pos())}
+		}
+		return program, nil
+	}
+
+	program, err = l.Expand(program)
+	if err != nil {
+		return nil, &Error{Path: path, Err: err}
+	}
+	return program, nil
+}
+
+// firstMacro finds the first macro definition at the top level of a module.
+//
+// Parameters:
+//   - program: The parsed module.
+//
+// Returns:
+//   - *ast.LetStatement: The let statement binding a macro literal, or nil if there is none.
+func firstMacro(program *ast.Program) *ast.LetStatement {
+	for _, statement := range program.Statements {
+		if let, ok := statement.(*ast.LetStatement); ok {
+			if _, ok := let.Value.(*ast.MacroLiteral); ok {
+				return let
+			}
+		}
+	}
+	return nil
+}
+
+// Exports lists the names exported by the export statements of a module.
+//
+// Parameters:
+//   - program: The parsed module.
+//
+// Returns:
+//   - []*ast.Identifier: The exported names, in the order they are listed.
+func Exports(program *ast.Program) []*ast.Identifier {
+	exports := []*ast.Identifier{}
+	for _, statement := range program.Statements {
+		if export, ok := statement.(*ast.ExportStatement); ok {
+			exports = append(exports, export.Names...)
+		}
+	}
+	return exports
+}
+
+// Cycle describes the import cycle formed by importing a module that is still being loaded.
+//
+// Parameters:
+//   - loading: The modules being loaded, the outermost first.
+//   - path: The module being imported.
+//
+// Returns:
+//   - error: The import cycle error, or nil when importing the module forms no cycle.
+func Cycle(loading []string, path string) error {
+	for i, p := range loading {
+		if p == path {
+			cycle := append(append([]string{}, loading[i:]...), path)
+			return fmt.Errorf("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	return nil
+}
+
+// isFile reports whether a path names an existing regular file.
+//
+// Parameters:
+//   - path: The path to check.
+//
+// Returns:
+//   - bool: Whether the file exists.
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
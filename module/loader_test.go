@@ -0,0 +1,198 @@
+package module
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib")
+	writeFiles(t, dir, map[string]string{
+		"app/main.mk":        "",
+		"app/util.mk":        "",
+		"app/lib/strings.mk": "",
+		"lib/strings.mk":     "",
+		"lib/math.mk":        "",
+	})
+	loader := NewLoader(lib)
+	from := filepath.Join(dir, "app", "main.mk")
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"util.mk", filepath.Join(dir, "app", "util.mk")},
+		{"./util.mk", filepath.Join(dir, "app", "util.mk")},
+		{"lib/strings.mk", filepath.Join(dir, "app", "lib", "strings.mk")},
+		{"math.mk", filepath.Join(lib, "math.mk")},
+		{"../lib/math.mk", filepath.Join(lib, "math.mk")},
+		{filepath.Join(lib, "strings.mk"), filepath.Join(lib, "strings.mk")},
+	}
+
+	for _, tt := range tests {
+		resolved, err := loader.Resolve(tt.path, from)
+		if err != nil {
+			t.Errorf("Resolve(%q) failed: %s", tt.path, err)
+			continue
+		}
+		if resolved != tt.expected {
+			t.Errorf("Resolve(%q) wrong. want=%q, got=%q", tt.path, tt.expected, resolved)
+		}
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib")
+	writeFiles(t, dir, map[string]string{
+		"lib/math.mk": "",
+	})
+	loader := NewLoader(lib)
+	from := filepath.Join(dir, "main.mk")
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"missing.mk", `cannot find module "missing.mk"`},
+		{"./math.mk", `cannot find module "./math.mk"`},
+		{"lib", `cannot find module "lib"`},
+	}
+
+	for _, tt := range tests {
+		_, err := loader.Resolve(tt.path, from)
+		if err == nil {
+			t.Errorf("Resolve(%q) did not fail", tt.path)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"good.mk": "let a = 1; let b = 2; export a; export b;",
+		"bad.mk":  "let a = 1;\nlet = 2;",
+	})
+	loader := NewLoader()
+
+	program, err := loader.Parse(filepath.Join(dir, "good.mk"))
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+
+	exports := Exports(program)
+	if len(exports) != 2 || exports[0].Value != "a" || exports[1].Value != "b" {
+		t.Errorf("wrong exports. got=%v", exports)
+	}
+
+	bad := filepath.Join(dir, "bad.mk")
+	_, err = loader.Parse(bad)
+	if err == nil {
+		t.Fatalf("Parse did not fail")
+	}
+
+	expected := bad + ":2:5: expected next token to be IDENT, but got = instead"
+	if err.Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%q", expected, err.Error())
+	}
+}
+
+func TestParseWithOperators(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"pipe.mk": "let x = 1 |> f;"})
+	path := filepath.Join(dir, "pipe.mk")
+
+	if _, err := NewLoader().Parse(path); err == nil {
+		t.Fatalf("Parse did not fail without the added operator")
+	}
+
+	loader := NewLoader()
+	loader.Operators = parser.NewOperators()
+	if err := loader.Operators.Infix("|>", parser.ASSIGN+1, parser.LeftAssociative, "pipe"); err != nil {
+		t.Fatalf("cannot add operator: %s", err)
+	}
+
+	program, err := loader.Parse(path)
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	if program.String() != "let x = pipe(1, f);" {
+		t.Errorf("wrong program. got=%q", program.String())
+	}
+}
+
+func TestParseMacros(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"macros.mk": "let a = 1;\nlet m = macro(x) { x };\nexport a;"})
+	path := filepath.Join(dir, "macros.mk")
+
+	_, err := NewLoader().Parse(path)
+	if err == nil {
+		t.Fatalf("Parse did not fail without Expand")
+	}
+	expected := path + ":2:1: macros are not supported in modules"
+	if err.Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%q", expected, err.Error())
+	}
+
+	loader := NewLoader()
+	loader.Expand = func(program *ast.Program) (*ast.Program, error) {
+		program.Statements = program.Statements[:1]
+		return program, nil
+	}
+	program, err := loader.Parse(path)
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	if program.String() != "let a = 1;" {
+		t.Errorf("wrong program. got=%q", program.String())
+	}
+
+	loader.Expand = func(program *ast.Program) (*ast.Program, error) {
+		return nil, fmt.Errorf("2:1: expansion failed")
+	}
+	_, err = loader.Parse(path)
+	if err == nil || err.Error() != path+":2:1: expansion failed" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestCycle(t *testing.T) {
+	loading := []string{"main.mk", "a.mk", "b.mk"}
+
+	if err := Cycle(loading, "c.mk"); err != nil {
+		t.Errorf("unexpected cycle: %s", err)
+	}
+
+	err := Cycle(loading, "a.mk")
+	if err == nil {
+		t.Fatalf("cycle not detected")
+	}
+
+	expected := "import cycle: a.mk -> b.mk -> a.mk"
+	if err.Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%q", expected, err.Error())
+	}
+}
@@ -1,6 +1,10 @@
 package object
 
-import "fmt"
+import (
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+)
 
 var Builtins = []struct {
 	Name    string
@@ -15,9 +19,16 @@ var Builtins = []struct {
 
 			switch arg := args[0].(type) {
 			case *Array:
-				return &Integer{Value: int64(len(arg.Elements))}
+				return &Integer{Value: int64(arg.Len())}
 			case *String:
-				return &Integer{Value: int64(len(arg.Value))}
+				return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			case *Generator:
+				iterator, _ := NewIterator(arg)
+				elements, exception := collect(iterator)
+				if exception != nil {
+					return exception
+				}
+				return &Integer{Value: int64(len(elements))}
 			default:
 				return newError("argument to `len` not supported, got %s", args[0].Type())
 			}
@@ -45,9 +56,8 @@ var Builtins = []struct {
 				if args[0].Type() != ARRAY_OBJ {
 					return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
 				}
-				arr := args[0].(*Array)
-				if len(arr.Elements) > 0 {
-					return arr.Elements[0]
+				if first, ok := args[0].(*Array).At(0); ok {
+					return first
 				}
 
 				return nil
@@ -64,9 +74,8 @@ var Builtins = []struct {
 				if args[0].Type() != ARRAY_OBJ {
 					return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
 				}
-				arr := args[0].(*Array)
-				if len(arr.Elements) > 0 {
-					return arr.Elements[len(arr.Elements)-1]
+				if last, ok := args[0].(*Array).At(-1); ok {
+					return last
 				}
 
 				return nil
@@ -85,12 +94,9 @@ var Builtins = []struct {
 					return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
 				}
 
-				arr := args[0].(*Array)
-				length := len(arr.Elements)
-				if length > 0 {
-					newElements := make([]Object, length-1, length-1)
-					copy(newElements, arr.Elements[1:length])
-					return &Array{Elements: newElements}
+				elements := args[0].(*Array).Snapshot()
+				if len(elements) > 0 {
+					return &Array{Elements: elements[1:]}
 				}
 				return nil
 			},
@@ -108,28 +114,432 @@ var Builtins = []struct {
 					return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
 				}
 
-				arr := args[0].(*Array)
-				length := len(arr.Elements)
+				elements := args[0].(*Array).Snapshot()
+				return &Array{Elements: append(elements, args[1])}
+			},
+		},
+	},
+	{
+		"append!",
+		&Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) < 1 {
+					return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+				}
 
-				newElements := make([]Object, length+1, length+1)
-				copy(newElements, arr.Elements)
-				newElements[length] = args[1]
-				return &Array{Elements: newElements}
+				arr, ok := args[0].(*Array)
+				if !ok {
+					return newError("argument to `append!` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr.Append(args[1:]...)
+				return arr
 			},
 		},
 	},
+	{
+		"delete!",
+		&Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+
+				hash, ok := args[0].(*Hash)
+				if !ok {
+					return newError("argument to `delete!` must be HASH, got %s", args[0].Type())
+				}
+				key, ok := args[1].(Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s", args[1].Type())
+				}
+
+				pair, ok := hash.Delete(key.HashKey())
+				if !ok {
+					return nil
+				}
+				return pair.Value
+			},
+		},
+	},
+	{
+		"error",
+		&Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) < 1 || len(args) > 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
+
+				message, ok := args[0].(*String)
+				if !ok {
+					return newError("argument to `error` must be STRING, got %s", args[0].Type())
+				}
+
+				err := &Error{Message: message.Value}
+				if len(args) == 2 {
+					err.Data = args[1]
+				}
+				return err
+			},
+		},
+	},
+	{
+		"next",
+		&Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) < 1 || len(args) > 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
+
+				generator, ok := args[0].(*Generator)
+				if !ok {
+					return newError("argument to `next` must be GENERATOR, got %s", args[0].Type())
+				}
+
+				value, ok := generator.Next()
+				if !ok {
+					// The optional second argument is returned once the generator is exhausted.
+					if len(args) == 2 {
+						return args[1]
+					}
+					return nil
+				}
+				return value
+			},
+		},
+	},
+	{
+		"array",
+		&Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				iterator, ok := NewIterator(args[0])
+				if !ok {
+					return newError("argument to `array` not supported, got %s", args[0].Type())
+				}
+
+				elements, exception := collect(iterator)
+				if exception != nil {
+					return exception
+				}
+				return &Array{Elements: elements}
+			},
+		},
+	},
+	{
+		"spawn",
+		&Builtin{CallingFn: Spawn},
+	},
+	{
+		"chan",
+		&Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) > 1 {
+					return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+				}
+				if len(args) == 0 {
+					return NewChannel(0)
+				}
+
+				capacity, ok := args[0].(*Integer)
+				if !ok {
+					return newError("argument to `chan` must be INTEGER, got %s", args[0].Type())
+				}
+				if capacity.Value < 0 {
+					return newError("capacity of `chan` must not be negative, got %d", capacity.Value)
+				}
+				return NewChannel(int(capacity.Value))
+			},
+		},
+	},
+	{
+		"send",
+		&Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+
+				channel, ok := args[0].(*Channel)
+				if !ok {
+					return newError("argument to `send` must be CHANNEL, got %s", args[0].Type())
+				}
+				if err := channel.send(args[1]); err != nil {
+					return err
+				}
+				return nil
+			},
+		},
+	},
+	{
+		"recv",
+		&Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) < 1 || len(args) > 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
+
+				channel, ok := args[0].(*Channel)
+				if !ok {
+					return newError("argument to `recv` must be CHANNEL, got %s", args[0].Type())
+				}
+
+				value, ok := channel.Receive()
+				if !ok {
+					// The optional second argument is returned once the channel is closed.
+					if len(args) == 2 {
+						return args[1]
+					}
+					return nil
+				}
+				return value
+			},
+		},
+	},
+	{
+		"close",
+		&Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				channel, ok := args[0].(*Channel)
+				if !ok {
+					return newError("argument to `close` must be CHANNEL, got %s", args[0].Type())
+				}
+				if !channel.Close() {
+					return newError("close of closed channel")
+				}
+				return nil
+			},
+		},
+	},
+	{
+		"select",
+		&Builtin{
+			Fn: func(args ...Object) Object {
+				if len(args) < 1 || len(args) > 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
+
+				cases, ok := args[0].(*Array)
+				if !ok {
+					return newError("argument to `select` must be ARRAY, got %s", args[0].Type())
+				}
+
+				var fallback Object
+				if len(args) == 2 {
+					fallback = args[1]
+				}
+				return selectCase(cases.Snapshot(), fallback)
+			},
+		},
+	},
+}
+
+// builtinIndex maps the name of each function in Builtins to its position. An entry left
+// behind when Builtins is cut back, as a test does to remove the functions it added, points
+// past its end or at a function of another name, and is ignored.
+var builtinIndex = map[string]int{}
+
+func init() {
+	for i, def := range Builtins {
+		builtinIndex[def.Name] = i
+	}
+}
+
+// BuiltinIndex finds the position of a built-in function in Builtins, by which the compiler
+// numbers the functions.
+//
+// Parameters:
+//   - name: The name of the function.
+//
+// Returns:
+//   - int: The position of the function.
+//   - bool: Whether there is a function with the name.
+func BuiltinIndex(name string) (int, bool) {
+	i, ok := builtinIndex[name]
+	if !ok || i >= len(Builtins) || Builtins[i].Name != name {
+		return 0, false
+	}
+	return i, true
 }
 
 func GetBuiltinByName(name string) *Builtin {
-	for _, def := range Builtins {
-		if def.Name == name {
-			return def.Builtin
-		}
+	if i, ok := BuiltinIndex(name); ok {
+		return Builtins[i].Builtin
+	}
+
+	return nil
+}
+
+// AddBuiltin adds a built-in function implemented in Go, so that a host embedding the
+// interpreter can provide functions of its own, e.g. for an operator it adds to the parser.
+// The compiler numbers the built-in functions by their position in Builtins, so functions
+// must be added before any code is compiled or evaluated, and not while code is running;
+// they must not be appended to Builtins directly, which would leave them out of the index
+// GetBuiltinByName looks them up in.
+// A function fails by returning an *Exception, which is thrown where the function was
+// called; an *Error it returns is an ordinary value, like the result of error(msg).
+//
+// Parameters:
+//   - name: The name the function is called by.
+//   - fn: The function.
+//
+// Returns:
+//   - error: An error if the name is taken or there are too many built-in functions.
+func AddBuiltin(name string, fn BuiltinFunction) error {
+	if GetBuiltinByName(name) != nil {
+		return fmt.Errorf("builtin %s is already defined", name)
+	}
+	// OpGetBuiltin has a one byte operand.
+	if len(Builtins) >= 256 {
+		return fmt.Errorf("too many builtins, cannot add %s", name)
 	}
 
+	Builtins = append(Builtins, struct {
+		Name    string
+		Builtin *Builtin
+	}{name, &Builtin{Fn: fn}})
+	builtinIndex[name] = len(Builtins) - 1
 	return nil
 }
 
-func newError(format string, a ...any) *Error {
-	return &Error{Message: fmt.Sprintf(format, a...)}
+// Spawn starts a task for the spawn builtin, which calls a function concurrently with the
+// code calling spawn. The result of the function is sent on a channel, which is closed
+// afterwards; a value thrown by the function is sent as an *Exception, so that receiving it
+// throws it again.
+//
+// Parameters:
+//   - call: Calls a function in the task, returning its result or an *Exception.
+//   - args: The arguments of spawn: the function, followed by the arguments to call it with.
+//
+// Returns:
+//   - Object: The channel receiving the result, or an error if the arguments are wrong.
+func Spawn(call Caller, args ...Object) Object {
+	if len(args) < 1 {
+		return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+	}
+
+	switch args[0].(type) {
+	case *Function, *Closure, *Builtin, *BoundMethod, *Struct, *VariantType:
+	default:
+		return newError("argument to `spawn` must be a function, got %s", args[0].Type())
+	}
+
+	fn, fnArgs := args[0], append([]Object{}, args[1:]...)
+	result := NewChannel(1)
+	startTask()
+	go func() {
+		defer endTask()
+		result.Send(call(fn, fnArgs))
+		result.Close()
+	}()
+
+	return result
+}
+
+// selectCase waits until one of the cases of the select builtin can go ahead, and goes ahead
+// with it. A channel is a case receiving from it; an array of a channel and a value is a case
+// sending the value on the channel.
+//
+// Parameters:
+//   - cases: The cases.
+//   - fallback: The value returned when no case can go ahead at once, or nil to wait.
+//
+// Returns:
+//   - Object: An array of the index of the case that went ahead and the received value, or
+//     null for a send or a closed channel; the fallback; or an error.
+func selectCase(cases []Object, fallback Object) (result Object) {
+	selectCases := []reflect.SelectCase{}
+	for _, c := range cases {
+		switch c := c.(type) {
+		case *Channel:
+			selectCases = append(selectCases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.values)})
+			continue
+		case *Array:
+			elements := c.Snapshot()
+			if len(elements) != 2 {
+				break
+			}
+			if channel, ok := elements[0].(*Channel); ok {
+				value := reflect.ValueOf(&elements[1]).Elem()
+				selectCases = append(selectCases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(channel.values), Send: value})
+				continue
+			}
+		}
+		return newError("case of `select` must be CHANNEL or [CHANNEL, value], got %s", c.Inspect())
+	}
+
+	// Sending on a closed Go channel panics.
+	defer func() {
+		if recover() != nil {
+			result = newError("send on closed channel")
+		}
+	}()
+
+	var chosen int
+	var received reflect.Value
+	var ok bool
+	if fallback != nil {
+		selectCases = append(selectCases, reflect.SelectCase{Dir: reflect.SelectDefault})
+		chosen, received, ok = reflect.Select(selectCases)
+		if chosen == len(cases) {
+			return fallback
+		}
+	} else {
+		var err *Exception
+		chosen, received, ok, err = wait(selectCases)
+		if err != nil {
+			return err
+		}
+	}
+
+	var value Object = NULL
+	if ok {
+		value = received.Interface().(Object)
+		if exception, thrown := value.(*Exception); thrown {
+			return exception
+		}
+	}
+	return &Array{Elements: []Object{&Integer{Value: int64(chosen)}, value}}
+}
+
+// newError creates the exception a built-in function fails with. It is thrown like the
+// value of a throw statement, so it ends the program unless it is caught with try.
+//
+// Parameters:
+//   - format: The format string used to create the error message.
+//   - a: Arguments to the format string.
+//
+// Returns:
+//   - *Exception: The exception throwing the error.
+func newError(format string, a ...any) *Exception {
+	return &Exception{Value: &Error{Message: fmt.Sprintf(format, a...)}}
+}
+
+// collect runs an iterator to its end.
+//
+// Parameters:
+//   - iterator: The iterator.
+//
+// Returns:
+//   - []Object: The elements produced by the iterator.
+//   - *Exception: The exception thrown by a generator, or nil.
+func collect(iterator *Iterator) ([]Object, *Exception) {
+	elements := []Object{}
+	for {
+		value, ok := iterator.Next()
+		if !ok {
+			return elements, nil
+		}
+		if exception, ok := value.(*Exception); ok {
+			return nil, exception
+		}
+		elements = append(elements, value)
+	}
 }
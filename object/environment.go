@@ -1,10 +1,30 @@
 package object
 
+import "sync"
+
 // Environment represents the running interpreter Environment
-// including bound values to identifiers.
+// including bound values to identifiers. Functions passed to spawn share their
+// environment with other tasks, so the store is only accessed under a lock.
 type Environment struct {
-	store map[string]Object // store is the persistent store for identifiers and their values.
-	outer *Environment      // When nil, this is the outermost environment, otherwise represents a parent environment.
+	mu       sync.RWMutex      // Guards the store.
+	store    map[string]Object // store is the persistent store for identifiers and their values.
+	outer    *Environment      // When nil, this is the outermost environment, otherwise represents a parent environment.
+	importer Importer          // Loads the modules named in import statements, shared with enclosed environments.
+	path     string            // The path of the file the code using the environment came from.
+	yield    func(Object) bool // Hands a value yielded by the generator function running in the environment to its caller, or nil.
+}
+
+// Importer loads the modules named in import statements.
+type Importer interface {
+	// Import loads a module.
+	//
+	// Parameters:
+	//   - path: The path written in the import statement.
+	//   - from: The path of the importing file, or an empty string for code not read from a file.
+	//
+	// Returns:
+	//   - Object: The module, or an exception if it cannot be loaded.
+	Import(path, from string) Object
 }
 
 // NewEnvironment creates a new environment.
@@ -16,6 +36,22 @@ func NewEnvironment() *Environment {
 	return &Environment{store: store, outer: nil}
 }
 
+// NewModuleEnvironment creates a new outermost environment for the code of a file, in which
+// import statements are resolved relative to that file.
+//
+// Parameters:
+//   - path: The path of the file, or an empty string for code not read from a file.
+//   - importer: The importer loading the modules named in import statements.
+//
+// Returns:
+//   - *Environment: the newly created environment.
+func NewModuleEnvironment(path string, importer Importer) *Environment {
+	env := NewEnvironment()
+	env.path = path
+	env.importer = importer
+	return env
+}
+
 // NewEnclosedEnvironment creates a new environment and sets the outer environment
 // as its parent.
 //
@@ -27,9 +63,59 @@ func NewEnvironment() *Environment {
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.importer = outer.importer
+	env.path = outer.path
+	env.yield = outer.yield
 	return env
 }
 
+// NewGeneratorEnvironment creates a new environment for the body of a generator function.
+//
+// Parameters:
+//   - outer: The outer environment that is to be the parent of the new environment.
+//   - yield: Hands a yielded value to the caller of the generator, suspending the function
+//     until the next value is asked for; it returns true then, and never returns when no more
+//     values will be asked for.
+//
+// Returns:
+//   - *Environment: The new environment with a pointer to the parent.
+func NewGeneratorEnvironment(outer *Environment, yield func(Object) bool) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.yield = yield
+	return env
+}
+
+// Yield hands a value yielded by a generator function running in the environment to the
+// caller of the generator.
+//
+// Parameters:
+//   - val: The yielded value.
+//
+// Returns:
+//   - bool: False when the environment is not that of a generator function, otherwise true
+//     once the next value is asked for.
+func (e *Environment) Yield(val Object) bool {
+	if e.yield == nil {
+		return false
+	}
+	return e.yield(val)
+}
+
+// Import loads a module named in an import statement of the code using the environment.
+//
+// Parameters:
+//   - path: The path written in the import statement.
+//
+// Returns:
+//   - Object: The module, or an exception if it cannot be loaded.
+//   - bool: False when the environment has no importer, otherwise true.
+func (e *Environment) Import(path string) (Object, bool) {
+	if e.importer == nil {
+		return nil, false
+	}
+	return e.importer.Import(path, e.path), true
+}
+
 // Get looks for an identifier in the environment store.
 //
 // Parameters:
@@ -39,13 +125,41 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 //   - Object: The object if it's found.
 //   - bool: True when the object was found, otherwise false.
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
 	return obj, ok
 }
 
+// Assign updates the value of an existing identifier in the nearest environment that
+// defines it, so that every closure sharing that environment sees the new value.
+//
+// Parameters:
+//   - name: The name of the identifier to update.
+//   - val: The new value.
+//
+// Returns:
+//   - Object: The input object after saving.
+//   - bool: True when the identifier was found, otherwise false.
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	e.mu.Lock()
+	_, ok := e.store[name]
+	if ok {
+		e.store[name] = val
+	}
+	e.mu.Unlock()
+	if ok {
+		return val, true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return nil, false
+}
+
 // Set stores the name and value of the identifier in the environment store.
 //
 // Parameters:
@@ -55,6 +169,8 @@ func (e *Environment) Get(name string) (Object, bool) {
 // Returns:
 //   - Object: The input object after saving.
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.store[name] = val
 	return val
 }
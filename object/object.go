@@ -2,11 +2,18 @@ package object
 
 import (
 	"bytes"
+	"cmp"
 	"fmt"
 	"hash/fnv"
+	"math"
 	"monkey/ast"
 	"monkey/code"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 // ObjectType represents the underlying object's type.
@@ -14,6 +21,7 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ           = "INTEGER"
+	FLOAT_OBJ             = "FLOAT"
 	BOOLEAN_OBJ           = "BOOLEAN"
 	NULL_OBJ              = "NULL"
 	RETURN_VALUE_OBJ      = "RETURN_VALUE"
@@ -25,6 +33,23 @@ const (
 	HASH_OBJ              = "HASH"
 	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
 	CLOSURE_OBJ           = "CLOSURE"
+	CELL_OBJ              = "CELL"
+	ITERATOR_OBJ          = "ITERATOR"
+	BREAK_OBJ             = "BREAK"
+	CONTINUE_OBJ          = "CONTINUE"
+	QUOTE_OBJ             = "QUOTE"
+	MACRO_OBJ             = "MACRO"
+	EXCEPTION_OBJ         = "EXCEPTION"
+	MODULE_OBJ            = "MODULE"
+	STRUCT_OBJ            = "STRUCT"
+	INSTANCE_OBJ          = "INSTANCE"
+	BOUND_METHOD_OBJ      = "BOUND_METHOD"
+	ENUM_OBJ              = "ENUM"
+	VARIANT_TYPE_OBJ      = "VARIANT_TYPE"
+	VARIANT_OBJ           = "VARIANT"
+	GENERATOR_OBJ         = "GENERATOR"
+	CHANNEL_OBJ           = "CHANNEL"
+	TAIL_CALL_OBJ         = "TAIL_CALL"
 )
 
 // Object represents our universal type.
@@ -53,6 +78,31 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// Float represents a floating point number.
+type Float struct {
+	Value float64 // The value of the float.
+}
+
+// Inspect represents the object as a string. Whole numbers keep a ".0" suffix
+// so they can be told apart from integers.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// Type gets the underlying object type.
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// HashKey produces a hash for a key.
+func (f *Float) HashKey() HashKey {
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 // Boolean represents a boolean value.
 type Boolean struct {
 	Value bool // The actual value.
@@ -106,6 +156,10 @@ func (s *String) HashKey() HashKey {
 // Null represents no value.
 type Null struct{}
 
+// NULL is the null value. The evaluator and the virtual machine use it for every null, so
+// that built-in functions can put null in the values they build.
+var NULL = &Null{}
+
 // Inspect represents the object as a string.
 func (n *Null) Inspect() string {
 	return "null"
@@ -131,9 +185,55 @@ func (r *ReturnValue) Inspect() string {
 	return r.Value.Inspect()
 }
 
+// Break signals that a loop should stop. It travels up through the enclosing blocks
+// to the innermost loop.
+type Break struct{}
+
+// Type gets the underlying object type.
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+// Inspect represents the object as a string.
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+// Continue signals that a loop should start its next iteration. It travels up through
+// the enclosing blocks to the innermost loop.
+type Continue struct{}
+
+// Type gets the underlying object type.
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+// Inspect represents the object as a string.
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+// TailCall is a call in tail position, returned by a function instead of being made by it.
+// The caller of the function makes the call, so that a chain of tail calls does not nest.
+type TailCall struct {
+	Function  Object   // The called function.
+	Arguments []Object // The arguments of the call.
+}
+
+// Type gets the underlying object type.
+func (t *TailCall) Type() ObjectType {
+	return TAIL_CALL_OBJ
+}
+
+// Inspect represents the object as a string.
+func (t *TailCall) Inspect() string {
+	return "tail call"
+}
+
 // Error represents an error that occurs during interpretation.
 type Error struct {
 	Message string // The error message.
+	Data    Object // Any further details given to error(msg, data), or nil.
 }
 
 // Type gets the underlying object type.
@@ -146,11 +246,43 @@ func (e *Error) Inspect() string {
 	return fmt.Sprintf("ERROR: %s", e.Message)
 }
 
+// Exception carries a thrown value up through the evaluator until a try expression catches it.
+// Runtime errors are thrown as an Exception holding an Error.
+type Exception struct {
+	Value Object // The thrown value.
+}
+
+// Type gets the underlying object type.
+func (e *Exception) Type() ObjectType {
+	return EXCEPTION_OBJ
+}
+
+// Inspect represents the object as a string.
+func (e *Exception) Inspect() string {
+	return "EXCEPTION: " + e.Value.Inspect()
+}
+
+// Uncaught describes the exception as the error it ends the program with. A thrown Error
+// keeps its message, any other thrown value is reported as an uncaught exception.
+//
+// Returns:
+//   - *Error: The error describing the exception.
+func (e *Exception) Uncaught() *Error {
+	if err, ok := e.Value.(*Error); ok {
+		return err
+	}
+	return &Error{Message: "uncaught exception: " + e.Value.Inspect(), Data: e.Value}
+}
+
 // Function represents a callable function.
 type Function struct {
 	Parameters []*ast.Identifier   // The parameters that were passed to the function.
+	Patterns   []ast.Pattern       // The pattern destructuring each parameter, nil for a plain parameter name.
+	Defaults   []ast.Expression    // The default value of each parameter, nil for a required parameter.
+	Rest       *ast.Identifier     // The parameter collecting any further arguments, or nil.
 	Body       *ast.BlockStatement // The block of statements to execute.
 	Env        *Environment        // The environment containing the current state.
+	Generator  bool                // Whether calling the function returns a generator instead of running the body.
 }
 
 // Type gets the underlying object type.
@@ -163,11 +295,24 @@ func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	for i, p := range f.Parameters {
+		param := p.String()
+		if i < len(f.Patterns) && f.Patterns[i] != nil {
+			param = f.Patterns[i].String()
+		}
+		if i < len(f.Defaults) && f.Defaults[i] != nil {
+			param += " = " + f.Defaults[i].String()
+		}
+		params = append(params, param)
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
 	}
 
 	out.WriteString("fn")
+	if f.Generator {
+		out.WriteString("*")
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
@@ -177,13 +322,365 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// Quote holds an unevaluated piece of the syntax tree, produced by quote(...).
+type Quote struct {
+	Node ast.Node // The quoted node.
+}
+
+// Type gets the underlying object type.
+func (q *Quote) Type() ObjectType {
+	return QUOTE_OBJ
+}
+
+// Inspect represents the object as a string.
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+// Macro represents a user defined macro. Its arguments are passed in as quotes and its
+// body has to produce the quote that replaces the call.
+type Macro struct {
+	Parameters []*ast.Identifier   // The names the quoted arguments are bound to.
+	Body       *ast.BlockStatement // The block of statements to execute.
+	Env        *Environment        // The environment the macro was defined in.
+}
+
+// Type gets the underlying object type.
+func (m *Macro) Type() ObjectType {
+	return MACRO_OBJ
+}
+
+// Inspect represents the object as a string.
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+// Module is the namespace produced by importing a module file. Its exported values are
+// looked up by indexing it with their names, e.g. str["upper"].
+type Module struct {
+	Path    string            // The path of the module file.
+	Exports map[string]Object // The exported values by name.
+}
+
+// Type gets the underlying object type.
+func (m *Module) Type() ObjectType {
+	return MODULE_OBJ
+}
+
+// Inspect represents the object as a string.
+func (m *Module) Inspect() string {
+	return "module(" + m.Path + ")"
+}
+
+// Struct is a struct type declared with a struct statement. Calling it with the values of
+// its fields, in order, creates an instance.
+type Struct struct {
+	Name    string            // The name of the struct type.
+	Fields  []string          // The names of the fields.
+	Methods map[string]Object // The methods added by impl statements, by name.
+}
+
+// Type gets the underlying object type.
+func (s *Struct) Type() ObjectType {
+	return STRUCT_OBJ
+}
+
+// Inspect represents the object as a string.
+func (s *Struct) Inspect() string {
+	return "struct " + s.Name + " { " + strings.Join(s.Fields, ", ") + " }"
+}
+
+// FieldIndex finds a field of the struct type.
+//
+// Parameters:
+//   - name: The name of the field.
+//
+// Returns:
+//   - int: The position of the field, or -1 if the struct type has no such field.
+func (s *Struct) FieldIndex(name string) int {
+	for i, field := range s.Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Instance is a value of a struct type. Tasks started by spawn can share a struct value,
+// so once it is created its fields are only accessed under a lock, through Field and
+// SetField.
+type Instance struct {
+	mu     sync.RWMutex // Guards the fields.
+	Struct *Struct      // The struct type of the value.
+	Fields []Object     // The values of the fields, in the order the struct type declares them.
+}
+
+// Field returns the value of a field.
+//
+// Parameters:
+//   - index: The index of the field in the struct type.
+//
+// Returns:
+//   - Object: The value of the field.
+func (i *Instance) Field(index int) Object {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.Fields[index]
+}
+
+// SetField changes the value of a field.
+//
+// Parameters:
+//   - index: The index of the field in the struct type.
+//   - value: The new value of the field.
+func (i *Instance) SetField(index int, value Object) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.Fields[index] = value
+}
+
+// Type gets the underlying object type.
+func (i *Instance) Type() ObjectType {
+	return INSTANCE_OBJ
+}
+
+// Inspect represents the object as a string.
+func (i *Instance) Inspect() string {
+	fields := []string{}
+	for idx, field := range i.Struct.Fields {
+		fields = append(fields, field+": "+i.Field(idx).Inspect())
+	}
+
+	return i.Struct.Name + " { " + strings.Join(fields, ", ") + " }"
+}
+
+// BoundMethod is a method looked up on an instance, e.g. p.dist. Calling it passes the
+// instance as the first argument of the method.
+type BoundMethod struct {
+	Receiver Object // The instance the method was looked up on.
+	Method   Object // The function implementing the method.
+}
+
+// Type gets the underlying object type.
+func (b *BoundMethod) Type() ObjectType {
+	return BOUND_METHOD_OBJ
+}
+
+// Inspect represents the object as a string.
+func (b *BoundMethod) Inspect() string {
+	return "bound method " + b.Method.Inspect()
+}
+
+// Enum is an enum type, whose values are one of a fixed set of variants.
+type Enum struct {
+	Name     string         // The name of the enum type.
+	Variants []*VariantType // The variants, in the order they are declared.
+}
+
+// Type gets the underlying object type.
+func (e *Enum) Type() ObjectType {
+	return ENUM_OBJ
+}
+
+// Inspect represents the object as a string.
+func (e *Enum) Inspect() string {
+	variants := []string{}
+	for _, variant := range e.Variants {
+		if len(variant.Fields) == 0 {
+			variants = append(variants, variant.Tag)
+			continue
+		}
+		variants = append(variants, variant.Tag+"("+strings.Join(variant.Fields, ", ")+")")
+	}
+
+	return "enum " + e.Name + " { " + strings.Join(variants, ", ") + " }"
+}
+
+// AddVariant declares a variant of the enum type.
+//
+// Parameters:
+//   - tag: The name of the variant.
+//   - fields: The names of the fields of its payload.
+func (e *Enum) AddVariant(tag string, fields []string) {
+	variant := &VariantType{Enum: e, Tag: tag, Fields: fields}
+	if len(fields) == 0 {
+		variant.Value = &Variant{Kind: variant, Payload: []Object{}}
+	}
+	e.Variants = append(e.Variants, variant)
+}
+
+// Variant finds a variant of the enum type.
+//
+// Parameters:
+//   - tag: The name of the variant.
+//
+// Returns:
+//   - *VariantType: The variant, or nil if the enum type has no such variant.
+func (e *Enum) Variant(tag string) *VariantType {
+	for _, variant := range e.Variants {
+		if variant.Tag == tag {
+			return variant
+		}
+	}
+	return nil
+}
+
+// MissingVariants lists the variants of the enum type that are not covered.
+//
+// Parameters:
+//   - covered: The names of the covered variants.
+//
+// Returns:
+//   - []string: The names of the other variants, in the order they are declared.
+func (e *Enum) MissingVariants(covered []string) []string {
+	missing := []string{}
+	for _, variant := range e.Variants {
+		if !slices.Contains(covered, variant.Tag) {
+			missing = append(missing, variant.Tag)
+		}
+	}
+	return missing
+}
+
+// LookupVariant finds the variant a variant pattern like Result.Ok(value) matches.
+//
+// Parameters:
+//   - enum: The value the name of the enum type in the pattern refers to.
+//   - tag: The name of the variant.
+//   - numFields: The number of field patterns.
+//
+// Returns:
+//   - *VariantType: The variant.
+//   - error: An error if enum is not an enum type, has no such variant, or the variant has a
+//     different number of fields.
+func LookupVariant(enum Object, tag string, numFields int) (*VariantType, error) {
+	e, ok := enum.(*Enum)
+	if !ok {
+		return nil, fmt.Errorf("variant pattern needs an ENUM, got %s", enum.Type())
+	}
+
+	variant := e.Variant(tag)
+	if variant == nil {
+		return nil, fmt.Errorf("%s has no variant %s", e.Name, tag)
+	}
+	if numFields != len(variant.Fields) {
+		return nil, fmt.Errorf("wrong number of fields in pattern %s: want=%d, got=%d", variant.Name(), len(variant.Fields), numFields)
+	}
+
+	return variant, nil
+}
+
+// VariantType is a variant of an enum type, e.g. Result.Ok. Calling a variant with fields
+// makes a value of it, while a variant without fields has a single value.
+type VariantType struct {
+	Enum   *Enum    // The enum type the variant belongs to.
+	Tag    string   // The name of the variant.
+	Fields []string // The names of the fields of its payload.
+	Value  *Variant // The value of a variant without fields, or nil.
+}
+
+// Type gets the underlying object type.
+func (v *VariantType) Type() ObjectType {
+	return VARIANT_TYPE_OBJ
+}
+
+// Inspect represents the object as a string.
+func (v *VariantType) Inspect() string {
+	return v.Name()
+}
+
+// Name returns the name of the variant qualified with the name of its enum type.
+func (v *VariantType) Name() string {
+	return v.Enum.Name + "." + v.Tag
+}
+
+// FieldIndex finds a field of the payload of the variant.
+//
+// Parameters:
+//   - name: The name of the field.
+//
+// Returns:
+//   - int: The position of the field, or -1 if the variant has no such field.
+func (v *VariantType) FieldIndex(name string) int {
+	for i, field := range v.Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Variant is a value of an enum type: a tag naming its variant and the payload.
+type Variant struct {
+	Kind    *VariantType // The variant, which holds the tag.
+	Payload []Object     // The values of the fields, in the order the variant declares them.
+}
+
+// Type gets the underlying object type.
+func (v *Variant) Type() ObjectType {
+	return VARIANT_OBJ
+}
+
+// Inspect represents the object as a string.
+func (v *Variant) Inspect() string {
+	if len(v.Payload) == 0 {
+		return v.Kind.Name()
+	}
+
+	payload := []string{}
+	for _, value := range v.Payload {
+		payload = append(payload, value.Inspect())
+	}
+
+	return v.Kind.Name() + "(" + strings.Join(payload, ", ") + ")"
+}
+
 // BuiltinFunction is a function that is built into the
 // interpreter for users of the monkey language.
 type BuiltinFunction func(args ...Object) Object
 
+// Caller calls a function on behalf of a built-in function, in the evaluator or the virtual
+// machine running the program. It returns the result of the function, or an *Exception for
+// a value the function threw.
+type Caller func(fn Object, args []Object) Object
+
+// CallingFunction is the implementation of a built-in function that calls functions
+// itself, through the caller it is given.
+type CallingFunction func(call Caller, args ...Object) Object
+
 // Builtin represents a built-in function.
 type Builtin struct {
-	Fn BuiltinFunction // The built-in function implementation.
+	Fn        BuiltinFunction // The built-in function implementation.
+	CallingFn CallingFunction // The implementation of a built-in function calling functions, used instead of Fn when set.
+}
+
+// Apply calls the built-in function.
+//
+// Parameters:
+//   - call: Calls a function for a built-in function calling functions.
+//   - args: The arguments.
+//
+// Returns:
+//   - Object: The result of the built-in function.
+func (b *Builtin) Apply(call Caller, args ...Object) Object {
+	if b.CallingFn != nil {
+		return b.CallingFn(call, args...)
+	}
+	return b.Fn(args...)
 }
 
 // Type gets the underlying object type.
@@ -196,9 +693,80 @@ func (b *Builtin) Inspect() string {
 	return "builtin function"
 }
 
-// Array represents an array of objects.
+// Array represents an array of objects. Tasks started by spawn can share an array, so once
+// it is created its elements are only accessed under a lock, through Len, At, SetAt, Append
+// and Snapshot.
 type Array struct {
-	Elements []Object // The elements of the array.
+	mu       sync.RWMutex // Guards the elements.
+	Elements []Object     // The elements of the array.
+}
+
+// Len returns the number of elements.
+func (a *Array) Len() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.Elements)
+}
+
+// At looks up the element at an index. A negative index counts from the end.
+//
+// Parameters:
+//   - index: The index.
+//
+// Returns:
+//   - Object: The element.
+//   - bool: Whether the index is in range.
+func (a *Array) At(index int64) (Object, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if index < 0 {
+		index += int64(len(a.Elements))
+	}
+	if index < 0 || index >= int64(len(a.Elements)) {
+		return nil, false
+	}
+	return a.Elements[index], true
+}
+
+// SetAt replaces the element at an index. A negative index counts from the end.
+//
+// Parameters:
+//   - index: The index.
+//   - value: The new element.
+//
+// Returns:
+//   - bool: Whether the index is in range.
+func (a *Array) SetAt(index int64, value Object) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if index < 0 {
+		index += int64(len(a.Elements))
+	}
+	if index < 0 || index >= int64(len(a.Elements)) {
+		return false
+	}
+	a.Elements[index] = value
+	return true
+}
+
+// Append adds elements to the end of the array.
+//
+// Parameters:
+//   - values: The elements.
+func (a *Array) Append(values ...Object) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Elements = append(a.Elements, values...)
+}
+
+// Snapshot returns a copy of the elements, which later changes to the array do not affect.
+//
+// Returns:
+//   - []Object: The elements.
+func (a *Array) Snapshot() []Object {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return slices.Clone(a.Elements)
 }
 
 // Type gets the underlying object type.
@@ -211,7 +779,7 @@ func (a *Array) Inspect() string {
 	var out bytes.Buffer
 
 	elements := []string{}
-	for _, e := range a.Elements {
+	for _, e := range a.Snapshot() {
 		elements = append(elements, e.Inspect())
 	}
 
@@ -222,6 +790,58 @@ func (a *Array) Inspect() string {
 	return out.String()
 }
 
+// SliceBounds resolves the bounds of a slice over an array or string of the given length.
+// Negative bounds count back from the end and bounds outside the sequence are clamped to it.
+//
+// Parameters:
+//   - length: The number of elements in the sequence being sliced.
+//   - low: The first index of the slice, or null for the start of the sequence.
+//   - high: The index one past the end of the slice, or null for the end of the sequence.
+//
+// Returns:
+//   - int: The resolved first index.
+//   - int: The resolved end index, never less than the first index.
+//   - error: An error if a bound is not an integer.
+func SliceBounds(length int, low, high Object) (int, int, error) {
+	lo, err := sliceBound(low, 0, length)
+	if err != nil {
+		return 0, 0, err
+	}
+	hi, err := sliceBound(high, length, length)
+	if err != nil {
+		return 0, 0, err
+	}
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi, nil
+}
+
+// sliceBound resolves a single slice bound.
+//
+// Parameters:
+//   - bound: The integer bound, or null when it was omitted.
+//   - def: The index to use when the bound was omitted.
+//   - length: The number of elements in the sequence being sliced.
+//
+// Returns:
+//   - int: The bound as an index between 0 and length.
+//   - error: An error if the bound is not an integer.
+func sliceBound(bound Object, def, length int) (int, error) {
+	if bound == NULL {
+		return def, nil
+	}
+	integer, ok := bound.(*Integer)
+	if !ok {
+		return 0, fmt.Errorf("slice bounds must be integers, got %s", bound.Type())
+	}
+	i := integer.Value
+	if i < 0 {
+		i += int64(length)
+	}
+	return int(max(0, min(i, int64(length)))), nil
+}
+
 // HashKey is a representation of a hashed value for an object.
 type HashKey struct {
 	Type  ObjectType // The type of object that was hashed.
@@ -234,11 +854,56 @@ type HashPair struct {
 	Value Object // The value for the hash.
 }
 
-// Hash represents a map, hashmap, or dictionary type structure.
+// Hash represents a map, hashmap, or dictionary type structure. Tasks started by spawn can
+// share a hash, so once it is created its pairs are only accessed under a lock, through Get,
+// Set, Delete and SortedPairs.
 type Hash struct {
+	mu    sync.RWMutex         // Guards the pairs.
 	Pairs map[HashKey]HashPair // Collection of key value pairs.
 }
 
+// Get looks up the pair with a key.
+//
+// Parameters:
+//   - key: The hash key of the key.
+//
+// Returns:
+//   - HashPair: The pair.
+//   - bool: Whether the hash has a pair with the key.
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	pair, ok := h.Pairs[key]
+	return pair, ok
+}
+
+// Set stores a pair, replacing any pair with the same key.
+//
+// Parameters:
+//   - key: The hash key of the key of the pair.
+//   - pair: The pair.
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.Pairs[key] = pair
+}
+
+// Delete removes the pair with a key.
+//
+// Parameters:
+//   - key: The hash key of the key.
+//
+// Returns:
+//   - HashPair: The removed pair.
+//   - bool: Whether the hash had a pair with the key.
+func (h *Hash) Delete(key HashKey) (HashPair, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	pair, ok := h.Pairs[key]
+	delete(h.Pairs, key)
+	return pair, ok
+}
+
 // Type gets the underlying object type.
 func (h *Hash) Type() ObjectType {
 	return HASH_OBJ
@@ -250,7 +915,7 @@ func (h *Hash) Inspect() string {
 
 	pairs := []string{}
 
-	for _, pair := range h.Pairs {
+	for _, pair := range h.SortedPairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 
@@ -261,6 +926,51 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+// SortedPairs returns the pairs of the hash ordered by key, so that iterating over a
+// hash is deterministic. Keys are grouped by type, then ordered by value.
+//
+// Returns:
+//   - []HashPair: The key value pairs in order.
+func (h *Hash) SortedPairs() []HashPair {
+	h.mu.RLock()
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return compareKeys(pairs[i].Key, pairs[j].Key) < 0
+	})
+
+	return pairs
+}
+
+// compareKeys orders two hash keys, first by type and then by value.
+//
+// Parameters:
+//   - a: The first key.
+//   - b: The second key.
+//
+// Returns:
+//   - int: A negative number when a comes first, a positive number when b comes first, otherwise 0.
+func compareKeys(a, b Object) int {
+	if a.Type() != b.Type() {
+		return strings.Compare(string(a.Type()), string(b.Type()))
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return cmp.Compare(a.Value, b.(*Integer).Value)
+	case *Float:
+		return cmp.Compare(a.Value, b.(*Float).Value)
+	case *String:
+		return strings.Compare(a.Value, b.(*String).Value)
+	default:
+		return strings.Compare(a.Inspect(), b.Inspect())
+	}
+}
+
 // Hashable indicates that an object in our system can be used as a hash key.
 type Hashable interface {
 	HashKey() HashKey
@@ -270,7 +980,11 @@ type Hashable interface {
 type CompiledFunction struct {
 	Instructions  code.Instructions // Instructions is the collection of bytecode instructions in the function.
 	NumLocals     int               // The number of local variables needed.
-	NumParameters int               // The arity of the function (number of params expected).
+	NumParameters int               // The arity of the function (number of params expected), not counting a rest parameter.
+	NumDefaults   int               // The number of trailing parameters that have a default value.
+	Variadic      bool              // Whether further arguments are collected into an array by a rest parameter.
+	Module        string            // The path of the module when the function runs the top-level code of a module file.
+	Generator     bool              // Whether calling the function returns a generator instead of running it.
 }
 
 // Type gets the underlying object type.
@@ -285,7 +999,7 @@ func (c *CompiledFunction) Inspect() string {
 
 type Closure struct {
 	Fn   *CompiledFunction
-	Free []Object
+	Free []*Cell // The captured variables, shared with the scope that defined them.
 }
 
 func (c *Closure) Type() ObjectType {
@@ -295,3 +1009,359 @@ func (c *Closure) Type() ObjectType {
 func (c *Closure) Inspect() string {
 	return fmt.Sprintf("Closure[%p]", c)
 }
+
+// Cell holds a variable that has been captured by a closure. The defining function
+// and every closure that captures the variable share the cell, so an assignment
+// through any of them is seen by all of them. Closures passed to spawn can use the
+// cell from several tasks at once, so the value is only accessed under a lock.
+type Cell struct {
+	mu    sync.RWMutex // Guards the value.
+	value Object       // The current value of the variable.
+}
+
+// NewCell creates a new cell.
+//
+// Parameters:
+//   - value: The initial value of the variable.
+//
+// Returns:
+//   - *Cell: The new cell.
+func NewCell(value Object) *Cell {
+	return &Cell{value: value}
+}
+
+// Get returns the current value of the variable.
+func (c *Cell) Get() Object {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.value
+}
+
+// Set changes the value of the variable.
+func (c *Cell) Set(value Object) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.value = value
+}
+
+// Type gets the underlying object type.
+func (c *Cell) Type() ObjectType {
+	return CELL_OBJ
+}
+
+// Inspect represents the object as a string.
+func (c *Cell) Inspect() string {
+	return fmt.Sprintf("Cell[%p]", c)
+}
+
+// Iterator produces the elements of a collection one at a time for a for-in loop.
+type Iterator struct {
+	Next func() (Object, bool) // Next returns the next element, or false when there are no more.
+}
+
+// Type gets the underlying object type.
+func (i *Iterator) Type() ObjectType {
+	return ITERATOR_OBJ
+}
+
+// Inspect represents the object as a string.
+func (i *Iterator) Inspect() string {
+	return fmt.Sprintf("Iterator[%p]", i)
+}
+
+// Equals reports whether two objects hold the same value. Integers and floats are compared
+// numerically, strings, booleans and null by value, and any other objects by identity.
+//
+// Parameters:
+//   - a: The first object.
+//   - b: The second object.
+//
+// Returns:
+//   - bool: True when the objects are equal, otherwise false.
+func Equals(a, b Object) bool {
+	switch a := a.(type) {
+	case *Integer:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == b.Value
+		case *Float:
+			return float64(a.Value) == b.Value
+		}
+		return false
+	case *Float:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == float64(b.Value)
+		case *Float:
+			return a.Value == b.Value
+		}
+		return false
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+	}
+	return a == b
+}
+
+// Generator is the iterator returned by calling a generator function. The function only runs
+// when a value is asked for, up to the yield producing that value, so a generator can produce
+// a long or endless sequence without building an array of it. The engine that called the
+// function keeps the state of the suspended function behind Resume.
+type Generator struct {
+	Resume func() (Object, bool) // Runs the function up to its next yield and returns the yielded value, or false once the function has returned. A value thrown by the function is returned as an *Exception.
+	mu     sync.Mutex            // Held while the function runs, in which case it cannot be resumed.
+	done   bool                  // Whether the function has returned or thrown.
+}
+
+// Type gets the underlying object type.
+func (g *Generator) Type() ObjectType {
+	return GENERATOR_OBJ
+}
+
+// Inspect represents the object as a string.
+func (g *Generator) Inspect() string {
+	return fmt.Sprintf("Generator[%p]", g)
+}
+
+// Next resumes the function of the generator to get its next value. Once the function has
+// returned or thrown, the generator produces no more values.
+//
+// Returns:
+//   - Object: The yielded value, or an *Exception if the function threw.
+//   - bool: False when the function has returned, otherwise true.
+func (g *Generator) Next() (Object, bool) {
+	// The function may be running in another task, or be asking for its own next value.
+	if !g.mu.TryLock() {
+		return newError("generator is already running"), true
+	}
+	defer g.mu.Unlock()
+
+	if g.done {
+		return nil, false
+	}
+
+	value, ok := g.Resume()
+	if _, thrown := value.(*Exception); thrown || !ok {
+		g.done = true
+	}
+	return value, ok
+}
+
+// Channel passes values between the tasks started by spawn. A send waits until a receiver
+// takes the value or, if the channel has a buffer, until there is room in it. Once the
+// channel is closed, no more values can be sent, and receivers get the values left in the
+// buffer before they are told that the channel is closed.
+type Channel struct {
+	values chan Object // The Go channel carrying the values.
+}
+
+// NewChannel creates a new channel.
+//
+// Parameters:
+//   - capacity: The number of values the channel holds before a send waits for a receiver.
+//
+// Returns:
+//   - *Channel: The new channel.
+func NewChannel(capacity int) *Channel {
+	return &Channel{values: make(chan Object, capacity)}
+}
+
+// Type gets the underlying object type.
+func (c *Channel) Type() ObjectType {
+	return CHANNEL_OBJ
+}
+
+// Inspect represents the object as a string.
+func (c *Channel) Inspect() string {
+	return fmt.Sprintf("Channel[%p]", c)
+}
+
+// Send sends a value, waiting until it is received or buffered.
+//
+// Parameters:
+//   - val: The value to send.
+//
+// Returns:
+//   - bool: False when the channel is closed or the send would wait forever, otherwise true.
+func (c *Channel) Send(val Object) bool {
+	return c.send(val) == nil
+}
+
+// send sends a value, waiting until it is received or buffered.
+//
+// Parameters:
+//   - val: The value to send.
+//
+// Returns:
+//   - *Exception: An error when the channel is closed or the send would wait forever, or nil.
+func (c *Channel) send(val Object) (err *Exception) {
+	// Sending on a closed Go channel panics, also when it is closed while the send waits.
+	defer func() {
+		if recover() != nil {
+			err = newError("send on closed channel")
+		}
+	}()
+
+	select {
+	case c.values <- val:
+		return nil
+	default:
+	}
+	_, _, _, err = wait([]reflect.SelectCase{{Dir: reflect.SelectSend, Chan: reflect.ValueOf(c.values), Send: reflect.ValueOf(&val).Elem()}})
+	return err
+}
+
+// Receive receives a value, waiting until one is sent.
+//
+// Returns:
+//   - Object: The received value, or an *Exception if the receive would wait forever.
+//   - bool: False when the channel is closed and has no more values, otherwise true.
+func (c *Channel) Receive() (Object, bool) {
+	select {
+	case val, ok := <-c.values:
+		return val, ok
+	default:
+	}
+
+	_, received, ok, err := wait([]reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.values)}})
+	if err != nil {
+		return err, true
+	}
+	if !ok {
+		return nil, false
+	}
+	val, _ := received.Interface().(Object)
+	return val, true
+}
+
+// Close closes the channel, waking up every receiver waiting for a value.
+//
+// Returns:
+//   - bool: False when the channel was already closed, otherwise true.
+func (c *Channel) Close() (closed bool) {
+	defer func() {
+		if recover() != nil {
+			closed = false
+		}
+	}()
+
+	close(c.values)
+	return true
+}
+
+// tasks keeps count of the tasks started by spawn that have not ended.
+var tasks struct {
+	mu    sync.Mutex
+	live  int
+	ended chan struct{} // Closed when the last live task ends.
+}
+
+// startTask counts a task started by spawn as live, until endTask is called for it.
+func startTask() {
+	tasks.mu.Lock()
+	defer tasks.mu.Unlock()
+	if tasks.live == 0 {
+		tasks.ended = make(chan struct{})
+	}
+	tasks.live++
+}
+
+// endTask counts a task started by spawn as ended.
+func endTask() {
+	tasks.mu.Lock()
+	defer tasks.mu.Unlock()
+	tasks.live--
+	if tasks.live == 0 {
+		close(tasks.ended)
+	}
+}
+
+// wait waits until one of a set of channel operations can go ahead and goes ahead with it,
+// like reflect.Select. Only a live task can let an operation go ahead that cannot go ahead
+// at once, so when there is none, wait fails with a deadlock error instead of waiting
+// forever.
+//
+// Parameters:
+//   - cases: The channel operations.
+//
+// Returns:
+//   - int: The index of the operation that went ahead.
+//   - reflect.Value: The received value, for a receive.
+//   - bool: For a receive, whether a value was received rather than the channel closed.
+//   - *Exception: A deadlock error, or nil.
+func wait(cases []reflect.SelectCase) (int, reflect.Value, bool, *Exception) {
+	n := len(cases)
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	for {
+		if chosen, received, ok := reflect.Select(cases); chosen < n {
+			return chosen, received, ok, nil
+		}
+
+		tasks.mu.Lock()
+		ended, live := tasks.ended, tasks.live > 0
+		tasks.mu.Unlock()
+		if !live {
+			// A task may have gone ahead with an operation before it ended.
+			if chosen, received, ok := reflect.Select(cases); chosen < n {
+				return chosen, received, ok, nil
+			}
+			return 0, reflect.Value{}, false, newError("deadlock: waiting on a channel with no live task left")
+		}
+
+		cases[n] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ended)}
+		if chosen, received, ok := reflect.Select(cases); chosen < n {
+			return chosen, received, ok, nil
+		}
+		cases[n] = reflect.SelectCase{Dir: reflect.SelectDefault}
+	}
+}
+
+// NewIterator creates an iterator over the elements of an array, the keys of a hash in
+// sorted order, the characters of a string, the values produced by a generator, or the
+// values received from a channel until it is closed.
+//
+// Parameters:
+//   - obj: The collection to iterate over.
+//
+// Returns:
+//   - *Iterator: The new iterator.
+//   - bool: False when the object cannot be iterated over.
+func NewIterator(obj Object) (*Iterator, bool) {
+	var elements []Object
+
+	switch obj := obj.(type) {
+	case *Generator:
+		return &Iterator{Next: obj.Next}, true
+	case *Channel:
+		return &Iterator{Next: obj.Receive}, true
+	case *Array:
+		elements = obj.Snapshot()
+	case *Hash:
+		for _, pair := range obj.SortedPairs() {
+			elements = append(elements, pair.Key)
+		}
+	case *String:
+		for _, r := range obj.Value {
+			elements = append(elements, &String{Value: string(r)})
+		}
+	default:
+		return nil, false
+	}
+
+	i := 0
+	next := func() (Object, bool) {
+		if i >= len(elements) {
+			return nil, false
+		}
+		i++
+		return elements[i-1], true
+	}
+
+	return &Iterator{Next: next}, true
+}
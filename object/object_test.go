@@ -1,6 +1,9 @@
 package object
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -58,3 +61,316 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	half1 := &Float{Value: 0.5}
+	half2 := &Float{Value: 0.5}
+	quarter := &Float{Value: 0.25}
+
+	if half1.HashKey() != half2.HashKey() {
+		t.Errorf("floats with same content have different hash keys")
+	}
+
+	if half1.HashKey() == quarter.HashKey() {
+		t.Errorf("floats with different content have same hash keys")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1, "1.0"},
+		{-3, "-3.0"},
+		{2.5, "2.5"},
+		{1e21, "1e+21"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect for %g. want=%q, got=%q", tt.value, tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestSliceBounds(t *testing.T) {
+	tests := []struct {
+		low, high Object
+		lo, hi    int
+	}{
+		{NULL, NULL, 0, 5},
+		{&Integer{Value: 1}, &Integer{Value: 3}, 1, 3},
+		{&Integer{Value: -2}, NULL, 3, 5},
+		{&Integer{Value: -9}, &Integer{Value: 9}, 0, 5},
+		{&Integer{Value: 4}, &Integer{Value: 2}, 4, 4},
+	}
+
+	for _, tt := range tests {
+		lo, hi, err := SliceBounds(5, tt.low, tt.high)
+		if err != nil {
+			t.Fatalf("SliceBounds failed: %s", err)
+		}
+		if lo != tt.lo || hi != tt.hi {
+			t.Errorf("wrong bounds for [%s:%s]. want=%d:%d, got=%d:%d", tt.low.Inspect(), tt.high.Inspect(), tt.lo, tt.hi, lo, hi)
+		}
+	}
+
+	_, _, err := SliceBounds(5, &String{Value: "a"}, NULL)
+	if err == nil || err.Error() != "slice bounds must be integers, got STRING" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestHashInspectIsSorted(t *testing.T) {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	for _, key := range []Object{&String{Value: "b"}, &Integer{Value: 2}, &String{Value: "a"}, &Integer{Value: 1}} {
+		hash.Pairs[key.(Hashable).HashKey()] = HashPair{Key: key, Value: &Boolean{Value: true}}
+	}
+
+	expected := "{1: true, 2: true, a: true, b: true}"
+	if hash.Inspect() != expected {
+		t.Errorf("wrong Inspect. want=%q, got=%q", expected, hash.Inspect())
+	}
+}
+
+func TestIterator(t *testing.T) {
+	tests := []struct {
+		collection Object
+		expected   []string
+	}{
+		{&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "x"}}}, []string{"1", "x"}},
+		{&String{Value: "héllo"}, []string{"h", "é", "l", "l", "o"}},
+		{&Array{}, []string{}},
+	}
+
+	for _, tt := range tests {
+		iterator, ok := NewIterator(tt.collection)
+		if !ok {
+			t.Fatalf("cannot iterate over %s", tt.collection.Type())
+		}
+
+		actual := []string{}
+		for {
+			value, ok := iterator.Next()
+			if !ok {
+				break
+			}
+			actual = append(actual, value.Inspect())
+		}
+
+		if strings.Join(actual, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("wrong elements. want=%v, got=%v", tt.expected, actual)
+		}
+	}
+
+	if _, ok := NewIterator(&Integer{Value: 1}); ok {
+		t.Errorf("expected integers not to be iterable")
+	}
+}
+
+func TestEquals(t *testing.T) {
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Float{Value: 1}, true},
+		{&Float{Value: 1.5}, &Float{Value: 1.5}, true},
+		{&Integer{Value: 1}, &String{Value: "1"}, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&Boolean{Value: true}, &Boolean{Value: true}, true},
+		{&Boolean{Value: true}, &Integer{Value: 1}, false},
+		{&Null{}, &Null{}, true},
+		{&Array{}, &Array{}, false},
+	}
+
+	for _, tt := range tests {
+		if Equals(tt.a, tt.b) != tt.expected {
+			t.Errorf("Equals(%s, %s) wrong. want=%t", tt.a.Inspect(), tt.b.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	values := []Object{&Integer{Value: 1}, &Exception{Value: &String{Value: "boom"}}, &Integer{Value: 2}}
+	resumed := 0
+	generator := &Generator{Resume: func() (Object, bool) {
+		resumed++
+		if len(values) == 0 {
+			return nil, false
+		}
+		value := values[0]
+		values = values[1:]
+		return value, true
+	}}
+
+	if value, ok := generator.Next(); !ok || value.Inspect() != "1" {
+		t.Fatalf("wrong first value. got=%v, %t", value, ok)
+	}
+	if value, ok := generator.Next(); !ok || value.Type() != EXCEPTION_OBJ {
+		t.Fatalf("expected the exception to be returned. got=%v, %t", value, ok)
+	}
+	if value, ok := generator.Next(); ok {
+		t.Errorf("expected no values after an exception. got=%v", value)
+	}
+	if resumed != 2 {
+		t.Errorf("generator resumed after it was done. want=2, got=%d", resumed)
+	}
+
+	var running *Generator
+	running = &Generator{Resume: func() (Object, bool) { return running.Next() }}
+	value, ok := running.Next()
+	exception, isException := value.(*Exception)
+	if !ok || !isException || exception.Value.(*Error).Message != "generator is already running" {
+		t.Errorf("expected a running generator not to be resumed. got=%v", value)
+	}
+}
+
+func TestChannel(t *testing.T) {
+	channel := NewChannel(2)
+	if !channel.Send(&Integer{Value: 1}) || !channel.Send(&Integer{Value: 2}) {
+		t.Fatalf("expected sends to a buffered channel to succeed")
+	}
+	if !channel.Close() {
+		t.Fatalf("expected the channel to close")
+	}
+	if channel.Close() {
+		t.Errorf("expected closing a closed channel to fail")
+	}
+	if channel.Send(&Integer{Value: 3}) {
+		t.Errorf("expected sending on a closed channel to fail")
+	}
+
+	iterator, ok := NewIterator(channel)
+	if !ok {
+		t.Fatalf("cannot iterate over CHANNEL")
+	}
+	actual := []string{}
+	for {
+		value, ok := iterator.Next()
+		if !ok {
+			break
+		}
+		actual = append(actual, value.Inspect())
+	}
+	if strings.Join(actual, ",") != "1,2" {
+		t.Errorf("wrong values received. want=[1 2], got=%v", actual)
+	}
+
+	if value, ok := channel.Receive(); ok {
+		t.Errorf("expected no values from a drained channel. got=%v", value)
+	}
+
+	value, ok := NewChannel(0).Receive()
+	exception, thrown := value.(*Exception)
+	if !ok || !thrown || exception.Uncaught().Message != "deadlock: waiting on a channel with no live task left" {
+		t.Errorf("expected a deadlock error from a channel no task sends on. got=%v", value)
+	}
+}
+
+func TestBuiltinApplyPassesCaller(t *testing.T) {
+	fn := &Builtin{Fn: func(args ...Object) Object { return args[0] }}
+	call := func(fn Object, args []Object) Object {
+		return fn.(*Builtin).Fn(args...)
+	}
+
+	task := GetBuiltinByName("spawn").Apply(call, fn, &Integer{Value: 7})
+	channel, ok := task.(*Channel)
+	if !ok {
+		t.Fatalf("spawn did not return a channel. got=%T (%+v)", task, task)
+	}
+	if value, ok := channel.Receive(); !ok || value.Inspect() != "7" {
+		t.Errorf("wrong result of the spawned call. got=%v", value)
+	}
+}
+
+func TestHashConcurrentAccess(t *testing.T) {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	done := make(chan bool)
+	for i := 0; i < 4; i++ {
+		go func() {
+			for j := int64(0); j < 100; j++ {
+				key := &Integer{Value: j}
+				hash.Set(key.HashKey(), HashPair{Key: key, Value: key})
+				hash.Get(key.HashKey())
+				hash.SortedPairs()
+			}
+			done <- true
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+
+	if len(hash.SortedPairs()) != 100 {
+		t.Errorf("wrong number of pairs. got=%d", len(hash.SortedPairs()))
+	}
+	if _, ok := hash.Delete((&Integer{Value: 5}).HashKey()); !ok {
+		t.Errorf("pair not deleted")
+	}
+	if _, ok := hash.Get((&Integer{Value: 5}).HashKey()); ok {
+		t.Errorf("deleted pair still found")
+	}
+}
+
+func TestArrayConcurrentAccess(t *testing.T) {
+	array := &Array{}
+	done := make(chan bool)
+	for i := 0; i < 4; i++ {
+		go func() {
+			for j := int64(0); j < 100; j++ {
+				array.Append(&Integer{Value: j})
+				array.SetAt(-1, &Integer{Value: j})
+				array.At(0)
+				array.Snapshot()
+			}
+			done <- true
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+
+	if array.Len() != 400 {
+		t.Errorf("wrong number of elements. got=%d", array.Len())
+	}
+	if array.SetAt(400, NULL) {
+		t.Errorf("element set out of range")
+	}
+	if _, ok := array.At(-401); ok {
+		t.Errorf("element found out of range")
+	}
+}
+
+func TestAddBuiltin(t *testing.T) {
+	if err := AddBuiltin("len", nil); err == nil || err.Error() != "builtin len is already defined" {
+		t.Errorf("wrong error for a builtin that is already defined. got=%v", err)
+	}
+
+	builtins := Builtins
+	t.Cleanup(func() { Builtins = builtins })
+	err := AddBuiltin("double", func(args ...Object) Object {
+		return &Integer{Value: args[0].(*Integer).Value * 2}
+	})
+	if err != nil {
+		t.Fatalf("cannot add builtin: %s", err)
+	}
+
+	if Builtins[len(Builtins)-1].Name != "double" {
+		t.Fatalf("builtin not added to the end of Builtins. got=%s", Builtins[len(Builtins)-1].Name)
+	}
+	result := GetBuiltinByName("double").Fn(&Integer{Value: 21})
+	if result.Inspect() != "42" {
+		t.Errorf("wrong result of the added builtin. got=%s", result.Inspect())
+	}
+
+	Builtins = builtins
+	if GetBuiltinByName("double") != nil {
+		t.Errorf("builtin still found after cutting it from Builtins")
+	}
+	if i, ok := BuiltinIndex("len"); !ok || Builtins[i].Name != "len" {
+		t.Errorf("wrong index of len. got=%d, %t", i, ok)
+	}
+}
@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/token"
+)
+
+// ErrorCode identifies the kind of a parse error so tools can react to it without
+// matching on the message.
+type ErrorCode string
+
+const (
+	ErrLexical              ErrorCode = "E001" // The lexer rejected the source, e.g. an unterminated string.
+	ErrUnexpectedToken      ErrorCode = "E002" // A specific token was expected but a different one was found.
+	ErrMissingExpression    ErrorCode = "E003" // An expression was expected but the token cannot start one.
+	ErrInvalidNumber        ErrorCode = "E004" // A numeric literal is out of range or malformed.
+	ErrInvalidInterpolation ErrorCode = "E005" // A ${...} interpolation in a string is malformed.
+	ErrInvalidAssignment    ErrorCode = "E006" // The left hand side of an assignment cannot be assigned to.
+	ErrOutsideLoop          ErrorCode = "E007" // A break or continue statement is not inside a loop.
+	ErrInvalidPattern       ErrorCode = "E008" // A token cannot start a pattern, e.g. in the arm of a match expression.
+	ErrInvalidParameter     ErrorCode = "E009" // A required parameter follows a parameter with a default value.
+	ErrNotTopLevel          ErrorCode = "E010" // An import or export statement is inside a block.
+	ErrInvalidType          ErrorCode = "E011" // A token cannot start a type annotation.
+	ErrOutsideGenerator     ErrorCode = "E012" // A yield statement is not inside a generator function.
+)
+
+// ParseError represents a problem found in the source code while parsing.
+type ParseError struct {
+	Code     ErrorCode         // The kind of error.
+	Message  string            // A description of the problem.
+	Pos      token.Position    // The position of the first character of the offending token.
+	End      token.Position    // The position immediately after the offending token.
+	Expected []token.TokenType // The token types that would have been accepted, if known.
+	Found    token.TokenType   // The type of the offending token.
+}
+
+// Error returns the error message prefixed with its position.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
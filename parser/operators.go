@@ -0,0 +1,204 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/token"
+	"strings"
+)
+
+// Associativity tells how a chain of operators with the same precedence is grouped.
+type Associativity int
+
+const (
+	LeftAssociative  Associativity = iota // a op b op c is parsed as (a op b) op c
+	RightAssociative                      // a op b op c is parsed as a op (b op c)
+)
+
+// operatorChars are the characters an operator added by the host can be made of.
+const operatorChars = "!#$%&*+-./:<=>?@^|~"
+
+// operator is an operator added by the host.
+type operator struct {
+	function      string        // The name of the function the operator is mapped to.
+	builtin       bool          // Whether the function is a built-in function, called whatever the program binds to its name.
+	precedence    int           // The precedence of the operator, or of its operand for a prefix operator.
+	associativity Associativity // How a chain of the operator is grouped, for an infix operator.
+}
+
+// Operators is a set of operators added by the host on top of the operators built into the
+// language, such as a |> pipeline or a ** power operator. An operator is mapped to the name
+// of a function, and is parsed as a call to it: with |> mapped to pipe, x |> f is the same
+// as pipe(x, f). When the name is that of a built-in function as the operator is added,
+// including one added by the host with object.AddBuiltin, the operator always calls that
+// function, even where the program binds the name to something else. Otherwise the name is
+// looked up where the operator is used, so it can be a function defined by the program.
+//
+// The operators are registered before parsing starts and must not be changed while a
+// parser uses them.
+type Operators struct {
+	infix  map[token.TokenType]*operator // The infix operators, by symbol.
+	prefix map[token.TokenType]*operator // The prefix operators, by symbol.
+}
+
+// NewOperators creates an empty set of operators.
+//
+// Returns:
+//   - *Operators: The new set of operators.
+func NewOperators() *Operators {
+	return &Operators{
+		infix:  map[token.TokenType]*operator{},
+		prefix: map[token.TokenType]*operator{},
+	}
+}
+
+// Infix adds an infix operator. Its precedence is compared with the precedence levels of
+// the built-in operators, which are ten apart so that it can also fall between two of them:
+// PRODUCT + 1 binds tighter than * but looser than a prefix operator.
+//
+// Parameters:
+//   - symbol: The operator symbol, made of the characters !#$%&*+-./:<=>?@^|~.
+//   - precedence: The precedence of the operator, which must be higher than LOWEST.
+//   - associativity: How a chain of the operator is grouped.
+//   - function: The name of the function called with the left and right operands.
+//
+// Returns:
+//   - error: An error if the symbol or the precedence cannot be used.
+func (o *Operators) Infix(symbol string, precedence int, associativity Associativity, function string) error {
+	if err := o.check(symbol, o.infix, function); err != nil {
+		return err
+	}
+	if precedence <= LOWEST {
+		return fmt.Errorf("precedence of operator %s must be higher than LOWEST, got %d", symbol, precedence)
+	}
+
+	o.infix[token.TokenType(symbol)] = &operator{function: function, builtin: isBuiltin(function), precedence: precedence, associativity: associativity}
+	return nil
+}
+
+// Prefix adds a prefix operator.
+//
+// Parameters:
+//   - symbol: The operator symbol, made of the characters !#$%&*+-./:<=>?@^|~.
+//   - precedence: The precedence the operand is parsed with; PREFIX parses it like the
+//     operand of - or !.
+//   - function: The name of the function called with the operand.
+//
+// Returns:
+//   - error: An error if the symbol cannot be used.
+func (o *Operators) Prefix(symbol string, precedence int, function string) error {
+	if err := o.check(symbol, o.prefix, function); err != nil {
+		return err
+	}
+
+	o.prefix[token.TokenType(symbol)] = &operator{function: function, builtin: isBuiltin(function), precedence: precedence}
+	return nil
+}
+
+// isBuiltin reports whether a name is that of a built-in function.
+//
+// Parameters:
+//   - name: The name.
+//
+// Returns:
+//   - bool: Whether there is a built-in function with the name.
+func isBuiltin(name string) bool {
+	_, ok := object.BuiltinIndex(name)
+	return ok
+}
+
+// check reports why an operator cannot be added, if it cannot. A symbol that the lexer
+// already reads as a whole token belongs to the language and cannot be redefined.
+//
+// Parameters:
+//   - symbol: The operator symbol.
+//   - operators: The operators of the same kind added so far.
+//   - function: The name of the function the operator is mapped to.
+//
+// Returns:
+//   - error: The problem with the operator, or nil.
+func (o *Operators) check(symbol string, operators map[token.TokenType]*operator, function string) error {
+	if symbol == "" || strings.Trim(symbol, operatorChars) != "" {
+		return fmt.Errorf("invalid operator %q: must be made of the characters %s", symbol, operatorChars)
+	}
+	if strings.Contains(symbol, "//") || strings.Contains(symbol, "/*") {
+		return fmt.Errorf("invalid operator %q: must not contain the start of a comment", symbol)
+	}
+	if function == "" {
+		return fmt.Errorf("no function for operator %s", symbol)
+	}
+
+	tok := lexer.New(symbol).NextToken()
+	if _, ok := operators[token.TokenType(symbol)]; ok || (tok.Literal == symbol && tok.Type != token.ILLEGAL) {
+		return fmt.Errorf("operator %s is already defined", symbol)
+	}
+	return nil
+}
+
+// symbols lists the symbols of the operators, for the lexer.
+//
+// Returns:
+//   - []string: The symbols of the infix and prefix operators.
+func (o *Operators) symbols() []string {
+	symbols := []string{}
+	for tt := range o.infix {
+		symbols = append(symbols, string(tt))
+	}
+	for tt := range o.prefix {
+		symbols = append(symbols, string(tt))
+	}
+	return symbols
+}
+
+// parseOperatorCall parses an infix operator added by the host as a call to the function
+// it is mapped to, with the left and right operands as the arguments.
+//
+// Parameters:
+//   - left: The left operand.
+//
+// Returns:
+//   - ast.Expression: The call expression.
+func (p *Parser) parseOperatorCall(left ast.Expression) ast.Expression {
+	op := p.operators.infix[p.curToken.Type]
+	call := &ast.CallExpression{Token: p.curToken, Function: p.operatorFunction(op)}
+
+	precedence := op.precedence
+	if op.associativity == RightAssociative {
+		precedence--
+	}
+	p.nextToken()
+	call.Arguments = []ast.Expression{left, p.parseExpression(precedence)}
+
+	return call
+}
+
+// parsePrefixOperatorCall parses a prefix operator added by the host as a call to the
+// function it is mapped to, with the operand as the argument.
+//
+// Returns:
+//   - ast.Expression: The call expression.
+func (p *Parser) parsePrefixOperatorCall() ast.Expression {
+	op := p.operators.prefix[p.curToken.Type]
+	call := &ast.CallExpression{Token: p.curToken, Function: p.operatorFunction(op)}
+
+	p.nextToken()
+	call.Arguments = []ast.Expression{p.parseExpression(op.precedence)}
+
+	return call
+}
+
+// operatorFunction creates the identifier of the function an operator is mapped to, at
+// the position of the current operator token. The identifier of a built-in function is
+// marked as such, so that no binding of the program can hide it.
+//
+// Parameters:
+//   - op: The operator.
+//
+// Returns:
+//   - *ast.Identifier: The identifier naming the function.
+func (p *Parser) operatorFunction(op *operator) *ast.Identifier {
+	tok := token.Token{Type: token.IDENT, Literal: op.function, Pos: p.curToken.Pos, End: p.curToken.End}
+	return &ast.Identifier{Token: tok, Value: op.function, Builtin: op.builtin}
+}
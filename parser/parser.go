@@ -13,9 +13,14 @@ type (
 	infixParseFn  func(ast.Expression) ast.Expression // A function that is used to parse an expression with an infix operator.
 )
 
+// The precedence levels of the built-in operators. They are ten apart so that an operator
+// added by the host can be given a precedence between two of them.
 const (
-	_           int = iota
+	_           int = iota * 10
 	LOWEST          // The lowest precedence possible
+	ASSIGN          // = or +=
+	LOGICAL_OR      // ||
+	LOGICAL_AND     // &&
 	EQUALS          // ==
 	LESSGREATER     // < or >
 	SUM             // +
@@ -27,26 +32,45 @@ const (
 
 // precedence defines the operator precedence for each given token type.
 var precedence = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NE:       EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.PERCENT_ASSIGN:  ASSIGN,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.EQ:              EQUALS,
+	token.NE:              EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LE:              LESSGREATER,
+	token.GE:              LESSGREATER,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
+	token.DOT:             INDEX,
 }
 
 // Parser represents the monkey language parser to convert tokens into a runnable program.
 type Parser struct {
 	lex            *lexer.Lexer                      // The lexer that is used for generating tokens.
-	errors         []string                          // The list of parse errors encountered.
+	errors         []*ParseError                     // The list of parse errors encountered.
+	panicMode      bool                              // Set after an error until the parser has synchronized, suppressing follow-on errors.
+	loopDepth      int                               // The number of loops enclosing the current token within the current function.
+	generator      bool                              // Whether the current token is inside the body of a generator function.
+	blockDepth     int                               // The number of blocks enclosing the current token.
+	comments       []token.Comment                   // The comments attached to every token read so far.
+	lexErrors      int                               // The number of lexer errors already copied into errors.
 	curToken       token.Token                       // The current token to be parsed.
 	peekToken      token.Token                       // The next token to be parsed.
 	prefixParseFns map[token.TokenType]prefixParseFn // The map of token types to a predetermined prefix parsing function.
 	infixParseFns  map[token.TokenType]infixParseFn  // The map of token types to a predetermined infix parsing function.
+	operators      *Operators                        // The operators added by the host.
 }
 
 // New creates a new Parser.
@@ -57,7 +81,27 @@ type Parser struct {
 // Returns:
 //   - *Parser: a new parser.
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{lex: l, errors: []string{}}
+	return NewWithOperators(l, nil)
+}
+
+// NewWithOperators creates a new Parser that also parses the operators added by the host.
+// The lexer is told about the operator symbols so that it reads each as a single token.
+//
+// Parameters:
+//   - l: A lexer used to handle the source code lexing.
+//   - operators: The operators added by the host, or nil if there are none.
+//
+// Returns:
+//   - *Parser: a new parser.
+func NewWithOperators(l *lexer.Lexer, operators *Operators) *Parser {
+	if operators == nil {
+		operators = NewOperators()
+	}
+	for _, symbol := range operators.symbols() {
+		l.AddOperator(symbol)
+	}
+
+	p := &Parser{lex: l, errors: []*ParseError{}, operators: operators}
 
 	// Read two tokens so curToken and peekToken are both set.
 	p.nextToken()
@@ -67,14 +111,19 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefixFn(token.IDENT, p.parseIdentifier)
 	p.registerPrefixFn(token.INT, p.parseIntegerLiteral)
+	p.registerPrefixFn(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefixFn(token.BANG, p.parsePrefixExpression)
 	p.registerPrefixFn(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefixFn(token.TRUE, p.parseBoolean)
 	p.registerPrefixFn(token.FALSE, p.parseBoolean)
 	p.registerPrefixFn(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefixFn(token.IF, p.parseIfExpression)
+	p.registerPrefixFn(token.MATCH, p.parseMatchExpression)
 	p.registerPrefixFn(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefixFn(token.MACRO, p.parseMacroLiteral)
+	p.registerPrefixFn(token.TRY, p.parseTryExpression)
 	p.registerPrefixFn(token.STRING, p.parseStringLiteral)
+	p.registerPrefixFn(token.TEMPLATE_HEAD, p.parseInterpolatedString)
 	p.registerPrefixFn(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefixFn(token.LBRACE, p.parseHashLiteral)
 
@@ -84,12 +133,32 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfixFn(token.MINUS, p.parseInfixExpression)
 	p.registerInfixFn(token.SLASH, p.parseInfixExpression)
 	p.registerInfixFn(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfixFn(token.PERCENT, p.parseInfixExpression)
 	p.registerInfixFn(token.EQ, p.parseInfixExpression)
 	p.registerInfixFn(token.NE, p.parseInfixExpression)
 	p.registerInfixFn(token.LT, p.parseInfixExpression)
 	p.registerInfixFn(token.GT, p.parseInfixExpression)
+	p.registerInfixFn(token.LE, p.parseInfixExpression)
+	p.registerInfixFn(token.GE, p.parseInfixExpression)
+	p.registerInfixFn(token.AND, p.parseInfixExpression)
+	p.registerInfixFn(token.OR, p.parseInfixExpression)
+	p.registerInfixFn(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.SLASH_ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.PERCENT_ASSIGN, p.parseAssignExpression)
 	p.registerInfixFn(token.LPAREN, p.parseCallExpression)
 	p.registerInfixFn(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfixFn(token.DOT, p.parseMemberExpression)
+
+	// Register the operators added by the host
+	for tokenType := range operators.prefix {
+		p.registerPrefixFn(tokenType, p.parsePrefixOperatorCall)
+	}
+	for tokenType := range operators.infix {
+		p.registerInfixFn(tokenType, p.parseOperatorCall)
+	}
 
 	return p
 }
@@ -107,24 +176,92 @@ func (p *Parser) registerInfixFn(tokenType token.TokenType, fn infixParseFn) {
 // Errors returns any errors encountered during parsing.
 //
 // Returns:
-//   - []string: The errors encountered during parsing.
-func (p *Parser) Errors() []string {
+//   - []*ParseError: The errors encountered during parsing.
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
+// addError adds an error about a token to the parser's error list and enters panic mode.
+// While in panic mode further errors are dropped, since they are usually caused by the
+// first one, until the statement loop synchronizes.
+//
+// Parameters:
+//   - code: The kind of error.
+//   - tok: The offending token.
+//   - expected: The token types that would have been accepted, if any.
+//   - format: The format string used to create the error message.
+//   - a: Arguments to the format string.
+func (p *Parser) addError(code ErrorCode, tok token.Token, expected []token.TokenType, format string, a ...any) {
+	if p.panicMode {
+		return
+	}
+	p.panicMode = true
+
+	p.errors = append(p.errors, &ParseError{
+		Code:     code,
+		Message:  fmt.Sprintf(format, a...),
+		Pos:      tok.Pos,
+		End:      tok.End,
+		Expected: expected,
+		Found:    tok.Type,
+	})
+}
+
 // peekError creates an error message and adds it to the parser's error list.
 //
 // Parameters:
 //   - t: The expected token type.
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, but got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(ErrUnexpectedToken, p.peekToken, []token.TokenType{t},
+		"expected next token to be %s, but got %s instead", t, p.peekToken.Type)
+}
+
+// synchronize skips tokens after an error until the end of the failed statement, which is
+// a ; or the token before the } that closes the enclosing block, and leaves panic mode.
+// Braces opened while skipping are matched so that a nested block is skipped as a whole.
+func (p *Parser) synchronize() {
+	p.panicMode = false
+	depth := 0
+
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				return
+			}
+		}
+
+		if depth == 0 && (p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF)) {
+			return
+		}
+		p.nextToken()
+	}
 }
 
 // nextToken uses the lexer to get the next token and update its internal state.
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.lex.NextToken()
+
+	p.comments = append(p.comments, p.peekToken.Leading...)
+	p.comments = append(p.comments, p.peekToken.Trailing...)
+
+	for _, err := range p.lex.Errors()[p.lexErrors:] {
+		p.errors = append(p.errors, &ParseError{
+			Code:    ErrLexical,
+			Message: err.Message,
+			Pos:     err.Pos,
+			End:     p.peekToken.End,
+			Found:   p.peekToken.Type,
+		})
+	}
+	p.lexErrors = len(p.lex.Errors())
 }
 
 // ParseProgram uses the parser to parse the source code.
@@ -137,11 +274,20 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	for !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.panicMode {
+			p.synchronize()
+			// There is no enclosing block at the top level, so a } that synchronize stopped
+			// at belongs to the expression that failed to parse, e.g. a hash literal.
+			for p.peekTokenIs(token.RBRACE) {
+				p.nextToken()
+				p.synchronize()
+			}
+		} else if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	program.Comments = p.comments
 
 	return program
 }
@@ -156,6 +302,28 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.YIELD:
+		return p.parseYieldStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
+	case token.STRUCT:
+		return p.parseStructStatement()
+	case token.IMPL:
+		return p.parseImplStatement()
+	case token.ENUM:
+		return p.parseEnumStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -168,11 +336,28 @@ func (p *Parser) parseStatement() ast.Statement {
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parsePattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			stmt.Type = p.parseType()
+			if stmt.Type == nil {
+				return nil
+			}
+		}
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -181,7 +366,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
-	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Name != nil {
 		fl.Name = stmt.Name.Value
 	}
 
@@ -246,14 +431,15 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
-// parseExpressionStatement constructs an Expression Statement at the current parser position.
+// parseThrowStatement constructs a throw statement at the current parser position.
 //
 // Returns:
-//   - *ast.ExpressionStatement: The new expression statement.
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	stmt := &ast.ExpressionStatement{Token: p.curToken}
+//   - *ast.ThrowStatement: The throw statement parsed from the current parser position.
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
 
-	stmt.Expression = p.parseExpression(LOWEST)
+	stmt.Value = p.parseExpression(LOWEST)
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -262,267 +448,1392 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	return stmt
 }
 
-// parseExpression parses the expression with the given precedence level.
-//
-// Parameters:
-//   - precedence: The precedence to use for determining parsing order. (higher is more important)
+// parseYieldStatement parses a yield statement, which is only allowed inside a generator function.
 //
 // Returns:
-//   - ast.Expression: The expression parsed from the current token position.
-func (p *Parser) parseExpression(precedence int) ast.Expression {
-	prefix := p.prefixParseFns[p.curToken.Type]
-	if prefix == nil {
-		p.noPrefixParseFnError(p.curToken.Type)
+//   - ast.Statement: The yield statement parsed from the current parser position.
+func (p *Parser) parseYieldStatement() ast.Statement {
+	stmt := &ast.YieldStatement{Token: p.curToken}
+
+	if !p.generator {
+		p.addError(ErrOutsideGenerator, p.curToken, nil, "yield outside of a generator function")
 		return nil
 	}
+	p.nextToken()
 
-	leftExp := prefix()
-
-	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
-		infix := p.infixParseFns[p.peekToken.Type]
-		if infix == nil {
-			return leftExp
-		}
+	stmt.Value = p.parseExpression(LOWEST)
 
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
-		leftExp = infix(leftExp)
 	}
 
-	return leftExp
-}
-
-// parseIdentifier parses the current token as an Identifier.
-//
-// Returns:
-//   - ast.Expression: The expression parsed from the current token position.
-func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return stmt
 }
 
-// parseIntegerLiteral parses the current token as an integer.
+// parseImportStatement parses an import statement, e.g. import "lib/strings.mk" as str;
+// which is only allowed at the top level of a program.
 //
 // Returns:
-//   - ast.Expression: The integer literal expression parsed from the current token position.
-func (p *Parser) parseIntegerLiteral() ast.Expression {
-	lit := &ast.IntegerLiteral{Token: p.curToken}
+//   - ast.Statement: The import statement parsed from the current parser position.
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
 
-	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
-	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+	if p.blockDepth > 0 {
+		p.addError(ErrNotTopLevel, p.curToken, nil, "import is only allowed at the top level")
+		return nil
 	}
 
-	lit.Value = value
-	return lit
-}
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 
-// parseStringLiteral parses the current token as a literal string.
-//
-// Returns:
-//   - ast.Expression: The string literal expression parsed from the current token position.
-func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
-}
+	if !p.expectPeek(token.AS) {
+		return nil
+	}
 
-// noPrefixParseFnError adds an error message to the parser error list when no registered parsing function was found.
-//
-// Parameters:
-//   - t: The token type that was missing the registered prefixParseFn.
-func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
-}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
-// parsePrefixExpression creates a prefix expression by parsing the operator and expression.
-//
-// Returns:
-//   - ast.Expression: The expression parsed as a prefix expression.
-func (p *Parser) parsePrefixExpression() ast.Expression {
-	expression := &ast.PrefixExpression{
-		Token:    p.curToken,
-		Operator: p.curToken.Literal,
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
 	}
 
-	p.nextToken()
-	expression.Right = p.parseExpression(PREFIX)
-	return expression
+	return stmt
 }
 
-// peekPrecedence finds the precedence of the parsers peekToken.
+// parseExportStatement parses an export statement, e.g. export upper, lower;
+// which is only allowed at the top level of a program.
 //
 // Returns:
-//   - int: The precedence for the peekToken.
-func (p *Parser) peekPrecedence() int {
-	if p, ok := precedence[p.peekToken.Type]; ok {
-		return p
+//   - ast.Statement: The export statement parsed from the current parser position.
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+
+	if p.blockDepth > 0 {
+		p.addError(ErrNotTopLevel, p.curToken, nil, "export is only allowed at the top level")
+		return nil
 	}
 
-	return LOWEST
-}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Names = append(stmt.Names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
 
-// curPrecedence finds the precedence for the parser's curToken.
-//
-// Returns:
-//   - int: The precedence for the curToken.
-func (p *Parser) curPrecedence() int {
-	if p, ok := precedence[p.curToken.Type]; ok {
-		return p
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Names = append(stmt.Names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
 	}
 
-	return LOWEST
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
 }
 
-// parseInfixExpression constructs an infix expression.
-//
-// Parameters:
-//   - left: An expression used as the left hand side of the infix expression.
+// parseStructStatement parses a struct declaration, e.g. struct Point { x, y }
 //
 // Returns:
-//   - ast.Expression: The resulting infix expression.
-func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
-	expression := &ast.InfixExpression{
-		Token:    p.curToken,
-		Operator: p.curToken.Literal,
-		Left:     left,
-	}
+//   - ast.Statement: The struct statement parsed from the current parser position.
+func (p *Parser) parseStructStatement() ast.Statement {
+	stmt := &ast.StructStatement{Token: p.curToken}
 
-	precedence := p.curPrecedence()
-	p.nextToken()
-	expression.Right = p.parseExpression(precedence)
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
-	return expression
-}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
 
-// parseBoolean parses a boolean expression.
-//
-// Returns:
-//   - ast.Expression: A boolean ast expression.
-func (p *Parser) parseBoolean() ast.Expression {
-	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
-}
+	stmt.Fields = []*ast.Identifier{}
+	seen := map[string]bool{}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		if seen[p.curToken.Literal] {
+			p.addError(ErrUnexpectedToken, p.curToken, nil, "duplicate field %s in struct %s", p.curToken.Literal, stmt.Name.Value)
+			return nil
+		}
+		seen[p.curToken.Literal] = true
+		stmt.Fields = append(stmt.Fields, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
 
-// parseGroupedExpression parses expression that are grouped.
-//
-// Returns:
-//   - ast.Expression: The grouping ast expression.
-func (p *Parser) parseGroupedExpression() ast.Expression {
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
 	p.nextToken()
+	stmt.Rbrace = p.curToken
 
-	exp := p.parseExpression(LOWEST)
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
 	}
 
-	return exp
+	return stmt
 }
 
-// parseIfExpression parses an if expression into its separate parts.
+// parseEnumStatement parses an enum declaration, e.g. enum Result { Ok(value), Err(reason), None }
 //
 // Returns:
-//   - ast.Expression: the parsed if expression.
-func (p *Parser) parseIfExpression() ast.Expression {
-	expression := &ast.IfExpression{Token: p.curToken}
-
-	if !p.expectPeek(token.LPAREN) {
-		return nil
-	}
-
-	p.nextToken()
-	expression.Condition = p.parseExpression(LOWEST)
+//   - ast.Statement: The enum statement parsed from the current parser position.
+func (p *Parser) parseEnumStatement() ast.Statement {
+	stmt := &ast.EnumStatement{Token: p.curToken}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
-	expression.Consequence = p.parseBlockStatement()
+	stmt.Variants = []*ast.EnumVariant{}
+	seen := map[string]bool{}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		if seen[p.curToken.Literal] {
+			p.addError(ErrUnexpectedToken, p.curToken, nil, "duplicate variant %s in enum %s", p.curToken.Literal, stmt.Name.Value)
+			return nil
+		}
+		seen[p.curToken.Literal] = true
 
-	if p.peekTokenIs(token.ELSE) {
-		p.nextToken()
+		variant := p.parseEnumVariant()
+		if variant == nil {
+			return nil
+		}
+		stmt.Variants = append(stmt.Variants, variant)
 
-		if !p.expectPeek(token.LBRACE) {
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
 		}
+	}
+	p.nextToken()
+	stmt.Rbrace = p.curToken
 
-		expression.Alternative = p.parseBlockStatement()
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
 	}
 
-	return expression
+	return stmt
 }
 
-// parseBlockStatement parses a block statement like the Consequence of an if expression.
+// parseEnumVariant parses a variant of an enum declaration, e.g. Ok(value) or None.
 //
 // Returns:
-//   - *ast.BlockStatement: The block statement after parsing.
-func (p *Parser) parseBlockStatement() *ast.BlockStatement {
-	block := &ast.BlockStatement{Token: p.curToken}
-	block.Statements = []ast.Statement{}
+//   - *ast.EnumVariant: The variant, or nil if its fields are malformed.
+func (p *Parser) parseEnumVariant() *ast.EnumVariant {
+	variant := &ast.EnumVariant{
+		Name:   &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+		Fields: []*ast.Identifier{},
+	}
 
+	if !p.peekTokenIs(token.LPAREN) {
+		return variant
+	}
 	p.nextToken()
 
-	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		if stmt != nil {
-			block.Statements = append(block.Statements, stmt)
+	seen := map[string]bool{}
+	for !p.peekTokenIs(token.RPAREN) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		if seen[p.curToken.Literal] {
+			p.addError(ErrUnexpectedToken, p.curToken, nil, "duplicate field %s in variant %s", p.curToken.Literal, variant.Name.Value)
+			return nil
+		}
+		seen[p.curToken.Literal] = true
+		variant.Fields = append(variant.Fields, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return nil
 		}
-		p.nextToken()
 	}
+	p.nextToken()
 
-	return block
+	return variant
 }
 
-// parseFunctionLiteral parses a user defined function.
+// parseImplStatement parses the methods of a struct type, e.g. impl Point { fn dist(self) { ... } }
+//
+// Returns:
+//   - ast.Statement: The impl statement parsed from the current parser position.
+func (p *Parser) parseImplStatement() ast.Statement {
+	stmt := &ast.ImplStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Methods = []*ast.Method{}
+	for !p.peekTokenIs(token.RBRACE) {
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+			continue
+		}
+
+		if !p.expectPeek(token.FUNCTION) {
+			return nil
+		}
+		fn := &ast.FunctionLiteral{Token: p.curToken}
+		if p.peekTokenIs(token.ASTERISK) {
+			p.nextToken()
+			fn.Generator = true
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if !p.parseFunctionSignatureAndBody(fn) {
+			return nil
+		}
+		stmt.Methods = append(stmt.Methods, &ast.Method{Name: name, Function: fn})
+	}
+	p.nextToken()
+	stmt.Rbrace = p.curToken
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseWhileStatement parses a while loop, e.g. while (x < 10) { ... }.
+//
+// Returns:
+//   - ast.Statement: The while statement parsed from the current parser position.
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+	if stmt.Body == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseForStatement parses either a C-style for loop, e.g. for (let i = 0; i < n; i += 1) { ... },
+// or a for-in loop, e.g. for (x in items) { ... }.
+//
+// Returns:
+//   - ast.Statement: The for or for-in statement parsed from the current parser position.
+func (p *Parser) parseForStatement() ast.Statement {
+	forToken := p.curToken
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+
+	if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.IN) {
+		return p.parseForInStatement(forToken)
+	}
+
+	stmt := &ast.ForStatement{Token: forToken}
+
+	if !p.curTokenIs(token.SEMICOLON) {
+		stmt.Init = p.parseStatement()
+		if !p.curTokenIs(token.SEMICOLON) {
+			p.peekError(token.SEMICOLON)
+			return nil
+		}
+	}
+
+	if !p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		stmt.Condition = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.SEMICOLON) {
+		return nil
+	}
+
+	if !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		stmt.Update = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+	if stmt.Body == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseForInStatement parses the rest of a for-in loop, starting at the loop variable.
+//
+// Parameters:
+//   - forToken: The 'for' token that started the loop.
+//
+// Returns:
+//   - ast.Statement: The for-in statement parsed from the current parser position.
+func (p *Parser) parseForInStatement(forToken token.Token) ast.Statement {
+	stmt := &ast.ForInStatement{Token: forToken}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	p.nextToken()
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+	if stmt.Body == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseLoopBody parses the block of a loop, where break and continue are allowed.
+//
+// Returns:
+//   - *ast.BlockStatement: The loop body, or nil when the block is missing.
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	return p.parseBlockStatement()
+}
+
+// parseBreakStatement parses a break statement, which is only allowed inside a loop.
+//
+// Returns:
+//   - ast.Statement: The break statement parsed from the current parser position.
+func (p *Parser) parseBreakStatement() ast.Statement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.addError(ErrOutsideLoop, p.curToken, nil, "break outside of a loop")
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseContinueStatement parses a continue statement, which is only allowed inside a loop.
+//
+// Returns:
+//   - ast.Statement: The continue statement parsed from the current parser position.
+func (p *Parser) parseContinueStatement() ast.Statement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.addError(ErrOutsideLoop, p.curToken, nil, "continue outside of a loop")
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseExpressionStatement constructs an Expression Statement at the current parser position.
+//
+// Returns:
+//   - *ast.ExpressionStatement: The new expression statement.
+func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+
+	stmt.Expression = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseExpression parses the expression with the given precedence level.
+//
+// Parameters:
+//   - precedence: The precedence to use for determining parsing order. (higher is more important)
+//
+// Returns:
+//   - ast.Expression: The expression parsed from the current token position.
+func (p *Parser) parseExpression(precedence int) ast.Expression {
+	prefix := p.prefixParseFns[p.curToken.Type]
+	if prefix == nil {
+		p.noPrefixParseFnError(p.curToken.Type)
+		return nil
+	}
+
+	leftExp := prefix()
+
+	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+		infix := p.infixParseFns[p.peekToken.Type]
+		if infix == nil {
+			return leftExp
+		}
+
+		p.nextToken()
+		leftExp = infix(leftExp)
+	}
+
+	return leftExp
+}
+
+// parseIdentifier parses the current token as an Identifier.
+//
+// Returns:
+//   - ast.Expression: The expression parsed from the current token position.
+func (p *Parser) parseIdentifier() ast.Expression {
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+// parseIntegerLiteral parses the current token as an integer.
+//
+// Returns:
+//   - ast.Expression: The integer literal expression parsed from the current token position.
+func (p *Parser) parseIntegerLiteral() ast.Expression {
+	lit := &ast.IntegerLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if err != nil {
+		p.addError(ErrInvalidNumber, p.curToken, nil, "could not parse %q as integer", p.curToken.Literal)
+	}
+
+	lit.Value = value
+	return lit
+}
+
+// parseFloatLiteral parses the current token as a floating point number.
+//
+// Returns:
+//   - ast.Expression: The float literal expression parsed from the current token position.
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.addError(ErrInvalidNumber, p.curToken, nil, "could not parse %q as float", p.curToken.Literal)
+	}
+
+	lit.Value = value
+	return lit
+}
+
+// parseStringLiteral parses the current token as a literal string.
+//
+// Returns:
+//   - ast.Expression: The string literal expression parsed from the current token position.
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+// parseInterpolatedString parses a string literal with embedded ${...} expressions,
+// starting at the TEMPLATE_HEAD token.
+//
+// Returns:
+//   - ast.Expression: The interpolated string expression parsed from the current token position.
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+	str.Parts = p.appendStringPart(str.Parts, p.curToken)
+
+	for {
+		p.nextToken()
+		if p.curTokenIs(token.TEMPLATE_MIDDLE) || p.curTokenIs(token.TEMPLATE_TAIL) {
+			p.addError(ErrInvalidInterpolation, p.curToken, nil, "empty expression in string interpolation")
+			return nil
+		}
+
+		str.Parts = append(str.Parts, p.parseExpression(LOWEST))
+
+		if !p.peekTokenIs(token.TEMPLATE_MIDDLE) && !p.peekTokenIs(token.TEMPLATE_TAIL) {
+			p.addError(ErrInvalidInterpolation, p.peekToken,
+				[]token.TokenType{token.TEMPLATE_MIDDLE, token.TEMPLATE_TAIL},
+				"expected } to close string interpolation, but got %s instead", p.peekToken.Type)
+			return nil
+		}
+		p.nextToken()
+		str.Parts = p.appendStringPart(str.Parts, p.curToken)
+
+		if p.curTokenIs(token.TEMPLATE_TAIL) {
+			str.Tail = p.curToken
+			return str
+		}
+	}
+}
+
+// appendStringPart adds the text of a template token to the parts of an interpolated string.
+// Empty text is skipped since it does not contribute to the result.
+//
+// Parameters:
+//   - parts: The parts parsed so far.
+//   - tok: The TEMPLATE_HEAD, TEMPLATE_MIDDLE or TEMPLATE_TAIL token.
+//
+// Returns:
+//   - []ast.Expression: The parts with the text appended.
+func (p *Parser) appendStringPart(parts []ast.Expression, tok token.Token) []ast.Expression {
+	if tok.Literal == "" {
+		return parts
+	}
+	return append(parts, &ast.StringLiteral{Token: tok, Value: tok.Literal})
+}
+
+// noPrefixParseFnError adds an error message to the parser error list when no registered parsing function was found.
+//
+// Parameters:
+//   - t: The token type that was missing the registered prefixParseFn.
+func (p *Parser) noPrefixParseFnError(t token.TokenType) {
+	p.addError(ErrMissingExpression, p.curToken, nil, "no prefix parse function for %s found", t)
+}
+
+// parsePrefixExpression creates a prefix expression by parsing the operator and expression.
+//
+// Returns:
+//   - ast.Expression: The expression parsed as a prefix expression.
+func (p *Parser) parsePrefixExpression() ast.Expression {
+	expression := &ast.PrefixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+	}
+
+	p.nextToken()
+	expression.Right = p.parseExpression(PREFIX)
+	return expression
+}
+
+// peekPrecedence finds the precedence of the parsers peekToken.
+//
+// Returns:
+//   - int: The precedence for the peekToken.
+func (p *Parser) peekPrecedence() int {
+	if p, ok := precedence[p.peekToken.Type]; ok {
+		return p
+	}
+	if op, ok := p.operators.infix[p.peekToken.Type]; ok {
+		return op.precedence
+	}
+
+	return LOWEST
+}
+
+// curPrecedence finds the precedence for the parser's curToken.
+//
+// Returns:
+//   - int: The precedence for the curToken.
+func (p *Parser) curPrecedence() int {
+	if p, ok := precedence[p.curToken.Type]; ok {
+		return p
+	}
+	if op, ok := p.operators.infix[p.curToken.Type]; ok {
+		return op.precedence
+	}
+
+	return LOWEST
+}
+
+// parseInfixExpression constructs an infix expression.
+//
+// Parameters:
+//   - left: An expression used as the left hand side of the infix expression.
+//
+// Returns:
+//   - ast.Expression: The resulting infix expression.
+func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	expression := &ast.InfixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+
+	return expression
+}
+
+// parseAssignExpression constructs an assignment to an existing binding or to an index
+// expression. Assignment is right associative, so a = b = 1 assigns 1 to b and then to a.
+//
+// Parameters:
+//   - target: The expression on the left hand side of the assignment operator.
+//
+// Returns:
+//   - ast.Expression: The resulting assign expression.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression, *ast.MemberExpression:
+	default:
+		p.addError(ErrInvalidAssignment, p.curToken, nil, "cannot assign to %s", target)
+		return nil
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+
+	return expression
+}
+
+// parseMemberExpression parses the access to a member of a value, e.g. p.x
+//
+// Parameters:
+//   - object: The expression producing the value whose member is accessed.
+//
+// Returns:
+//   - ast.Expression: The member expression.
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Member = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+// parseBoolean parses a boolean expression.
+//
+// Returns:
+//   - ast.Expression: A boolean ast expression.
+func (p *Parser) parseBoolean() ast.Expression {
+	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
+}
+
+// parseGroupedExpression parses expression that are grouped.
+//
+// Returns:
+//   - ast.Expression: The grouping ast expression.
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	p.nextToken()
+
+	exp := p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return exp
+}
+
+// parseIfExpression parses an if expression into its separate parts.
+//
+// Returns:
+//   - ast.Expression: the parsed if expression.
+func (p *Parser) parseIfExpression() ast.Expression {
+	expression := &ast.IfExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Consequence = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.ELSE) {
+		p.nextToken()
+
+		// An else if chain is an if expression nested as the only statement of the alternative.
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			block := &ast.BlockStatement{Token: p.curToken}
+			nested := p.parseIfExpression()
+			if nested == nil {
+				return nil
+			}
+			block.Statements = []ast.Statement{&ast.ExpressionStatement{Token: block.Token, Expression: nested}}
+			expression.Alternative = block
+			return expression
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Alternative = p.parseBlockStatement()
+	}
+
+	return expression
+}
+
+// parseTryExpression parses a try expression, e.g. try { ... } catch (e) { ... } finally { ... }.
+// The catch block may leave out the name the thrown value is bound to, and either the catch
+// or the finally block may be left out, but not both.
+//
+// Returns:
+//   - ast.Expression: The try expression, or nil if it is malformed.
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Body = p.parseBlockStatement()
+
+	if !p.peekTokenIs(token.CATCH) && !p.peekTokenIs(token.FINALLY) {
+		p.addError(ErrUnexpectedToken, p.peekToken, []token.TokenType{token.CATCH, token.FINALLY},
+			"expected next token to be %s or %s, but got %s instead", token.CATCH, token.FINALLY, p.peekToken.Type)
+		return nil
+	}
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			expression.CatchParam = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	return expression
+}
+
+// parseMatchExpression parses a match expression like match (x) { 1 => "one", n if n > 1 => "many", _ => "none" }.
+// An arm's body is either a block or a single expression; a hash literal body has to be
+// wrapped in parentheses so that it is not mistaken for a block.
+//
+// Returns:
+//   - ast.Expression: The parsed match expression.
+func (p *Parser) parseMatchExpression() ast.Expression {
+	exp := &ast.MatchExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	exp.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		exp.Arms = append(exp.Arms, arm)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	exp.Rbrace = p.curToken
+
+	return exp
+}
+
+// parseMatchArm parses a single `pattern if guard => body` arm of a match expression.
+//
+// Returns:
+//   - *ast.MatchArm: The parsed arm, or nil if it is malformed.
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Pattern: p.parsePattern()}
+	if arm.Pattern == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.FAT_ARROW) {
+		return nil
+	}
+	p.nextToken()
+
+	if p.curTokenIs(token.LBRACE) {
+		arm.Body = p.parseBlockStatement()
+		return arm
+	}
+
+	stmt := &ast.ExpressionStatement{Token: p.curToken, Expression: p.parseExpression(LOWEST)}
+	if stmt.Expression == nil {
+		return nil
+	}
+	arm.Body = &ast.BlockStatement{Token: p.curToken, Statements: []ast.Statement{stmt}}
+
+	return arm
+}
+
+// parsePattern parses the pattern starting at the current token. A pattern is _, a name
+// to bind, a literal, an array pattern like [first, ...rest], a hash pattern like {"type": t}
+// or a variant pattern like Result.Ok(value).
+//
+// Returns:
+//   - ast.Pattern: The parsed pattern, or nil if the current token cannot start one.
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token.IDENT:
+		if p.curToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: p.curToken}
+		}
+		if p.peekTokenIs(token.DOT) {
+			return p.parseVariantPattern()
+		}
+		return &ast.BindingPattern{Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+	case token.INT, token.FLOAT, token.STRING, token.TRUE, token.FALSE:
+		value := p.prefixParseFns[p.curToken.Type]()
+		if value == nil {
+			return nil
+		}
+		return &ast.LiteralPattern{Value: value}
+	case token.MINUS:
+		if !p.peekTokenIs(token.INT) && !p.peekTokenIs(token.FLOAT) {
+			break
+		}
+		value := p.parsePrefixExpression()
+		if value == nil {
+			return nil
+		}
+		return &ast.LiteralPattern{Value: value}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	}
+
+	p.addError(ErrInvalidPattern, p.curToken, nil, "expected a pattern, got %s", p.curToken.Type)
+	return nil
+}
+
+// parseArrayPattern parses an array pattern like [first, second] or [first, ...rest].
+//
+// Returns:
+//   - ast.Pattern: The parsed array pattern, or nil if it is malformed.
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			pattern.HasRest = true
+			if p.peekTokenIs(token.IDENT) {
+				p.nextToken()
+				if p.curToken.Literal != "_" {
+					pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+				}
+			}
+			// The rest element has to be the last one.
+			break
+		}
+
+		element := p.parsePattern()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	pattern.Rbracket = p.curToken
+
+	return pattern
+}
+
+// parseVariantPattern parses a variant pattern like Result.Ok(value) or Color.Red, with one
+// pattern for each field of the variant.
+//
+// Returns:
+//   - ast.Pattern: The parsed variant pattern, or nil if it is malformed.
+func (p *Parser) parseVariantPattern() ast.Pattern {
+	pattern := &ast.VariantPattern{
+		Enum:   &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+		Fields: []ast.Pattern{},
+	}
+	p.nextToken()
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	pattern.Tag = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.peekTokenIs(token.LPAREN) {
+		return pattern
+	}
+	p.nextToken()
+
+	for !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+
+		field := p.parsePattern()
+		if field == nil {
+			return nil
+		}
+		pattern.Fields = append(pattern.Fields, field)
+
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	pattern.Rparen = p.curToken
+
+	return pattern
+}
+
+// parseHashPattern parses a hash pattern like {"type": t, "size": 1}. The keys have to be
+// string, integer or boolean literals, and a bare name like {name} is short for {"name": name}.
+//
+// Returns:
+//   - ast.Pattern: The parsed hash pattern, or nil if it is malformed.
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		if p.curTokenIs(token.IDENT) && (p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.RBRACE)) {
+			name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			key := &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+			pattern.Keys = append(pattern.Keys, key)
+			pattern.Values = append(pattern.Values, &ast.BindingPattern{Name: name})
+
+			if !p.peekTokenIs(token.RBRACE) {
+				p.nextToken()
+			}
+			continue
+		}
+
+		switch p.curToken.Type {
+		case token.STRING, token.INT, token.TRUE, token.FALSE:
+		default:
+			p.addError(ErrInvalidPattern, p.curToken, nil, "expected a literal hash pattern key, got %s", p.curToken.Type)
+			return nil
+		}
+		key := p.prefixParseFns[p.curToken.Type]()
+		if key == nil {
+			return nil
+		}
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+
+		value := p.parsePattern()
+		if value == nil {
+			return nil
+		}
+
+		pattern.Keys = append(pattern.Keys, key)
+		pattern.Values = append(pattern.Values, value)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	pattern.Rbrace = p.curToken
+
+	return pattern
+}
+
+// parseBlockStatement parses a block statement like the Consequence of an if expression.
+//
+// Returns:
+//   - *ast.BlockStatement: The block statement after parsing.
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
+
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		stmt := p.parseStatement()
+		if p.panicMode {
+			p.synchronize()
+		} else if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		p.nextToken()
+	}
+
+	if p.curTokenIs(token.RBRACE) {
+		block.Rbrace = p.curToken
+	}
+
+	return block
+}
+
+// parseFunctionLiteral parses a user defined function.
 //
 // Returns:
 //   - ast.Expression: The function literal as an expression.
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
+	if p.peekTokenIs(token.ASTERISK) {
+		p.nextToken()
+		lit.Generator = true
+	}
+
+	if !p.parseFunctionSignatureAndBody(lit) {
+		return nil
+	}
+
+	return lit
+}
+
+// parseFunctionSignatureAndBody parses the parameters and body of a function, starting
+// with the current token right before the opening '('.
+//
+// Parameters:
+//   - lit: The function literal to fill in.
+//
+// Returns:
+//   - bool: false if the parameters or body are malformed, otherwise true.
+func (p *Parser) parseFunctionSignatureAndBody(lit *ast.FunctionLiteral) bool {
+	if !p.expectPeek(token.LPAREN) {
+		return false
+	}
+
+	if !p.parseFunctionParameters(lit) {
+		return false
+	}
+
+	if p.peekTokenIs(token.ARROW) {
+		p.nextToken()
+		p.nextToken()
+		lit.ReturnType = p.parseType()
+		if lit.ReturnType == nil {
+			return false
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return false
+	}
+
+	// A loop around the function literal does not make break or continue valid inside it,
+	// and only the body of the generator itself may yield.
+	loopDepth, generator := p.loopDepth, p.generator
+	p.loopDepth, p.generator = 0, lit.Generator
+	lit.Body = p.parseBlockStatement()
+	p.loopDepth, p.generator = loopDepth, generator
+
+	return true
+}
+
+// parseMacroLiteral parses a macro definition. Unlike functions, macros only take
+// plain parameter names.
+//
+// Returns:
+//   - ast.Expression: The macro literal, or nil if it is malformed.
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	lit.Parameters = []*ast.Identifier{}
+	for !p.peekTokenIs(token.RPAREN) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		lit.Parameters = append(lit.Parameters, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+	loopDepth, generator := p.loopDepth, p.generator
+	p.loopDepth, p.generator = 0, false
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth, p.generator = loopDepth, generator
+
 	return lit
 }
 
-// parseFunctionParameters handles the parsing task for function input parameters.
+// parseFunctionParameters handles the parsing task for function input parameters, e.g.
+// fn(a, [b, c], d = 10, ...rest). Parameters with a default value have to come after the
+// required ones, and a rest parameter has to be the last one. The type annotation of a rest
+// parameter, as in ...rest: int, is the type of each argument it collects.
+//
+// Parameters:
+//   - lit: The function literal whose parameters, patterns, defaults and rest parameter are set.
 //
 // Returns:
-//   - []*ast.Identifier: A slice of identifiers that are function parameters.
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+//   - bool: false when the parameter list is malformed.
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
+	lit.Patterns = []ast.Pattern{}
+	lit.Defaults = []ast.Expression{}
+	lit.Types = []ast.Type{}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
-	p.nextToken()
+	for {
+		p.nextToken()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if p.peekTokenIs(token.COLON) {
+				p.nextToken()
+				p.nextToken()
+				if lit.RestType = p.parseType(); lit.RestType == nil {
+					return false
+				}
+			}
+			break
+		}
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
+		ident, pattern := p.parseFunctionParameter(len(lit.Parameters))
+		if ident == nil {
+			return false
+		}
+
+		var typ ast.Type
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			typ = p.parseType()
+			if typ == nil {
+				return false
+			}
+		}
+
+		var def ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			def = p.parseExpression(LOWEST)
+			if def == nil {
+				return false
+			}
+		} else if len(lit.Defaults) > 0 && lit.Defaults[len(lit.Defaults)-1] != nil {
+			p.addError(ErrInvalidParameter, ident.Token, nil, "required parameter cannot follow a parameter with a default value")
+			return false
+		}
+
+		lit.Parameters = append(lit.Parameters, ident)
+		lit.Patterns = append(lit.Patterns, pattern)
+		lit.Defaults = append(lit.Defaults, def)
+		lit.Types = append(lit.Types, typ)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
 	}
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+
+	return p.expectPeek(token.RPAREN)
+}
+
+// parseFunctionParameter parses a single function parameter, which is either a name or an
+// array or hash pattern. A destructured parameter is given a name that cannot clash with
+// user defined names, e.g. $1, which holds the argument until it is destructured.
+//
+// Parameters:
+//   - index: The position of the parameter in the parameter list.
+//
+// Returns:
+//   - *ast.Identifier: The name of the parameter, or nil if the pattern is malformed.
+//   - ast.Pattern: The pattern destructuring the parameter, or nil for a plain name.
+func (p *Parser) parseFunctionParameter(index int) (*ast.Identifier, ast.Pattern) {
+	if !p.curTokenIs(token.LBRACKET) && !p.curTokenIs(token.LBRACE) {
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}, nil
+	}
+
+	tok := p.curToken
+	pattern := p.parsePattern()
+	if pattern == nil {
+		return nil, nil
+	}
+
+	return &ast.Identifier{Token: tok, Value: fmt.Sprintf("$%d", index)}, pattern
+}
+
+// parseType parses the type annotation starting at the current token. A type is a name like
+// int or Point, an array type like [int], a hash type like {string: int} or a function type
+// like fn(int, int) -> bool.
+//
+// Returns:
+//   - ast.Type: The parsed type, or nil if the current token cannot start one.
+func (p *Parser) parseType() ast.Type {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+
+	case token.LBRACKET:
+		typ := &ast.ArrayType{Token: p.curToken}
+		p.nextToken()
+		typ.Element = p.parseType()
+		if typ.Element == nil || !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		typ.Rbracket = p.curToken
+		return typ
+
+	case token.LBRACE:
+		typ := &ast.HashType{Token: p.curToken}
+		p.nextToken()
+		typ.Key = p.parseType()
+		if typ.Key == nil || !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		typ.Value = p.parseType()
+		if typ.Value == nil || !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+		typ.Rbrace = p.curToken
+		return typ
+
+	case token.FUNCTION:
+		typ := &ast.FunctionType{Token: p.curToken, Parameters: []ast.Type{}}
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		for !p.peekTokenIs(token.RPAREN) {
+			p.nextToken()
+			param := p.parseType()
+			if param == nil {
+				return nil
+			}
+			typ.Parameters = append(typ.Parameters, param)
+
+			if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+		}
+		p.nextToken()
+		typ.Rparen = p.curToken
+
+		if p.peekTokenIs(token.ARROW) {
+			p.nextToken()
+			p.nextToken()
+			typ.Return = p.parseType()
+			if typ.Return == nil {
+				return nil
+			}
+		}
+		return typ
 	}
 
-	return identifiers
+	p.addError(ErrInvalidType, p.curToken, nil, "expected a type, got %s", p.curToken.Type)
+	return nil
 }
 
 // parseCallExpression handles parsing for a callable expression.
@@ -535,6 +1846,7 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Rparen = p.curToken
 	return exp
 }
 
@@ -545,6 +1857,7 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken
 	return array
 }
 
@@ -564,12 +1877,12 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	}
 
 	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	list = append(list, p.parseListElement())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
+		list = append(list, p.parseListElement())
 	}
 
 	if !p.expectPeek(end) {
@@ -579,6 +1892,27 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return list
 }
 
+// parseListElement parses an element of an argument list or array literal, which may be
+// spread with ... e.g. f(...args) or [...xs, 4].
+//
+// Returns:
+//   - ast.Expression: The parsed element.
+func (p *Parser) parseListElement() ast.Expression {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.curToken}
+	p.nextToken()
+
+	spread.Value = p.parseExpression(LOWEST)
+	if spread.Value == nil {
+		return nil
+	}
+
+	return spread
+}
+
 // parseIndexExpression parses an index expression like items[0].
 //
 // Parameters:
@@ -590,11 +1924,43 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
 
 	p.nextToken()
+	if p.curTokenIs(token.COLON) {
+		return p.parseSliceExpression(exp.Token, left, nil)
+	}
 	exp.Index = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(exp.Token, left, exp.Index)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	exp.Rbracket = p.curToken
+
+	return exp
+}
+
+// parseSliceExpression parses the remainder of a slice expression once its ':' has been reached.
+// Parameters:
+//   - tok: The opening '[' token.
+//   - left: The expression being sliced.
+//   - low: The already parsed start index, or nil when it was omitted.
+//
+// Returns:
+//   - The parsed SliceExpression, or nil if the closing ']' is missing.
+func (p *Parser) parseSliceExpression(tok token.Token, left, low ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Low: low}
+
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.High = p.parseExpression(LOWEST)
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	exp.Rbracket = p.curToken
 
 	return exp
 }
@@ -624,6 +1990,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.Rbrace = p.curToken
 
 	return hash
 }
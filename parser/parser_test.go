@@ -3,6 +3,8 @@ package parser
 import (
 	"monkey/ast"
 	"monkey/lexer"
+	"monkey/token"
+	"slices"
 	"testing"
 )
 
@@ -106,6 +108,57 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	testIntegerLiteral(t, stmt.Expression, 5)
 }
 
+func TestNumericLiteralForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF", 255},
+		{"0b1010", 10},
+		{"0o17", 15},
+		{"1_000_000", 1000000},
+	}
+
+	for _, tt := range tests {
+		program := constructTestProgram(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %d. got=%d", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"0.5e3;", 500},
+		{"1_000.25;", 1000.25},
+	}
+
+	for _, tt := range tests {
+		program := constructTestProgram(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
 func TestParsingPrefixExpression(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -161,6 +214,11 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
+		{"5 % 5;", 5, "%", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
 	}
 
 	for _, tt := range infixTests {
@@ -287,6 +345,26 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a = b = c || d",
+			"(a = (b = (c || d)))",
+		},
+		{
+			"x += y * 2",
+			"(x += (y * 2))",
+		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"a && b || c <= d % 2",
+			"((a && b) || (c <= (d % 2)))",
+		},
+		{
+			"a + b >= c * d",
+			"((a + b) >= (c * d))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -419,6 +497,295 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestElseIfExpression(t *testing.T) {
+	input := `if (x < 0) { a } else if (x == 0) { b } else { c }`
+
+	program := constructTestProgram(t, input)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression not *ast.IfExpression. got=%T", stmt.Expression)
+	}
+
+	if exp.Alternative == nil || len(exp.Alternative.Statements) != 1 {
+		t.Fatalf("exp.Alternative is not a single statement. got=%v", exp.Alternative)
+	}
+
+	alternative, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("exp.Alternative.Statements[0] is not ast.ExpressionStatement. got=%T", exp.Alternative.Statements[0])
+	}
+
+	nested, ok := alternative.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("alternative is not *ast.IfExpression. got=%T", alternative.Expression)
+	}
+
+	if !testInfixExpression(t, nested.Condition, "x", "==", 0) {
+		return
+	}
+
+	if nested.Alternative == nil {
+		t.Fatal("nested.Alternative was nil and shouldn't have been")
+	}
+
+	expected := "if(x < 0) aelse if(x == 0) belse c"
+	if program.String() != expected {
+		t.Errorf("expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b, ...rest] = arr;", "let [a, b, ...rest] = arr;"},
+		{"let {name, age} = person;", "let {name: name, age: age} = person;"},
+		{`let {"name": n, "pos": [x, _]} = person;`, "let {name: n, pos: [x, _]} = person;"},
+		{"let f = fn([a, b], {c}, d) { a };", "let f = fn<f>([a, b], {c: c}, d)a;"},
+	}
+
+	for _, tt := range tests {
+		program := constructTestProgram(t, tt.input)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.LetStatement. got=%T", program.Statements[0])
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			if len(fn.Parameters) != 3 || len(fn.Patterns) != 3 {
+				t.Fatalf("wrong number of parameters. got=%d", len(fn.Parameters))
+			}
+			if fn.Name != "f" {
+				t.Errorf("fn.Name not 'f'. got=%q", fn.Name)
+			}
+			if fn.Patterns[2] != nil {
+				t.Errorf("plain parameter has a pattern. got=%s", fn.Patterns[2])
+			}
+			testIdentifier(t, fn.Parameters[2], "d")
+			continue
+		}
+
+		if stmt.Name != nil {
+			t.Errorf("stmt.Name is not nil. got=%s", stmt.Name)
+		}
+		if stmt.Pattern == nil {
+			t.Errorf("stmt.Pattern is nil")
+		}
+	}
+}
+
+func TestParameterDefaultsAndSpread(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 10, ...rest) { a };", "fn(a, b = 10, ...rest)a"},
+		{"fn(...args) { args };", "fn(...args)args"},
+		{"fn([a, b] = [1, 2]) { a };", "fn([a, b] = [1, 2])a"},
+		{"f(...args, 1, ...[2]);", "f(...args, 1, ...[2])"},
+		{"[0, ...xs];", "[0, ...xs]"},
+	}
+
+	for _, tt := range tests {
+		program := constructTestProgram(t, tt.input)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	program := constructTestProgram(t, "fn(a, b = 10, ...rest) { a };")
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+
+	if len(fn.Parameters) != 2 || len(fn.Defaults) != 2 {
+		t.Fatalf("wrong number of parameters. got=%d", len(fn.Parameters))
+	}
+	if fn.Defaults[0] != nil {
+		t.Errorf("fn.Defaults[0] is not nil. got=%s", fn.Defaults[0])
+	}
+	testIntegerLiteral(t, fn.Defaults[1], 10)
+	testIdentifier(t, fn.Rest, "rest")
+}
+
+func TestInvalidParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a = 1, b) { a }", "1:11: required parameter cannot follow a parameter with a default value"},
+		{"fn(...rest, a) { a }", "1:11: expected next token to be ), but got , instead"},
+		{"fn(...) { a }", "1:7: expected next token to be IDENT, but got ) instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("wrong number of errors for %q. want=1, got=%d (%v)", tt.input, len(errors), errors)
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestMatchExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`match (x) { 1 => "one", -2.5 => y, _ => z }`,
+			"match (x) { 1 => one, (-2.5) => y, _ => z }",
+		},
+		{
+			`match (x) { [] => 0, [first, ...rest] => first, [a, ...] => a, }`,
+			"match (x) { [] => 0, [first, ...rest] => first, [a, ...] => a }",
+		},
+		{
+			`match (x) { {"type": t, "size": [w, h]} => t, {} => 0 }`,
+			"match (x) { {type: t, size: [w, h]} => t, {} => 0 }",
+		},
+		{
+			`match (x) { n if n > 10 => { let y = n; y }, n => n }`,
+			"match (x) { n if (n > 10) => let y = n;y, n => n }",
+		},
+	}
+
+	for _, tt := range tests {
+		program := constructTestProgram(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.MatchExpression); !ok {
+			t.Fatalf("stmt.Expression not *ast.MatchExpression. got=%T", stmt.Expression)
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestMatchArmPatterns(t *testing.T) {
+	program := constructTestProgram(t, `match (x) { [a, ...rest] if a => 1, {"k": _} => 2 }`)
+
+	match := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+	if len(match.Arms) != 2 {
+		t.Fatalf("match.Arms does not contain 2 arms. got=%d", len(match.Arms))
+	}
+
+	array, ok := match.Arms[0].Pattern.(*ast.ArrayPattern)
+	if !ok {
+		t.Fatalf("pattern is not *ast.ArrayPattern. got=%T", match.Arms[0].Pattern)
+	}
+	if len(array.Elements) != 1 || !array.HasRest || array.Rest == nil || array.Rest.Value != "rest" {
+		t.Errorf("wrong array pattern. got=%s", array)
+	}
+	if _, ok := array.Elements[0].(*ast.BindingPattern); !ok {
+		t.Errorf("element is not *ast.BindingPattern. got=%T", array.Elements[0])
+	}
+	testIdentifier(t, match.Arms[0].Guard, "a")
+
+	hash, ok := match.Arms[1].Pattern.(*ast.HashPattern)
+	if !ok {
+		t.Fatalf("pattern is not *ast.HashPattern. got=%T", match.Arms[1].Pattern)
+	}
+	if len(hash.Keys) != 1 || len(hash.Values) != 1 {
+		t.Fatalf("hash pattern does not contain 1 pair. got=%d", len(hash.Keys))
+	}
+	if _, ok := hash.Values[0].(*ast.WildcardPattern); !ok {
+		t.Errorf("value is not *ast.WildcardPattern. got=%T", hash.Values[0])
+	}
+	if match.Arms[1].Guard != nil {
+		t.Errorf("guard is not nil. got=%s", match.Arms[1].Guard)
+	}
+}
+
+func TestInvalidPatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { x + 1 => 2 }", "1:15: expected next token to be =>, but got + instead"},
+		{"match (x) { fn => 2 }", "1:13: expected a pattern, got FUNCTION"},
+		{"match (x) { {k: 1} => 2 }", "1:14: expected a literal hash pattern key, got IDENT"},
+		{"match (x) { [...rest, a] => 2 }", "1:21: expected next token to be ], but got , instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("wrong number of errors for %q. want=1, got=%d (%v)", tt.input, len(errors), errors)
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	program := constructTestProgram(t, input)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression not ast.MacroLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. Want 2, got=%d", len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d\n", len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T", macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+
+	for _, input := range []string{"macro(x, 1) { x }", "macro(x) x"} {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parse errors for %q", input)
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -529,6 +896,64 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStringExpression(t *testing.T) {
+	input := `"Hello ${name}, ${a + b}!"`
+
+	program := constructTestProgram(t, input)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	if len(str.Parts) != 5 {
+		t.Fatalf("wrong number of parts. want=5, got=%d", len(str.Parts))
+	}
+
+	for i, expected := range []string{"Hello ", ", ", "!"} {
+		literal, ok := str.Parts[i*2].(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("part %d not *ast.StringLiteral. got=%T", i*2, str.Parts[i*2])
+		}
+		if literal.Value != expected {
+			t.Errorf("part %d has wrong value. want=%q, got=%q", i*2, expected, literal.Value)
+		}
+	}
+
+	testIdentifier(t, str.Parts[1], "name")
+	testInfixExpression(t, str.Parts[3], "a", "+", "b")
+
+	if str.String() != `"Hello ${name}, ${(a + b)}!"` {
+		t.Errorf("str.String() wrong. got=%q", str.String())
+	}
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a ${} b"`, "1:6: empty expression in string interpolation"},
+		{`"a ${x y} b"`, "1:8: expected } to close string interpolation, but got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("input %q - expected parser errors, got none", tt.input)
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("input %q - wrong error. expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
 func TestArrayLiteral(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -568,19 +993,56 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
-func TestParsingHashLiteralsStringKeys(t *testing.T) {
-	input := `{"one": 1, "two": 2, "three": 3}`
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"myArray[1:3]", "(myArray[1:3])"},
+		{"myArray[:-1]", "(myArray[:(-1)])"},
+		{"myArray[2:]", "(myArray[2:])"},
+		{"myArray[:]", "(myArray[:])"},
+		{"s[i + 1:len(s)][0]", "((s[(i + 1):len(s)])[0])"},
+	}
 
-	program := constructTestProgram(t, input)
+	for _, tt := range tests {
+		program := constructTestProgram(t, tt.input)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if stmt.Expression.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, stmt.Expression.String())
+		}
+	}
 
+	program := constructTestProgram(t, "myArray[1:]")
 	stmt := program.Statements[0].(*ast.ExpressionStatement)
-	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	slice, ok := stmt.Expression.(*ast.SliceExpression)
 	if !ok {
-		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+		t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
 	}
-
-	expected := map[string]int64{
-		"one":   1,
+	if !testIdentifier(t, slice.Left, "myArray") {
+		return
+	}
+	if !testIntegerLiteral(t, slice.Low, 1) {
+		return
+	}
+	if slice.High != nil {
+		t.Errorf("slice.High is not nil. got=%s", slice.High)
+	}
+}
+
+func TestParsingHashLiteralsStringKeys(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	program := constructTestProgram(t, input)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	expected := map[string]int64{
+		"one":   1,
 		"two":   2,
 		"three": 3,
 	}
@@ -759,3 +1221,1031 @@ func TestFunctionLiteralWithName(t *testing.T) {
 			function.Name)
 	}
 }
+
+func TestNodePositions(t *testing.T) {
+	input := `let add = fn(x, y) {
+	x + y;
+};
+add(1, [2, 3][0]);`
+
+	program := constructTestProgram(t, input)
+
+	tests := []struct {
+		node          ast.Node
+		expectedStart string
+		expectedEnd   string
+	}{
+		{program, "1:1", "4:18"},
+		{program.Statements[0], "1:1", "3:2"},
+		{program.Statements[0].(*ast.LetStatement).Value, "1:11", "3:2"},
+		{program.Statements[1], "4:1", "4:18"},
+		{program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression).Arguments[1], "4:8", "4:17"},
+	}
+
+	for i, tt := range tests {
+		if tt.node.Pos().String() != tt.expectedStart {
+			t.Errorf("tests[%d] - Pos() wrong. expected=%s, got=%s", i, tt.expectedStart, tt.node.Pos())
+		}
+
+		if tt.node.End().String() != tt.expectedEnd {
+			t.Errorf("tests[%d] - End() wrong. expected=%s, got=%s", i, tt.expectedEnd, tt.node.End())
+		}
+	}
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	input := "let x = 5;\nlet = 10;"
+
+	l := lexer.NewWithFilename("main.mk", input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors, got none")
+	}
+
+	expected := "main.mk:2:5: expected next token to be IDENT, but got = instead"
+	if errors[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		target   string
+		operator string
+		value    any
+	}{
+		{"x = 5;", "x", "=", 5},
+		{"x += 5;", "x", "+=", 5},
+		{"x -= y;", "x", "-=", "y"},
+		{"x *= 2;", "x", "*=", 2},
+		{"x /= 2;", "x", "/=", 2},
+		{"x %= 2;", "x", "%=", 2},
+	}
+
+	for _, tt := range tests {
+		program := constructTestProgram(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.AssignExpression. got=%T", stmt.Expression)
+		}
+
+		testIdentifier(t, exp.Target, tt.target)
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator not %q. got=%q", tt.operator, exp.Operator)
+		}
+		testLiteralExpression(t, exp.Value, tt.value)
+	}
+}
+
+func TestIndexAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[0] = 5;", "((a[0]) = 5)"},
+		{`h["k"] += 1;`, "((h[k]) += 1)"},
+		{"a[i][j] = a[j][i];", "(((a[i])[j]) = ((a[j])[i]))"},
+	}
+
+	for _, tt := range tests {
+		program := constructTestProgram(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.AssignExpression. got=%T", stmt.Expression)
+		}
+		if _, ok := exp.Target.(*ast.IndexExpression); !ok {
+			t.Fatalf("exp.Target not *ast.IndexExpression. got=%T", exp.Target)
+		}
+		if exp.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, exp.String())
+		}
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	l := lexer.New("1 + x = 5;")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("wrong number of errors. want=1, got=%d", len(errors))
+	}
+
+	if errors[0].Code != ErrInvalidAssignment {
+		t.Errorf("wrong error code. want=%q, got=%q", ErrInvalidAssignment, errors[0].Code)
+	}
+
+	expected := "1:7: cannot assign to (1 + x)"
+	if errors[0].Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	program := constructTestProgram(t, "while (x < 10) { x += 1; }")
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Errorf("body is not 1 statement. got=%d", len(stmt.Body.Statements))
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"for (let i = 0; i < 10; i += 1) { puts(i); }",
+			"for (let i = 0; (i < 10); (i += 1)) puts(i)",
+		},
+		{
+			"for (i = 0; i < 10; i = i + 1) { puts(i); }",
+			"for ((i = 0); (i < 10); (i = (i + 1))) puts(i)",
+		},
+		{
+			"for (;;) { break; }",
+			"for (; ; ) break;",
+		},
+		{
+			"for (x in [1, 2]) { continue; }",
+			"for (x in [1, 2]) continue;",
+		},
+	}
+
+	for _, tt := range tests {
+		program := constructTestProgram(t, tt.input)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestForInStatement(t *testing.T) {
+	program := constructTestProgram(t, "for (key in items) { puts(key); }")
+
+	stmt, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ForInStatement. got=%T", program.Statements[0])
+	}
+
+	testIdentifier(t, stmt.Variable, "key")
+	testIdentifier(t, stmt.Iterable, "items")
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Errorf("body is not 1 statement. got=%d", len(stmt.Body.Statements))
+	}
+}
+
+func TestLoopTrailingSemicolons(t *testing.T) {
+	tests := []string{
+		"while (i < 3) { i += 1 }; i",
+		"for (let i = 0; i < 3; i += 1) { puts(i) }; i",
+		"for (x in xs) { puts(x) }; x",
+	}
+
+	for _, input := range tests {
+		program := constructTestProgram(t, input)
+		if len(program.Statements) != 2 {
+			t.Fatalf("program.Statements does not contain 2 statements for %q. got=%d", input, len(program.Statements))
+		}
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	program := constructTestProgram(t, "throw x + 1;")
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ThrowStatement. got=%T", program.Statements[0])
+	}
+
+	testInfixExpression(t, stmt.Value, "x", "+", 1)
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"try { f(); } catch (e) { puts(e); }",
+			"try f() catch (e) puts(e)",
+		},
+		{
+			"try { f(); } finally { done(); }",
+			"try f() finally done()",
+		},
+		{
+			"try { f(); } catch (e) { 1 } finally { done(); }",
+			"try f() catch (e) 1 finally done()",
+		},
+		{
+			"let x = try { f() } catch { 0 };",
+			"let x = try f() catch 0;",
+		},
+	}
+
+	for _, tt := range tests {
+		program := constructTestProgram(t, tt.input)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestTryWithoutCatchOrFinally(t *testing.T) {
+	l := lexer.New("try { f(); } 1;")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors, got none")
+	}
+
+	if errors[0].Code != ErrUnexpectedToken {
+		t.Errorf("wrong error code. want=%q, got=%q", ErrUnexpectedToken, errors[0].Code)
+	}
+
+	expected := "1:14: expected next token to be CATCH or FINALLY, but got INT instead"
+	if errors[0].Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	program := constructTestProgram(t, `import "lib/strings.mk" as str;`)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ImportStatement. got=%T", program.Statements[0])
+	}
+
+	if stmt.Path.Value != "lib/strings.mk" {
+		t.Errorf("stmt.Path.Value not %q. got=%q", "lib/strings.mk", stmt.Path.Value)
+	}
+	testIdentifier(t, stmt.Name, "str")
+
+	if program.String() != `import "lib/strings.mk" as str;` {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestExportStatement(t *testing.T) {
+	program := constructTestProgram(t, "export upper, lower;")
+
+	stmt, ok := program.Statements[0].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExportStatement. got=%T", program.Statements[0])
+	}
+
+	if len(stmt.Names) != 2 {
+		t.Fatalf("stmt.Names does not contain 2 names. got=%d", len(stmt.Names))
+	}
+	testIdentifier(t, stmt.Names[0], "upper")
+	testIdentifier(t, stmt.Names[1], "lower")
+
+	if program.String() != "export upper, lower;" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestStructStatement(t *testing.T) {
+	program := constructTestProgram(t, "struct Point { x, y }")
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.StructStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.StructStatement. got=%T", program.Statements[0])
+	}
+
+	testIdentifier(t, stmt.Name, "Point")
+	if len(stmt.Fields) != 2 {
+		t.Fatalf("stmt.Fields does not contain 2 fields. got=%d", len(stmt.Fields))
+	}
+	testIdentifier(t, stmt.Fields[0], "x")
+	testIdentifier(t, stmt.Fields[1], "y")
+
+	if program.String() != "struct Point { x, y }" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestImplStatement(t *testing.T) {
+	program := constructTestProgram(t, "impl Point { fn dist(self) { self.x * self.x } fn scale(self, k) { self.x = self.x * k; } }")
+
+	stmt, ok := program.Statements[0].(*ast.ImplStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ImplStatement. got=%T", program.Statements[0])
+	}
+
+	testIdentifier(t, stmt.Name, "Point")
+	if len(stmt.Methods) != 2 {
+		t.Fatalf("stmt.Methods does not contain 2 methods. got=%d", len(stmt.Methods))
+	}
+	testIdentifier(t, stmt.Methods[0].Name, "dist")
+	testIdentifier(t, stmt.Methods[1].Name, "scale")
+
+	params := stmt.Methods[1].Function.Parameters
+	if len(params) != 2 {
+		t.Fatalf("scale does not have 2 parameters. got=%d", len(params))
+	}
+	testIdentifier(t, params[0], "self")
+	testIdentifier(t, params[1], "k")
+
+	expected := "impl Point { fn dist(self)((self.x) * (self.x)) fn scale(self, k)((self.x) = ((self.x) * k)) }"
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. want=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestMemberExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"p.x", "(p.x)"},
+		{"p.q.x", "((p.q).x)"},
+		{"p.dist()", "(p.dist)()"},
+		{"a[0].x", "((a[0]).x)"},
+		{"p.x + p.y * 2", "((p.x) + ((p.y) * 2))"},
+		{"-p.x", "(-(p.x))"},
+		{"p.x = 3", "((p.x) = 3)"},
+		{"p.x += 1", "((p.x) += 1)"},
+	}
+
+	for _, tt := range tests {
+		program := constructTestProgram(t, tt.input)
+
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong for %q. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, x }", "1:19: duplicate field x in struct Point"},
+		{"p.1", "1:3: expected next token to be IDENT, but got INT instead"},
+		{"impl Point { let x = 1; }", "1:14: expected next token to be FUNCTION, but got LET instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tt.input)
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestEnumStatement(t *testing.T) {
+	program := constructTestProgram(t, "enum Result { Ok(value), Err(reason, code), None }")
+
+	stmt, ok := program.Statements[0].(*ast.EnumStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.EnumStatement. got=%T", program.Statements[0])
+	}
+
+	testIdentifier(t, stmt.Name, "Result")
+	if len(stmt.Variants) != 3 {
+		t.Fatalf("stmt.Variants does not contain 3 variants. got=%d", len(stmt.Variants))
+	}
+
+	tests := []struct {
+		name   string
+		fields []string
+	}{
+		{"Ok", []string{"value"}},
+		{"Err", []string{"reason", "code"}},
+		{"None", []string{}},
+	}
+
+	for i, tt := range tests {
+		variant := stmt.Variants[i]
+		testIdentifier(t, variant.Name, tt.name)
+		if len(variant.Fields) != len(tt.fields) {
+			t.Fatalf("variant %s has wrong number of fields. want=%d, got=%d", tt.name, len(tt.fields), len(variant.Fields))
+		}
+		for j, field := range tt.fields {
+			testIdentifier(t, variant.Fields[j], field)
+		}
+	}
+
+	if program.String() != "enum Result { Ok(value), Err(reason, code), None }" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestVariantPatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (r) { Result.Ok(v) => v, Result.None => 0 }", "match (r) { Result.Ok(v) => v, Result.None => 0 }"},
+		{"match (r) { Result.Ok(_) => 1, Result.Err() => 2 }", "match (r) { Result.Ok(_) => 1, Result.Err => 2 }"},
+		{"match (r) { Option.Some(Option.Some(1)) => 1, [Option.None, x] => x }", "match (r) { Option.Some(Option.Some(1)) => 1, [Option.None, x] => x }"},
+		{`match (r) { {"k": Option.Some(v)} => v }`, `match (r) { {k: Option.Some(v)} => v }`},
+	}
+
+	for _, tt := range tests {
+		program := constructTestProgram(t, tt.input)
+
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestMatchCoverage(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected map[string][]string
+	}{
+		{"match (r) { Result.Ok(v) => v, Result.Err(_) => 0 }", map[string][]string{"Result": {"Ok", "Err"}}},
+		{"match (r) { Result.Ok(1) => 1, Result.Ok(v) if v > 1 => v, Result.Err(e) => 0 }", map[string][]string{"Result": {"Err"}}},
+		{"match (r) { Result.Ok(v) => v, Color.Red => 1 }", map[string][]string{"Result": {"Ok"}, "Color": {"Red"}}},
+		{"match (r) { Result.Ok(v) => v, x if x => 1, 1 => 2 }", map[string][]string{"Result": {"Ok"}}},
+		{"match (r) { Result.Ok(v) => v, _ => 0 }", nil},
+		{"match (r) { Result.Ok(v) => v, other => 0 }", nil},
+	}
+
+	for _, tt := range tests {
+		program := constructTestProgram(t, tt.input)
+		match := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+
+		coverage := match.Coverage()
+		if tt.expected == nil {
+			if coverage != nil {
+				t.Errorf("coverage of %q not nil. got=%v", tt.input, coverage)
+			}
+			continue
+		}
+
+		if len(coverage) != len(tt.expected) {
+			t.Fatalf("wrong number of enums for %q. want=%d, got=%d", tt.input, len(tt.expected), len(coverage))
+		}
+		for _, enum := range coverage {
+			if !slices.Equal(enum.Covered, tt.expected[enum.Enum.Value]) {
+				t.Errorf("wrong coverage of %s for %q. want=%v, got=%v", enum.Enum.Value, tt.input, tt.expected[enum.Enum.Value], enum.Covered)
+			}
+		}
+	}
+}
+
+func TestEnumErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"enum Result { Ok(value), Ok(reason) }", "1:26: duplicate variant Ok in enum Result"},
+		{"enum Pair { Of(a, a) }", "1:19: duplicate field a in variant Of"},
+		{"enum Result { Ok(1) }", "1:18: expected next token to be IDENT, but got INT instead"},
+		{"match (r) { Result.1 => 1 }", "1:20: expected next token to be IDENT, but got INT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tt.input)
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5;", "let x: int = 5;"},
+		{"let xs: [int] = [];", "let xs: [int] = [];"},
+		{`let h: {string: [float]} = {};`, "let h: {string: [float]} = {};"},
+		{"let f: fn(int, string) -> bool = g;", "let f: fn(int, string) -> bool = g;"},
+		{"let f: fn() = g;", "let f: fn() = g;"},
+		{"let p: Point = q;", "let p: Point = q;"},
+		{"fn(a: string, b: [int]) -> bool { }", "fn(a: string, b: [int]) -> bool "},
+		{"fn(b, a: int = 1) { a }", "fn(b, a: int = 1)a"},
+		{"fn(a: int, ...rest: [string]) { a }", "fn(a: int, ...rest: [string])a"},
+		{"fn(f: fn(int) -> int) -> fn(int) -> int { f }", "fn(f: fn(int) -> int) -> fn(int) -> int f"},
+		{"a - b", "(a - b)"},
+	}
+
+	for _, tt := range tests {
+		program := constructTestProgram(t, tt.input)
+
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestFunctionTypeAnnotations(t *testing.T) {
+	program := constructTestProgram(t, "fn(a: string, b, c: [int]) -> bool { true }")
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.FunctionLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(function.Types) != 3 {
+		t.Fatalf("function.Types does not contain 3 types. got=%d", len(function.Types))
+	}
+	if named, ok := function.Types[0].(*ast.NamedType); !ok || named.Name != "string" {
+		t.Errorf("function.Types[0] is not string. got=%v", function.Types[0])
+	}
+	if function.Types[1] != nil {
+		t.Errorf("function.Types[1] is not nil. got=%v", function.Types[1])
+	}
+	array, ok := function.Types[2].(*ast.ArrayType)
+	if !ok {
+		t.Fatalf("function.Types[2] is not *ast.ArrayType. got=%T", function.Types[2])
+	}
+	if array.End().Column != 26 {
+		t.Errorf("array type ends at wrong column. want=26, got=%d", array.End().Column)
+	}
+	if function.ReturnType == nil || function.ReturnType.String() != "bool" {
+		t.Errorf("function.ReturnType is not bool. got=%v", function.ReturnType)
+	}
+}
+
+func TestTypeAnnotationErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		code     ErrorCode
+	}{
+		{"let x: 5 = 5;", "1:8: expected a type, got INT", ErrInvalidType},
+		{"let x: [int = 5;", "1:13: expected next token to be ], but got = instead", ErrUnexpectedToken},
+		{"let h: {string} = {};", "1:15: expected next token to be :, but got } instead", ErrUnexpectedToken},
+		{"fn(a: ) { a }", "1:7: expected a type, got )", ErrInvalidType},
+		{"fn(a) -> 5 { a }", "1:10: expected a type, got INT", ErrInvalidType},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tt.input)
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, errors[0].Error())
+		}
+		if errors[0].Code != tt.code {
+			t.Errorf("wrong error code for %q. want=%s, got=%s", tt.input, tt.code, errors[0].Code)
+		}
+	}
+}
+
+func TestGeneratorFunction(t *testing.T) {
+	program := constructTestProgram(t, "fn*(n) { yield n; yield n * 2; }")
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.FunctionLiteral. got=%T", stmt.Expression)
+	}
+	if !function.Generator {
+		t.Fatalf("function.Generator is false")
+	}
+
+	if len(function.Body.Statements) != 2 {
+		t.Fatalf("function.Body.Statements has not 2 statements. got=%d", len(function.Body.Statements))
+	}
+	yield, ok := function.Body.Statements[0].(*ast.YieldStatement)
+	if !ok {
+		t.Fatalf("function.Body.Statements[0] is not *ast.YieldStatement. got=%T", function.Body.Statements[0])
+	}
+	testIdentifier(t, yield.Value, "n")
+
+	if _, ok := function.Body.Statements[1].(*ast.YieldStatement); !ok {
+		t.Fatalf("function.Body.Statements[1] is not *ast.YieldStatement. got=%T", function.Body.Statements[1])
+	}
+
+	if program.String() != "fn*(n)yield n;yield (n * 2);" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestYieldErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		code     ErrorCode
+	}{
+		{"yield 1;", "1:1: yield outside of a generator function", ErrOutsideGenerator},
+		{"fn() { yield 1; }", "1:8: yield outside of a generator function", ErrOutsideGenerator},
+		{"fn*() { fn() { yield 1; } }", "1:16: yield outside of a generator function", ErrOutsideGenerator},
+		{"fn*() { macro() { yield 1; } }", "1:19: yield outside of a generator function", ErrOutsideGenerator},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tt.input)
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, errors[0].Error())
+		}
+		if errors[0].Code != tt.code {
+			t.Errorf("wrong error code for %q. want=%s, got=%s", tt.input, tt.code, errors[0].Code)
+		}
+	}
+}
+
+func TestAddedOperators(t *testing.T) {
+	operators := NewOperators()
+	for _, err := range []error{
+		operators.Infix("|>", ASSIGN+1, LeftAssociative, "pipe"),
+		operators.Infix("..", SUM-1, LeftAssociative, "range"),
+		operators.Infix("**", PRODUCT+1, RightAssociative, "pow"),
+		operators.Prefix("~", PREFIX, "neg"),
+	} {
+		if err != nil {
+			t.Fatalf("cannot add operator: %s", err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a |> f |> g", "pipe(pipe(a, f), g)"},
+		{"2 ** 3 ** 2", "pow(2, pow(3, 2))"},
+		{"2 * 3 ** 2", "(2 * pow(3, 2))"},
+		{"-2 ** 2", "pow((-2), 2)"},
+		{"1 .. n + 1", "range(1, (n + 1))"},
+		{"1 + 2 .. 5 |> f", "pipe(range((1 + 2), 5), f)"},
+		{"~x ** 2", "pow(neg(x), 2)"},
+		{"[...xs, ~y]", "[...xs, neg(y)]"},
+		{"a || b", "(a || b)"},
+	}
+
+	for _, tt := range tests {
+		p := NewWithOperators(lexer.New(tt.input), operators)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %q. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	p := NewWithOperators(lexer.New("x |> f"), operators)
+	stmt := p.ParseProgram().Statements[0].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.CallExpression. got=%T", stmt.Expression)
+	}
+	testIdentifier(t, call.Function, "pipe")
+	if call.Function.Pos().Column != 3 {
+		t.Errorf("wrong position of the function. want=3, got=%d", call.Function.Pos().Column)
+	}
+	if call.Function.(*ast.Identifier).Builtin {
+		t.Errorf("function of an operator mapped to a program function marked as builtin")
+	}
+
+	// An operator mapped to a built-in function calls it even where the name is bound.
+	if err := operators.Infix("+++", SUM, LeftAssociative, "push"); err != nil {
+		t.Fatalf("cannot add operator: %s", err)
+	}
+	p = NewWithOperators(lexer.New("xs +++ 1"), operators)
+	stmt = p.ParseProgram().Statements[0].(*ast.ExpressionStatement)
+	call = stmt.Expression.(*ast.CallExpression)
+	if !call.Function.(*ast.Identifier).Builtin {
+		t.Errorf("function of an operator mapped to a builtin not marked as builtin")
+	}
+
+	p = New(lexer.New("x |> f"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected parse errors without the added operators")
+	}
+}
+
+func TestAddOperatorErrors(t *testing.T) {
+	operators := NewOperators()
+	if err := operators.Infix("|>", SUM, LeftAssociative, "pipe"); err != nil {
+		t.Fatalf("cannot add operator: %s", err)
+	}
+
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{operators.Infix("|>", SUM, LeftAssociative, "other"), "operator |> is already defined"},
+		{operators.Infix("+", SUM, LeftAssociative, "add"), "operator + is already defined"},
+		{operators.Infix("...", SUM, LeftAssociative, "spread"), "operator ... is already defined"},
+		{operators.Prefix("!", PREFIX, "not"), "operator ! is already defined"},
+		{operators.Infix("in", SUM, LeftAssociative, "contains"), `invalid operator "in": must be made of the characters !#$%&*+-./:<=>?@^|~`},
+		{operators.Infix("", SUM, LeftAssociative, "empty"), `invalid operator "": must be made of the characters !#$%&*+-./:<=>?@^|~`},
+		{operators.Infix("</", SUM, LeftAssociative, "tag"), ""},
+		{operators.Infix("//", SUM, LeftAssociative, "div"), `invalid operator "//": must not contain the start of a comment`},
+		{operators.Infix("<>", LOWEST, LeftAssociative, "ne"), "precedence of operator <> must be higher than LOWEST, got 10"},
+		{operators.Prefix("$", PREFIX, ""), "no function for operator $"},
+	}
+
+	for i, tt := range tests {
+		got := ""
+		if tt.err != nil {
+			got = tt.err.Error()
+		}
+		if got != tt.expected {
+			t.Errorf("tests[%d] - wrong error. want=%q, got=%q", i, tt.expected, got)
+		}
+	}
+}
+
+func TestModuleStatementsOutsideTopLevel(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`if (true) { import "a.mk" as a; }`, "1:13: import is only allowed at the top level"},
+		{"let f = fn() { export f; };", "1:16: export is only allowed at the top level"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("wrong number of errors for %q. want=1, got=%d", tt.input, len(errors))
+		}
+
+		if errors[0].Code != ErrNotTopLevel {
+			t.Errorf("wrong error code. want=%q, got=%q", ErrNotTopLevel, errors[0].Code)
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break outside of a loop"},
+		{"continue;", "1:1: continue outside of a loop"},
+		{"if (true) { break; }", "1:13: break outside of a loop"},
+		{"while (true) { fn() { continue; } }", "1:23: continue outside of a loop"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("wrong number of errors for %q. want=1, got=%d", tt.input, len(errors))
+		}
+
+		if errors[0].Code != ErrOutsideLoop {
+			t.Errorf("wrong error code. want=%q, got=%q", ErrOutsideLoop, errors[0].Code)
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestParseErrorDetails(t *testing.T) {
+	input := "let x 5;"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("wrong number of errors. want=1, got=%d", len(errors))
+	}
+
+	err := errors[0]
+	if err.Code != ErrUnexpectedToken {
+		t.Errorf("err.Code wrong. want=%q, got=%q", ErrUnexpectedToken, err.Code)
+	}
+	if err.Message != "expected next token to be =, but got INT instead" {
+		t.Errorf("err.Message wrong. got=%q", err.Message)
+	}
+	if err.Pos.Column != 7 || err.End.Column != 8 {
+		t.Errorf("err span wrong. want=7-8, got=%d-%d", err.Pos.Column, err.End.Column)
+	}
+	if len(err.Expected) != 1 || err.Expected[0] != token.ASSIGN {
+		t.Errorf("err.Expected wrong. got=%v", err.Expected)
+	}
+	if err.Found != token.INT {
+		t.Errorf("err.Found wrong. want=%q, got=%q", token.INT, err.Found)
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements int
+	}{
+		{
+			"let = 1; let y = 2; let 3; y;",
+			[]string{
+				"1:5: expected next token to be IDENT, but got = instead",
+				"1:25: expected next token to be IDENT, but got INT instead",
+			},
+			2,
+		},
+		{
+			"let x = (1 + ; let y = 2;",
+			[]string{"1:14: no prefix parse function for ; found"},
+			1,
+		},
+		{
+			"let f = fn() { let = 1; 2 }; let g = fn() { if (x { 1 } }; f;",
+			[]string{
+				"1:20: expected next token to be IDENT, but got = instead",
+				"1:51: expected next token to be ), but got { instead",
+			},
+			3,
+		},
+		{
+			"1 }; 2",
+			[]string{"1:3: no prefix parse function for } found"},
+			2,
+		},
+		{
+			"let h = {1 2}; h;",
+			[]string{"1:12: expected next token to be :, but got INT instead"},
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("input %q - wrong number of errors. want=%d, got=%d", tt.input, len(tt.expectedErrors), len(errors))
+			for _, err := range errors {
+				t.Errorf("parser error: %q", err.Error())
+			}
+			continue
+		}
+
+		for i, expected := range tt.expectedErrors {
+			if errors[i].Error() != expected {
+				t.Errorf("input %q - wrong error %d. want=%q, got=%q", tt.input, i, expected, errors[i].Error())
+			}
+		}
+
+		if len(program.Statements) != tt.expectedStatements {
+			t.Errorf("input %q - wrong number of statements. want=%d, got=%d", tt.input, tt.expectedStatements, len(program.Statements))
+		}
+	}
+}
+
+func TestLexerErrorsAreReported(t *testing.T) {
+	input := `let s = "abc;`
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors, got none")
+	}
+
+	expected := "1:9: unterminated string literal"
+	if errors[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := lexer.New("let x = 1; /* open")
+	p := New(l)
+	program := p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("wrong number of errors. want=1, got=%d (%v)", len(errors), errors)
+	}
+	if errors[0].Code != ErrLexical {
+		t.Errorf("wrong error code. want=%q, got=%q", ErrLexical, errors[0].Code)
+	}
+	expected := "1:12: unterminated block comment"
+	if errors[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0].Error())
+	}
+	if len(program.Statements) != 1 {
+		t.Errorf("wrong number of statements. want=1, got=%d", len(program.Statements))
+	}
+}
+
+func TestProgramComments(t *testing.T) {
+	input := `// Adds two numbers.
+let add = fn(a, b) {
+	a + b; // the sum
+};
+/* done */`
+
+	program := constructTestProgram(t, input)
+
+	expected := []string{"// Adds two numbers.", "// the sum", "/* done */"}
+	if len(program.Comments) != len(expected) {
+		t.Fatalf("program.Comments has wrong length. want=%d, got=%d", len(expected), len(program.Comments))
+	}
+
+	for i, text := range expected {
+		if program.Comments[i].Text != text {
+			t.Errorf("program.Comments[%d] wrong. want=%q, got=%q", i, text, program.Comments[i].Text)
+		}
+	}
+
+	let := program.Statements[0].(*ast.LetStatement)
+	if len(let.Token.Leading) != 1 || let.Token.Leading[0].Text != "// Adds two numbers." {
+		t.Errorf("let statement lost its leading comment. got=%+v", let.Token.Leading)
+	}
+}
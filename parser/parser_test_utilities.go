@@ -138,7 +138,7 @@ func checkParserErrors(t *testing.T, p *Parser) {
 
 	t.Errorf("parser had %d errors", len(errors))
 	for _, msg := range errors {
-		t.Errorf("parser error: %q", msg)
+		t.Errorf("parser error: %q", msg.Error())
 	}
 
 	t.FailNow()
@@ -4,13 +4,18 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"monkey/ast"
 	"monkey/compiler"
+	"monkey/evaluator"
 	"monkey/object"
 
-	// "monkey/evaluator"
 	"monkey/lexer"
+	"monkey/module"
 	"monkey/parser"
+	"monkey/token"
+	"monkey/typecheck"
 	"monkey/vm"
+	"strings"
 )
 
 const PROMPT = ">> "
@@ -23,11 +28,15 @@ const PROMPT = ">> "
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	constants := []object.Object{}
-	globals := make([]object.Object, vm.GlobalsSize)
+	globals := vm.NewGlobals()
 	symbolTable := compiler.NewSymbolTable()
 	for i, v := range object.Builtins {
 		symbolTable.DefineBuiltin(i, v.Name)
 	}
+	macroEnv := object.NewEnvironment()
+	loader := module.NewLoaderFromEnv()
+	loader.Expand = evaluator.ExpandModuleMacros
+	checker := typecheck.New()
 
 	for {
 		fmt.Fprintf(out, PROMPT)
@@ -43,25 +52,42 @@ func Start(in io.Reader, out io.Writer) {
 		program := p.ParseProgram()
 
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			printParserErrors(out, line, p.Errors())
+			continue
+		}
+
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := evaluator.ExpandMacros(program, macroEnv)
+		if err != nil {
+			fmt.Fprintf(out, "Whoops! Macro expansion failed:\n%s\n", err)
+			continue
+		}
+
+		if errors := checker.Check(expanded.(*ast.Program)); len(errors) != 0 {
+			printTypeErrors(out, line, errors)
 			continue
 		}
 
 		comp := compiler.NewWithState(symbolTable, constants)
-		err := comp.Compile(program)
+		comp.SetLoader(loader, "")
+		err = comp.Compile(expanded)
 		if err != nil {
 			fmt.Fprintf(out, "Whoops! Compilation failed:\n%s\n", err)
 			continue
 		}
 
-		machine := vm.NewWithGlobalsStore(comp.Bytecode(), globals)
+		machine := vm.NewWithGlobals(comp.Bytecode(), globals)
 		err = machine.Run()
 		if err != nil {
 			fmt.Fprintf(out, "Whoops! Executing bytecode failed:\n%s\n", err)
 			continue
 		}
 
+		// A line that only defines macros leaves nothing to print.
 		lastPopped := machine.LastPoppedStackElem()
+		if lastPopped == nil {
+			continue
+		}
 		io.WriteString(out, lastPopped.Inspect())
 		io.WriteString(out, "\n")
 	}
@@ -80,16 +106,69 @@ const MONKEY_FACE = `            __,__
            '-----'
 `
 
-// printParserErrors prints the errors out to the writer for the user.
+// printParserErrors prints the errors out to the writer for the user, each followed
+// by the offending source line with a caret under the offending column.
 //
 // Parameters:
 //   - out: The output writer.
+//   - source: The source code that was parsed.
 //   - errors: The errors to print to the output.
-func printParserErrors(out io.Writer, errors []string) {
+func printParserErrors(out io.Writer, source string, errors []*parser.ParseError) {
 	io.WriteString(out, MONKEY_FACE)
 	io.WriteString(out, "Whoops! We ran into some monkey business here!\n")
 	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, err := range errors {
+		io.WriteString(out, "\t"+err.Error()+"\n")
+		io.WriteString(out, sourceExcerpt(source, err.Pos, err.End))
+	}
+}
+
+// printTypeErrors prints the type errors out to the writer for the user, each followed
+// by the offending source line with carets under the offending code.
+//
+// Parameters:
+//   - out: The output writer.
+//   - source: The source code that was checked.
+//   - errors: The errors to print to the output.
+func printTypeErrors(out io.Writer, source string, errors []*typecheck.Error) {
+	io.WriteString(out, "Whoops! Type checking failed:\n")
+	for _, err := range errors {
+		io.WriteString(out, "\t"+err.Error()+"\n")
+		io.WriteString(out, sourceExcerpt(source, err.Pos, err.End))
+	}
+}
+
+// sourceExcerpt renders the source line of a span with carets underneath the span.
+//
+// Parameters:
+//   - source: The source code the positions refer to.
+//   - pos: The position of the first character of the span.
+//   - end: The position immediately after the span.
+//
+// Returns:
+//   - string: The excerpt, or an empty string when the position is not in the source.
+func sourceExcerpt(source string, pos, end token.Position) string {
+	lines := strings.Split(source, "\n")
+	if !pos.IsValid() || pos.Line > len(lines) {
+		return ""
+	}
+	line := []rune(lines[pos.Line-1])
+
+	// Keep tabs so the caret lines up with the source however wide the terminal draws them.
+	var marker strings.Builder
+	for i := 0; i < pos.Column-1 && i < len(line); i++ {
+		if line[i] == '\t' {
+			marker.WriteRune('\t')
+		} else {
+			marker.WriteRune(' ')
+		}
 	}
+
+	width := 1
+	if end.Line == pos.Line && end.Column > pos.Column {
+		width = end.Column - pos.Column
+	}
+	marker.WriteString(strings.Repeat("^", width))
+
+	return "\t" + string(line) + "\n\t" + marker.String() + "\n"
 }
@@ -1,12 +1,64 @@
 package token
 
+import "fmt"
+
 // TokenType represents the type of a token.
 type TokenType string
 
 // Token represents a lexer token in the Monkey language.
 type Token struct {
-	Type    TokenType // The type of the token.
-	Literal string    // The literal value of the token.
+	Type     TokenType // The type of the token.
+	Literal  string    // The literal value of the token.
+	Pos      Position  // The position of the first character of the token.
+	End      Position  // The position immediately after the last character of the token.
+	Leading  []Comment // The comments that appear between the previous token and this one.
+	Trailing []Comment // The comments that follow this token on the same line.
+}
+
+// Comment represents a line (// ...) or block (/* ... */) comment in the source code.
+// Comments are not tokens; they are attached to the nearest token as trivia.
+type Comment struct {
+	Text string   // The text of the comment, including the comment markers.
+	Pos  Position // The position of the first character of the comment.
+	End  Position // The position immediately after the last character of the comment.
+}
+
+// IsBlock reports whether the comment is a block comment.
+//
+// Returns:
+//   - bool: True when the comment starts with /*, otherwise false.
+func (c Comment) IsBlock() bool {
+	return len(c.Text) >= 2 && c.Text[:2] == "/*"
+}
+
+// Position represents a location in the source code.
+type Position struct {
+	Filename string // The name of the source file, empty when the source did not come from a file.
+	Offset   int    // The byte offset into the source, starting at 0.
+	Line     int    // The line number, starting at 1.
+	Column   int    // The column number, starting at 1.
+}
+
+// IsValid reports whether the position has been set by the lexer.
+//
+// Returns:
+//   - bool: True when the position has a line number, otherwise false.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns a string representation of the position in the form file:line:column.
+// The file name is omitted when it is empty, and an invalid position is represented as "-".
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
 }
 
 const (
@@ -17,6 +69,7 @@ const (
 	// Identifiers and literals
 	IDENT = "IDENT" // add, x, y, z
 	INT   = "INT"
+	FLOAT = "FLOAT"
 
 	// Operators
 	ASSIGN   = "="
@@ -25,16 +78,31 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 
-	LT = "<"
-	GT = ">"
-	EQ = "=="
-	NE = "!="
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+	PERCENT_ASSIGN  = "%="
+
+	LT  = "<"
+	GT  = ">"
+	LE  = "<="
+	GE  = ">="
+	EQ  = "=="
+	NE  = "!="
+	AND = "&&"
+	OR  = "||"
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	FAT_ARROW = "=>"
+	ARROW     = "->"
+	ELLIPSIS  = "..."
+	DOT       = "."
 
 	// Grouping
 	LPAREN   = "("
@@ -52,18 +120,60 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	MATCH    = "MATCH"
+	MACRO    = "MACRO"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	AS       = "AS"
+	STRUCT   = "STRUCT"
+	IMPL     = "IMPL"
+	ENUM     = "ENUM"
+	YIELD    = "YIELD"
 	STRING   = "STRING"
+
+	// Interpolated strings, e.g. "a ${b} c ${d} e" is
+	// TEMPLATE_HEAD("a ") b TEMPLATE_MIDDLE(" c ") d TEMPLATE_TAIL(" e")
+	TEMPLATE_HEAD   = "TEMPLATE_HEAD"
+	TEMPLATE_MIDDLE = "TEMPLATE_MIDDLE"
+	TEMPLATE_TAIL   = "TEMPLATE_TAIL"
 )
 
 // keywords is the map of reserved keywords to their token types.
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"match":    MATCH,
+	"macro":    MACRO,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
+	"import":   IMPORT,
+	"export":   EXPORT,
+	"as":       AS,
+	"struct":   STRUCT,
+	"impl":     IMPL,
+	"enum":     ENUM,
+	"yield":    YIELD,
 }
 
 // LookupIdent returns the token type for the given identifier.
@@ -0,0 +1,88 @@
+package typecheck
+
+// builtins holds the signature of each function in object.Builtins, by name. A signature
+// gives the function type for a call with arguments of the given types, so that e.g. first
+// returns an int when it is called with an [int].
+var builtins = map[string]func(args []Type) *Function{
+	"len": func(args []Type) *Function {
+		return &Function{Params: []Type{&Union{Types: []Type{String, &Array{Element: Any}}}}, Required: 1, Return: Int}
+	},
+	"puts": func(args []Type) *Function {
+		return &Function{Rest: Any, Return: Null}
+	},
+	"first": func(args []Type) *Function {
+		element := elementType(args)
+		return &Function{Params: []Type{&Array{Element: element}}, Required: 1, Return: element}
+	},
+	"last": func(args []Type) *Function {
+		element := elementType(args)
+		return &Function{Params: []Type{&Array{Element: element}}, Required: 1, Return: element}
+	},
+	"rest": func(args []Type) *Function {
+		array := &Array{Element: elementType(args)}
+		return &Function{Params: []Type{array}, Required: 1, Return: array}
+	},
+	"push": func(args []Type) *Function {
+		element := elementType(args)
+		if len(args) > 1 {
+			element = join(element, args[1])
+		}
+		return &Function{Params: []Type{&Array{Element: Any}, Any}, Required: 2, Return: &Array{Element: element}}
+	},
+	"append!": func(args []Type) *Function {
+		array := &Array{Element: elementType(args)}
+		return &Function{Params: []Type{array}, Required: 1, Rest: Any, Return: array}
+	},
+	"delete!": func(args []Type) *Function {
+		hash := &Hash{Key: Any, Value: Any}
+		if len(args) > 0 {
+			if h, ok := args[0].(*Hash); ok {
+				hash = h
+			}
+		}
+		return &Function{Params: []Type{hash, hash.Key}, Required: 2, Return: hash.Value}
+	},
+	"error": func(args []Type) *Function {
+		return &Function{Params: []Type{String, Any}, Required: 1, Return: Any}
+	},
+	"next": func(args []Type) *Function {
+		return &Function{Params: []Type{Any, Any}, Required: 1, Return: Any}
+	},
+	"array": func(args []Type) *Function {
+		return &Function{Params: []Type{Any}, Required: 1, Return: &Array{Element: Any}}
+	},
+	"spawn": func(args []Type) *Function {
+		return &Function{Params: []Type{Any}, Required: 1, Rest: Any, Return: Any}
+	},
+	"chan": func(args []Type) *Function {
+		return &Function{Params: []Type{Int}, Return: Any}
+	},
+	"send": func(args []Type) *Function {
+		return &Function{Params: []Type{Any, Any}, Required: 2, Return: Null}
+	},
+	"recv": func(args []Type) *Function {
+		return &Function{Params: []Type{Any, Any}, Required: 1, Return: Any}
+	},
+	"close": func(args []Type) *Function {
+		return &Function{Params: []Type{Any}, Required: 1, Return: Null}
+	},
+	"select": func(args []Type) *Function {
+		return &Function{Params: []Type{&Array{Element: Any}, Any}, Required: 1, Return: Any}
+	},
+}
+
+// elementType gives the element type of the array passed as the first argument of a call.
+//
+// Parameters:
+//   - args: The types of the arguments.
+//
+// Returns:
+//   - Type: The element type, or any if the first argument is not known to be an array.
+func elementType(args []Type) Type {
+	if len(args) > 0 {
+		if array, ok := args[0].(*Array); ok {
+			return array.Element
+		}
+	}
+	return Any
+}
@@ -0,0 +1,978 @@
+// Package typecheck checks the optional type annotations of a program before it is run.
+// Where a binding is not annotated, its type is inferred from its value; values the checker
+// cannot follow, such as the members of structs and modules, have the type any, which is
+// compatible with every type.
+//
+// The name of a struct or an enum can be used as a type anywhere in the scope declaring it,
+// but the fields of a struct cannot be annotated. The annotation of a rest parameter is the
+// type of each argument it collects; a function type annotation cannot describe a rest
+// parameter. A function annotated with a result type other than null or any must end with
+// a value or leave its body with return or throw, except that a body ending with a loop is
+// trusted to return from inside it. A match expression must cover every variant of the
+// enums it matches against, unless an arm matches any value.
+package typecheck
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Error is a type error, reported with the position of the offending code.
+type Error struct {
+	Message string         // A description of the problem.
+	Pos     token.Position // The position of the first character of the offending code.
+	End     token.Position // The position immediately after the offending code.
+}
+
+// Error returns the error message prefixed with its position.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+// binding is a name known to the checker.
+type binding struct {
+	typ       Type     // The type of the values the name can hold.
+	annotated bool     // Whether the type was annotated, so assignments must keep to it.
+	variants  []string // The variants of the enum declared under the name, or nil if it may hold another value.
+}
+
+// scope holds the names and types defined in a function, a loop, a match arm or the
+// program. The other blocks share the scope around them, as they do at runtime.
+type scope struct {
+	names  map[string]*binding
+	types  map[string]Type
+	outer  *scope
+	blocks int // The number of blocks being checked in the scope, which may not run.
+}
+
+// function collects what the checker learns about the function whose body it is in.
+type function struct {
+	declared  Type   // The annotated result type, or for a generator the annotated type of the yielded values, or nil.
+	generator bool   // Whether the function is a generator, whose returned values are dropped.
+	results   []Type // The types of the values returned by return statements.
+}
+
+// Checker checks programs one after the other, keeping the names defined at the top level
+// of each for the next, like the lines entered into the REPL.
+type Checker struct {
+	global    *scope
+	scope     *scope
+	functions []*function
+	errors    []*Error
+}
+
+// New creates a new checker that knows the built-in functions.
+//
+// Returns:
+//   - *Checker: The new checker.
+func New() *Checker {
+	global := &scope{names: map[string]*binding{}, types: map[string]Type{}}
+	for _, def := range object.Builtins {
+		if signature, ok := builtins[def.Name]; ok {
+			global.names[def.Name] = &binding{typ: &Builtin{Name: def.Name, Signature: signature}}
+		}
+	}
+	return &Checker{global: global, scope: global}
+}
+
+// Check checks a program with a new checker.
+//
+// Parameters:
+//   - program: The program to check.
+//
+// Returns:
+//   - []*Error: The type errors found, in the order they were found.
+func Check(program *ast.Program) []*Error {
+	return New().Check(program)
+}
+
+// Check checks a program. The names the program defines at the top level stay known to
+// the checker.
+//
+// Parameters:
+//   - program: The program to check.
+//
+// Returns:
+//   - []*Error: The type errors found, in the order they were found.
+func (c *Checker) Check(program *ast.Program) []*Error {
+	c.errors = nil
+	c.scope = c.global
+	c.functions = nil
+	c.checkStatements(program.Statements)
+	return c.errors
+}
+
+// errorf records a type error at a node.
+//
+// Parameters:
+//   - node: The offending node.
+//   - format: The format of the message.
+//   - args: The values for the format.
+func (c *Checker) errorf(node ast.Node, format string, args ...any) {
+	c.errors = append(c.errors, &Error{Message: fmt.Sprintf(format, args...), Pos: node.Pos(), End: node.End()})
+}
+
+// pushScope starts a new scope inside the current one.
+func (c *Checker) pushScope() {
+	c.scope = &scope{names: map[string]*binding{}, types: map[string]Type{}, outer: c.scope}
+}
+
+// popScope ends the current scope.
+func (c *Checker) popScope() {
+	c.scope = c.scope.outer
+}
+
+// define binds a name in the current scope. A name defined again inside a block may keep
+// the value it had if the block does not run, so it is given a type covering both.
+//
+// Parameters:
+//   - name: The name.
+//   - typ: The type of its values.
+//   - annotated: Whether the type was annotated.
+func (c *Checker) define(name string, typ Type, annotated bool) {
+	if b, ok := c.scope.names[name]; ok && c.scope.blocks > 0 {
+		joined := join(b.typ, typ)
+		annotated = annotated && joined.String() == typ.String()
+		typ = joined
+	}
+	c.scope.names[name] = &binding{typ: typ, annotated: annotated}
+}
+
+// lookup finds the binding of a name in the current scope or the scopes around it.
+//
+// Parameters:
+//   - name: The name.
+//
+// Returns:
+//   - *binding: The binding, or nil if the name is not known.
+func (c *Checker) lookup(name string) *binding {
+	for s := c.scope; s != nil; s = s.outer {
+		if b, ok := s.names[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+// lookupType finds a struct or enum type by name.
+//
+// Parameters:
+//   - name: The name of the type.
+//
+// Returns:
+//   - Type: The type, or nil if no such type is known.
+func (c *Checker) lookupType(name string) Type {
+	for s := c.scope; s != nil; s = s.outer {
+		if t, ok := s.types[name]; ok {
+			return t
+		}
+	}
+	return nil
+}
+
+// resolveType turns a type annotation into a type.
+//
+// Parameters:
+//   - annotation: The type annotation.
+//
+// Returns:
+//   - Type: The annotated type, or any if it names an unknown type.
+func (c *Checker) resolveType(annotation ast.Type) Type {
+	switch annotation := annotation.(type) {
+	case *ast.NamedType:
+		if t, ok := basicTypes[annotation.Name]; ok {
+			return t
+		}
+		if t := c.lookupType(annotation.Name); t != nil {
+			return t
+		}
+		c.errorf(annotation, "unknown type %s", annotation.Name)
+	case *ast.ArrayType:
+		return &Array{Element: c.resolveType(annotation.Element)}
+	case *ast.HashType:
+		return &Hash{Key: c.resolveType(annotation.Key), Value: c.resolveType(annotation.Value)}
+	case *ast.FunctionType:
+		fn := &Function{Required: len(annotation.Parameters), Return: Any}
+		for _, param := range annotation.Parameters {
+			fn.Params = append(fn.Params, c.resolveType(param))
+		}
+		if annotation.Return != nil {
+			fn.Return = c.resolveType(annotation.Return)
+		}
+		return fn
+	}
+	return Any
+}
+
+// checkStatement checks a statement.
+//
+// Parameters:
+//   - statement: The statement.
+//
+// Returns:
+//   - Type: The type of the value of an expression statement, never for a statement that
+//     leaves the enclosing block early, and null for any other statement.
+func (c *Checker) checkStatement(statement ast.Statement) Type {
+	switch statement := statement.(type) {
+	case *ast.ExpressionStatement:
+		if statement.Expression == nil {
+			return Null
+		}
+		return c.checkExpression(statement.Expression)
+	case *ast.LetStatement:
+		c.checkLetStatement(statement)
+	case *ast.ReturnStatement:
+		c.checkReturnStatement(statement)
+		return never
+	case *ast.ThrowStatement:
+		c.checkExpression(statement.Value)
+		return never
+	case *ast.YieldStatement:
+		c.checkYieldStatement(statement)
+	case *ast.BreakStatement, *ast.ContinueStatement:
+		return never
+	case *ast.BlockStatement:
+		return c.checkBlock(statement)
+	case *ast.WhileStatement:
+		c.checkExpression(statement.Condition)
+		c.checkBlock(statement.Body)
+	case *ast.ForStatement:
+		c.pushScope()
+		if statement.Init != nil {
+			c.checkStatement(statement.Init)
+		}
+		if statement.Condition != nil {
+			c.checkExpression(statement.Condition)
+		}
+		if statement.Update != nil {
+			c.checkExpression(statement.Update)
+		}
+		c.checkBlock(statement.Body)
+		c.popScope()
+	case *ast.ForInStatement:
+		iterable := c.checkExpression(statement.Iterable)
+		element := Type(Any)
+		switch iterable := iterable.(type) {
+		case *Array:
+			element = iterable.Element
+		case *Basic:
+			if iterable == String {
+				element = String
+			}
+		}
+		c.pushScope()
+		c.define(statement.Variable.Value, element, false)
+		c.checkBlock(statement.Body)
+		c.popScope()
+	case *ast.ImportStatement:
+		c.define(statement.Name.Value, Any, false)
+	case *ast.StructStatement:
+		constructor := &Function{Required: len(statement.Fields), Return: &Named{Name: statement.Name.Value}}
+		for range statement.Fields {
+			constructor.Params = append(constructor.Params, Any)
+		}
+		c.scope.types[statement.Name.Value] = constructor.Return
+		c.define(statement.Name.Value, constructor, false)
+	case *ast.ImplStatement:
+		for _, method := range statement.Methods {
+			c.checkExpression(method.Function)
+		}
+	case *ast.EnumStatement:
+		_, redefined := c.scope.names[statement.Name.Value]
+		c.scope.types[statement.Name.Value] = &Named{Name: statement.Name.Value}
+		c.define(statement.Name.Value, Any, false)
+		// A block that does not run leaves the name with the value it had before.
+		if !redefined || c.scope.blocks == 0 {
+			variants := []string{}
+			for _, variant := range statement.Variants {
+				variants = append(variants, variant.Name.Value)
+			}
+			c.scope.names[statement.Name.Value].variants = variants
+		}
+	}
+	return Null
+}
+
+// checkBlock checks the statements of a block in the current scope, which the block
+// shares at runtime.
+//
+// Parameters:
+//   - block: The block.
+//
+// Returns:
+//   - Type: The type of the value of the block, which is the value of its last statement.
+func (c *Checker) checkBlock(block *ast.BlockStatement) Type {
+	c.scope.blocks++
+	defer func() { c.scope.blocks-- }()
+	return c.checkStatements(block.Statements)
+}
+
+// checkStatements checks a list of statements in the current scope. The structs and enums
+// the statements declare are known as types from the start, so an annotation can name one
+// declared further down.
+//
+// Parameters:
+//   - statements: The statements.
+//
+// Returns:
+//   - Type: The type of the value of the last statement, or null if there are none.
+func (c *Checker) checkStatements(statements []ast.Statement) Type {
+	for _, statement := range statements {
+		switch statement := statement.(type) {
+		case *ast.StructStatement:
+			c.scope.types[statement.Name.Value] = &Named{Name: statement.Name.Value}
+		case *ast.EnumStatement:
+			c.scope.types[statement.Name.Value] = &Named{Name: statement.Name.Value}
+		}
+	}
+
+	result := Type(Null)
+	for _, statement := range statements {
+		result = c.checkStatement(statement)
+	}
+	return result
+}
+
+// checkLetStatement checks a let statement and binds its names. A function bound by a
+// let statement can call itself, so its name is bound before its body is checked.
+//
+// Parameters:
+//   - statement: The let statement.
+func (c *Checker) checkLetStatement(statement *ast.LetStatement) {
+	if statement.Pattern != nil {
+		c.checkExpression(statement.Value)
+		c.bindPattern(statement.Pattern)
+		return
+	}
+
+	var declared Type
+	if statement.Type != nil {
+		declared = c.resolveType(statement.Type)
+	}
+
+	var value Type
+	if fn, ok := statement.Value.(*ast.FunctionLiteral); ok {
+		signature := c.signature(fn)
+		if declared != nil {
+			c.define(statement.Name.Value, declared, true)
+		} else {
+			c.define(statement.Name.Value, signature, false)
+		}
+		value = c.checkFunctionBody(fn, signature)
+	} else {
+		value = c.checkExpression(statement.Value)
+	}
+	if declared == nil {
+		c.define(statement.Name.Value, value, false)
+		return
+	}
+
+	if !Assignable(value, declared) {
+		c.errorf(statement.Value, "cannot use %s as %s in let %s", value, declared, statement.Name.Value)
+	}
+	c.define(statement.Name.Value, declared, true)
+}
+
+// checkReturnStatement checks the value of a return statement against the annotated
+// result type of the function it is in.
+//
+// Parameters:
+//   - statement: The return statement.
+func (c *Checker) checkReturnStatement(statement *ast.ReturnStatement) {
+	value := Type(Null)
+	var node ast.Node = statement
+	if statement.ReturnValue != nil {
+		value = c.checkExpression(statement.ReturnValue)
+		node = statement.ReturnValue
+	}
+
+	// A return statement at the top level of a module ends the module.
+	if len(c.functions) == 0 {
+		return
+	}
+	fn := c.functions[len(c.functions)-1]
+	fn.results = append(fn.results, value)
+	if fn.declared != nil && !fn.generator && !Assignable(value, fn.declared) {
+		c.errorf(node, "cannot use %s as %s in return", value, fn.declared)
+	}
+}
+
+// checkYieldStatement checks the value of a yield statement against the annotated type of
+// the values the generator it is in yields.
+//
+// Parameters:
+//   - statement: The yield statement.
+func (c *Checker) checkYieldStatement(statement *ast.YieldStatement) {
+	value := c.checkExpression(statement.Value)
+
+	// The parser only accepts yield statements inside generator functions.
+	fn := c.functions[len(c.functions)-1]
+	if fn.declared != nil && !Assignable(value, fn.declared) {
+		c.errorf(statement.Value, "cannot use %s as %s in yield", value, fn.declared)
+	}
+}
+
+// bindPattern binds the names of a pattern. The checker does not follow the parts of the
+// value a pattern takes apart, so the names have the type any.
+//
+// Parameters:
+//   - pattern: The pattern.
+func (c *Checker) bindPattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.BindingPattern:
+		c.define(pattern.Name.Value, Any, false)
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			c.bindPattern(element)
+		}
+		if pattern.Rest != nil {
+			c.define(pattern.Rest.Value, &Array{Element: Any}, false)
+		}
+	case *ast.HashPattern:
+		for _, value := range pattern.Values {
+			c.bindPattern(value)
+		}
+	case *ast.VariantPattern:
+		for _, field := range pattern.Fields {
+			c.bindPattern(field)
+		}
+	}
+}
+
+// checkExpression checks an expression and infers its type.
+//
+// Parameters:
+//   - expression: The expression.
+//
+// Returns:
+//   - Type: The type of the value of the expression.
+func (c *Checker) checkExpression(expression ast.Expression) Type {
+	switch expression := expression.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.FloatLiteral:
+		return Float
+	case *ast.StringLiteral:
+		return String
+	case *ast.Boolean:
+		return Bool
+	case *ast.InterpolatedString:
+		for _, part := range expression.Parts {
+			c.checkExpression(part)
+		}
+		return String
+	case *ast.Identifier:
+		if expression.Builtin {
+			if signature, ok := builtins[expression.Value]; ok {
+				return &Builtin{Name: expression.Value, Signature: signature}
+			}
+			return Any
+		}
+		if b := c.lookup(expression.Value); b != nil {
+			return b.typ
+		}
+	case *ast.PrefixExpression:
+		return c.checkPrefixExpression(expression)
+	case *ast.InfixExpression:
+		left := c.checkExpression(expression.Left)
+		right := c.checkExpression(expression.Right)
+		return c.checkOperator(expression, expression.Operator, left, right)
+	case *ast.AssignExpression:
+		return c.checkAssignExpression(expression)
+	case *ast.IfExpression:
+		c.checkExpression(expression.Condition)
+		consequence := c.checkBlock(expression.Consequence)
+		alternative := Type(Null)
+		if expression.Alternative != nil {
+			alternative = c.checkBlock(expression.Alternative)
+		}
+		return join(consequence, alternative)
+	case *ast.TryExpression:
+		result := c.checkBlock(expression.Body)
+		if expression.Catch != nil {
+			if expression.CatchParam != nil {
+				c.scope.blocks++
+				c.define(expression.CatchParam.Value, Any, false)
+				c.scope.blocks--
+			}
+			result = join(result, c.checkBlock(expression.Catch))
+		}
+		if expression.Finally != nil {
+			c.checkBlock(expression.Finally)
+		}
+		return result
+	case *ast.MatchExpression:
+		c.checkExpression(expression.Subject)
+		c.checkExhaustive(expression)
+		var result Type
+		for _, arm := range expression.Arms {
+			c.pushScope()
+			c.bindPattern(arm.Pattern)
+			if arm.Guard != nil {
+				c.checkExpression(arm.Guard)
+			}
+			result = join(result, c.checkBlock(arm.Body))
+			c.popScope()
+		}
+		if result == nil {
+			return Null
+		}
+		return result
+	case *ast.FunctionLiteral:
+		return c.checkFunctionBody(expression, c.signature(expression))
+	case *ast.CallExpression:
+		return c.checkCallExpression(expression)
+	case *ast.ArrayLiteral:
+		var element Type
+		for _, e := range expression.Elements {
+			if spread, ok := e.(*ast.SpreadExpression); ok {
+				e := Type(Any)
+				if array, ok := c.checkExpression(spread.Value).(*Array); ok {
+					e = array.Element
+				}
+				element = join(element, e)
+				continue
+			}
+			element = join(element, c.checkExpression(e))
+		}
+		if element == nil {
+			element = Any
+		}
+		return &Array{Element: element}
+	case *ast.HashLiteral:
+		// The pairs are kept in a map, so they are checked in source order to report errors in a stable order.
+		keys := make([]ast.Expression, 0, len(expression.Pairs))
+		for key := range expression.Pairs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Pos().Offset < keys[j].Pos().Offset })
+
+		var key, value Type
+		for _, k := range keys {
+			key = join(key, c.checkExpression(k))
+			value = join(value, c.checkExpression(expression.Pairs[k]))
+		}
+		if key == nil {
+			key, value = Any, Any
+		}
+		return &Hash{Key: key, Value: value}
+	case *ast.IndexExpression:
+		left := c.checkExpression(expression.Left)
+		index := c.checkExpression(expression.Index)
+		return c.checkIndex(expression, left, index)
+	case *ast.SliceExpression:
+		left := c.checkExpression(expression.Left)
+		for _, bound := range []ast.Expression{expression.Low, expression.High} {
+			if bound == nil {
+				continue
+			}
+			if t := c.checkExpression(bound); !Assignable(t, Int) {
+				c.errorf(bound, "slice index must be int, got %s", t)
+			}
+		}
+		if _, ok := left.(*Array); ok || left == String {
+			return left
+		}
+	case *ast.MemberExpression:
+		c.checkExpression(expression.Object)
+	case *ast.SpreadExpression:
+		c.checkExpression(expression.Value)
+	}
+	return Any
+}
+
+// checkPrefixExpression checks a prefix expression.
+//
+// Parameters:
+//   - expression: The prefix expression.
+//
+// Returns:
+//   - Type: The type of its value.
+func (c *Checker) checkPrefixExpression(expression *ast.PrefixExpression) Type {
+	right := c.checkExpression(expression.Right)
+
+	switch expression.Operator {
+	case "!":
+		return Bool
+	case "-":
+		if isUnknown(right) || isNumeric(right) {
+			return right
+		}
+		c.errorf(expression, "unknown operator: -%s", right)
+	}
+	return Any
+}
+
+// checkOperator checks the operands of an infix operator, following the rules the
+// evaluator and the virtual machine apply at runtime.
+//
+// Parameters:
+//   - node: The node applying the operator, where errors are reported.
+//   - operator: The operator, e.g. +
+//   - left: The type of the left operand.
+//   - right: The type of the right operand.
+//
+// Returns:
+//   - Type: The type of the result.
+func (c *Checker) checkOperator(node ast.Node, operator string, left, right Type) Type {
+	switch operator {
+	case "&&", "||":
+		return join(left, right)
+	case "==", "!=":
+		return Bool
+	}
+
+	comparison := operator == "<" || operator == ">" || operator == "<=" || operator == ">="
+	switch {
+	case isUnknown(left) || isUnknown(right):
+		if comparison {
+			return Bool
+		}
+		return Any
+	case isNumeric(left) && isNumeric(right):
+		if comparison {
+			return Bool
+		}
+		if left == Int && right == Int {
+			return Int
+		}
+		return Float
+	case left == String && right == String && operator == "+":
+		return String
+	case left.String() != right.String():
+		c.errorf(node, "type mismatch: %s %s %s", left, operator, right)
+	default:
+		c.errorf(node, "unknown operator: %s %s %s", left, operator, right)
+	}
+	return Any
+}
+
+// checkExhaustive checks that the arms of a match expression cover every variant of each
+// enum they match against whose declaration is known.
+//
+// Parameters:
+//   - expression: The match expression.
+func (c *Checker) checkExhaustive(expression *ast.MatchExpression) {
+	for _, coverage := range expression.Coverage() {
+		b := c.lookup(coverage.Enum.Value)
+		if b == nil || b.variants == nil {
+			continue
+		}
+
+		missing := []string{}
+		for _, variant := range b.variants {
+			if !slices.Contains(coverage.Covered, variant) {
+				missing = append(missing, variant)
+			}
+		}
+		if len(missing) > 0 {
+			c.errorf(expression, "non-exhaustive match on %s: missing %s", coverage.Enum.Value, strings.Join(missing, ", "))
+		}
+	}
+}
+
+// checkAssignExpression checks an assignment. Assigning to a name with an annotated type
+// must keep to that type; assigning a value of a different type to a name whose type was
+// inferred widens the type of the name.
+//
+// Parameters:
+//   - expression: The assignment.
+//
+// Returns:
+//   - Type: The type of the assigned value.
+func (c *Checker) checkAssignExpression(expression *ast.AssignExpression) Type {
+	value := c.checkExpression(expression.Value)
+	operator := strings.TrimSuffix(expression.Operator, "=")
+
+	switch target := expression.Target.(type) {
+	case *ast.Identifier:
+		b := c.lookup(target.Value)
+		if b == nil {
+			return value
+		}
+		b.variants = nil
+		if operator != "" {
+			value = c.checkOperator(expression, operator, b.typ, value)
+		}
+		if !b.annotated {
+			b.typ = join(b.typ, value)
+		} else if !Assignable(value, b.typ) {
+			c.errorf(expression.Value, "cannot assign %s to %s of type %s", value, target.Value, b.typ)
+		}
+	case *ast.IndexExpression:
+		left := c.checkExpression(target.Left)
+		index := c.checkExpression(target.Index)
+		element := c.checkIndex(target, left, index)
+		if operator != "" {
+			value = c.checkOperator(expression, operator, element, value)
+		}
+		c.widenElement(target, left, index, element, value)
+	case *ast.MemberExpression:
+		c.checkExpression(target.Object)
+	}
+	return value
+}
+
+// widenElement widens the type of an array or a hash whose element is assigned a value of
+// a different type, when the type of the array or hash was inferred.
+//
+// Parameters:
+//   - target: The index expression assigned to.
+//   - left: The type of the indexed value.
+//   - index: The type of the index.
+//   - element: The type of the element.
+//   - value: The type of the assigned value.
+func (c *Checker) widenElement(target *ast.IndexExpression, left, index, element, value Type) {
+	widened := join(element, value)
+	if widened.String() == element.String() {
+		return
+	}
+
+	switch left := left.(type) {
+	case *Array:
+		c.widen(target.Left, &Array{Element: widened})
+	case *Hash:
+		c.widen(target.Left, &Hash{Key: join(left.Key, index), Value: widened})
+	}
+}
+
+// widen widens the type of the value an expression refers to. Only a name whose type was
+// inferred is widened to the given type; the value of an index expression is widened to
+// any, as the checker does not follow it.
+//
+// Parameters:
+//   - target: The expression.
+//   - typ: The wider type.
+func (c *Checker) widen(target ast.Expression, typ Type) {
+	switch target := target.(type) {
+	case *ast.Identifier:
+		if b := c.lookup(target.Value); b != nil && !b.annotated {
+			b.typ = typ
+		}
+	case *ast.IndexExpression:
+		c.widen(target.Left, Any)
+	}
+}
+
+// checkIndex checks an index expression.
+//
+// Parameters:
+//   - node: The index expression, where errors are reported.
+//   - left: The type of the indexed value.
+//   - index: The type of the index.
+//
+// Returns:
+//   - Type: The type of the element.
+func (c *Checker) checkIndex(node ast.Node, left, index Type) Type {
+	switch left := left.(type) {
+	case *Array:
+		if !Assignable(index, Int) {
+			c.errorf(node, "array index must be int, got %s", index)
+		}
+		return left.Element
+	case *Hash:
+		return left.Value
+	case *Basic:
+		if left == String {
+			if !Assignable(index, Int) {
+				c.errorf(node, "string index must be int, got %s", index)
+			}
+			return String
+		}
+		if !isUnknown(left) {
+			c.errorf(node, "index operator not supported: %s", left)
+		}
+	case *Function, *Builtin:
+		c.errorf(node, "index operator not supported: %s", left)
+	}
+	return Any
+}
+
+// signature gives the type of a function literal as far as its annotations tell it.
+// Parameters without an annotation have the type any, and so has the result.
+//
+// Parameters:
+//   - fn: The function literal.
+//
+// Returns:
+//   - *Function: The function type.
+func (c *Checker) signature(fn *ast.FunctionLiteral) *Function {
+	t := &Function{Return: Any}
+	for i := range fn.Parameters {
+		param := Type(Any)
+		if fn.Types != nil && fn.Types[i] != nil {
+			param = c.resolveType(fn.Types[i])
+		}
+		t.Params = append(t.Params, param)
+		if fn.Defaults == nil || fn.Defaults[i] == nil {
+			t.Required = i + 1
+		}
+	}
+	if fn.Rest != nil {
+		t.Rest = Any
+		if fn.RestType != nil {
+			t.Rest = c.resolveType(fn.RestType)
+		}
+	}
+	// Calling a generator function returns a generator, whatever its annotation.
+	if fn.ReturnType != nil && !fn.Generator {
+		t.Return = c.resolveType(fn.ReturnType)
+	}
+	return t
+}
+
+// checkFunctionBody checks the parameters and the body of a function. Unless the result
+// type is annotated, it is inferred from the return statements and the last statement of
+// the body.
+//
+// Parameters:
+//   - fn: The function literal.
+//   - signature: The type of the function as far as its annotations tell it.
+//
+// Returns:
+//   - Type: The function type.
+func (c *Checker) checkFunctionBody(fn *ast.FunctionLiteral, signature *Function) Type {
+	c.pushScope()
+	defer c.popScope()
+
+	for i, param := range fn.Parameters {
+		typ := signature.Params[i]
+		if fn.Defaults != nil && fn.Defaults[i] != nil {
+			if value := c.checkExpression(fn.Defaults[i]); !Assignable(value, typ) {
+				c.errorf(fn.Defaults[i], "cannot use %s as %s in default value of %s", value, typ, param.Value)
+			}
+		}
+
+		if fn.Patterns != nil && fn.Patterns[i] != nil {
+			c.bindPattern(fn.Patterns[i])
+		} else {
+			c.define(param.Value, typ, fn.Types != nil && fn.Types[i] != nil)
+		}
+	}
+	if fn.Rest != nil {
+		c.define(fn.Rest.Value, &Array{Element: signature.Rest}, fn.RestType != nil)
+	}
+
+	var declared Type
+	switch {
+	case fn.ReturnType != nil && fn.Generator:
+		// The annotated result type of a generator function is the type of the values it yields.
+		declared = c.resolveType(fn.ReturnType)
+	case fn.ReturnType != nil:
+		declared = signature.Return
+	}
+	context := &function{declared: declared, generator: fn.Generator}
+	c.functions = append(c.functions, context)
+	last := c.checkStatements(fn.Body.Statements)
+	c.functions = c.functions[:len(c.functions)-1]
+
+	// Only a value the body ends with is a result; a body ending with any other statement results in null.
+	statements := fn.Body.Statements
+	if declared != nil && !fn.Generator {
+		var end ast.Statement
+		if len(statements) > 0 {
+			end = statements[len(statements)-1]
+		}
+		switch end := end.(type) {
+		case *ast.ExpressionStatement:
+			if end.Expression != nil && !Assignable(last, declared) {
+				c.errorf(end.Expression, "cannot use %s as %s in return", last, declared)
+			}
+		case *ast.WhileStatement, *ast.ForStatement, *ast.ForInStatement:
+			// The checker cannot tell whether a loop ends, so one ending the body is trusted to return from inside.
+		default:
+			if last != never && !Assignable(Null, declared) {
+				var node ast.Node = fn.Body
+				if end != nil {
+					node = end
+				}
+				c.errorf(node, "missing return of %s: the body ends without a value", declared)
+			}
+		}
+	}
+
+	if declared != nil || fn.Generator {
+		return signature
+	}
+
+	var result Type
+	for _, r := range context.results {
+		result = join(result, r)
+	}
+	result = join(result, last)
+	t := *signature
+	if result != nil && result != never {
+		t.Return = result
+	}
+	return &t
+}
+
+// checkCallExpression checks the arguments of a call against the parameters of the
+// called function, if its type is known.
+//
+// Parameters:
+//   - call: The call expression.
+//
+// Returns:
+//   - Type: The type of the result.
+func (c *Checker) checkCallExpression(call *ast.CallExpression) Type {
+	callee := c.checkExpression(call.Function)
+
+	args := make([]Type, len(call.Arguments))
+	spread := false
+	for i, arg := range call.Arguments {
+		if s, ok := arg.(*ast.SpreadExpression); ok {
+			c.checkExpression(s.Value)
+			args[i] = Any
+			spread = true
+			continue
+		}
+		args[i] = c.checkExpression(arg)
+	}
+
+	var fn *Function
+	switch callee := callee.(type) {
+	case *Function:
+		fn = callee
+	case *Builtin:
+		fn = callee.Signature(args)
+	default:
+		if !isUnknown(callee) {
+			c.errorf(call.Function, "not a function: %s", callee)
+		}
+		return Any
+	}
+
+	// The number of arguments a spread produces is only known at runtime.
+	if spread {
+		return fn.Return
+	}
+
+	switch {
+	case len(args) < fn.Required && fn.Rest != nil:
+		c.errorf(call, "wrong number of arguments: want at least %d, got=%d", fn.Required, len(args))
+	case (len(args) < fn.Required || len(args) > len(fn.Params)) && fn.Rest == nil:
+		if fn.Required == len(fn.Params) {
+			c.errorf(call, "wrong number of arguments: want=%d, got=%d", fn.Required, len(args))
+		} else {
+			c.errorf(call, "wrong number of arguments: want between %d and %d, got=%d", fn.Required, len(fn.Params), len(args))
+		}
+	default:
+		for i, arg := range args {
+			param := fn.Rest
+			if i < len(fn.Params) {
+				param = fn.Params[i]
+			}
+			if !Assignable(arg, param) {
+				c.errorf(call.Arguments[i], "cannot use %s as %s in argument %d to %s", arg, param, i+1, call.Function.String())
+			}
+		}
+	}
+
+	return fn.Return
+}
@@ -0,0 +1,223 @@
+package typecheck
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestWellTypedPrograms(t *testing.T) {
+	tests := []string{
+		`let x: int = 5; let y: float = x; y * 2.5`,
+		`let add = fn(a: int, b: int) -> int { a + b }; let z: int = add(1, 2);`,
+		`let f = fn(a: string, b: [int]) -> bool { len(a) > len(b) }; f("abc", [1, 2])`,
+		`let fact = fn(n: int) -> int { if (n < 2) { return 1; } n * fact(n - 1) }; fact(5)`,
+		`let sign = fn(n: int) -> string { if (n < 0) { return "-" } else { return "+" } };`,
+		`let xs: [int] = [1, 2, 3]; let head: int = first(xs); let tail: [int] = rest(xs);`,
+		`let h: {string: int} = {"a": 1, "b": 2}; let a: int = h["a"];`,
+		`let apply = fn(f: fn(int) -> int, x: int) -> int { f(x) }; apply(fn(n) { n * 2 }, 3)`,
+		`let twice: fn(int) -> int = fn(x: float) -> int { 2 }; twice(1)`,
+		`let x = 1; x = "now a string"; let s: string = x;`,
+		`let x: float = 1; x += 2;`,
+		`let greet = fn(name, greeting = "hello") { greeting + " " + name }; greet("monkey")`,
+		`let sum = fn(...xs) { len(xs) }; sum(1, "a", true)`,
+		`let args = [1, 2]; let add = fn(a: int, b: int) { a + b }; add(...args)`,
+		`struct Point { x, y } let p: Point = Point(1, 2); p.x + 1`,
+		`enum Shape { Circle(r), Square(s) } let s: Shape = Shape.Circle(1);`,
+		`let x = try { throw error("boom"); } catch (e) { e["message"] }; len(x)`,
+		`let xs = [1, 2]; for (x in xs) { let y: int = x; }`,
+		`for (let i = 0; i < 3; i += 1) { puts(i); }`,
+		`let s: string = "monkey"[0:3] + "a"[0];`,
+		`let xs: [any] = []; append!(xs, 1, "two"); delete!({"a": 1}, "a")`,
+		`match (5) { 1 => "one", n if n > 1 => "many", _ => "none" }`,
+		`let [a, b] = [1, 2]; a + b`,
+		`let f = fn() { f }; let g = fn() -> null { puts("x") };`,
+		`let count = fn*(n: int) -> int { let i = 0; while (i < n) { yield i; i += 1; } }; let g = count(3); next(g) + 1`,
+		`let g = fn*() -> string { yield "a"; return 1; }; let xs: [any] = array(g()); len(g())`,
+		`let ch = chan(1); let task = spawn(fn(n: int) { send(ch, n) }, 1); recv(task); select([ch], null)`,
+		`let f = fn() -> int { let x = 2; return x; }; let g = fn() -> int { throw "no"; };`,
+		`let f = fn() -> null { }; let g = fn() -> any { let x = 1; };`,
+		`let f = fn() -> int { while (true) { return 1; } };`,
+		`let sum = fn(...xs: int) -> int { let total = 0; for (x in xs) { total += x; } total }; sum(1, 2)`,
+		`let area = fn(s: Shape) { s }; let origin = fn() -> Point { Point(0, 0) }; struct Point { x, y } enum Shape { Dot }`,
+		`let x = "s"; if (true) { let x = 1; } x + 1`,
+		`let x = "s"; if (false) { let x = 1; } x + "t"`,
+		`let x = "s"; try { throw 1; } catch (x) { } x + 1`,
+		`let f = fn(x: int) { let x = "s"; x + "t" };`,
+		`let a = [1]; a[0] = "s"; a[0] + "t"`,
+		`let h = {"k": 1}; h["k"] = "s"; h["k"] + "t"`,
+		`let m = [[1]]; m[0][0] = "s"; m[0][0] + "t"`,
+		`enum Color { Red, Green } match (Color.Red) { Color.Red => 1, Color.Green => 2 }`,
+		`enum Color { Red, Green } match (Color.Red) { Color.Red => 1, _ => 2 }`,
+		`enum Color { Red, Green } let f = fn(Color) { match (1) { Color.Red => 1, 1 => 2 } };`,
+		`enum Color { Red, Green } Color = 1; match (1) { Color.Red => 1, 1 => 2 }`,
+		`match (1) { Color.Red => 1, 1 => 2 }`,
+	}
+
+	for _, input := range tests {
+		errors := Check(parse(t, input))
+		if len(errors) != 0 {
+			t.Errorf("unexpected type errors for %q: %v", input, errors)
+		}
+	}
+}
+
+func TestTypeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let x: int = "five";`, []string{`1:14: cannot use string as int in let x`}},
+		{`let x: string = 1 + 2.5;`, []string{`1:17: cannot use float as string in let x`}},
+		{`let x: [int] = ["one", "two"];`, []string{`1:16: cannot use [string] as [int] in let x`}},
+		{`let x: {string: int} = {"a": "b"};`, []string{`1:24: cannot use {string: string} as {string: int} in let x`}},
+		{`let x: int = 1; x = "one";`, []string{`1:21: cannot assign string to x of type int`}},
+		{`let x: int = 1; x += 0.5;`, []string{`1:22: cannot assign float to x of type int`}},
+		{`let x = [1, 2]; let y: string = first(x);`, []string{`1:33: cannot use int as string in let y`}},
+		{`let x: Point = 1;`, []string{`1:8: unknown type Point`}},
+		{`struct Point { x, y } let p: Point = 1;`, []string{`1:38: cannot use int as Point in let p`}},
+		{`1 + "a"`, []string{`1:1: type mismatch: int + string`}},
+		{`"a" - "b"`, []string{`1:1: unknown operator: string - string`}},
+		{`true * false`, []string{`1:1: unknown operator: bool * bool`}},
+		{`-"a"`, []string{`1:1: unknown operator: -string`}},
+		{`let f = fn(a: int) { a }; f("a")`, []string{`1:29: cannot use string as int in argument 1 to f`}},
+		{`let f = fn(a, b) { a }; f(1)`, []string{`1:25: wrong number of arguments: want=2, got=1`}},
+		{`let f = fn(a, b = 2) { a }; f(1, 2, 3)`, []string{`1:29: wrong number of arguments: want between 1 and 2, got=3`}},
+		{`let f = fn(a, ...rest) { a }; f()`, []string{`1:31: wrong number of arguments: want at least 1, got=0`}},
+		{`let f = fn(a: int = "x") { a };`, []string{`1:21: cannot use string as int in default value of a`}},
+		{`let f = fn() -> int { "x" };`, []string{`1:23: cannot use string as int in return`}},
+		{`let f = fn() -> int { };`, []string{`1:21: missing return of int: the body ends without a value`}},
+		{`let f = fn() -> int { let x = 1; };`, []string{`1:23: missing return of int: the body ends without a value`}},
+		{`let f = fn(x) -> string { if (x) { return "a"; } let y = x; };`, []string{
+			`1:50: missing return of string: the body ends without a value`,
+		}},
+		{`let f = fn(...xs: int) { xs }; f(1, "a")`, []string{`1:37: cannot use string as int in argument 2 to f`}},
+		{`let f = fn(...xs: int) { let s: [string] = xs; };`, []string{`1:44: cannot use [int] as [string] in let s`}},
+		{`let f = fn(...xs: int) { xs = ["a"]; };`, []string{`1:31: cannot assign [string] to xs of type [int]`}},
+		{`let f = fn(...xs: Point) { xs };`, []string{`1:19: unknown type Point`}},
+		{`let f = fn(p: Point) { p }; f(1); struct Point { x, y }`, []string{`1:31: cannot use int as Point in argument 1 to f`}},
+		{`let f = fn() { let s: Shape = 1; }; enum Shape { Dot }`, []string{`1:31: cannot use int as Shape in let s`}},
+		{`enum Color { Red, Green, Blue } match (Color.Red) { Color.Red => 1, Color.Green if true => 2 }`, []string{
+			`1:33: non-exhaustive match on Color: missing Green, Blue`,
+		}},
+		{`enum Option { Some(v), None } let f = fn(o) { match (o) { Option.Some(1) => 1, Option.None => 0 } };`, []string{
+			`1:47: non-exhaustive match on Option: missing Some`,
+		}},
+		{`let f = fn() { struct Point { x } }; let p: Point = 1;`, []string{`1:45: unknown type Point`}},
+		{`let f = fn(x) -> int { if (x) { return "a"; } 1 };`, []string{`1:40: cannot use string as int in return`}},
+		{`let f = fn() -> string { 1 }; let g: int = f();`, []string{
+			`1:26: cannot use int as string in return`,
+			`1:44: cannot use string as int in let g`,
+		}},
+		{`let x = 5; x(1)`, []string{`1:12: not a function: int`}},
+		{`len(1)`, []string{`1:5: cannot use int as string | [any] in argument 1 to len`}},
+		{`first("abc")`, []string{`1:7: cannot use string as [any] in argument 1 to first`}},
+		{`push([1], 2, 3)`, []string{`1:1: wrong number of arguments: want=2, got=3`}},
+		{`error(1)`, []string{`1:7: cannot use int as string in argument 1 to error`}},
+		{`let xs = [1, 2]; xs["a"]`, []string{`1:18: array index must be int, got string`}},
+		{`"abc"[true]`, []string{`1:1: string index must be int, got bool`}},
+		{`5[0]`, []string{`1:1: index operator not supported: int`}},
+		{`[1, 2][true:]`, []string{`1:8: slice index must be int, got bool`}},
+		{`let apply = fn(f: fn(int) -> int) { f(1) }; apply(fn(s: string) { s })`, []string{
+			`1:51: cannot use fn(string) -> string as fn(int) -> int in argument 1 to apply`,
+		}},
+		{`let f = fn(x: int) -> [string] { [x] };`, []string{`1:34: cannot use [int] as [string] in return`}},
+		{`let a = {"b": 1 + "c", "d": 2 * "e"};`, []string{
+			`1:15: type mismatch: int + string`,
+			`1:29: type mismatch: int * string`,
+		}},
+		{`let s = "a"; for (c in s) { c * 2 }`, []string{`1:29: type mismatch: string * int`}},
+		{`let g = fn*() -> int { yield "a"; };`, []string{`1:30: cannot use string as int in yield`}},
+		{`let g = fn*(n: int) { yield n; }; g("a")`, []string{`1:37: cannot use string as int in argument 1 to g`}},
+		{`chan("a")`, []string{`1:6: cannot use string as int in argument 1 to chan`}},
+		{`select(1)`, []string{`1:8: cannot use int as [any] in argument 1 to select`}},
+		{`if (true) { let y = 1; } y + "a"`, []string{`1:26: type mismatch: int + string`}},
+		{`let a = [1]; a[0] = 2.5; a[0] + "t"`, []string{`1:26: type mismatch: float + string`}},
+	}
+
+	for _, tt := range tests {
+		errors := Check(parse(t, tt.input))
+		if len(errors) != len(tt.expected) {
+			t.Errorf("wrong number of type errors for %q. want=%d, got=%d (%v)", tt.input, len(tt.expected), len(errors), errors)
+			continue
+		}
+		for i, err := range errors {
+			if err.Error() != tt.expected[i] {
+				t.Errorf("wrong type error for %q. want=%q, got=%q", tt.input, tt.expected[i], err.Error())
+			}
+		}
+	}
+}
+
+func TestErrorSpan(t *testing.T) {
+	errors := Check(parse(t, `let x: int = "five";`))
+	if len(errors) != 1 {
+		t.Fatalf("wrong number of type errors. want=1, got=%d", len(errors))
+	}
+	if errors[0].Pos.Column != 14 || errors[0].End.Column != 20 {
+		t.Errorf("wrong span. want=14..20, got=%d..%d", errors[0].Pos.Column, errors[0].End.Column)
+	}
+}
+
+func TestCheckerKeepsTopLevelNames(t *testing.T) {
+	checker := New()
+	if errors := checker.Check(parse(t, `let x: int = 1;`)); len(errors) != 0 {
+		t.Fatalf("unexpected type errors: %v", errors)
+	}
+
+	errors := checker.Check(parse(t, `x = "one";`))
+	if len(errors) != 1 || errors[0].Message != "cannot assign string to x of type int" {
+		t.Errorf("wrong type errors. got=%v", errors)
+	}
+}
+
+func TestBuiltinSignatures(t *testing.T) {
+	for _, def := range object.Builtins {
+		if _, ok := builtins[def.Name]; !ok {
+			t.Errorf("no signature for builtin %s", def.Name)
+		}
+	}
+}
+
+func TestAssignable(t *testing.T) {
+	tests := []struct {
+		from     Type
+		to       Type
+		expected bool
+	}{
+		{Int, Int, true},
+		{Int, Float, true},
+		{Float, Int, false},
+		{String, Any, true},
+		{Any, String, true},
+		{&Array{Element: Int}, &Array{Element: Float}, true},
+		{&Array{Element: String}, &Array{Element: Int}, false},
+		{&Hash{Key: String, Value: Int}, &Hash{Key: String, Value: Any}, true},
+		{&Named{Name: "Point"}, &Named{Name: "Point"}, true},
+		{&Named{Name: "Point"}, &Named{Name: "Shape"}, false},
+		{&Function{Params: []Type{Float}, Required: 1, Return: Int}, &Function{Params: []Type{Int}, Required: 1, Return: Float}, true},
+		{&Function{Params: []Type{Int}, Required: 1, Return: Int}, &Function{Params: []Type{Float}, Required: 1, Return: Int}, false},
+		{&Function{Params: []Type{Int, Int}, Required: 2, Return: Int}, &Function{Params: []Type{Int}, Required: 1, Return: Int}, false},
+		{&Function{Rest: Any, Return: Null}, &Function{Params: []Type{Int}, Required: 1, Return: Null}, true},
+		{String, &Union{Types: []Type{String, &Array{Element: Any}}}, true},
+		{Int, &Union{Types: []Type{String, &Array{Element: Any}}}, false},
+	}
+
+	for _, tt := range tests {
+		if got := Assignable(tt.from, tt.to); got != tt.expected {
+			t.Errorf("Assignable(%s, %s) wrong. want=%t, got=%t", tt.from, tt.to, tt.expected, got)
+		}
+	}
+}
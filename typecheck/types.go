@@ -0,0 +1,214 @@
+package typecheck
+
+import "strings"
+
+// Type is the static type of a value, as far as the checker knows it.
+type Type interface {
+	String() string
+}
+
+// Basic is a type without parts, like int or string.
+type Basic struct {
+	Name string // The name the type is written with.
+}
+
+// String returns the name of the type.
+func (b *Basic) String() string { return b.Name }
+
+var (
+	Int    = &Basic{Name: "int"}
+	Float  = &Basic{Name: "float"}
+	String = &Basic{Name: "string"}
+	Bool   = &Basic{Name: "bool"}
+	Null   = &Basic{Name: "null"}
+	// Any is the type of the values the checker knows nothing about. It can be used wherever
+	// any other type is expected, and any value can be used where it is expected.
+	Any = &Basic{Name: "any"}
+
+	// never is the type of a block that does not complete, because it ends by returning,
+	// throwing or leaving a loop. It does not add to the type of the expression it is in.
+	never = &Basic{Name: "never"}
+)
+
+// basicTypes are the basic types that can be written in an annotation, by name.
+var basicTypes = map[string]*Basic{
+	Int.Name:    Int,
+	Float.Name:  Float,
+	String.Name: String,
+	Bool.Name:   Bool,
+	Null.Name:   Null,
+	Any.Name:    Any,
+}
+
+// Array is the type of the arrays whose elements all have the same type.
+type Array struct {
+	Element Type // The type of the elements.
+}
+
+// String returns the type as it is written in an annotation, e.g. [int].
+func (a *Array) String() string { return "[" + a.Element.String() + "]" }
+
+// Hash is the type of the hashes whose keys and values all have the same type.
+type Hash struct {
+	Key   Type // The type of the keys.
+	Value Type // The type of the values.
+}
+
+// String returns the type as it is written in an annotation, e.g. {string: int}.
+func (h *Hash) String() string { return "{" + h.Key.String() + ": " + h.Value.String() + "}" }
+
+// Function is the type of a function.
+type Function struct {
+	Params   []Type // The types of the parameters.
+	Required int    // The number of parameters without a default value.
+	Rest     Type   // The type of each further argument, or nil when no further arguments are taken.
+	Return   Type   // The type of the result.
+}
+
+// String returns the type as it is written in an annotation, e.g. fn(int, string) -> bool.
+func (f *Function) String() string {
+	params := []string{}
+	for _, p := range f.Params {
+		params = append(params, p.String())
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
+	}
+	return "fn(" + strings.Join(params, ", ") + ") -> " + f.Return.String()
+}
+
+// Named is the type of the instances of a struct, or of the variants of an enum.
+type Named struct {
+	Name string // The name of the struct or enum.
+}
+
+// String returns the name of the struct or enum.
+func (n *Named) String() string { return n.Name }
+
+// Union is the type of a parameter of a built-in function that accepts values of
+// several types, e.g. the argument of len.
+type Union struct {
+	Types []Type // The accepted types.
+}
+
+// String returns the accepted types separated by bars, e.g. string | [any].
+func (u *Union) String() string {
+	types := []string{}
+	for _, t := range u.Types {
+		types = append(types, t.String())
+	}
+	return strings.Join(types, " | ")
+}
+
+// Builtin is the type of a built-in function. Most built-in functions work on arrays of
+// any element type, so the type of a call depends on the types of its arguments.
+type Builtin struct {
+	Name      string                      // The name of the built-in function.
+	Signature func(args []Type) *Function // Gives the function type for a call with arguments of the given types.
+}
+
+// String returns the function type for a call whose argument types are not known.
+func (b *Builtin) String() string { return b.Signature(nil).String() }
+
+// Assignable reports whether a value of one type can be used where another type is
+// expected. An int can be used where a float is expected, and a function can be used
+// where a function type is expected if it accepts the expected arguments and its result
+// can be used as the expected result.
+//
+// Parameters:
+//   - from: The type of the value.
+//   - to: The expected type.
+//
+// Returns:
+//   - bool: Whether the value can be used.
+func Assignable(from, to Type) bool {
+	if from == Any || from == never || to == Any {
+		return true
+	}
+	if b, ok := from.(*Builtin); ok {
+		from = b.Signature(nil)
+	}
+
+	switch to := to.(type) {
+	case *Basic:
+		return from == to || (to == Float && from == Int)
+	case *Array:
+		from, ok := from.(*Array)
+		return ok && Assignable(from.Element, to.Element)
+	case *Hash:
+		from, ok := from.(*Hash)
+		return ok && Assignable(from.Key, to.Key) && Assignable(from.Value, to.Value)
+	case *Function:
+		from, ok := from.(*Function)
+		if !ok || from.Required > len(to.Params) || (from.Rest == nil && len(to.Params) > len(from.Params)) {
+			return false
+		}
+		for i, param := range to.Params {
+			accepted := from.Rest
+			if i < len(from.Params) {
+				accepted = from.Params[i]
+			}
+			if !Assignable(param, accepted) {
+				return false
+			}
+		}
+		return Assignable(from.Return, to.Return)
+	case *Named:
+		from, ok := from.(*Named)
+		return ok && from.Name == to.Name
+	case *Union:
+		for _, t := range to.Types {
+			if Assignable(from, t) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// join gives the type of a value that has one of two types, such as the value of an if
+// expression with two branches. Types that differ join to any, except int and float,
+// which join to float.
+//
+// Parameters:
+//   - a: The first type, or nil when there is none yet.
+//   - b: The second type, or nil.
+//
+// Returns:
+//   - Type: The joined type, or nil if both are nil.
+func join(a, b Type) Type {
+	switch {
+	case a == nil || a == never:
+		return b
+	case b == nil || b == never:
+		return a
+	case a.String() == b.String():
+		return a
+	case isNumeric(a) && isNumeric(b):
+		return Float
+	}
+	return Any
+}
+
+// isNumeric reports whether a type is int or float.
+//
+// Parameters:
+//   - t: The type.
+//
+// Returns:
+//   - bool: Whether the type is a number type.
+func isNumeric(t Type) bool {
+	return t == Int || t == Float
+}
+
+// isUnknown reports whether the checker knows nothing about the values of a type.
+//
+// Parameters:
+//   - t: The type.
+//
+// Returns:
+//   - bool: Whether the type is any, or the type of a block that does not complete.
+func isUnknown(t Type) bool {
+	return t == Any || t == never
+}
@@ -9,6 +9,7 @@ type Frame struct {
 	cl          *object.Closure
 	ip          int
 	basePointer int
+	numArgs     int
 }
 
 func NewFrame(cl *object.Closure, basePointer int) *Frame {
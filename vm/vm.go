@@ -2,27 +2,110 @@ package vm
 
 import (
 	"fmt"
+	"math"
 	"monkey/code"
 	"monkey/compiler"
 	"monkey/object"
+	"strings"
+	"sync"
 )
 
 const StackSize = 2048
 const MaxFrames = 1024
+
+// The initial stack size and number of frames of a VM created by fork.
+const (
+	forkStackSize = 16
+	forkFrames    = 4
+)
+
 const GlobalsSize = 65536
 
 type VM struct {
 	constants   []object.Object
 	stack       []object.Object
-	sp          int             // always points to the next value. top of stack is at stack[sp - 1]
-	globals     []object.Object // Global variables
-	frames      []*Frame        // Frames to handle various scopes
-	framesIndex int             // Index to the current frame
+	sp          int                       // always points to the next value. top of stack is at stack[sp - 1]
+	globals     *Globals                  // Global variables, shared with the VMs of spawned tasks
+	frames      []*Frame                  // Frames to handle various scopes
+	framesIndex int                       // Index to the current frame
+	handlers    []handler                 // Exception handlers installed by OpTry, innermost last
+	modules     map[string]*object.Module // Modules already imported, by path
+	yielded     object.Object             // The value of the last OpYield when the VM runs a generator, until it is handed over
+}
+
+// Globals is the store of the global variables. The VMs running the tasks started by spawn
+// share the store with the VM that started them, so it is only accessed under a lock.
+type Globals struct {
+	mu     sync.RWMutex
+	values []object.Object
+}
+
+// NewGlobals creates a store with room for GlobalsSize variables.
+func NewGlobals() *Globals {
+	return &Globals{values: make([]object.Object, GlobalsSize)}
+}
+
+// Get returns the value of the global variable with the given index.
+func (g *Globals) Get(index int) object.Object {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if cell, ok := g.values[index].(*object.Cell); ok {
+		return cell.Get()
+	}
+	return g.values[index]
+}
+
+// Set changes the value of the global variable with the given index, through its cell if
+// a closure has captured it.
+func (g *Globals) Set(index int, value object.Object) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if cell, ok := g.values[index].(*object.Cell); ok {
+		cell.Set(value)
+		return
+	}
+	g.values[index] = value
+}
+
+// Cell returns the cell holding the global variable with the given index, boxing the
+// variable the first time a closure captures it.
+func (g *Globals) Cell(index int) *object.Cell {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	cell, ok := g.values[index].(*object.Cell)
+	if !ok {
+		cell = object.NewCell(g.values[index])
+		g.values[index] = cell
+	}
+	return cell
+}
+
+// Clear clears a range of global variables, dropping any cells closures have captured.
+func (g *Globals) Clear(start, count int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	clear(g.values[start : start+count])
+}
+
+// handler records where to resume when a value is thrown inside a try block.
+type handler struct {
+	target      int // The position of the catch code in the frame's instructions
+	framesIndex int // The frame the handler was installed in
+	sp          int // The stack pointer when the handler was installed
+}
+
+// thrownError carries a value thrown with OpThrow out of the run loop.
+type thrownError struct {
+	value object.Object
+}
+
+func (e *thrownError) Error() string {
+	return (&object.Exception{Value: e.value}).Uncaught().Message
 }
 
 var True = &object.Boolean{Value: true}
 var False = &object.Boolean{Value: false}
-var Null = &object.Null{}
+var Null = object.NULL
 
 func New(bytecode *compiler.Bytecode) *VM {
 	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
@@ -36,19 +119,59 @@ func New(bytecode *compiler.Bytecode) *VM {
 		constants:   bytecode.Constants,
 		stack:       make([]object.Object, StackSize),
 		sp:          0,
-		globals:     make([]object.Object, GlobalsSize),
+		globals:     NewGlobals(),
 		frames:      frames,
 		framesIndex: 1,
+		modules:     map[string]*object.Module{},
 	}
 }
 
 func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	return NewWithGlobals(bytecode, &Globals{values: s})
+}
+
+// NewWithGlobals creates a VM using a store of global variables that other VMs can share,
+// such as those running the lines entered into the REPL one after the other.
+func NewWithGlobals(bytecode *compiler.Bytecode, globals *Globals) *VM {
 	vm := New(bytecode)
-	vm.globals = s
+	vm.globals = globals
 	return vm
 }
 
+// Run executes the bytecode. A runtime error or thrown value is passed to the innermost
+// exception handler, which unwinds the frames and the stack to where it was installed; when
+// there is none, Run stops and returns the error.
 func (vm *VM) Run() error {
+	for {
+		err := vm.run()
+		if err == nil || !vm.catch(err) {
+			return err
+		}
+	}
+}
+
+func (vm *VM) catch(err error) bool {
+	if len(vm.handlers) == 0 {
+		return false
+	}
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	var value object.Object
+	if thrown, ok := err.(*thrownError); ok {
+		value = thrown.value
+	} else {
+		value = &object.Error{Message: err.Error()}
+	}
+
+	vm.framesIndex = h.framesIndex
+	vm.sp = h.sp
+	vm.currentFrame().ip = h.target - 1
+
+	return vm.push(value) == nil
+}
+
+func (vm *VM) run() error {
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
@@ -71,8 +194,11 @@ func (vm *VM) Run() error {
 			}
 		case code.OpPop:
 			vm.pop()
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
-			vm.executeBinaryOperation(op)
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod:
+			err := vm.executeBinaryOperation(op)
+			if err != nil {
+				return err
+			}
 		case code.OpTrue:
 			err := vm.push(True)
 			if err != nil {
@@ -83,7 +209,8 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
-		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan:
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterThanOrEqual, code.OpLessThanOrEqual:
 			err := vm.executeComparison(op)
 			if err != nil {
 				return err
@@ -112,6 +239,17 @@ func (vm *VM) Run() error {
 				vm.currentFrame().ip = pos - 1
 			}
 
+		case code.OpJumpNotTruthyOrPop, code.OpJumpTruthyOrPop:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			// Leave the deciding operand on the stack as the result.
+			if isTruthy(vm.stack[vm.sp-1]) == (op == code.OpJumpTruthyOrPop) {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
 		case code.OpNull:
 			err := vm.push(Null)
 			if err != nil {
@@ -122,30 +260,85 @@ func (vm *VM) Run() error {
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.currentFrame().ip += 2
 
-			vm.globals[globalIndex] = vm.pop()
+			vm.globals.Set(int(globalIndex), vm.pop())
 
 		case code.OpGetGlobal:
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.currentFrame().ip += 2
 
-			err := vm.push(vm.globals[globalIndex])
+			err := vm.push(vm.globals.Get(int(globalIndex)))
 			if err != nil {
 				return err
 			}
 
-		case code.OpSetLocal:
+		case code.OpSetLocal, code.OpAssignLocal:
+			// A local captured by a closure is boxed in a cell, which a new value is written
+			// through, so the closure sees it whether it is assigned or defined again.
 			localIndex := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip += 1
 
 			frame := vm.currentFrame()
-			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+			slot := &vm.stack[frame.basePointer+int(localIndex)]
+			if cell, ok := (*slot).(*object.Cell); ok {
+				cell.Set(vm.pop())
+			} else {
+				*slot = vm.pop()
+			}
 
 		case code.OpGetLocal:
 			localIndex := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip += 1
 			frame := vm.currentFrame()
 
-			err := vm.push(vm.stack[frame.basePointer+int(localIndex)])
+			value := vm.stack[frame.basePointer+int(localIndex)]
+			if cell, ok := value.(*object.Cell); ok {
+				value = cell.Get()
+			}
+
+			err := vm.push(value)
+			if err != nil {
+				return err
+			}
+
+		case code.OpClearLocals:
+			start := int(code.ReadUint8(ins[ip+1:]))
+			count := int(code.ReadUint8(ins[ip+2:]))
+			vm.currentFrame().ip += 2
+
+			frame := vm.currentFrame()
+			clear(vm.stack[frame.basePointer+start : frame.basePointer+start+count])
+
+		case code.OpClearGlobals:
+			start := int(code.ReadUint16(ins[ip+1:]))
+			count := int(code.ReadUint16(ins[ip+3:]))
+			vm.currentFrame().ip += 4
+
+			vm.globals.Clear(start, count)
+
+		case code.OpGetGlobalCell:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err := vm.push(vm.globals.Cell(int(globalIndex)))
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+
+			// Box the local the first time it is captured; from then on the frame and
+			// every closure capturing it go through the same cell.
+			slot := &vm.stack[frame.basePointer+int(localIndex)]
+			cell, ok := (*slot).(*object.Cell)
+			if !ok {
+				cell = object.NewCell(*slot)
+				*slot = cell
+			}
+
+			err := vm.push(cell)
 			if err != nil {
 				return err
 			}
@@ -174,12 +367,151 @@ func (vm *VM) Run() error {
 			freeIndex := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip += 1
 
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(currentClosure.Free[freeIndex].Get())
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
 			currentClosure := vm.currentFrame().cl
 			err := vm.push(currentClosure.Free[freeIndex])
 			if err != nil {
 				return err
 			}
 
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			currentClosure.Free[freeIndex].Set(vm.pop())
+
+		case code.OpIter:
+			collection := vm.pop()
+			iterator, ok := object.NewIterator(collection)
+			if !ok {
+				return fmt.Errorf("cannot iterate over %s", collection.Type())
+			}
+
+			err := vm.push(iterator)
+			if err != nil {
+				return err
+			}
+
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			iterator := vm.pop().(*object.Iterator)
+			value, ok := iterator.Next()
+			if !ok {
+				vm.currentFrame().ip = pos - 1
+				continue
+			}
+			if exception, ok := value.(*object.Exception); ok {
+				return &thrownError{value: exception.Value}
+			}
+
+			err := vm.push(value)
+			if err != nil {
+				return err
+			}
+
+		case code.OpMatchValue, code.OpCheckValue:
+			literal := vm.pop()
+			value := vm.pop()
+
+			err := vm.patternResult(op, matchValue(value, literal))
+			if err != nil {
+				return err
+			}
+
+		case code.OpMatchArray, code.OpCheckArray:
+			length := int(code.ReadUint16(ins[ip+1:]))
+			hasRest := code.ReadUint8(ins[ip+3:]) == 1
+			vm.currentFrame().ip += 3
+
+			err := vm.patternResult(op, matchArray(vm.pop(), length, hasRest))
+			if err != nil {
+				return err
+			}
+
+		case code.OpMatchHash, code.OpCheckHash:
+			err := vm.patternResult(op, matchHash(vm.pop()))
+			if err != nil {
+				return err
+			}
+
+		case code.OpMatchKey, code.OpCheckKey:
+			key := vm.pop()
+			hash := vm.pop().(*object.Hash)
+
+			err := vm.patternResult(op, matchKey(hash, key))
+			if err != nil {
+				return err
+			}
+
+		case code.OpEnumPattern:
+			tag := vm.constants[code.ReadUint16(ins[ip+1:])].(*object.String).Value
+			numFields := int(code.ReadUint8(ins[ip+3:]))
+			vm.currentFrame().ip += 3
+
+			_, err := object.LookupVariant(vm.pop(), tag, numFields)
+			if err != nil {
+				return err
+			}
+
+		case code.OpExhaustive:
+			covered := vm.constants[code.ReadUint16(ins[ip+1:])].(*object.Array)
+			vm.currentFrame().ip += 2
+
+			enum := vm.pop().(*object.Enum)
+			if _, ok := vm.pop().(*object.Variant); ok {
+				err := checkExhaustive(enum, covered)
+				if err != nil {
+					return err
+				}
+			}
+
+		case code.OpMatchVariant, code.OpCheckVariant:
+			tag := vm.constants[code.ReadUint16(ins[ip+1:])].(*object.String).Value
+			numFields := int(code.ReadUint8(ins[ip+3:]))
+			vm.currentFrame().ip += 3
+
+			kind, err := object.LookupVariant(vm.pop(), tag, numFields)
+			if err != nil {
+				return err
+			}
+
+			err = vm.patternResult(op, matchVariant(vm.pop(), kind))
+			if err != nil {
+				return err
+			}
+
+		case code.OpPayload:
+			index := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			err := vm.push(vm.pop().(*object.Variant).Payload[index])
+			if err != nil {
+				return err
+			}
+
+		case code.OpRest:
+			start := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			elements := vm.pop().(*object.Array).Snapshot()
+
+			err := vm.push(&object.Array{Elements: elements[start:]})
+			if err != nil {
+				return err
+			}
+
 		case code.OpCurrentClosure:
 			currentClosure := vm.currentFrame().cl
 			err := vm.push(currentClosure)
@@ -199,6 +531,18 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpConcat:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			str := vm.buildString(vm.sp-numParts, vm.sp)
+			vm.sp = vm.sp - numParts
+
+			err := vm.push(str)
+			if err != nil {
+				return err
+			}
+
 		case code.OpHash:
 			numElements := int(code.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip += 2
@@ -223,6 +567,96 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeSetIndex(left, index, value)
+			if err != nil {
+				return err
+			}
+
+		case code.OpStruct:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			declared := vm.constants[constIndex].(*object.Struct)
+			err := vm.push(&object.Struct{Name: declared.Name, Fields: declared.Fields, Methods: map[string]object.Object{}})
+			if err != nil {
+				return err
+			}
+
+		case code.OpImpl:
+			numMethods := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			err := vm.executeImpl(numMethods)
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetMember:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			name := vm.constants[constIndex].(*object.String).Value
+			err := vm.executeGetMember(vm.pop(), name)
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetMember:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			name := vm.constants[constIndex].(*object.String).Value
+			value := vm.pop()
+			err := vm.executeSetMember(vm.pop(), name, value)
+			if err != nil {
+				return err
+			}
+
+		case code.OpTry:
+			target := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			vm.handlers = append(vm.handlers, handler{target: target, framesIndex: vm.framesIndex, sp: vm.sp})
+
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case code.OpThrow:
+			return &thrownError{value: vm.pop()}
+
+		case code.OpImport:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err := vm.executeImport(int(constIndex))
+			if err != nil {
+				return err
+			}
+
+		case code.OpModule:
+			numExports := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			err := vm.executeModule(numExports)
+			if err != nil {
+				return err
+			}
+
+		case code.OpSlice:
+			high := vm.pop()
+			low := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeSliceExpression(left, low, high)
+			if err != nil {
+				return err
+			}
+
 		case code.OpCall:
 			numArgs := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip += 1
@@ -231,6 +665,57 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpTailCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err := vm.executeTailCall(int(numArgs))
+			if err != nil {
+				return err
+			}
+
+		case code.OpCallSpread, code.OpTailCallSpread:
+			args := vm.pop().(*object.Array).Snapshot()
+			for _, arg := range args {
+				err := vm.push(arg)
+				if err != nil {
+					return err
+				}
+			}
+
+			var err error
+			if op == code.OpTailCallSpread {
+				err = vm.executeTailCall(len(args))
+			} else {
+				err = vm.executeCall(len(args))
+			}
+			if err != nil {
+				return err
+			}
+
+		case code.OpSpread:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array, err := vm.spreadArrays(vm.sp-numParts, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numParts
+
+			err = vm.push(array)
+			if err != nil {
+				return err
+			}
+
+		case code.OpJumpIfArg:
+			index := int(code.ReadUint8(ins[ip+1:]))
+			pos := int(code.ReadUint16(ins[ip+2:]))
+			vm.currentFrame().ip += 3
+
+			if index < vm.currentFrame().numArgs {
+				vm.currentFrame().ip = pos - 1
+			}
+
 		case code.OpReturnValue:
 			returnValue := vm.pop()
 			frame := vm.popFrame()
@@ -250,6 +735,12 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpYield:
+			// Leave the run loop with the frames and the stack as they are, so the generator
+			// continues after the yield when it is resumed.
+			vm.yielded = vm.pop()
+			return nil
+
 			// Bottom of switch
 		}
 	}
@@ -264,8 +755,10 @@ func (vm *VM) LastPoppedStackElem() object.Object {
 
 // push adds an object to the stack at the current stack pointer and then increments the pointer.
 func (vm *VM) push(o object.Object) error {
-	if vm.sp >= StackSize {
-		return fmt.Errorf("stack overflow")
+	if vm.sp >= len(vm.stack) {
+		if err := vm.reserve(vm.sp + 1); err != nil {
+			return err
+		}
 	}
 
 	vm.stack[vm.sp] = o
@@ -293,6 +786,26 @@ func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
 	return &object.Array{Elements: elements}
 }
 
+func (vm *VM) spreadArrays(startIndex, endIndex int) (object.Object, error) {
+	elements := []object.Object{}
+	for i := startIndex; i < endIndex; i++ {
+		array, ok := vm.stack[i].(*object.Array)
+		if !ok {
+			return nil, fmt.Errorf("cannot spread %s", vm.stack[i].Type())
+		}
+		elements = append(elements, array.Snapshot()...)
+	}
+	return &object.Array{Elements: elements}, nil
+}
+
+func (vm *VM) buildString(startIndex, endIndex int) object.Object {
+	var out strings.Builder
+	for i := startIndex; i < endIndex; i++ {
+		out.WriteString(vm.stack[i].Inspect())
+	}
+	return &object.String{Value: out.String()}
+}
+
 func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
 	hashedPairs := make(map[object.HashKey]object.HashPair)
 
@@ -323,6 +836,8 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	switch {
 	case leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ:
 		return vm.executeBinaryIntegerOperation(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return vm.executeBinaryFloatOperation(op, left, right)
 	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
 		return vm.executeBinaryStringOperation(op, left, right)
 	}
@@ -344,7 +859,15 @@ func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.O
 	case code.OpMul:
 		result = leftValue * rightValue
 	case code.OpDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
 		result = leftValue / rightValue
+	case code.OpMod:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
+		result = leftValue % rightValue
 	default:
 		return fmt.Errorf("unknown integer operator: %d", op)
 	}
@@ -352,6 +875,30 @@ func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.O
 	return vm.push(&object.Integer{Value: result})
 }
 
+func (vm *VM) executeBinaryFloatOperation(op code.Opcode, left, right object.Object) error {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+
+	var result float64
+
+	switch op {
+	case code.OpAdd:
+		result = leftValue + rightValue
+	case code.OpSub:
+		result = leftValue - rightValue
+	case code.OpMul:
+		result = leftValue * rightValue
+	case code.OpDiv:
+		result = leftValue / rightValue
+	case code.OpMod:
+		result = math.Mod(leftValue, rightValue)
+	default:
+		return fmt.Errorf("unknown float operator: %d", op)
+	}
+
+	return vm.push(&object.Float{Value: result})
+}
+
 func (vm *VM) executeBinaryStringOperation(
 	op code.Opcode,
 	left, right object.Object,
@@ -374,6 +921,10 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 		return vm.executeIntegerComparison(op, left, right)
 	}
 
+	if isNumber(left) && isNumber(right) {
+		return vm.executeFloatComparison(op, left, right)
+	}
+
 	switch op {
 	case code.OpEqual:
 		return vm.push(nativeBoolToBooleanObject(right == left))
@@ -396,6 +947,34 @@ func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Object
 		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
 	case code.OpGreaterThan:
 		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpLessThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeFloatComparison(op code.Opcode, left, right object.Object) error {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpLessThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
 	default:
 		return fmt.Errorf("unknown operator: %d", op)
 	}
@@ -419,34 +998,98 @@ func (vm *VM) executeBangOperator() error {
 func (vm *VM) executeMinusOperator() error {
 	operand := vm.pop()
 
-	if operand.Type() != object.INTEGER_OBJ {
+	switch operand := operand.(type) {
+	case *object.Integer:
+		return vm.push(&object.Integer{Value: -operand.Value})
+	case *object.Float:
+		return vm.push(&object.Float{Value: -operand.Value})
+	default:
 		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
 	}
-
-	value := operand.(*object.Integer).Value
-	return vm.push(&object.Integer{Value: -value})
 }
 
 func (vm *VM) executeIndexExpression(left, index object.Object) error {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeStringIndex(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return vm.executeHashIndex(left, index)
+	case left.Type() == object.ERROR_OBJ:
+		return vm.executeErrorIndex(left, index)
+	case left.Type() == object.MODULE_OBJ:
+		return vm.executeModuleIndex(left, index)
 	default:
 		return fmt.Errorf("index operator not supported: %s", left.Type())
 	}
 }
 
 func (vm *VM) executeArrayIndex(array, index object.Object) error {
-	arrayObject := array.(*object.Array)
+	element, ok := array.(*object.Array).At(index.(*object.Integer).Value)
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(element)
+}
+
+func (vm *VM) executeStringIndex(str, index object.Object) error {
+	runes := []rune(str.(*object.String).Value)
 	i := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-	if i < 0 || i > max {
+	length := int64(len(runes))
+	if i < 0 {
+		i += length
+	}
+	if i < 0 || i >= length {
 		return vm.push(Null)
 	}
 
-	return vm.push(arrayObject.Elements[i])
+	return vm.push(&object.String{Value: string(runes[i])})
+}
+
+func (vm *VM) executeSetIndex(left, index, value object.Object) error {
+	switch left := left.(type) {
+	case *object.Array:
+		integer, ok := index.(*object.Integer)
+		if !ok {
+			return fmt.Errorf("array index must be INTEGER, got %s", index.Type())
+		}
+		if !left.SetAt(integer.Value, value) {
+			return fmt.Errorf("index out of range: %d", integer.Value)
+		}
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return fmt.Errorf("unusable as hash key: %s", index.Type())
+		}
+		left.Set(key.HashKey(), object.HashPair{Key: index, Value: value})
+	default:
+		return fmt.Errorf("index assignment not supported: %s", left.Type())
+	}
+
+	return vm.push(value)
+}
+
+func (vm *VM) executeSliceExpression(left, low, high object.Object) error {
+	switch left := left.(type) {
+	case *object.Array:
+		elements := left.Snapshot()
+		lo, hi, err := object.SliceBounds(len(elements), low, high)
+		if err != nil {
+			return err
+		}
+		return vm.push(&object.Array{Elements: elements[lo:hi]})
+	case *object.String:
+		runes := []rune(left.Value)
+		lo, hi, err := object.SliceBounds(len(runes), low, high)
+		if err != nil {
+			return err
+		}
+		return vm.push(&object.String{Value: string(runes[lo:hi])})
+	default:
+		return fmt.Errorf("slice operator not supported: %s", left.Type())
+	}
 }
 
 func (vm *VM) executeHashIndex(hash, index object.Object) error {
@@ -456,7 +1099,7 @@ func (vm *VM) executeHashIndex(hash, index object.Object) error {
 		return fmt.Errorf("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key.HashKey())
 	if !ok {
 		return vm.push(Null)
 	}
@@ -464,6 +1107,173 @@ func (vm *VM) executeHashIndex(hash, index object.Object) error {
 	return vm.push(pair.Value)
 }
 
+func (vm *VM) executeImpl(numMethods int) error {
+	base := vm.sp - 2*numMethods - 1
+	st, ok := vm.stack[base].(*object.Struct)
+	if !ok {
+		return fmt.Errorf("impl target must be STRUCT, got %s", vm.stack[base].Type())
+	}
+
+	for i := base + 1; i < vm.sp; i += 2 {
+		st.Methods[vm.stack[i].(*object.String).Value] = vm.stack[i+1]
+	}
+	vm.sp = base
+
+	return nil
+}
+
+func (vm *VM) executeGetMember(obj object.Object, name string) error {
+	switch obj := obj.(type) {
+	case *object.Instance:
+		if index := obj.Struct.FieldIndex(name); index >= 0 {
+			return vm.push(obj.Field(index))
+		}
+		if method, ok := obj.Struct.Methods[name]; ok {
+			return vm.push(&object.BoundMethod{Receiver: obj, Method: method})
+		}
+		return fmt.Errorf("%s has no field or method %s", obj.Struct.Name, name)
+	case *object.Enum:
+		variant := obj.Variant(name)
+		if variant == nil {
+			return fmt.Errorf("%s has no variant %s", obj.Name, name)
+		}
+		if variant.Value != nil {
+			return vm.push(variant.Value)
+		}
+		return vm.push(variant)
+	case *object.Variant:
+		if index := obj.Kind.FieldIndex(name); index >= 0 {
+			return vm.push(obj.Payload[index])
+		}
+		return fmt.Errorf("%s has no field %s", obj.Kind.Name(), name)
+	case *object.Module:
+		return vm.executeModuleIndex(obj, &object.String{Value: name})
+	case *object.Error:
+		return vm.executeErrorIndex(obj, &object.String{Value: name})
+	default:
+		return fmt.Errorf("member access not supported: %s", obj.Type())
+	}
+}
+
+func (vm *VM) executeSetMember(obj object.Object, name string, value object.Object) error {
+	instance, ok := obj.(*object.Instance)
+	if !ok {
+		return fmt.Errorf("member assignment not supported: %s", obj.Type())
+	}
+
+	index := instance.Struct.FieldIndex(name)
+	if index < 0 {
+		return fmt.Errorf("%s has no field %s", instance.Struct.Name, name)
+	}
+	instance.SetField(index, value)
+
+	return vm.push(value)
+}
+
+func (vm *VM) executeModuleIndex(mod, index object.Object) error {
+	moduleObject := mod.(*object.Module)
+	name, ok := index.(*object.String)
+	if !ok {
+		return fmt.Errorf("module member must be STRING, got %s", index.Type())
+	}
+
+	val, ok := moduleObject.Exports[name.Value]
+	if !ok {
+		return fmt.Errorf("module %s does not export %s", moduleObject.Path, name.Value)
+	}
+
+	return vm.push(val)
+}
+
+func (vm *VM) executeErrorIndex(err, index object.Object) error {
+	errorObject := err.(*object.Error)
+	field, ok := index.(*object.String)
+	if !ok {
+		return vm.push(Null)
+	}
+
+	switch field.Value {
+	case "message":
+		return vm.push(&object.String{Value: errorObject.Message})
+	case "data":
+		if errorObject.Data == nil {
+			return vm.push(Null)
+		}
+		return vm.push(errorObject.Data)
+	default:
+		return vm.push(Null)
+	}
+}
+
+// patternResult finishes a pattern test. The match opcodes push whether the test passed,
+// while the check opcodes used for destructuring fail with the reason it did not.
+func (vm *VM) patternResult(op code.Opcode, mismatch error) error {
+	switch op {
+	case code.OpCheckValue, code.OpCheckArray, code.OpCheckHash, code.OpCheckKey, code.OpCheckVariant:
+		return mismatch
+	}
+	return vm.push(nativeBoolToBooleanObject(mismatch == nil))
+}
+
+func matchValue(value, literal object.Object) error {
+	if !object.Equals(value, literal) {
+		return fmt.Errorf("%s does not match %s", value.Inspect(), literal.Inspect())
+	}
+	return nil
+}
+
+func matchArray(value object.Object, length int, hasRest bool) error {
+	array, ok := value.(*object.Array)
+	if !ok {
+		return fmt.Errorf("cannot destructure %s as an array", value.Type())
+	}
+
+	n := array.Len()
+	if hasRest && n < length {
+		return fmt.Errorf("cannot destructure an array of length %d, expected length of at least %d", n, length)
+	}
+	if !hasRest && n != length {
+		return fmt.Errorf("cannot destructure an array of length %d, expected length %d", n, length)
+	}
+	return nil
+}
+
+func matchVariant(value object.Object, kind *object.VariantType) error {
+	if variant, ok := value.(*object.Variant); !ok || variant.Kind != kind {
+		return fmt.Errorf("%s does not match %s", value.Inspect(), kind.Name())
+	}
+	return nil
+}
+
+func checkExhaustive(enum *object.Enum, covered *object.Array) error {
+	tags := make([]string, len(covered.Elements))
+	for i, tag := range covered.Elements {
+		tags[i] = tag.(*object.String).Value
+	}
+
+	if missing := enum.MissingVariants(tags); len(missing) > 0 {
+		return fmt.Errorf("non-exhaustive match on %s: missing %s", enum.Name, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func matchHash(value object.Object) error {
+	if _, ok := value.(*object.Hash); !ok {
+		return fmt.Errorf("cannot destructure %s as a hash", value.Type())
+	}
+	return nil
+}
+
+func matchKey(hash *object.Hash, key object.Object) error {
+	hashKey, ok := key.(object.Hashable)
+	if ok {
+		if _, ok := hash.Get(hashKey.HashKey()); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("missing hash key: %s", key.Inspect())
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return True
@@ -483,13 +1293,43 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
 func (v *VM) currentFrame() *Frame {
 	return v.frames[v.framesIndex-1]
 }
 
-func (v *VM) pushFrame(f *Frame) {
+func (v *VM) pushFrame(f *Frame) error {
+	if v.framesIndex >= len(v.frames) {
+		if v.framesIndex >= MaxFrames {
+			return fmt.Errorf("stack overflow")
+		}
+		v.frames = append(v.frames, make([]*Frame, min(len(v.frames), MaxFrames-len(v.frames)))...)
+	}
 	v.frames[v.framesIndex] = f
 	v.framesIndex++
+	return nil
+}
+
+// reserve makes room for size values on the stack. The stack of a VM running a generator or
+// a task starts small and grows as needed, up to StackSize.
+func (v *VM) reserve(size int) error {
+	if size > StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+	if size > len(v.stack) {
+		v.stack = append(v.stack, make([]object.Object, min(max(size, 2*len(v.stack)), StackSize)-len(v.stack))...)
+	}
+	return nil
 }
 
 func (v *VM) popFrame() *Frame {
@@ -498,22 +1338,151 @@ func (v *VM) popFrame() *Frame {
 }
 
 func (v *VM) callClosure(cl *object.Closure, numArgs int) error {
-	if numArgs != cl.Fn.NumParameters {
-		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
+	fn := cl.Fn
+	required := fn.NumParameters - fn.NumDefaults
+	if numArgs < required || (!fn.Variadic && numArgs > fn.NumParameters) {
+		return arityError(fn, numArgs)
 	}
 
-	frame := NewFrame(cl, v.sp-numArgs)
-	v.pushFrame(frame)
+	basePointer := v.sp - numArgs
+	if err := v.reserve(basePointer + fn.NumLocals); err != nil {
+		return err
+	}
 
-	v.sp = frame.basePointer + cl.Fn.NumLocals
+	var rest object.Object
+	if fn.Variadic {
+		// The arguments past the declared parameters are packed into the rest parameter,
+		// which is the local right after them.
+		extra := max(numArgs-fn.NumParameters, 0)
+		rest = v.buildArray(v.sp-extra, v.sp)
+		numArgs -= extra
+	}
+	// The other locals still hold what an earlier call left there, which may be a cell a
+	// closure captured; a new value must not be written through it.
+	clear(v.stack[basePointer+numArgs : basePointer+fn.NumLocals])
+	if rest != nil {
+		v.stack[basePointer+fn.NumParameters] = rest
+	}
+
+	if fn.Generator {
+		return v.startGenerator(cl, basePointer, numArgs)
+	}
+
+	frame := NewFrame(cl, basePointer)
+	frame.numArgs = numArgs
+	if err := v.pushFrame(frame); err != nil {
+		return err
+	}
+
+	v.sp = frame.basePointer + fn.NumLocals
 	return nil
 }
 
+// startGenerator calls a generator function. Rather than in a frame of the calling VM, the
+// function runs in a VM of its own, whose frames and stack stay as they are while the
+// generator is suspended between two values.
+func (v *VM) startGenerator(cl *object.Closure, basePointer, numArgs int) error {
+	g := v.fork()
+	if err := g.reserve(1 + cl.Fn.NumLocals); err != nil {
+		return err
+	}
+	g.stack[0] = cl
+	copy(g.stack[1:], v.stack[basePointer:basePointer+cl.Fn.NumLocals])
+
+	frame := NewFrame(cl, 1)
+	frame.numArgs = numArgs
+	if err := g.pushFrame(frame); err != nil {
+		return err
+	}
+	g.sp = frame.basePointer + cl.Fn.NumLocals
+
+	v.sp = basePointer - 1
+	return v.push(&object.Generator{Resume: g.resume})
+}
+
+// resume runs the function of a generator VM up to its next yield.
+func (v *VM) resume() (object.Object, bool) {
+	v.yielded = nil
+
+	if err := v.Run(); err != nil {
+		return exception(err), true
+	}
+	if v.yielded == nil {
+		return nil, false
+	}
+	return v.yielded, true
+}
+
+// callFunction calls a function for a builtin calling functions, such as spawn, in a VM of its
+// own running on the goroutine of the builtin or of the task it started.
+func (v *VM) callFunction(fn object.Object, args []object.Object) object.Object {
+	t := v.fork()
+	err := t.reserve(1 + len(args))
+	if err != nil {
+		return exception(err)
+	}
+	t.stack[0] = fn
+	copy(t.stack[1:], args)
+	t.sp = 1 + len(args)
+
+	err = t.executeCall(len(args))
+	if err == nil {
+		err = t.Run()
+	}
+	if err != nil {
+		return exception(err)
+	}
+	return t.stack[t.sp-1]
+}
+
+// fork creates a VM sharing the constants, globals and modules, for calling a function apart
+// from the frames of this VM. Its bottom frame has no instructions, so the run loop ends when
+// the function returns to it. Its stack and frames start small and grow as needed, as there
+// may be many generators suspended at once.
+func (v *VM) fork() *VM {
+	f := &VM{
+		constants:   v.constants,
+		stack:       make([]object.Object, forkStackSize),
+		globals:     v.globals,
+		frames:      make([]*Frame, forkFrames),
+		framesIndex: 1,
+		modules:     v.modules,
+	}
+	f.frames[0] = NewFrame(&object.Closure{Fn: &object.CompiledFunction{}}, 0)
+	return f
+}
+
+// exception turns an error stopping the run loop into the exception it stands for.
+func exception(err error) *object.Exception {
+	if thrown, ok := err.(*thrownError); ok {
+		return &object.Exception{Value: thrown.value}
+	}
+	return &object.Exception{Value: &object.Error{Message: err.Error()}}
+}
+
+func arityError(fn *object.CompiledFunction, got int) error {
+	required := fn.NumParameters - fn.NumDefaults
+	switch {
+	case fn.Variadic:
+		return fmt.Errorf("wrong number of arguments: want at least %d, got=%d", required, got)
+	case fn.NumDefaults > 0:
+		return fmt.Errorf("wrong number of arguments: want between %d and %d, got=%d", required, fn.NumParameters, got)
+	default:
+		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", fn.NumParameters, got)
+	}
+}
+
 func (v *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	args := v.stack[v.sp-numArgs : v.sp]
-	result := builtin.Fn(args...)
+
+	result := builtin.Apply(v.callFunction, args...)
 	v.sp = v.sp - numArgs - 1
 
+	// A builtin resuming a generator passes on what the generator threw.
+	if exception, ok := result.(*object.Exception); ok {
+		return &thrownError{value: exception.Value}
+	}
+
 	if result != nil {
 		v.push(result)
 	} else {
@@ -530,11 +1499,121 @@ func (v *VM) executeCall(numArgs int) error {
 		return v.callClosure(callee, numArgs)
 	case *object.Builtin:
 		return v.callBuiltin(callee, numArgs)
+	case *object.Struct:
+		return v.callStruct(callee, numArgs)
+	case *object.BoundMethod:
+		return v.callBoundMethod(callee, numArgs)
+	case *object.VariantType:
+		return v.callVariant(callee, numArgs)
 	default:
 		return fmt.Errorf("calling non-function and non-built-in")
 	}
 }
 
+// executeTailCall calls a function whose result the current function returns. A closure
+// takes the place of the current frame, so that a chain of tail calls runs in a constant
+// number of frames; any other function is called as for OpCall, and the OpReturnValue
+// following the call returns its result.
+func (v *VM) executeTailCall(numArgs int) error {
+	if method, ok := v.stack[v.sp-1-numArgs].(*object.BoundMethod); ok {
+		err := v.unbindMethod(method, numArgs)
+		if err != nil {
+			return err
+		}
+		numArgs++
+	}
+
+	cl, ok := v.stack[v.sp-1-numArgs].(*object.Closure)
+	if !ok || cl.Fn.Generator {
+		return v.executeCall(numArgs)
+	}
+
+	// Move the closure and the arguments down to where the current function and its
+	// arguments are, and call it from there.
+	frame := v.popFrame()
+	copy(v.stack[frame.basePointer-1:], v.stack[v.sp-1-numArgs:v.sp])
+	v.sp = frame.basePointer + numArgs
+
+	return v.callClosure(cl, numArgs)
+}
+
+// executeImport pushes an imported module. The first import of a module calls the function
+// running its top-level code, which ends with OpModule and returns the module.
+func (v *VM) executeImport(constIndex int) error {
+	fn := v.constants[constIndex].(*object.CompiledFunction)
+	if mod, ok := v.modules[fn.Module]; ok {
+		return v.push(mod)
+	}
+
+	cl := &object.Closure{Fn: fn}
+	err := v.push(cl)
+	if err != nil {
+		return err
+	}
+	return v.callClosure(cl, 0)
+}
+
+func (v *VM) executeModule(numExports int) error {
+	mod := &object.Module{Path: v.currentFrame().cl.Fn.Module, Exports: map[string]object.Object{}}
+	for i := v.sp - 2*numExports; i < v.sp; i += 2 {
+		mod.Exports[v.stack[i].(*object.String).Value] = v.stack[i+1]
+	}
+	v.sp -= 2 * numExports
+	v.modules[mod.Path] = mod
+
+	return v.push(mod)
+}
+
+func (v *VM) callStruct(st *object.Struct, numArgs int) error {
+	if numArgs != len(st.Fields) {
+		return fmt.Errorf("wrong number of arguments to %s: want=%d, got=%d", st.Name, len(st.Fields), numArgs)
+	}
+
+	fields := make([]object.Object, numArgs)
+	copy(fields, v.stack[v.sp-numArgs:v.sp])
+	v.sp = v.sp - numArgs - 1
+
+	return v.push(&object.Instance{Struct: st, Fields: fields})
+}
+
+func (v *VM) callVariant(kind *object.VariantType, numArgs int) error {
+	if numArgs != len(kind.Fields) {
+		return fmt.Errorf("wrong number of arguments to %s: want=%d, got=%d", kind.Name(), len(kind.Fields), numArgs)
+	}
+
+	payload := make([]object.Object, numArgs)
+	copy(payload, v.stack[v.sp-numArgs:v.sp])
+	v.sp = v.sp - numArgs - 1
+
+	return v.push(&object.Variant{Kind: kind, Payload: payload})
+}
+
+// callBoundMethod calls the method of a bound method with the receiver inserted before the
+// arguments on the stack.
+func (v *VM) callBoundMethod(method *object.BoundMethod, numArgs int) error {
+	err := v.unbindMethod(method, numArgs)
+	if err != nil {
+		return err
+	}
+	return v.executeCall(numArgs + 1)
+}
+
+// unbindMethod replaces a bound method about to be called by its method, and passes the
+// receiver as the first argument.
+func (v *VM) unbindMethod(method *object.BoundMethod, numArgs int) error {
+	if err := v.reserve(v.sp + 1); err != nil {
+		return err
+	}
+
+	callee := v.sp - 1 - numArgs
+	copy(v.stack[callee+2:v.sp+1], v.stack[callee+1:v.sp])
+	v.stack[callee] = method.Method
+	v.stack[callee+1] = method.Receiver
+	v.sp++
+
+	return nil
+}
+
 func (v *VM) pushClosure(constIndex int, numFree int) error {
 	constant := v.constants[constIndex]
 	function, ok := constant.(*object.CompiledFunction)
@@ -542,9 +1621,14 @@ func (v *VM) pushClosure(constIndex int, numFree int) error {
 		return fmt.Errorf("not a function: %+v", constant)
 	}
 
-	free := make([]object.Object, numFree)
+	free := make([]*object.Cell, numFree)
 	for i := range numFree {
-		free[i] = v.stack[v.sp-numFree+i]
+		value := v.stack[v.sp-numFree+i]
+		if cell, ok := value.(*object.Cell); ok {
+			free[i] = cell
+		} else {
+			free[i] = object.NewCell(value)
+		}
 	}
 	v.sp = v.sp - numFree
 	closure := &object.Closure{Fn: function, Free: free}
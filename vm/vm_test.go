@@ -4,9 +4,14 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/internal/hosttest"
 	"monkey/lexer"
+	"monkey/module"
 	"monkey/object"
 	"monkey/parser"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -29,6 +34,27 @@ func TestIntegerArithmetic(t *testing.T) {
 		{"-10", -10},
 		{"-50 + 100 + -50", 0},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 + 10 % 4", 4},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"2.5", 2.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3.0},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"10 - 2.5", 7.5},
+		{"7 / 2.0", 3.5},
+		{"1.5 < 2", true},
+		{"2 > 2.5", false},
+		{"1 == 1.0", true},
+		{"0.1 + 0.2 != 0.3", true},
 	}
 
 	runVmTests(t, tests)
@@ -67,6 +93,41 @@ func TestBooleanExpressions(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestComparisonOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"1 < 2", true},
+		{"2 < 1", false},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"2.5 >= 2", true},
+		{"1.5 <= 1", false},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 2", 2},
+		{"false && 2", false},
+		{"1 || 2", 1},
+		{"if (false) { 1 } || 3", 3},
+		{"1 < 2 && 2 < 3", true},
+		{"false && 1 / 0", false},
+		{"true || 1 / 0", true},
+		{"let f = fn(a, b) { a && b || 10 }; f(false, 1)", 10},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestConditionals(t *testing.T) {
 	tests := []vmTestCase{
 		{"if (true) { 10 }", 10},
@@ -84,6 +145,123 @@ func TestConditionals(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestDefaultAndVariadicParameters(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn(a, b = 10) { a + b }; f(1) + f(1, 2)", 14},
+		{"let f = fn(a, b = a * 2, c = b + 1) { a + b + c }; f(1)", 6},
+		{"let f = fn(a, ...rest) { a + len(rest) }; f(1) + f(1, 2, 3)", 4},
+		{"let sum = fn(...xs) { let s = 0; for (x in xs) { s += x; } s }; sum(1, 2, 3, 4)", 10},
+		{"let f = fn(...r) { r }; len(f())", 0},
+		{"let args = [1, 2, 3]; let f = fn(a, b, c) { a * 100 + b * 10 + c }; f(...args)", 123},
+		{"let xs = [1, 2]; let ys = [0, ...xs, 3, ...xs]; len(ys) * 100 + ys[3] * 10 + ys[4]", 631},
+		{"let f = fn(a, b = 2, ...rest) { a + b + len(rest) }; f(...[1]) + f(1, 5, 7, 8)", 11},
+		{"let count = fn(...args) { len(args) }; let g = fn(...args) { count(0, ...args) }; g(1, 2)", 3},
+		{"let make = fn(n = 5) { fn(x = n) { x } }; make()() + make(1)(2)", 7},
+		{"let f = fn([a, b] = [1, 2]) { a + b }; f() + f([3, 4])", 10},
+		{"len(...[[1, 2, 3]])", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []vmTestCase{
+		{"let [a, b] = [1, 2]; a + b", 3},
+		{"let [a, ...rest] = [1, 2, 3]; a + len(rest)", 3},
+		{"let [_, second] = [1, 2]; second", 2},
+		{"let [[a, b], c] = [[1, 2], 3]; a + b + c", 6},
+		{`let {name, age} = {"name": "Ann", "age": 30}; age`, 30},
+		{`let {"name": n, "tags": [first, ...]} = {"name": "x", "tags": [5, 6]}; first`, 5},
+		{`let f = fn([x, y], {z}) { x * y + z }; f([2, 3], {"z": 4})`, 10},
+		{`let f = fn() { let [a, b] = [1, 2]; let {c} = {"c": 3}; a + b + c }; f()`, 6},
+		{"let make = fn([a, b]) { fn() { a + b } }; make([1, 2])()", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestDestructuringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = [1];", "cannot destructure an array of length 1, expected length 2"},
+		{"let [a, b, ...rest] = [1];", "cannot destructure an array of length 1, expected length of at least 2"},
+		{`let {name} = {"age": 1};`, "missing hash key: name"},
+		{"let [a] = 5;", "cannot destructure INTEGER as an array"},
+		{"let {a} = [1];", "cannot destructure ARRAY as a hash"},
+		{"let f = fn([a]) { a }; f(1)", "cannot destructure INTEGER as an array"},
+		{"let [1, x] = [2, 3];", "2 does not match 1"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	describe := `
+let describe = fn(x) {
+	match (x) {
+		0 => "zero",
+		-1 => "minus one",
+		[n] if n > 100 => "big",
+		1.5 => "one and a half",
+		"hi" => "greeting",
+		true => "yes",
+		[] => "empty",
+		[a] => "one: ${a}",
+		[first, ...rest] => "first ${first}, ${len(rest)} more",
+		{"type": "circle", "r": r} => "circle ${r}",
+		{"type": t} => "shape ${t}",
+		_ => "other"
+	}
+};
+	`
+
+	tests := []vmTestCase{
+		{describe + "describe(0)", "zero"},
+		{describe + "describe(-1)", "minus one"},
+		{describe + "describe([500])", "big"},
+		{describe + "describe(1.5)", "one and a half"},
+		{describe + `describe("hi")`, "greeting"},
+		{describe + "describe(true)", "yes"},
+		{describe + "describe([])", "empty"},
+		{describe + "describe([7])", "one: 7"},
+		{describe + "describe([1, 2, 3])", "first 1, 2 more"},
+		{describe + `describe({"type": "circle", "r": 2})`, "circle 2"},
+		{describe + `describe({"type": "square", "size": 3})`, "shape square"},
+		{describe + "describe(false)", "other"},
+		{describe + "describe(7)", "other"},
+		{describe + `describe("1")`, "other"},
+		{"match (2.0) { 2 => 20, _ => 0 }", 20},
+		{"match ([1, [2, 3]]) { [a, [b, c]] => a + b + c }", 6},
+		{"match (3) { x => { let y = x * 2; y + 1 } }", 7},
+		{"match ([1, 2]) { [a] => a, [a, b, ...] => a + b }", 3},
+		{"match (5) { 1 => 10 }", Null},
+		{"let f = fn(x) { if (x < 0) { 1 } else if (x == 0) { 2 } else { 3 } }; f(-5) * 100 + f(0) * 10 + f(5)", 123},
+		{"let f = fn(xs) { match (xs) { [x, ...rest] => x + f(rest), [] => 0 } }; f([1, 2, 3, 4])", 10},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestGlobalLetStatements(t *testing.T) {
 	tests := []vmTestCase{
 		{"let one = 1; one", 1},
@@ -99,6 +277,9 @@ func TestStringExpressions(t *testing.T) {
 		{`"monkey"`, "monkey"},
 		{`"mon" + "key"`, "monkey"},
 		{`"mon" + "key" + "banana"`, "monkeybanana"},
+		{`"${1}"`, "1"},
+		{`let user = {"name": "Ana"}; let count = 3; "Hello ${user["name"]}, you have ${count} items"`, "Hello Ana, you have 3 items"},
+		{`"${[1, 2.5]} ${true} ${if (false) { 1 }} ${"${"nested"}"}"`, "[1, 2.5] true null nested"},
 	}
 
 	runVmTests(t, tests)
@@ -145,16 +326,276 @@ func TestIndexExpressions(t *testing.T) {
 		{"[[1, 1, 1]][0][0]", 1},
 		{"[][0]", Null},
 		{"[1, 2, 3][99]", Null},
-		{"[1][-1]", Null},
+		{"[1][-1]", 1},
+		{"[1, 2, 3][-2]", 2},
+		{"[1, 2, 3][-4]", Null},
 		{"{1: 1, 2: 2}[1]", 1},
 		{"{1: 1, 2: 2}[2]", 2},
 		{"{1: 1}[0]", Null},
 		{"{}[0]", Null},
+		{`"monkey"[0]`, "m"},
+		{`"héllo"[1]`, "é"},
+		{`let s = "日本語"; s[2]`, "語"},
+		{`let café = "☕"; café[0]`, "☕"},
+		{`"héllo"[5]`, Null},
+		{`"héllo"[-1]`, "o"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestMacros(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			let unless = macro(cond, a, b) { quote(if (!(unquote(cond))) { unquote(a) } else { unquote(b) }) };
+			unless(1 > 2, 10, 20)
+			`,
+			10,
+		},
+		{
+			`
+			let withTmp = macro(x) { quote((fn() { let tmp = 100; unquote(x) })()) };
+			let f = fn(tmp) { withTmp(tmp + 1) };
+			f(1)
+			`,
+			2,
+		},
+		{
+			`
+			let double = macro(x) { quote(unquote(x) * 2) };
+			let quadruple = macro(x) { quote(double(double(unquote(x)))) };
+			quadruple(3)
+			`,
+			12,
+		},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+		macroEnv := object.NewEnvironment()
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := evaluator.ExpandMacros(program, macroEnv)
+		if err != nil {
+			t.Fatalf("macro expansion error: %s", err)
+		}
+
+		comp := compiler.New()
+		err = comp.Compile(expanded)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		testExpectedObject(t, tt.expected, vm.LastPoppedStackElem())
+	}
+}
+
+var moduleFiles = map[string]string{
+	"lib/strings.mk": `
+		let join = fn(items, sep) {
+			let out = "";
+			for (let i = 0; i < len(items); i += 1) {
+				if (i > 0) { out += sep; }
+				out += items[i];
+			}
+			out
+		};
+		let hidden = 1;
+		export join;
+	`,
+	"lib/counter.mk": `
+		let count = [0];
+		let next = fn() { count[0] += 1; count[0] };
+		export next;
+	`,
+	"lib/math.mk": `
+		import "strings.mk" as strings;
+		let square = fn(x) { x * x };
+		let describe = fn(name) { strings["join"]([name, "squared"], " ") };
+		export square, describe;
+	`,
+	"lib/early.mk": `
+		let a = 1;
+		if (true) { return 0; }
+		a = 2;
+		export a;
+	`,
+	"lib/macros.mk": `
+		let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) };
+		let sign = fn(x) { unless(x < 0, "pos", "neg") };
+		export sign;
+	`,
+	"lib/fails.mk":     `throw error("module failed", 7);`,
+	"lib/undefined.mk": `let a = 1; export a, b;`,
+	"lib/a.mk":         `import "b.mk" as b; export b;`,
+	"lib/b.mk":         `import "a.mk" as a; export a;`,
+	"lib/broken.mk":    `let = 1;`,
+	"shared/greet.mk":  `let greet = fn(name) { "hello " + name }; export greet;`,
+}
+
+func writeModuleFiles(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range moduleFiles {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func runWithModules(dir, input string) (object.Object, error) {
+	loader := module.NewLoader(filepath.Join(dir, "shared"))
+	loader.Expand = evaluator.ExpandModuleMacros
+	comp := compiler.New()
+	comp.SetLoader(loader, filepath.Join(dir, "main.mk"))
+	err := comp.Compile(parse(input))
+	if err != nil {
+		return nil, err
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if err != nil {
+		return nil, err
+	}
+	return vm.LastPoppedStackElem(), nil
+}
+
+func TestImports(t *testing.T) {
+	dir := writeModuleFiles(t)
+
+	tests := []vmTestCase{
+		{`import "lib/strings.mk" as s; s["join"](["a", "b", "c"], ", ")`, "a, b, c"},
+		{`import "lib/math.mk" as m; m["square"](4)`, 16},
+		{`import "lib/math.mk" as m; m["describe"]("x")`, "x squared"},
+		{`import "lib/counter.mk" as a; import "lib/counter.mk" as b; a["next"](); b["next"]()`, 2},
+		{`import "lib/math.mk" as m; import "lib/strings.mk" as s; s["join"](["x", "y"], "")`, "xy"},
+		{`import "lib/early.mk" as e; e["a"]`, 1},
+		{`import "greet.mk" as g; g["greet"]("monkey")`, "hello monkey"},
+		{`import "lib/math.mk" as m; m.square(4)`, 16},
+		{`import "lib/math.mk" as m; m.describe("x")`, "x squared"},
+		{`import "lib/macros.mk" as m; m.sign(-2) + m.sign(3)`, "negpos"},
+		{`import "lib/counter.mk" as c; let f = fn() { c["next"]() }; f(); f()`, 2},
+		{`import "lib/strings.mk" as s; try { s["hidden"] } catch (e) { e["message"] }`,
+			"module " + filepath.Join(dir, "lib/strings.mk") + " does not export hidden"},
+	}
+
+	for _, tt := range tests {
+		result, err := runWithModules(dir, tt.input)
+		if err != nil {
+			t.Fatalf("error for %q: %s", tt.input, err)
+		}
+
+		testExpectedObject(t, tt.expected, result)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := writeModuleFiles(t)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/fails.mk" as f; 1`, "module failed"},
+		{`import "lib/strings.mk" as s; s[1]`, "module member must be STRING, got INTEGER"},
+		{`import "lib/missing.mk" as m;`, `1:1: cannot find module "lib/missing.mk"`},
+		{`import "lib/undefined.mk" as u;`, filepath.Join(dir, "lib/undefined.mk") + ":1:22: cannot export undefined name b"},
+		{`import "lib/a.mk" as a;`, filepath.Join(dir, "lib/b.mk") + ":1:1: import cycle: " +
+			filepath.Join(dir, "lib/a.mk") + " -> " + filepath.Join(dir, "lib/b.mk") + " -> " + filepath.Join(dir, "lib/a.mk")},
+		{`import "lib/broken.mk" as b;`, filepath.Join(dir, "lib/broken.mk") + ":1:5: expected next token to be IDENT, but got = instead"},
+	}
+
+	for _, tt := range tests {
+		_, err := runWithModules(dir, tt.input)
+		if err == nil {
+			t.Fatalf("expected error for %q but resulted in none.", tt.input)
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestImportCycleBackToEntry(t *testing.T) {
+	dir := writeModuleFiles(t)
+	a, b := filepath.Join(dir, "lib/a.mk"), filepath.Join(dir, "lib/b.mk")
+
+	comp := compiler.New()
+	comp.SetLoader(module.NewLoader(), a)
+	err := comp.Compile(parse(moduleFiles["lib/a.mk"]))
+	if err == nil {
+		t.Fatalf("expected an import cycle error but resulted in none.")
+	}
+
+	expected := b + ":1:1: import cycle: " + a + " -> " + b + " -> " + a
+	if err.Error() != expected {
+		t.Errorf("wrong error: want=%q, got=%q", expected, err)
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:]", []int{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][:-1]", []int{1, 2, 3}},
+		{"[1, 2, 3, 4][-2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][3:1]", []int{}},
+		{"[1, 2, 3, 4][-10:10]", []int{1, 2, 3, 4}},
+		{"let a = [1, 2, 3]; let n = 1; a[n:n + 1]", []int{2}},
+		{`"héllo wörld"[2:5]`, "llo"},
+		{`"héllo"[1:]`, "éllo"},
+		{`"héllo"[:-3]`, "hé"},
+		{`"héllo"[4:2]`, ""},
 	}
 
 	runVmTests(t, tests)
 }
 
+func TestSliceErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2][true:]", "slice bounds must be integers, got BOOLEAN"},
+		{"{1: 2}[0:1]", "slice operator not supported: HASH"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
 func TestCallingFunctionsWithoutArguments(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -391,6 +832,11 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 			input:    `fn(a, b) { a + b; }(1);`,
 			expected: `wrong number of arguments: want=2, got=1`,
 		},
+		{input: "fn(a, b = 1) { a }()", expected: "wrong number of arguments: want between 1 and 2, got=0"},
+		{input: "fn(a, b = 1) { a }(1, 2, 3)", expected: "wrong number of arguments: want between 1 and 2, got=3"},
+		{input: "fn(a, ...r) { a }()", expected: "wrong number of arguments: want at least 1, got=0"},
+		{input: "let f = fn(a) { a }; f(...5)", expected: "cannot spread INTEGER"},
+		{input: "[...1]", expected: "cannot spread INTEGER"},
 	}
 
 	for _, tt := range tests {
@@ -414,6 +860,548 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 	}
 }
 
+func TestDivisionByZero(t *testing.T) {
+	tests := []string{"1 / 0", "1 % 0", "let f = fn(a) { 10 / a }; f(0)"}
+
+	for _, input := range tests {
+		program := parse(input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != "division by zero" {
+			t.Fatalf("wrong VM error: want=%q, got=%q", "division by zero", err)
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; let s = 0; while (i < 5) { s += i; i += 1; } s", 10},
+		{"let s = 0; for (let i = 0; i < 10; i += 1) { if (i % 2 == 0) { continue; } if (i > 7) { break; } s += i; } s", 16},
+		{"let s = 0; for (x in [1, 2, 3]) { s += x; } s", 6},
+		{"let s = 0; for (k in {3: 1, 1: 2, 2: 3}) { s = s * 10 + k; } s", 123},
+		{`let n = 0; for (c in "héllo") { n += 1; } n`, 5},
+		{"let s = 0; for (let i = 0; i < 3; i += 1) { for (let j = 0; j < 3; j += 1) { if (j == 1) { break; } s += 1; } } s", 3},
+		{"let f = fn() { let i = 0; while (true) { i += 1; if (i == 5) { break; } } i }; f()", 5},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10; } } 0 }; f()", 20},
+		{"let f = fn() { let s = 0; for (let i = 0; i < 10000; i += 1) { s += 1; } s }; f()", 10000},
+		{`let out = ""; for (c in "héllo") { out = c + out; } out`, "olléh"},
+		{"let f = fn() { let fs = []; for (x in [1, 2, 3]) { fs = push(fs, fn() { x }); } fs[0]() + fs[2]() }; f()", 6},
+		{"let r = if (true) { let y = 1 }; r", Null},
+		{"let i = 0; while (i < 3) { i += 1 }; i", 3},
+		{"let s = 0; for (let i = 0; i < 3; i += 1) { s += i }; s", 3},
+		{"let s = 0; for (x in [1, 2]) { s += x }; s", 3},
+		{"let i = 0; while (i < 3) { i += 1 }", Null},
+		{"for (let i = 0; i < 3; i += 1) { if (i == 1) { break; } }", Null},
+		{"for (x in [1, 2]) { x }", Null},
+		{"let f = fn() { for (x in [1, 2]) { x } }; f()", Null},
+		{"let r = if (true) { while (false) { } }; r", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []vmTestCase{
+		{"try { throw 1; } catch (e) { e + 1 }", 2},
+		{"try { 5 } catch (e) { 0 }", 5},
+		{"try { throw 1; } catch { 2 }", 2},
+		{"try { let y = 1; } catch (e) { 0 }", Null},
+		{"let r = 1 + try { throw 1; } catch (e) { e + 1 }; r", 3},
+		{`try { [1][true] } catch (e) { e["message"] }`, "index operator not supported: ARRAY"},
+		{`try { 1 + true } catch (e) { e["message"] }`, "unsupported types for binary operation: INTEGER BOOLEAN"},
+		{`let f = fn(a) { a }; try { f() } catch (e) { e["message"] }`, "wrong number of arguments: want=1, got=0"},
+		{`try { throw error("boom", 42); } catch (e) { e["data"] }`, 42},
+		{`try { throw error("boom"); } catch (e) { e["message"] }`, "boom"},
+		{`try { throw error("boom"); } catch (e) { e["data"] }`, Null},
+		{"let x = 0; try { x = 1 } finally { x = x + 10 }; x", 11},
+		{"let x = 0; try { throw 1; } catch (e) { x = e } finally { x += 10 }; x", 11},
+		{"let log = 0; let f = fn() { try { return 1; } finally { log = 2; } }; f() + log", 3},
+		{`let x = 0; let f = fn() { try { throw "a"; } finally { x = 5; } }; try { f() } catch (e) { x }`, 5},
+		{"try { try { throw 1; } catch (e) { throw e + 1; } } catch (e) { e * 10 }", 20},
+		{"try { try { throw 1; } finally { throw 2; } } catch (e) { e }", 2},
+		{`let f = fn(n) { if (n == 0) { throw "done"; } f(n - 1) }; try { f(5) } catch (e) { e }`, "done"},
+		{"let f = fn() { [1][true] }; let r = try { f() } catch (e) { 1 }; r + 1", 2},
+		{"let s = 0; for (x in [1, 2, 3]) { try { if (x == 2) { continue; } s += x } finally { s += 10 } } s", 34},
+		{"let s = 0; while (true) { try { break; } finally { s = 7 } } s", 7},
+		{"let f = fn() { for (x in [1, 2]) { try { return x; } catch (e) { 0 } } }; f(); try { throw 3; } catch (e) { e }", 3},
+		{"try { len(1) } catch (e) { e.message }", "argument to `len` not supported, got INTEGER"},
+		{"let f = fn() { push(1, 2); 3 }; try { f() } catch (e) { e.message }", "argument to `push` must be ARRAY, got INTEGER"},
+		{"try { throw error(\"boom\", 42); } catch (e) { e.data }", 42},
+		{"first([error(\"boom\")]).message", "boom"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStructs(t *testing.T) {
+	tests := []vmTestCase{
+		{"struct Point { x, y } let p = Point(1, 2); p.x", 1},
+		{"struct Point { x, y } let p = Point(1, 2); p.y", 2},
+		{"struct Point { x, y } let p = Point(1, 2); p.x = 3; p.x", 3},
+		{"struct Point { x, y } let p = Point(1, 2); p.y = 5", 5},
+		{"struct Point { x, y } let p = Point(1, 2); p.y += 10; p.y", 12},
+		{"struct Box { value } let a = Box(1); let b = a; b.value = 7; a.value", 7},
+		{"struct Line { from, to } struct Point { x, y } let l = Line(Point(0, 0), Point(3, 4)); l.to.y", 4},
+		{"struct Point { x, y } impl Point { fn dist(self) { self.x * self.x + self.y * self.y } } Point(3, 4).dist()", 25},
+		{"struct Point { x, y } impl Point { fn add(self, other) { Point(self.x + other.x, self.y + other.y) } } Point(1, 2).add(Point(3, 4)).y", 6},
+		{"struct Counter { n } impl Counter { fn inc(self) { self.n += 1; self } } let c = Counter(0); c.inc().inc(); c.n", 2},
+		{"struct Point { x, y } impl Point { fn getX(self) { self.x } } let p = Point(9, 0); let f = p.getX; p.x = 1; f()", 1},
+		{"struct Point { x, y } impl Point { fn x(self) { 0 } } Point(5, 6).x", 5},
+		{"struct Point { x, y } let p = Point(1, 2); impl Point { fn sum(self) { self.x + self.y } } p.sum()", 3},
+		{"struct Node { value, next } let list = Node(1, Node(2, Node(3, false))); let s = 0; let n = list; while (n != false) { s += n.value; n = n.next; } s", 6},
+		{"struct P { x } let f = fn() { struct Q { y } Q(4) }; f().y", 4},
+		{"struct Empty { } impl Empty { fn name(self) { \"empty\" } } Empty().name()", "empty"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStructErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, y } Point(1)", "wrong number of arguments to Point: want=2, got=1"},
+		{"struct Point { x, y } Point(1, 2).z", "Point has no field or method z"},
+		{"struct Point { x, y } let p = Point(1, 2); p.z = 1", "Point has no field z"},
+		{"struct Point { x, y } Point(1, 2).dist()", "Point has no field or method dist"},
+		{"let a = 1; impl a { fn f(self) { 1 } }", "impl target must be STRUCT, got INTEGER"},
+		{"[1, 2].len", "member access not supported: ARRAY"},
+		{"let a = [1]; a.x = 1", "member assignment not supported: ARRAY"},
+		{"struct Point { x, y } impl Point { fn f(self, a) { a } } Point(1, 2).f()", "wrong number of arguments: want=2, got=1"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestEnums(t *testing.T) {
+	tests := []vmTestCase{
+		{"enum Result { Ok(value), Err(reason) } let r = Result.Ok(5); match (r) { Result.Ok(v) => v, Result.Err(e) => 0 }", 5},
+		{`enum Result { Ok(value), Err(reason) } let r = Result.Err("bad"); match (r) { Result.Ok(v) => v, Result.Err(e) => e }`, "bad"},
+		{"enum Result { Ok(value), Err(reason) } Result.Ok(7).value", 7},
+		{"enum Color { Red, Green, Blue } let c = Color.Green; match (c) { Color.Red => 1, Color.Green => 2, Color.Blue => 3 }", 2},
+		{"enum Color { Red, Green } if (Color.Red == Color.Red) { 1 } else { 0 }", 1},
+		{"enum Color { Red, Green } if (Color.Red == Color.Green) { 1 } else { 0 }", 0},
+		{"enum Color { Red, Green } match (5) { Color.Red => 1, 5 => 2 }", 2},
+		{"enum Color { Red, Green } match (\"c\") { Color.Red => 1 }", nil},
+		{"enum Shape { Rect(w, h), Circle(r) } let area = fn(s) { match (s) { Shape.Rect(w, h) => w * h, Shape.Circle(r) => 3 * r * r } }; area(Shape.Rect(2, 3)) + area(Shape.Circle(2))", 18},
+		{"enum Option { Some(value), None } let f = fn(o) { match (o) { Option.Some(1) => 10, Option.Some(v) => v, Option.None => 0 } }; f(Option.Some(1)) + f(Option.Some(5)) + f(Option.None)", 15},
+		{"enum Option { Some(value), None } match (Option.Some(3)) { Option.Some(v) if v > 5 => 1, Option.Some(v) => 2, Option.None => 3 }", 2},
+		{"enum Option { Some(value), None } match (Option.None) { Option.Some(v) => v, _ => 9 }", 9},
+		{"enum Option { Some(value), None } match (Option.Some(4)) { Option.None => 0, other => 8 }", 8},
+		{"enum Option { Some(value), None } match (Option.Some(Option.Some(6))) { Option.Some(Option.Some(v)) => v, Option.Some(_) => 1, Option.None => 0 }", 6},
+		{"enum Option { Some(value), None } match ([Option.Some(1), Option.None]) { [Option.Some(a), Option.None] => a, _ => 0 }", 1},
+		{"enum Option { Some(value), None } match (5) { Option.Some(v) => v, Option.None => 0 }", Null},
+		{"enum Pair { Of(a, b) } let [x, Pair.Of(y, z)] = [1, Pair.Of(2, 3)]; x + y + z", 6},
+		{"enum Tree { Leaf, Node(left, value, right) } let sum = fn(t) { match (t) { Tree.Leaf => 0, Tree.Node(l, v, r) => sum(l) + v + sum(r) } }; sum(Tree.Node(Tree.Node(Tree.Leaf, 1, Tree.Leaf), 2, Tree.Leaf))", 3},
+		{"enum Result { Ok(value), Err(reason) } let ok = Result.Ok; ok(11).value", 11},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestEnumErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"enum Result { Ok(value), Err(reason) } Result.Ok(1, 2)", "wrong number of arguments to Result.Ok: want=1, got=2"},
+		{"enum Result { Ok(value), Err(reason) } Result.Maybe", "Result has no variant Maybe"},
+		{"enum Result { Ok(value), Err(reason) } Result.Ok(1).reason", "Result.Ok has no field reason"},
+		{"enum Result { Ok(value), Err(reason) } match (Result.Ok(1)) { Result.Ok(v) => v }", "non-exhaustive match on Result: missing Err"},
+		{"enum Color { Red, Green, Blue } match (Color.Red) { Color.Red => 1, Color.Green if true => 2 }", "non-exhaustive match on Color: missing Green, Blue"},
+		{"enum Option { Some(value), None } match (Option.None) { Option.Some(1) => 1, Option.None => 0 }", "non-exhaustive match on Option: missing Some"},
+		{"enum Result { Ok(value), Err(reason) } match (1) { Result.Ok(v) => v, Result.Bad(e) => e }", "Result has no variant Bad"},
+		{"enum Result { Ok(value), Err(reason) } match (1) { Result.Ok(v, w) => v, _ => 0 }", "wrong number of fields in pattern Result.Ok: want=1, got=2"},
+		{"let Result = 1; match (1) { Result.Ok(v) => v, _ => 0 }", "variant pattern needs an ENUM, got INTEGER"},
+		{"enum Pair { Of(a, b) } let [Pair.Of(x, y)] = [1]; x", "1 does not match Pair.Of"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error for %q but resulted in none.", tt.input)
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []vmTestCase{
+		{"let count = fn*(n) { let i = 0; while (i < n) { yield i; i += 1; } }; let g = count(3); [next(g), next(g), next(g)]", []int{0, 1, 2}},
+		{"let count = fn*(n) { let i = 0; while (i < n) { yield i; i += 1; } }; let g = count(1); next(g); next(g)", Null},
+		{`let g = fn*() { yield 1; }(); next(g); next(g, "done")`, "done"},
+		{"let naturals = fn*() { let i = 0; while (true) { yield i; i += 1; } }; let sum = 0; for (n in naturals()) { if (n > 4) { break; } sum += n; }; sum", 10},
+		{"let g = fn*() { yield 1; yield 2; yield 3; }; len(g())", 3},
+		{"let g = fn*(a, b = a * 2) { yield a; yield b; }; array(g(3))", []int{3, 6}},
+		{"let g = fn*(x) { if (x) { yield 1; } else { yield 2; } yield 3; }; array(g(false))", []int{2, 3}},
+		{"let g = fn*() { yield 1; return 5; yield 2; }; array(g())", []int{1}},
+		{`let g = fn*() { try { yield 1; throw 2; } catch (e) { yield e; } finally { yield 3; } }; array(g())`, []int{1, 2, 3}},
+		{"let inner = fn*() { yield 1; yield 2; }; let outer = fn*() { for (x in inner()) { yield x * 10; } }; array(outer())", []int{10, 20}},
+		{"let side = [0]; let g = fn*() { side[0] += 1; yield side[0]; }; let it = g(); let before = side[0]; next(it); [before, side[0]]", []int{0, 1}},
+		{"let g = fn*() { yield 1; }; let a = g(); let b = g(); [next(a), next(b)]", []int{1, 1}},
+		{"let base = 10; let g = fn*(n) { let f = fn(x) { x + base + n }; yield f(1); }; next(g(5))", 16},
+		{"struct Range { lo, hi } impl Range { fn* items(self) { let i = self.lo; while (i < self.hi) { yield i; i += 1; } } } array(Range(2, 5).items())", []int{2, 3, 4}},
+		{`let g = fn*() { yield 1; throw "boom"; }; let it = g(); next(it); try { next(it) } catch (e) { e }`, "boom"},
+		{`let g = fn*() { yield 1; throw "boom"; }; let it = g(); next(it); try { next(it) } catch (e) { next(it, 0) }`, 0},
+		{"try { next([1]) } catch (e) { e }", &object.Error{Message: "argument to `next` must be GENERATOR, got ARRAY"}},
+		{"try { array(1) } catch (e) { e }", &object.Error{Message: "argument to `array` not supported, got INTEGER"}},
+		{"let g = fn*() { let depth = fn(n) { if (n == 0) { 0 } else { 1 + depth(n - 1) } }; yield depth(500); }; next(g())", 500},
+		{"let g = fn*(xs) { yield len(xs); }; next(g([1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20]))", 20},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let g = fn*(a) { yield a; }; g()", "wrong number of arguments: want=1, got=0"},
+		{`let g = fn*() { yield 1; throw "boom"; }; let it = g(); next(it); next(it)`, "uncaught exception: boom"},
+		{`let g = fn*() { throw error("bad"); }; len(g())`, "bad"},
+		{`let g = fn*() { throw error("bad"); }; for (x in g()) { x }`, "bad"},
+		{"let it = [0]; let g = fn*() { yield next(it[0]); }; it[0] = g(); next(it[0])", "generator is already running"},
+		{"let g = fn*() { let f = fn() { f() + 1 }; yield f(); }; next(g())", "stack overflow"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error for %q but resulted in none.", tt.input)
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestSpawnAndChannels(t *testing.T) {
+	tests := []vmTestCase{
+		{"let ch = chan(); spawn(fn() { send(ch, 1); send(ch, 2); close(ch); }); array(ch)", []int{1, 2}},
+		{"let task = spawn(fn(a, b) { a + b }, 1, 2); recv(task)", 3},
+		{`let task = spawn(fn(a, b) { a + b }, 1, 2); recv(task); recv(task, "closed")`, "closed"},
+		{"let task = spawn(len, [1, 2]); recv(task)", 2},
+		{"struct Point { x, y } recv(spawn(Point, 3, 4)).y", 4},
+		{`let task = spawn(fn() { throw "boom"; }); try { recv(task) } catch (e) { e }`, "boom"},
+		{`let task = spawn(fn(a) { a }); try { recv(task) } catch (e) { e["message"] }`, "wrong number of arguments: want=1, got=0"},
+		{"let done = chan(); let work = fn(n) { send(done, n * n) }; for (let i = 1; i <= 4; i += 1) { spawn(work, i) } let sum = 0; for (let i = 0; i < 4; i += 1) { sum += recv(done) } sum", 30},
+		{"let counter = fn() { let count = 0; let done = chan(); spawn(fn() { count = 5; send(done, true) }); recv(done); count }; counter()", 5},
+		{"let value = 0; let done = chan(); spawn(fn() { value = 7; send(done, true) }); recv(done); value", 7},
+		{"let results = chan(3); let worker = fn(jobs) { for (j in jobs) { send(results, j * 10) } }; let jobs = chan(3); spawn(worker, jobs); send(jobs, 1); send(jobs, 2); close(jobs); [recv(results), recv(results)]", []int{10, 20}},
+		{"let a = chan(1); let b = chan(1); send(b, 9); select([a, b])", []int{1, 9}},
+		{`let a = chan(); select([a], "nothing")`, "nothing"},
+		{"let a = chan(1); select([[a, 5]]); recv(a)", 5},
+		{"let a = chan(); close(a); select([a])[1]", Null},
+		{`let task = spawn(fn() { throw "boom"; }); try { select([task]) } catch (e) { e }`, "boom"},
+		{"let g = fn*() { yield 1; yield 2; }; let it = g(); let task = spawn(fn() { next(it) }); [recv(task), next(it)]", []int{1, 2}},
+		{"let h = {}; let done = chan(); let work = fn(k) { for (let i = 0; i < 100; i += 1) { h[k * 100 + i] = 1; h[i] } send(done, true) }; for (let k = 0; k < 4; k += 1) { spawn(work, k) } for (let k = 0; k < 4; k += 1) { recv(done) } let sum = 0; for (let k = 0; k < 400; k += 1) { sum += h[k] } sum", 400},
+		{"struct Cursor { at } let c = Cursor(0); let done = chan(); let work = fn(k) { for (let i = 0; i < 100; i += 1) { c.at = k; c.at } send(done, true) }; for (let k = 1; k <= 4; k += 1) { spawn(work, k) } for (let k = 0; k < 4; k += 1) { recv(done) } c.at > 0", true},
+		{"let xs = []; let done = chan(); let work = fn(k) { for (let i = 0; i < 100; i += 1) { append!(xs, k); xs[0] = k; xs[-1]; len(xs) } send(done, true) }; for (let k = 0; k < 4; k += 1) { spawn(work, k) } for (let k = 0; k < 4; k += 1) { recv(done) } len(xs)", 400},
+		{"let ch = chan(); spawn(fn() { 1 }); try { recv(ch) } catch (e) { e }", &object.Error{Message: "deadlock: waiting on a channel with no live task left"}},
+		{"try { send(chan(), 1) } catch (e) { e }", &object.Error{Message: "deadlock: waiting on a channel with no live task left"}},
+		{"try { select([chan()]) } catch (e) { e }", &object.Error{Message: "deadlock: waiting on a channel with no live task left"}},
+		{"try { let a = chan(); close(a); close(a) } catch (e) { e }", &object.Error{Message: "close of closed channel"}},
+		{"try { let a = chan(); close(a); send(a, 1) } catch (e) { e }", &object.Error{Message: "send on closed channel"}},
+		{"try { select([1]) } catch (e) { e }", &object.Error{Message: "case of `select` must be CHANNEL or [CHANNEL, value], got 1"}},
+		{"try { spawn(1) } catch (e) { e }", &object.Error{Message: "argument to `spawn` must be a function, got INTEGER"}},
+		{"try { chan(-1) } catch (e) { e }", &object.Error{Message: "capacity of `chan` must not be negative, got -1"}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSpawnedTaskErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`recv(spawn(fn() { throw "boom"; }))`, "uncaught exception: boom"},
+		{"recv(spawn(fn() { 1 + true }))", "unsupported types for binary operation: INTEGER BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error for %q but resulted in none.", tt.input)
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []vmTestCase{
+		{"let loop = fn(n) { if (n == 0) { return 0; } loop(n - 1) }; loop(1000000)", 0},
+		{"let loop = fn(n) { n == 0 || loop(n - 1) }; loop(1000000)", true},
+		{"let loop = fn(n) { n > 0 && loop(n - 1) }; loop(1000000)", false},
+		{"let loop = fn(n) { match (n) { 0 => { 0 }, _ => { loop(n - 1) } } }; loop(1000000)", 0},
+		{"let loop = fn(n) { match (n) { 0 => { 0 }, m if m > 0 => { if (m > 1) { loop(m - 2) } else { loop(m - 1) } } } }; loop(1000000)", 0},
+		{"let loop = fn(n) { return n == 0 || loop(n - 1); }; loop(1000000)", true},
+		{"let count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } }; count(100000, 0)", 100000},
+		{"let count = fn(n, acc) { if (n > 0) { count(n - 1, acc + 1) } else { acc } }; count(100000, 0)", 100000},
+		{"let count = fn(n, acc) { if (n == 0) { return acc; } return count(n - 1, acc + 1); }; count(100000, 0)", 100000},
+		{"let isOdd = 0; let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } }; isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } }; isEven(100001)", false},
+		{"let count = fn(n, acc = 0) { if (n == 0) { return acc; } count(n - 1, acc + 2) }; count(100000)", 200000},
+		{"let sum = fn(n, ...rest) { if (n == 0) { return len(rest); } sum(n - 1, n, n) }; sum(100000)", 2},
+		{"let f = fn(n, ...rest) { if (n == 0) { return len(rest); } f(n - 1, ...rest) }; f(100000, 1, 2, 3)", 3},
+		{"let f = fn(n, acc) { if (n == 0) { return acc; } let args = [n - 1, acc + 1]; f(...args) }; f(100000, 0)", 100000},
+		{"struct Counter { step } impl Counter { fn run(self, n, acc) { if (n == 0) { return acc; } self.run(n - 1, acc + self.step) } } Counter(3).run(100000, 0)", 300000},
+		{"let make = fn() { let total = 0; let add = fn(n) { if (n == 0) { return total; } total += n; add(n - 1) }; add }; make()(1000)", 500500},
+		{"let f = fn(n) { len([n]) }; f(1)", 1},
+		{`let fail = fn() { throw "boom"; }; let g = fn() { try { return fail(); } catch (e) { "caught " + e } }; g()`, "caught boom"},
+		{`let log = []; let f = fn() { append!(log, "f"); 1 }; let g = fn() { try { return f(); } finally { append!(log, "finally"); } }; g(); log[0] + " " + log[1]`, "f finally"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestAddedOperators(t *testing.T) {
+	operators := hosttest.Operators(t)
+
+	tests := []vmTestCase{
+		{"2 ** 3 ** 2", 512},
+		{"2 * 3 ** 2", 18},
+		{"~2 ** 2", 4},
+		{"~(2 ** 2)", -4},
+		{"len(1 .. 2 + 3)", 5},
+		{"5 |> fn(x) { x * 2 } |> fn(x) { x + 1 }", 11},
+		{"1 .. 4 |> len", 4},
+		{"let f = fn(n) { n .. n + 1 }; f(3)", []int{3, 4}},
+		{`try { "a" ** 2 } catch (e) { e }`, &object.Error{Message: "arguments to `pow` must be INTEGER"}},
+		{"let pow = fn(a, b) { 0 }; 2 ** 3", 8},
+		{"let f = fn(pow) { 2 ** 3 }; f(1)", 8},
+	}
+
+	for _, tt := range tests {
+		p := parser.NewWithOperators(lexer.New(hosttest.Prelude+tt.input), operators)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		testExpectedObject(t, tt.expected, vm.LastPoppedStackElem())
+	}
+}
+
+func TestMatchScopeAgreesWithEvaluator(t *testing.T) {
+	tests := []string{
+		"let x = 100; match (5) { x => x }; x",
+		"let x = 100; match ([1, 2]) { [x, y] if x > y => 0, [y, x] => x + y }; x",
+		"let x = 100; match (5) { n => { let x = n; x } }; x",
+		"let f = fn() { let x = 100; let y = match (5) { x => x * 2 }; x + y }; f()",
+		"match (5) { x => x }; match (6) { y => x }",
+	}
+
+	runAgreementTests(t, tests)
+}
+
+func TestRedefinitionAgreesWithEvaluator(t *testing.T) {
+	tests := []string{
+		"fn() { let x = 1; let g = fn() { x }; let x = 2; g() }()",
+		"fn() { let x = 1; let g = fn() { x }; let x = x + 1; [g(), x] }()",
+		"let x = 1; let g = fn() { x }; let x = 2; g()",
+		"fn(x) { let g = fn() { x }; let x = 3; g() }(1)",
+		"fn() { let x = 1; let g = fn() { x }; if (true) { let x = 2; } g() }()",
+		"let make = fn(v) { let n = 0; let get = fn() { n }; let n = v; get }; let a = make(1); let b = make(2); [a(), b()]",
+	}
+
+	runAgreementTests(t, tests)
+}
+
+func TestLoopCapturesAgreeWithEvaluator(t *testing.T) {
+	tests := []string{
+		"fn() { let fs = []; let i = 0; while (i < 3) { let j = i; append!(fs, fn() { j }); i += 1; } [fs[0](), fs[1](), fs[2]()] }()",
+		"fn() { let fs = []; for (x in [1, 2, 3]) { append!(fs, fn() { x }); } [fs[0](), fs[1](), fs[2]()] }()",
+		"fn() { let fs = []; for (x in [0, 1]) { try { throw x; } catch (e) { append!(fs, fn() { e }); } } [fs[0](), fs[1]()] }()",
+		"let fs = []; for (x in [1, 2, 3]) { append!(fs, fn() { x }); } [fs[0](), fs[1](), fs[2]()]",
+		"let fs = []; let i = 0; while (i < 3) { let j = i; append!(fs, fn() { j }); i += 1; } [fs[0](), fs[1](), fs[2]()]",
+		"fn() { let fs = []; for (j in [1, 2, 3]) { append!(fs, fn() { j }); j += 10; } [fs[0](), fs[1](), fs[2]()] }()",
+		"let fs = []; for (j in [1, 2, 3]) { append!(fs, fn() { j }); j += 10; } [fs[0](), fs[1](), fs[2]()]",
+		"fn() { let fs = []; for (let i = 0; i < 3; i += 1) { append!(fs, fn() { i }); } [fs[0](), fs[2]()] }()",
+	}
+
+	runAgreementTests(t, tests)
+}
+
+func TestLoopScopesAgreeWithEvaluator(t *testing.T) {
+	tests := []string{
+		"let i = 100; for (let i = 0; i < 3; i += 1) {} i",
+		"fn() { let i = 100; for (let i = 0; i < 3; i += 1) {} i }()",
+		"let x = 100; for (x in [1, 2]) { let y = x; } x",
+		"fn() { let x = 100; for (x in [1, 2]) { let y = x; } x }()",
+		"for (let i = 0; i < 3; i += 1) {} i",
+		"for (x in [1]) { let y = x; } y",
+		"let n = 0; for (let i = 0; i < 3; i += 1) { n += i; } n",
+		"let fs = []; for (a in [1, 2]) { for (b in [a]) { append!(fs, fn() { b }); } } [fs[0](), fs[1]()]",
+		"fn() { let fs = []; for (a in [1, 2]) { for (b in [a]) { append!(fs, fn() { b }); } } [fs[0](), fs[1]()] }()",
+		"let fs = []; let i = 0; while (i < 2) { match (i) { n => append!(fs, fn() { n }) }; i += 1; } [fs[0](), fs[1]()]",
+		"fn() { let fs = []; let i = 0; while (i < 2) { match (i) { n => append!(fs, fn() { n }) }; i += 1; } [fs[0](), fs[1]()] }()",
+	}
+
+	runAgreementTests(t, tests)
+}
+
+// runAgreementTests runs each input through the evaluator and the VM and fails the test
+// when the two give different results. An input the evaluator fails on may fail on the VM
+// with an error of its own.
+func runAgreementTests(t *testing.T, tests []string) {
+	t.Helper()
+
+	for _, input := range tests {
+		expected := evaluator.Eval(parse(input), object.NewEnvironment())
+
+		comp := compiler.New()
+		err := comp.Compile(parse(input))
+		var actual object.Object
+		if err == nil {
+			vm := New(comp.Bytecode())
+			if err = vm.Run(); err == nil {
+				actual = vm.LastPoppedStackElem()
+			}
+		}
+
+		if err != nil {
+			if _, ok := expected.(*object.Error); !ok {
+				t.Errorf("unexpected error for %q: %s (evaluator gave %s)", input, err, expected.Inspect())
+			}
+			continue
+		}
+		if actual.Inspect() != expected.Inspect() {
+			t.Errorf("engines disagree for %q. evaluator=%s, vm=%s", input, expected.Inspect(), actual.Inspect())
+		}
+	}
+}
+
+func TestUncaughtExceptions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"throw 1;", "uncaught exception: 1"},
+		{`throw error("boom");`, "boom"},
+		{"try { throw 1; } finally { 2 }", "uncaught exception: 1"},
+		{"try { throw 1; } catch (e) { throw e + 1; }", "uncaught exception: 2"},
+		{"let f = fn() { try { 1 } catch (e) { 2 } }; f(); 1 + true", "unsupported types for binary operation: INTEGER BOOLEAN"},
+		{"len(1); 5", "argument to `len` not supported, got INTEGER"},
+		{"let f = fn() { first(1); 2 }; f() + 1", "argument to `first` must be ARRAY, got INTEGER"},
+		{"let f = fn() { f() + 1 }; f()", "stack overflow"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestIterateOverNonIterable(t *testing.T) {
+	program := parse("for (x in 5) { x }")
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if err == nil {
+		t.Fatalf("expected VM error but resulted in none.")
+	}
+
+	if err.Error() != "cannot iterate over INTEGER" {
+		t.Fatalf("wrong VM error: want=%q, got=%q", "cannot iterate over INTEGER", err)
+	}
+}
+
 type vmTestCase struct {
 	input    string
 	expected any
@@ -463,6 +1451,12 @@ func testExpectedObject(
 			t.Errorf("testIntegerObject failed: %s", err)
 		}
 
+	case float64:
+		err := testFloatObject(expected, actual)
+		if err != nil {
+			t.Errorf("testFloatObject failed: %s", err)
+		}
+
 	case bool:
 		err := testBooleanObject(bool(expected), actual)
 		if err != nil {
@@ -553,6 +1547,21 @@ func testIntegerObject(expected int64, actual object.Object) error {
 	return nil
 }
 
+func testFloatObject(expected float64, actual object.Object) error {
+	result, ok := actual.(*object.Float)
+	if !ok {
+		return fmt.Errorf("object is not Float. got=%T (%+v)",
+			actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+	}
+
+	return nil
+}
+
 func testBooleanObject(expected bool, actual object.Object) error {
 	result, ok := actual.(*object.Boolean)
 	if !ok {
@@ -588,13 +1597,15 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
+		{`len("日本語")`, 3},
 		{
-			`len(1)`,
+			`try { len(1) } catch (e) { e }`,
 			&object.Error{
 				Message: "argument to `len` not supported, got INTEGER",
 			},
 		},
-		{`len("one", "two")`,
+		{`try { len("one", "two") } catch (e) { e }`,
 			&object.Error{
 				Message: "wrong number of arguments. got=2, want=1",
 			},
@@ -604,14 +1615,14 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`puts("hello", "world!")`, Null},
 		{`first([1, 2, 3])`, 1},
 		{`first([])`, Null},
-		{`first(1)`,
+		{`try { first(1) } catch (e) { e }`,
 			&object.Error{
 				Message: "argument to `first` must be ARRAY, got INTEGER",
 			},
 		},
 		{`last([1, 2, 3])`, 3},
 		{`last([])`, Null},
-		{`last(1)`,
+		{`try { last(1) } catch (e) { e }`,
 			&object.Error{
 				Message: "argument to `last` must be ARRAY, got INTEGER",
 			},
@@ -619,11 +1630,27 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`rest([1, 2, 3])`, []int{2, 3}},
 		{`rest([])`, Null},
 		{`push([], 1)`, []int{1}},
-		{`push(1, 1)`,
+		{`try { push(1, 1) } catch (e) { e }`,
 			&object.Error{
 				Message: "argument to `push` must be ARRAY, got INTEGER",
 			},
 		},
+		{`let a = [1]; push(a, 2); a`, []int{1}},
+		{`let a = [1]; append!(a, 2, 3); a`, []int{1, 2, 3}},
+		{`try { append!({}, 1) } catch (e) { e }`,
+			&object.Error{
+				Message: "argument to `append!` must be ARRAY, got HASH",
+			},
+		},
+		{`let h = {"a": 1}; delete!(h, "a")`, 1},
+		{`let h = {"a": 1}; delete!(h, "a"); h["a"]`, Null},
+		{`delete!({}, "a")`, Null},
+		{`error("boom")`, &object.Error{Message: "boom"}},
+		{`try { error(1) } catch (e) { e }`,
+			&object.Error{
+				Message: "argument to `error` must be STRING, got INTEGER",
+			},
+		},
 	}
 
 	runVmTests(t, tests)
@@ -708,6 +1735,112 @@ func TestClosures(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestIndexAssignExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let a = [1, 2, 3]; a[0] = 9; a[0] + a[2]", 12},
+		{"let a = [1, 2, 3]; a[-1] = 9; a[2]", 9},
+		{"let a = [1, 2, 3]; a[1] += 10; a[1]", 12},
+		{"let a = [1, 2, 3]; let b = a; b[0] = 5; a[0]", 5},
+		{"let a = [1, 2, 3]; let b = a[:]; a[0] = 9; b[0]", 1},
+		{`let h = {}; h["k"] = 1; h["k"] += 2; h["k"]`, 3},
+		{"let a = [[1], [2]]; a[1][0] = 7; a[1][0]", 7},
+		{"let a = [0]; let i = 0; let next = fn() { i += 1; 0 }; a[next()] += 5; i * 10 + a[0]", 15},
+		{"let f = fn(a) { a[0] = 4 }; let a = [0]; f(a) + a[0]", 8},
+		{"let a = []; for (let i = 0; i < 10000; i += 1) { append!(a, i) }; len(a)", 10000},
+		{"let f = fn() { let a = [1, 2]; a[0] *= 3; a }; f()", []int{3, 2}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestIndexAssignErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [1]; a[1] = 2", "index out of range: 1"},
+		{`let a = [1]; a["x"] = 2`, "array index must be INTEGER, got STRING"},
+		{`let s = "a"; s[0] = "b"`, "index assignment not supported: STRING"},
+		{"let h = {}; h[[]] = 1", "unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; let y = x = 5; x + y", 10},
+		{"let a = 1; let b = 2; a = b = 3; a + b", 6},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x %= 4", 2},
+		{"let s = 0; let add = fn(n) { s += n }; add(1); add(2); s", 3},
+		{"let f = fn() { let x = 1; x = x + 1; x }; f()", 2},
+		{"let f = fn(n) { n += 10; n }; f(1)", 11},
+		{`
+		let counter = fn() {
+			let count = 0;
+			fn() { count += 1 }
+		};
+		let a = counter();
+		let b = counter();
+		a(); a(); b();
+		a() * 10 + b()
+		`, 32},
+		{`
+		let f = fn() {
+			let x = 1;
+			let get = fn() { x };
+			x = 5;
+			get()
+		};
+		f()
+		`, 5},
+		{`
+		let outer = fn() {
+			let x = 0;
+			let middle = fn() {
+				let inner = fn() { x += 1 };
+				inner();
+				inner();
+			};
+			middle();
+			x
+		};
+		outer()
+		`, 2},
+		{`
+		let f = fn() {
+			let x = 1;
+			let g = fn() { x = 7 };
+			g();
+			x
+		};
+		let h = fn() { let y = 3; y };
+		f() + h()
+		`, 10},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestRecursiveFunctions(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -777,3 +1910,37 @@ func TestRecursiveFunctions(t *testing.T) {
 
 	runVmTests(t, tests)
 }
+
+func TestGlobalsStore(t *testing.T) {
+	symbolTable := compiler.NewSymbolTable()
+	constants := []object.Object{}
+	store := make([]object.Object, GlobalsSize)
+	globals := NewGlobals()
+
+	var results []object.Object
+	for _, line := range []string{"let a = 1;", "let b = a + 1;", "let f = fn() { a + b }; f()"} {
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(parse(line)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		results = nil
+		for _, vm := range []*VM{NewWithGlobalsStore(bytecode, store), NewWithGlobals(bytecode, globals)} {
+			if err := vm.Run(); err != nil {
+				t.Fatalf("vm error: %s", err)
+			}
+			results = append(results, vm.LastPoppedStackElem())
+		}
+	}
+
+	for _, result := range results {
+		if err := testIntegerObject(3, result); err != nil {
+			t.Errorf("testIntegerObject failed: %s", err)
+		}
+	}
+	if err := testIntegerObject(2, store[1]); err != nil {
+		t.Errorf("global not kept in the store: %s", err)
+	}
+}